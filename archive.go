@@ -0,0 +1,103 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Archiver stores an immutable copy of every email sent, e.g. for compliance.
+// Archive is called after the server accepted the email, with the message
+// exactly as it was transmitted, DKIM signatures included.
+type Archiver interface {
+	Archive(ctx context.Context, message []byte, metadata ArchiveMetadata) error
+}
+
+// ErrArchiveFailed is returned when an email was sent but could not be archived.
+// The email must not be sent again; only the archived copy is missing.
+var ErrArchiveFailed = errors.New("email sent but archiving failed")
+
+// SetArchiver sets the archiver that receives a copy of every email sent
+func (m *Mail) SetArchiver(archiver Archiver) *Mail {
+	m.archiver = archiver
+	return m
+}
+
+// archive hands the captured message to archiver along with the metadata of the email
+func (m *Mail) archive(ctx context.Context, archiver Archiver, capture *bytes.Buffer) error {
+	if archiver == nil || capture == nil {
+		return nil
+	}
+
+	metadata := ArchiveMetadata{
+		From:       m.getEnvelopeFrom(),
+		Recipients: m.recipients(),
+		Subject:    m.Subject,
+		SentAt:     time.Now(),
+	}
+	if err := archiver.Archive(ctx, capture.Bytes(), metadata); err != nil {
+		return fmt.Errorf("%w: %w", ErrArchiveFailed, err)
+	}
+	return nil
+}
+
+// archiveName returns a unique, chronologically sortable name for an archived email
+func archiveName(sentAt time.Time) string {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%s-%x", sentAt.UTC().Format("20060102T150405.000000000Z"), buf[:])
+}
+
+// FileArchiver archives emails as read-only .eml files in a directory, each
+// with a .json file holding its metadata
+type FileArchiver struct {
+	Dir string
+}
+
+// NewFileArchiver creates a file archiver, creating dir if it does not exist
+func NewFileArchiver(dir string) (*FileArchiver, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating archive directory: %w", err)
+	}
+	return &FileArchiver{Dir: dir}, nil
+}
+
+// Archive implements Archiver
+func (a *FileArchiver) Archive(ctx context.Context, message []byte, metadata ArchiveMetadata) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	name := filepath.Join(a.Dir, archiveName(metadata.SentAt))
+	if err := writeReadOnlyFile(name+".eml", message); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return writeReadOnlyFile(name+".json", data)
+}
+
+// writeReadOnlyFile creates a new read-only file with data, never overwriting an existing file
+func writeReadOnlyFile(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o440)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(name)
+		return err
+	}
+	return f.Close()
+}
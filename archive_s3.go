@@ -0,0 +1,202 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3MetadataLimit is the maximum size of the user metadata of an S3 object,
+// counted as the bytes of its keys, without the x-amz-meta- prefix, and values
+const s3MetadataLimit = 2048
+
+// S3Archiver archives emails as objects in an S3 compatible bucket (AWS S3,
+// MinIO, Ceph, ...) using path-style requests signed with AWS Signature Version 4.
+// Objects are named Prefix + "2006/01/02/<timestamp>-<random>.eml" and carry the
+// archive metadata as x-amz-meta-* headers. Recipients that do not fit in the
+// 2 KB of user metadata S3 allows are left out of the headers, and the complete
+// metadata is stored as JSON in an object of the same name ending in ".json".
+type S3Archiver struct {
+	// Endpoint is the base URL of the service, e.g. "https://s3.eu-west-1.amazonaws.com"
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Retention, when positive, locks every object in COMPLIANCE mode for that
+	// long. The bucket must have S3 Object Lock enabled.
+	Retention time.Duration
+	// Client is the HTTP client used for the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Archive implements Archiver
+func (a *S3Archiver) Archive(ctx context.Context, message []byte, metadata ArchiveMetadata) error {
+	name := a.Prefix + metadata.SentAt.UTC().Format("2006/01/02/") + archiveName(metadata.SentAt)
+
+	subject := mime.QEncoding.Encode("utf-8", metadata.Subject)
+	count := strconv.Itoa(len(metadata.Recipients))
+	budget := s3MetadataLimit - len("from") - len(metadata.From) - len("subject") - len(subject) -
+		len("recipients") - len("recipient-count") - len(count)
+	recipients, complete := joinWithin(metadata.Recipients, ",", budget)
+
+	header := http.Header{}
+	header.Set("Content-Type", "message/rfc822")
+	header.Set("X-Amz-Meta-From", metadata.From)
+	header.Set("X-Amz-Meta-Recipients", recipients)
+	header.Set("X-Amz-Meta-Recipient-Count", count)
+	header.Set("X-Amz-Meta-Subject", subject)
+	if err := a.put(ctx, name+".eml", message, header, metadata.SentAt); err != nil {
+		return err
+	}
+	if complete {
+		return nil
+	}
+
+	// The recipients left out of the headers are kept in the metadata object
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return a.put(ctx, name+".json", data, http.Header{"Content-Type": {"application/json"}}, metadata.SentAt)
+}
+
+// put stores body as the object key with header, locked for the retention of
+// the archiver from sentAt
+func (a *S3Archiver) put(ctx context.Context, key string, body []byte, header http.Header, sentAt time.Time) error {
+	url := strings.TrimSuffix(a.Endpoint, "/") + awsEscapePath(a.Bucket+"/"+key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	if a.Retention > 0 {
+		sum := md5.Sum(body)
+		req.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+		req.Header.Set("X-Amz-Object-Lock-Mode", "COMPLIANCE")
+		req.Header.Set("X-Amz-Object-Lock-Retain-Until-Date",
+			sentAt.Add(a.Retention).UTC().Format(time.RFC3339))
+	}
+
+	payloadHash := sha256.Sum256(body)
+	signV4(req, "s3", hex.EncodeToString(payloadHash[:]), a.AccessKeyID, a.SecretAccessKey, a.Region, time.Now())
+
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3: storing %s failed: %s: %s", key, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// joinWithin joins the leading elements of elems that fit in max bytes with
+// sep, and reports whether every element fit
+func joinWithin(elems []string, sep string, max int) (string, bool) {
+	size := 0
+	for i, elem := range elems {
+		if i > 0 {
+			size += len(sep)
+		}
+		size += len(elem)
+		if size > max {
+			return strings.Join(elems[:i], sep), false
+		}
+	}
+	return strings.Join(elems, sep), true
+}
+
+// signV4 signs req for the AWS service (e.g. "s3") with AWS Signature Version 4,
+// covering the host and every header set on req
+func signV4(req *http.Request, service, payloadHash, accessKeyID, secretAccessKey, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscapePath(strings.TrimPrefix(req.URL.Path, "/")),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscapePath returns "/" followed by path with every byte other than the
+// unreserved characters and "/" percent-encoded, as Signature Version 4 requires
+func awsEscapePath(path string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&0x0f])
+	}
+	return b.String()
+}
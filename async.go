@@ -0,0 +1,335 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrQueueFull is returned by SendAsync when the queue is full and its overflow is QueueError
+	ErrQueueFull = errors.New("async queue is full")
+	// ErrQueueDropped is returned for a queued email dropped to make room under QueueDropOldest
+	ErrQueueDropped = errors.New("email dropped from the async queue")
+	// ErrQueueClosed is returned by SendAsync once the queue is closed
+	ErrQueueClosed = errors.New("async queue is closed")
+)
+
+// AsyncQueue is a bounded queue of emails waiting to be sent by SendAsync, so
+// producers see backpressure instead of piling up goroutines when the server
+// slows down. A queue can be shared by several Mail values.
+type AsyncQueue struct {
+	jobs     chan asyncJob
+	overflow QueueOverflow
+	workers  int
+	start    sync.Once
+	closed   bool
+	mu       sync.RWMutex
+	// control guards the pause state and the counts of the queue
+	control  sync.Mutex
+	paused   bool
+	resumed  chan struct{}
+	queued   int
+	inFlight int
+	// changed is closed when an email leaves the queue, waking Drain
+	changed chan struct{}
+	quotas  *Quotas
+	// closing is closed by Close, failing the producers blocked on a full queue
+	closing chan struct{}
+	// producers counts the producers waiting for room in the queue
+	producers sync.WaitGroup
+}
+
+// asyncJob is an email waiting in the queue together with the channel for its
+// result, either as an error or as a report
+type asyncJob struct {
+	mail   *Mail
+	result chan error
+	report chan SendReport
+	// release gives back the quota reserved for the email when it is not sent
+	// or fails
+	release func()
+}
+
+// NewAsyncQueue creates a queue holding up to size emails (DefaultQueueSize when
+// size is not positive), sent by DefaultPoolSize workers
+func NewAsyncQueue(size int, overflow QueueOverflow) *AsyncQueue {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	return &AsyncQueue{
+		jobs:     make(chan asyncJob, size),
+		overflow: overflow,
+		workers:  DefaultPoolSize,
+		closing:  make(chan struct{}),
+	}
+}
+
+// SetAsyncQueue makes SendAsync send the email through queue
+func (m *Mail) SetAsyncQueue(queue *AsyncQueue) *Mail {
+	m.asyncQueue = queue
+	return m
+}
+
+// SetAsyncConcurrency limits the sends of the email launched by SendAsync that are
+// in flight at the same time to n, independently of the pool size. Further sends
+// wait for one to finish before dialing. A non-positive n removes the limit.
+func (m *Mail) SetAsyncConcurrency(n int) *Mail {
+	if n <= 0 {
+		m.asyncLimit = nil
+	} else {
+		m.asyncLimit = make(chan struct{}, n)
+	}
+	return m
+}
+
+// sendLimited sends the email once the async concurrency limit allows it
+func (m *Mail) sendLimited() SendReport {
+	if limit := m.asyncLimit; limit != nil {
+		limit <- struct{}{}
+		defer func() { <-limit }()
+	}
+	return m.SendWithReport(context.Background())
+}
+
+// sendAsync sends the email in the background, through the async queue if one is
+// set, and delivers the outcome to job
+func (m *Mail) sendAsync(job asyncJob) {
+	job.mail = m
+	if m.asyncQueue != nil {
+		m.asyncQueue.enqueue(job)
+		return
+	}
+	go func() {
+		job.finish(m.sendLimited())
+	}()
+}
+
+// QueueDepth returns the number of emails waiting in the async queue of the email
+func (m *Mail) QueueDepth() int {
+	return m.asyncQueue.Len()
+}
+
+// Len returns the number of emails waiting in the queue
+func (q *AsyncQueue) Len() int {
+	if q == nil {
+		return 0
+	}
+	return len(q.jobs)
+}
+
+// Close stops the queue from accepting emails; those already queued are still
+// sent, and those waiting for room in a full queue fail with ErrQueueClosed
+func (q *AsyncQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.closing)
+	q.mu.Unlock()
+
+	// No producer may be sending once the jobs channel is closed
+	q.producers.Wait()
+	close(q.jobs)
+}
+
+// enqueue queues job for sending per the overflow behavior of the queue
+func (q *AsyncQueue) enqueue(job asyncJob) {
+	q.start.Do(q.startWorkers)
+
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		job.fail(ErrQueueClosed)
+		return
+	}
+	release, err := q.quotas.reserve(job.mail.quotaKey, len(job.mail.recipients()), job.mail.getClock().Now())
+	if err != nil {
+		q.mu.RUnlock()
+		job.fail(err)
+		return
+	}
+	job.release = release
+	q.count(1)
+
+	switch q.overflow {
+	case QueueError:
+		defer q.mu.RUnlock()
+		select {
+		case q.jobs <- job:
+		default:
+			q.count(-1)
+			job.release()
+			job.fail(ErrQueueFull)
+		}
+	case QueueDropOldest:
+		defer q.mu.RUnlock()
+		for {
+			select {
+			case q.jobs <- job:
+				return
+			default:
+			}
+			select {
+			case oldest := <-q.jobs:
+				q.count(-1)
+				oldest.release()
+				oldest.fail(ErrQueueDropped)
+			default:
+			}
+		}
+	default:
+		// Waiting for room must not hold up Close, so the lock is released and
+		// Close waits for the producer instead
+		q.producers.Add(1)
+		q.mu.RUnlock()
+		defer q.producers.Done()
+		select {
+		case q.jobs <- job:
+		case <-q.closing:
+			q.count(-1)
+			job.release()
+			job.fail(ErrQueueClosed)
+		}
+	}
+}
+
+// startWorkers starts the goroutines sending the queued emails
+func (q *AsyncQueue) startWorkers() {
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			for job := range q.jobs {
+				q.begin()
+				report := job.mail.sendLimited()
+				// An email that was not delivered does not count against the quota
+				if report.Err != nil && !errors.Is(report.Err, ErrArchiveFailed) {
+					job.release()
+				}
+				job.finish(report)
+				q.end()
+			}
+		}()
+	}
+}
+
+// Pause stops the queue from starting sends until Resume. Sends in flight are
+// completed, and SendAsync keeps queueing emails per the overflow behavior.
+func (q *AsyncQueue) Pause() {
+	q.control.Lock()
+	defer q.control.Unlock()
+	if !q.paused {
+		q.paused = true
+		q.resumed = make(chan struct{})
+	}
+}
+
+// Resume restarts the sends of a paused queue
+func (q *AsyncQueue) Resume() {
+	q.control.Lock()
+	defer q.control.Unlock()
+	if q.paused {
+		q.paused = false
+		close(q.resumed)
+	}
+}
+
+// Drain waits until every email queued has been sent, or until ctx is done.
+// A paused queue is drained once resumed. Closing the queue first makes Drain
+// wait for a graceful shutdown.
+func (q *AsyncQueue) Drain(ctx context.Context) error {
+	for {
+		q.control.Lock()
+		if q.queued == 0 {
+			q.control.Unlock()
+			return nil
+		}
+		if q.changed == nil {
+			q.changed = make(chan struct{})
+		}
+		changed := q.changed
+		q.control.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Status returns the state of the queue and the number of emails in it
+func (q *AsyncQueue) Status() QueueStatus {
+	q.mu.RLock()
+	closed := q.closed
+	q.mu.RUnlock()
+
+	q.control.Lock()
+	defer q.control.Unlock()
+	status := QueueStatus{Queued: q.queued - q.inFlight, InFlight: q.inFlight}
+	switch {
+	case q.paused:
+		status.State = QueuePaused
+	case closed:
+		status.State = QueueClosed
+	}
+	return status
+}
+
+// begin waits until the queue is not paused and counts a send in flight
+func (q *AsyncQueue) begin() {
+	q.control.Lock()
+	defer q.control.Unlock()
+	for q.paused {
+		resumed := q.resumed
+		q.control.Unlock()
+		<-resumed
+		q.control.Lock()
+	}
+	q.inFlight++
+}
+
+// end counts a send in flight as done
+func (q *AsyncQueue) end() {
+	q.control.Lock()
+	defer q.control.Unlock()
+	q.inFlight--
+	q.queued--
+	q.wakeDrain()
+}
+
+// count adds delta to the number of emails queued, waking Drain when an email leaves
+func (q *AsyncQueue) count(delta int) {
+	q.control.Lock()
+	defer q.control.Unlock()
+	q.queued += delta
+	if delta < 0 {
+		q.wakeDrain()
+	}
+}
+
+// wakeDrain wakes the callers of Drain waiting for an email to leave the queue.
+// It is called with control held.
+func (q *AsyncQueue) wakeDrain() {
+	if q.changed != nil {
+		close(q.changed)
+		q.changed = nil
+	}
+}
+
+// finish delivers report as the result of the job
+func (j asyncJob) finish(report SendReport) {
+	if j.report != nil {
+		j.report <- report
+		close(j.report)
+		return
+	}
+	j.result <- report.Err
+	close(j.result)
+}
+
+// fail finishes the job with err before the email was sent
+func (j asyncJob) fail(err error) {
+	j.finish(SendReport{Recipients: j.mail.recipients(), Err: err})
+}
@@ -0,0 +1,241 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// receive returns the result delivered on result, failing the test if none arrives
+func receive(t *testing.T, result chan error) error {
+	t.Helper()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(2 * time.Second):
+		t.Fatal("no result delivered")
+		return nil
+	}
+}
+
+func TestAsyncQueueOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow QueueOverflow
+		// wantErrs are the errors delivered right away to the three emails queued
+		wantErrs []error
+	}{
+		{"error", QueueError, []error{nil, nil, ErrQueueFull}},
+		{"drop oldest", QueueDropOldest, []error{ErrQueueDropped, nil, nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without workers the queued emails are never sent
+			queue := NewAsyncQueue(2, tt.overflow)
+			queue.workers = 0
+			m := (&Mail{}).SetAsyncQueue(queue)
+
+			var results []chan error
+			for i := 0; i < 3; i++ {
+				results = append(results, m.SendAsync())
+			}
+			if got := m.QueueDepth(); got != 2 {
+				t.Errorf("QueueDepth() = %d, want 2", got)
+			}
+			for i, want := range tt.wantErrs {
+				if want == nil {
+					continue
+				}
+				if err := receive(t, results[i]); !errors.Is(err, want) {
+					t.Errorf("email %d: error = %v, want %v", i, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAsyncQueueBlock(t *testing.T) {
+	queue := NewAsyncQueue(1, QueueBlock)
+	queue.workers = 0
+	m := (&Mail{}).SetAsyncQueue(queue)
+	m.SendAsync()
+
+	queued := make(chan struct{})
+	go func() {
+		m.SendAsync()
+		close(queued)
+	}()
+
+	select {
+	case <-queued:
+		t.Fatal("SendAsync() should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-queue.jobs
+	select {
+	case <-queued:
+	case <-time.After(2 * time.Second):
+		t.Fatal("SendAsync() still blocked after the queue made room")
+	}
+}
+
+func TestAsyncQueueCloseWhileBlocked(t *testing.T) {
+	queue := NewAsyncQueue(1, QueueBlock)
+	queue.workers = 0
+	m := (&Mail{}).SetAsyncQueue(queue)
+	m.SendAsync()
+
+	blocked := make(chan chan error)
+	go func() { blocked <- m.SendAsync() }()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		queue.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close() blocked behind the producer waiting for room")
+	}
+
+	select {
+	case result := <-blocked:
+		if err := receive(t, result); !errors.Is(err, ErrQueueClosed) {
+			t.Errorf("blocked SendAsync() error = %v, want ErrQueueClosed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("SendAsync() still blocked after Close()")
+	}
+	if got, want := queue.Status(), (QueueStatus{State: QueueClosed, Queued: 1}); got != want {
+		t.Errorf("Status() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAsyncQueueSend(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	queue := NewAsyncQueue(10, QueueBlock)
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1).SetAsyncQueue(queue)
+
+	var results []chan error
+	for i := 0; i < 5; i++ {
+		results = append(results, m.SendAsync())
+	}
+	for i, result := range results {
+		if err := receive(t, result); err != nil {
+			t.Errorf("email %d: error = %v", i, err)
+		}
+	}
+	if got := len(server.getMessages()); got != 5 {
+		t.Errorf("server received %d messages, want 5", got)
+	}
+
+	queue.Close()
+	if err := receive(t, m.SendAsync()); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("SendAsync() after Close() error = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestAsyncQueueControls(t *testing.T) {
+	var sends atomic.Int32
+	queue := NewAsyncQueue(10, QueueBlock)
+	// A single worker keeps the sends of the same email apart
+	queue.workers = 1
+	m := newSandboxMail().SetSandbox(false).SetAsyncQueue(queue).
+		SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error {
+			sends.Add(1)
+			return nil
+		}))
+
+	queue.Pause()
+	var results []chan error
+	for i := 0; i < 3; i++ {
+		results = append(results, m.SendAsync())
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := sends.Load(); got != 0 {
+		t.Errorf("%d emails sent while paused, want 0", got)
+	}
+	if got, want := queue.Status(), (QueueStatus{State: QueuePaused, Queued: 3}); got != want {
+		t.Errorf("Status() = %+v, want %+v", got, want)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := queue.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain() of a paused queue error = %v, want the context deadline", err)
+	}
+
+	queue.Resume()
+	if err := queue.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if got := sends.Load(); got != 3 {
+		t.Errorf("%d emails sent after Resume(), want 3", got)
+	}
+	for i, result := range results {
+		if err := receive(t, result); err != nil {
+			t.Errorf("email %d: error = %v", i, err)
+		}
+	}
+
+	queue.Close()
+	if got, want := queue.Status(), (QueueStatus{State: QueueClosed}); got != want {
+		t.Errorf("Status() after Close() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSetAsyncConcurrency(t *testing.T) {
+	server := newMockSMTPServer(t)
+	server.stall("MAIL FROM")
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1).SetAsyncConcurrency(2)
+
+	var results []chan error
+	for i := 0; i < 5; i++ {
+		results = append(results, m.SendAsync())
+	}
+
+	// Two sends stall in MAIL FROM: one on the pooled connection and one on a new connection
+	time.Sleep(200 * time.Millisecond)
+	if got := server.connectionCount(); got != 2 {
+		t.Errorf("server accepted %d connections, want 2", got)
+	}
+
+	server.close()
+	for _, result := range results {
+		receive(t, result)
+	}
+}
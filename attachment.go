@@ -0,0 +1,115 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"time"
+)
+
+// PreparedAttachment is an attachment encoded once that can be added to any
+// number of emails, which then write it without encoding it again. It is
+// immutable and safe to share between emails sent concurrently.
+type PreparedAttachment struct {
+	name    string
+	header  textproto.MIMEHeader
+	encoded []byte
+	size    int64
+}
+
+// PrepareAttachment reads r to the end and encodes it as an attachment named
+// name, typed by the extension of name
+func PrepareAttachment(name string, r io.Reader) (*PreparedAttachment, error) {
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	var encoded bytes.Buffer
+	content := &countingWriter{w: io.Discard}
+	if err := writeBase64(&encoded, io.TeeReader(r, content)); err != nil {
+		return nil, err
+	}
+	return &PreparedAttachment{
+		name: name,
+		header: textproto.MIMEHeader{
+			"Content-Type":              []string{sanitizeHeaderValue(contentType)},
+			"Content-Transfer-Encoding": []string{"base64"},
+			"Content-Disposition":       []string{contentDisposition("attachment", name)},
+		},
+		encoded: encoded.Bytes(),
+		size:    content.n,
+	}, nil
+}
+
+// Name returns the file name of the attachment
+func (a *PreparedAttachment) Name() string {
+	return a.name
+}
+
+// Size returns the encoded size of the attachment in bytes
+func (a *PreparedAttachment) Size() int64 {
+	return int64(len(a.encoded))
+}
+
+// writePart writes the attachment as a part of writer
+func (a *PreparedAttachment) writePart(writer *multipart.Writer) error {
+	part, err := writer.CreatePart(a.header)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(a.encoded)
+	return err
+}
+
+// SetPreparedAttachment adds attachments encoded with PrepareAttachment to the email
+func (m *Mail) SetPreparedAttachment(attachments ...*PreparedAttachment) *Mail {
+	m.attachmentParts = append(m.attachmentParts, attachments...)
+	return m
+}
+
+// attachmentStream reads a streaming attachment, throttling the read rate and
+// reporting progress as configured on the attachment
+type attachmentStream struct {
+	attachment AttachmentReader
+	read       int64
+	start      time.Time
+}
+
+// newAttachmentStream returns the reader to encode the attachment from
+func newAttachmentStream(attachment AttachmentReader) io.Reader {
+	if attachment.BytesPerSecond <= 0 && attachment.Progress == nil {
+		return attachment.Reader
+	}
+	return &attachmentStream{attachment: attachment}
+}
+
+// Read implements io.Reader
+func (s *attachmentStream) Read(p []byte) (int, error) {
+	rate := s.attachment.BytesPerSecond
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+
+	// Read in chunks of a tenth of a second so throttling stays smooth
+	if chunk := rate / 10; rate > 0 && int64(len(p)) > chunk {
+		p = p[:max(chunk, 1)]
+	}
+
+	n, err := s.attachment.Reader.Read(p)
+	s.read += int64(n)
+
+	if rate > 0 {
+		due := time.Duration(s.read * int64(time.Second) / rate)
+		if wait := due - time.Since(s.start); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	if s.attachment.Progress != nil && n > 0 {
+		s.attachment.Progress(s.read, s.attachment.Size)
+	}
+	return n, err
+}
@@ -0,0 +1,126 @@
+package gomail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttachmentStream(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 20*1024)
+
+	var progress []int64
+	stream := newAttachmentStream(AttachmentReader{
+		Name:           "data.bin",
+		Reader:         bytes.NewReader(data),
+		Size:           int64(len(data)),
+		BytesPerSecond: 100 * 1024,
+		Progress: func(read, total int64) {
+			if total != int64(len(data)) {
+				t.Errorf("Progress() total = %d, want %d", total, len(data))
+			}
+			progress = append(progress, read)
+		},
+	})
+
+	start := time.Now()
+	got, err := io.ReadAll(stream)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("stream altered the attachment data")
+	}
+
+	// 20 KiB at 100 KiB/s takes about 200ms
+	if elapsed < 150*time.Millisecond {
+		t.Errorf("reading took %v, expected throttling to about 200ms", elapsed)
+	}
+
+	if len(progress) < 2 {
+		t.Fatalf("Progress() called %d times, want several", len(progress))
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i] <= progress[i-1] {
+			t.Errorf("progress is not increasing: %v", progress)
+			break
+		}
+	}
+	if last := progress[len(progress)-1]; last != int64(len(data)) {
+		t.Errorf("final progress = %d, want %d", last, len(data))
+	}
+}
+
+func TestAttachmentStreamPassthrough(t *testing.T) {
+	reader := bytes.NewReader([]byte("data"))
+	if newAttachmentStream(AttachmentReader{Reader: reader}) != io.Reader(reader) {
+		t.Error("attachments without throttling or progress should be read directly")
+	}
+}
+
+func TestPreparedAttachment(t *testing.T) {
+	data := bytes.Repeat([]byte("%PDF"), 1000)
+	attachment, err := PrepareAttachment("report.pdf", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("PrepareAttachment() error = %v", err)
+	}
+	if attachment.Name() != "report.pdf" {
+		t.Errorf("Name() = %s, want report.pdf", attachment.Name())
+	}
+	if want := base64Size(int64(len(data))); attachment.Size() != want {
+		t.Errorf("Size() = %d, want %d", attachment.Size(), want)
+	}
+
+	for _, to := range []string{"first@example.com", "second@example.com"} {
+		m := &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Subject: "Report",
+			Content: "See attached",
+			To:      []string{to},
+		}
+		m.SetPreparedAttachment(attachment)
+
+		var buf bytes.Buffer
+		if err := m.writeMessage(&buf); err != nil {
+			t.Fatalf("writeMessage() error = %v", err)
+		}
+		msg, err := mail.ReadMessage(&buf)
+		if err != nil {
+			t.Fatalf("failed to parse message: %v", err)
+		}
+		_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatalf("invalid Content-Type: %v", err)
+		}
+
+		reader := multipart.NewReader(msg.Body, params["boundary"])
+		if _, err := reader.NextPart(); err != nil {
+			t.Fatalf("failed to read body part: %v", err)
+		}
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("failed to read attachment part: %v", err)
+		}
+		if got := part.Header.Get("Content-Type"); got != "application/pdf" {
+			t.Errorf("Content-Type = %s, want application/pdf", got)
+		}
+		if got := part.Header.Get("Content-Disposition"); !strings.Contains(got, `filename="report.pdf"`) {
+			t.Errorf("Content-Disposition = %s, want the file name", got)
+		}
+		got, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		if err != nil {
+			t.Fatalf("failed to decode attachment: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Error("decoded attachment differs from the prepared data")
+		}
+	}
+}
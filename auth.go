@@ -0,0 +1,227 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// CredentialsProvider supplies the SMTP credentials, so secrets can be kept in a
+// store such as Vault or AWS Secrets Manager and rotated without a restart. It is
+// consulted whenever a connection is dialed or taken from the pool, so it should
+// cache its secrets. A provider identifies the pools of its connections in a
+// PoolRegistry: pointer and other comparable providers by their value, and
+// others, such as func types, by the SetCredentialsProvider call that set them.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (user, pass string, err error)
+}
+
+// externalAuth implements the SASL EXTERNAL mechanism (RFC 4422 Appendix A),
+// where the server authenticates the client by its TLS certificate
+type externalAuth struct {
+	identity string
+}
+
+// Start implements smtp.Auth. The authorization identity, if any, is sent as the
+// initial response; an empty one asks the server to derive it from the certificate.
+func (a *externalAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		return "", nil, errors.New("EXTERNAL authentication requires a TLS connection")
+	}
+	if a.identity == "" {
+		return "EXTERNAL", nil, nil
+	}
+	return "EXTERNAL", []byte(a.identity), nil
+}
+
+// Next implements smtp.Auth. The identity was settled by Start, so a challenge
+// is answered with an empty response.
+func (a *externalAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	return nil, nil
+}
+
+// externalAuth reports whether the connection is authenticated by the TLS client certificate
+func (m *Mail) externalAuth() bool {
+	tlsConfig := m.getTLSConfig()
+	return tlsConfig != nil && tlsConfig.ExternalAuth
+}
+
+// SetAuthMethod pins the mechanism used to authenticate with User and Pass or
+// the credentials provider, which is otherwise negotiated with the server.
+// Authentication with OAuth2 always uses XOAUTH2.
+func (m *Mail) SetAuthMethod(method AuthMethod) *Mail {
+	m.authMethod = method
+	return m
+}
+
+// SetCredentialsProvider sets the provider of the SMTP credentials, which then
+// take the place of User and Pass. Pooled connections authenticated with
+// credentials that have since been rotated are replaced by new connections.
+func (m *Mail) SetCredentialsProvider(provider CredentialsProvider) *Mail {
+	if provider != nil && !reflect.ValueOf(provider).Comparable() {
+		provider = &credentialsRef{provider}
+	}
+	m.credentials = provider
+	return m
+}
+
+// credentialsRef refers to a provider that is not comparable, so that it can
+// identify pools by the pointer
+type credentialsRef struct {
+	CredentialsProvider
+}
+
+// hasCredentials reports whether m can authenticate, with a password, a
+// credentials provider, an OAuth2 token or by certificate
+func (m *Mail) hasCredentials() bool {
+	user, pass := m.staticCredentials()
+	return m.externalAuth() || m.credentials != nil || m.oauth2 != nil || user != "" && pass != ""
+}
+
+// UpdateCredentials replaces User and Pass while the Mail may be sending. The
+// pools are rebuilt: idle connections are closed right away, while sends in
+// flight finish on their connections, which are closed once released. New
+// connections authenticate with the new credentials.
+func (m *Mail) UpdateCredentials(user, pass string) *Mail {
+	m.configMutex.Lock()
+	oldUser, oldPass := m.User, m.Pass
+	m.User, m.Pass = user, pass
+	m.configMutex.Unlock()
+
+	m.retirePools(oldUser, oldPass, nil)
+	return m
+}
+
+// retirePools retires the pools of m and the pools of its registries that are
+// authenticated with user and pass, or with provider when it is not nil
+func (m *Mail) retirePools(user, pass string, provider CredentialsProvider) {
+	m.poolMutex.Lock()
+	pools := m.pools
+	m.pools = nil
+	registries := []*PoolRegistry{m.poolRegistry, m.senderPools}
+	m.poolMutex.Unlock()
+
+	for _, pool := range pools {
+		pool.retire()
+	}
+	for _, registry := range registries {
+		registry.retire(user, pass, provider)
+	}
+}
+
+// staticCredentials returns User and Pass
+func (m *Mail) staticCredentials() (string, string) {
+	m.configMutex.RLock()
+	defer m.configMutex.RUnlock()
+	return m.User, m.Pass
+}
+
+// getCredentials returns the user and password, or access token, to
+// authenticate with under ctx
+func (m *Mail) getCredentials(ctx context.Context) (string, string, error) {
+	if m.oauth2 != nil {
+		return m.oauth2Credentials(ctx)
+	}
+	if m.credentials == nil {
+		user, pass := m.staticCredentials()
+		return user, pass, nil
+	}
+	user, pass, err := m.credentials.Credentials(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("error fetching credentials: %w", err)
+	}
+	return user, pass, nil
+}
+
+// auth returns the SASL mechanism for authenticating to host as user, with
+// pass being the access token when authenticating with OAuth2
+func (m *Mail) auth(host, user, pass string) smtp.Auth {
+	if m.externalAuth() {
+		return &externalAuth{identity: user}
+	}
+	if m.oauth2 != nil {
+		return &xoauth2Auth{user: user, token: pass, host: host}
+	}
+	return passwordAuth(m.authMethod, host, user, pass)
+}
+
+// passwordAuth returns the SASL mechanism method for authenticating to host as
+// user with pass
+func passwordAuth(method AuthMethod, host, user, pass string) smtp.Auth {
+	switch method {
+	case AuthPlain:
+		return smtp.PlainAuth("", user, pass, host)
+	case AuthLogin:
+		return &loginAuth{user: user, pass: pass, host: host}
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(user, pass)
+	default:
+		return &negotiatedAuth{user: user, pass: pass, host: host}
+	}
+}
+
+// negotiatedMethods are the password mechanisms AuthAuto picks from, strongest first
+var negotiatedMethods = []AuthMethod{AuthCRAMMD5, AuthLogin, AuthPlain}
+
+// negotiatedAuth authenticates with the strongest password mechanism the server
+// offers, PLAIN when it offers none of them
+type negotiatedAuth struct {
+	user, pass, host string
+	auth             smtp.Auth
+}
+
+// Start implements smtp.Auth
+func (a *negotiatedAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	method := AuthPlain
+	for _, candidate := range negotiatedMethods {
+		if slices.ContainsFunc(server.Auth, func(offered string) bool { return strings.EqualFold(offered, string(candidate)) }) {
+			method = candidate
+			break
+		}
+	}
+	a.auth = passwordAuth(method, a.host, a.user, a.pass)
+	return a.auth.Start(server)
+}
+
+// Next implements smtp.Auth
+func (a *negotiatedAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	return a.auth.Next(fromServer, more)
+}
+
+// loginAuth implements the LOGIN mechanism, which answers the server's prompts
+// for the user name and password
+type loginAuth struct {
+	user, pass, host string
+}
+
+// Start implements smtp.Auth. Like PLAIN, the password is only sent over an
+// encrypted connection or to localhost.
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+// Next implements smtp.Auth
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(string(fromServer))
+	switch {
+	case strings.HasPrefix(prompt, "username"):
+		return []byte(a.user), nil
+	case strings.HasPrefix(prompt, "password"):
+		return []byte(a.pass), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN prompt %q", fromServer)
+	}
+}
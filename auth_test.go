@@ -0,0 +1,375 @@
+package gomail
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExternalAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity string
+		tls      bool
+		wantResp string
+		wantErr  bool
+	}{
+		{"derived identity", "", true, "", false},
+		{"authorization identity", "relay@example.com", true, "relay@example.com", false},
+		{"plaintext connection", "", false, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &externalAuth{identity: tt.identity}
+			mech, resp, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: tt.tls, Auth: []string{"EXTERNAL"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if mech != "EXTERNAL" {
+				t.Errorf("Start() mechanism = %q, want EXTERNAL", mech)
+			}
+			if string(resp) != tt.wantResp {
+				t.Errorf("Start() response = %q, want %q", resp, tt.wantResp)
+			}
+			if next, err := auth.Next([]byte(""), true); err != nil || len(next) != 0 {
+				t.Errorf("Next() = %q, %v, want an empty response", next, err)
+			}
+		})
+	}
+}
+
+func TestExternalAuthCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		mail *Mail
+		want bool
+	}{
+		{"password", &Mail{User: "user", Pass: "pass"}, true},
+		{"missing password", &Mail{User: "user"}, false},
+		{"certificate", (&Mail{}).SetTLSConfig(&TLSConfig{ExternalAuth: true}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mail.hasCredentials(); got != tt.want {
+				t.Errorf("hasCredentials() = %v, want %v", got, tt.want)
+			}
+			_, isExternal := tt.mail.auth("smtp.example.com", tt.mail.User, tt.mail.Pass).(*externalAuth)
+			if isExternal != tt.mail.externalAuth() {
+				t.Errorf("auth() external = %v, want %v", isExternal, tt.mail.externalAuth())
+			}
+		})
+	}
+}
+
+// rotatingCredentials is a CredentialsProvider whose password can be rotated
+type rotatingCredentials struct {
+	mu   sync.Mutex
+	pass string
+}
+
+// Credentials implements CredentialsProvider
+func (r *rotatingCredentials) Credentials(ctx context.Context) (string, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pass == "" {
+		return "", "", errors.New("secret not found")
+	}
+	return "user", r.pass, nil
+}
+
+// rotate replaces the password
+func (r *rotatingCredentials) rotate(pass string) {
+	r.mu.Lock()
+	r.pass = pass
+	r.mu.Unlock()
+}
+
+func TestCredentialsProvider(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	provider := &rotatingCredentials{pass: "first"}
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1).SetCredentialsProvider(provider)
+
+	steps := []struct {
+		pass        string
+		connections int
+	}{
+		{"first", 1},
+		{"first", 1},
+		{"second", 2},
+	}
+	for i, step := range steps {
+		provider.rotate(step.pass)
+		if err := m.Send(); err != nil {
+			t.Fatalf("send %d: Send() error = %v", i, err)
+		}
+		if got := server.connectionCount(); got != step.connections {
+			t.Errorf("send %d: %d connections, want %d", i, got, step.connections)
+		}
+	}
+
+	// The connection dialed after the rotation authenticated with the new password
+	messages := server.getMessages()
+	auth := base64.StdEncoding.EncodeToString([]byte("\x00user\x00second"))
+	if !strings.Contains(messages[len(messages)-1], "AUTH PLAIN "+auth) {
+		t.Error("connection was not authenticated with the rotated credentials")
+	}
+
+	provider.rotate("")
+	if err := m.Send(); err == nil || !strings.Contains(err.Error(), "secret not found") {
+		t.Errorf("Send() error = %v, want the provider error", err)
+	}
+}
+
+// credentialsFunc adapts a function to CredentialsProvider
+type credentialsFunc func(ctx context.Context) (string, string, error)
+
+func (f credentialsFunc) Credentials(ctx context.Context) (string, string, error) {
+	return f(ctx)
+}
+
+// tokenFunc adapts a function to TokenSource
+type tokenFunc func(ctx context.Context) (string, error)
+
+func (f tokenFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+func TestUncomparableProviders(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(m *Mail) *Mail
+	}{
+		{
+			name: "credentials provider",
+			set: func(m *Mail) *Mail {
+				return m.SetCredentialsProvider(credentialsFunc(func(ctx context.Context) (string, string, error) {
+					return "user", "secret", nil
+				}))
+			},
+		},
+		{
+			name: "token source",
+			set: func(m *Mail) *Mail {
+				return m.SetOAuth2(tokenFunc(func(ctx context.Context) (string, error) {
+					return "token", nil
+				}))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+			host, port, _ := net.SplitHostPort(server.addr())
+			registry := NewPoolRegistry()
+			defer registry.Close()
+
+			m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).SetPass("").
+				SetPoolSize(1).SetPoolRegistry(registry)
+			m = tt.set(m)
+			for i := 0; i < 2; i++ {
+				if err := m.Send(); err != nil {
+					t.Fatalf("send %d: Send() error = %v", i, err)
+				}
+			}
+			// Both sends used the pool registered for the provider
+			if got := server.connectionCount(); got != 1 {
+				t.Errorf("%d connections, want 1", got)
+			}
+		})
+	}
+}
+
+// waitForQuits waits for the server to receive want QUIT commands
+func waitForQuits(t *testing.T, server *mockSMTPServer, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for server.quitCount() < want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := server.quitCount(); got != want {
+		t.Errorf("server received %d QUIT commands, want %d", got, want)
+	}
+}
+
+func TestUpdateCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry *PoolRegistry
+	}{
+		{"own pools", nil},
+		{"pool registry", NewPoolRegistry()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+
+			host, port, _ := net.SplitHostPort(server.addr())
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "old",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+			}
+			m.SetPoolSize(2).SetCommandTimeouts(&CommandTimeouts{Quit: time.Second})
+			if tt.registry != nil {
+				m.SetPoolRegistry(tt.registry)
+				defer tt.registry.Close()
+			}
+
+			// Hold one connection as a send in flight while the other is idle
+			pool, err := m.getPool(context.Background(), m.endpoint())
+			if err != nil {
+				t.Fatalf("getPool() error = %v", err)
+			}
+			inFlight, err := pool.getConnectionContext(context.Background())
+			if err != nil {
+				t.Fatalf("getConnectionContext() error = %v", err)
+			}
+
+			m.UpdateCredentials("user", "new")
+			waitForQuits(t, server, 1)
+
+			// The connection in flight is closed once released instead of being reused
+			pool.releaseConnection(inFlight)
+			waitForQuits(t, server, 2)
+
+			if err := m.Send(); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			messages := server.getMessages()
+			auth := base64.StdEncoding.EncodeToString([]byte("\x00user\x00new"))
+			if len(messages) != 1 || !strings.Contains(messages[0], "AUTH PLAIN "+auth) {
+				t.Error("send after the update did not authenticate with the new credentials")
+			}
+		})
+	}
+}
+
+func TestAuthMethod(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   AuthMethod
+		wantMech string
+		wantErr  bool
+	}{
+		{"negotiated", AuthAuto, "CRAM-MD5", false},
+		{"plain", AuthPlain, "PLAIN", true},
+		{"login", AuthLogin, "LOGIN", true},
+		{"cram-md5", AuthCRAMMD5, "CRAM-MD5", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := (&Mail{}).SetAuthMethod(tt.method)
+			auth := m.auth("smtp.example.com", "user", "secret")
+
+			// PLAIN and LOGIN refuse to send the password over a connection without TLS
+			mech, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", Auth: []string{"PLAIN", "LOGIN", "CRAM-MD5"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start() without TLS error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				mech, _, _ = auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true})
+			}
+			if mech != tt.wantMech {
+				t.Errorf("Start() mechanism = %q, want %q", mech, tt.wantMech)
+			}
+		})
+	}
+
+	// The CRAM-MD5 response is the user and the HMAC-MD5 of the challenge
+	auth := (&Mail{}).SetAuthMethod(AuthCRAMMD5).auth("smtp.example.com", "user", "secret")
+	resp, err := auth.Next([]byte("<1896.697170952@postoffice.example.net>"), true)
+	if want := "user abb7de49c58da1416866bbc566b7e585"; err != nil || string(resp) != want {
+		t.Errorf("Next() = %q, %v, want %q", resp, err, want)
+	}
+}
+
+func TestNegotiatedAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		offered  []string
+		wantMech string
+	}{
+		{"strongest first", []string{"PLAIN", "LOGIN", "CRAM-MD5"}, "CRAM-MD5"},
+		{"login over plain", []string{"login", "plain"}, "LOGIN"},
+		{"plain only", []string{"PLAIN"}, "PLAIN"},
+		{"unsupported only", []string{"GSSAPI", "NTLM"}, "PLAIN"},
+		{"no AUTH extension", nil, "PLAIN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := (&Mail{}).auth("smtp.example.com", "user", "secret")
+			mech, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true, Auth: tt.offered})
+			if err != nil || mech != tt.wantMech {
+				t.Errorf("Start() = %q, %v, want %q", mech, err, tt.wantMech)
+			}
+		})
+	}
+
+	t.Run("oauth2 wins", func(t *testing.T) {
+		auth := (&Mail{}).SetOAuth2(&countingTokenSource{}).auth("smtp.example.com", "user", "token")
+		mech, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true, Auth: []string{"CRAM-MD5", "XOAUTH2"}})
+		if err != nil || mech != "XOAUTH2" {
+			t.Errorf("Start() = %q, %v, want XOAUTH2", mech, err)
+		}
+	})
+}
+
+func TestLoginAuth(t *testing.T) {
+	auth := &loginAuth{user: "user", pass: "secret", host: "localhost"}
+	if mech, resp, err := auth.Start(&smtp.ServerInfo{Name: "localhost"}); err != nil || mech != "LOGIN" || resp != nil {
+		t.Fatalf("Start() = %q, %q, %v, want LOGIN without initial response", mech, resp, err)
+	}
+	steps := []struct {
+		prompt  string
+		want    string
+		wantErr bool
+	}{
+		{"Username:", "user", false},
+		{"Password:", "secret", false},
+		{"Account:", "", true},
+	}
+	for _, step := range steps {
+		resp, err := auth.Next([]byte(step.prompt), true)
+		if (err != nil) != step.wantErr || string(resp) != step.want {
+			t.Errorf("Next(%q) = %q, %v, want %q", step.prompt, resp, err, step.want)
+		}
+	}
+	if resp, err := auth.Next(nil, false); err != nil || resp != nil {
+		t.Errorf("final Next() = %q, %v, want nothing", resp, err)
+	}
+}
@@ -0,0 +1,215 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SendResult is the outcome of sending one message of a batch
+type SendResult struct {
+	Mail     *Mail
+	Err      error
+	Duration time.Duration
+}
+
+// BatchReport summarizes a batch send
+type BatchReport struct {
+	Total    int
+	Sent     int
+	Failed   int
+	Results  []SendResult
+	Started  time.Time
+	Duration time.Duration
+}
+
+// SendAll sends msgs concurrently with at most concurrency sends in flight
+// (DefaultPoolSize when concurrency is not positive) and returns one result
+// per message, in the order of msgs. Messages configured with the same
+// PoolRegistry share its connections. Messages not yet started when ctx is
+// cancelled fail with the context error.
+func SendAll(ctx context.Context, msgs []*Mail, concurrency int) []SendResult {
+	if concurrency <= 0 {
+		concurrency = DefaultPoolSize
+	}
+
+	results := make([]SendResult, len(msgs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, msg := range msgs {
+		results[i].Mail = msg
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, msg *Mail) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			start := time.Now()
+			results[i].Err = msg.SendContext(ctx)
+			results[i].Duration = time.Since(start)
+		}(i, msg)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// SendMany sends msgs like SendAll with the default concurrency and returns a
+// report with totals, per-message outcomes and timing. The error joins the
+// errors of all failed messages and is nil when every message was sent.
+func SendMany(ctx context.Context, msgs ...*Mail) (BatchReport, error) {
+	report := BatchReport{Total: len(msgs), Started: time.Now()}
+	report.Results = SendAll(ctx, msgs, 0)
+	report.Duration = time.Since(report.Started)
+
+	var errs []error
+	for i, result := range report.Results {
+		if result.Err != nil {
+			report.Failed++
+			errs = append(errs, fmt.Errorf("message %d: %w", i, result.Err))
+			continue
+		}
+		report.Sent++
+	}
+	return report, errors.Join(errs...)
+}
+
+// AudienceReport summarizes sending one message to an audience in batches.
+// Total, Sent and Failed count recipients; Batches holds one report per
+// transmission, in order.
+type AudienceReport struct {
+	Total    int
+	Sent     int
+	Failed   int
+	Batches  []SendReport
+	Started  time.Time
+	Duration time.Duration
+}
+
+// SendToAudience sends msg to addrs in transmissions of at most batchSize Bcc
+// recipients each (all of them in one when batchSize is not positive), one
+// after another so each batch waits for the rate limit of msg. The To and Cc
+// recipients of msg are shown on every copy but receive only the first batch;
+// without To recipients the message is addressed to From. Batches not yet sent
+// when ctx is cancelled fail with the context error. The error joins the errors
+// of all failed batches and is nil when every batch was sent.
+func SendToAudience(ctx context.Context, msg *Mail, addrs []string, batchSize int) (AudienceReport, error) {
+	report := AudienceReport{Total: len(addrs), Started: time.Now()}
+	errs := msg.sendBatches(ctx, addrs, batchSize, true, &report)
+	report.Duration = time.Since(report.Started)
+	return report, errors.Join(errs...)
+}
+
+// SendToAudienceAt sends msg to recipients like SendToAudience, delivering to
+// each recipient at the next occurrence of the time of day at (e.g. 9*time.Hour
+// for 9:00) in their own time zone, so that transmissions are spread over the
+// day and arrive when they are most likely to be read. Recipients due at the same
+// moment are sent together, in batches of batchSize. It waits with the Clock of
+// msg and returns once every recipient was sent to; recipients not yet due when
+// ctx is cancelled fail with the context error.
+func SendToAudienceAt(ctx context.Context, msg *Mail, recipients []LocalRecipient, at time.Duration, batchSize int) (AudienceReport, error) {
+	report := AudienceReport{Total: len(recipients), Started: time.Now()}
+	clock := msg.getClock()
+
+	// Group the recipients by the moment they are due, in order
+	now := clock.Now()
+	due := make(map[time.Time][]string)
+	var moments []time.Time
+	for _, recipient := range recipients {
+		moment := nextLocalTime(now, recipient.Location, at)
+		if _, ok := due[moment]; !ok {
+			moments = append(moments, moment)
+		}
+		due[moment] = append(due[moment], recipient.Address)
+	}
+	slices.SortFunc(moments, time.Time.Compare)
+
+	var errs []error
+	for i, moment := range moments {
+		if wait := moment.Sub(clock.Now()); wait > 0 && ctx.Err() == nil {
+			select {
+			case <-clock.After(wait):
+			case <-ctx.Done():
+			}
+		}
+		errs = append(errs, msg.sendBatches(ctx, due[moment], batchSize, i == 0, &report)...)
+	}
+
+	report.Duration = time.Since(report.Started)
+	return report, errors.Join(errs...)
+}
+
+// nextLocalTime returns the first moment from now on at which the time of day in
+// loc (UTC when nil) is at
+func nextLocalTime(now time.Time, loc *time.Location, at time.Duration) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	day := local.Day()
+	hour, minute, second := int(at/time.Hour), int(at%time.Hour/time.Minute), int(at%time.Minute/time.Second)
+	for {
+		// Built from the wall clock, so a day with a DST transition still gets at
+		moment := time.Date(local.Year(), local.Month(), day, hour, minute, second, int(at%time.Second), loc)
+		if !moment.Before(now) {
+			return moment
+		}
+		day++
+	}
+}
+
+// sendBatches sends msg to addrs in batches of at most batchSize Bcc recipients
+// like SendToAudience, recording them in report. The To and Cc recipients of msg
+// receive the first batch when first is set. It returns the errors of the failed batches.
+func (m *Mail) sendBatches(ctx context.Context, addrs []string, batchSize int, first bool, report *AudienceReport) []error {
+	if batchSize <= 0 {
+		batchSize = max(len(addrs), 1)
+	}
+
+	var errs []error
+	for i := 0; i < len(addrs); i += batchSize {
+		batch := addrs[i:min(i+batchSize, len(addrs))]
+
+		var result SendReport
+		if err := ctx.Err(); err != nil {
+			result = SendReport{Recipients: batch, Err: err}
+		} else {
+			result = m.audienceBatch(batch, first && i == 0).SendWithReport(ctx)
+		}
+		report.Batches = append(report.Batches, result)
+
+		if result.Err != nil {
+			report.Failed += len(batch)
+			errs = append(errs, fmt.Errorf("batch %d: %w", len(report.Batches)-1, result.Err))
+			continue
+		}
+		report.Sent += len(batch)
+	}
+	return errs
+}
+
+// audienceBatch returns a copy of m that sends to batch as Bcc recipients, and
+// also to the To and Cc recipients of m when first is set
+func (m *Mail) audienceBatch(batch []string, first bool) *Mail {
+	c := m.clone()
+	if len(c.To) == 0 {
+		c.To = []string{c.From}
+	}
+	c.Bcc = batch
+
+	c.envelopeTo = batch
+	if first {
+		c.envelopeTo = append(append(append([]string{}, m.To...), m.Cc...), batch...)
+	}
+	return c
+}
@@ -0,0 +1,376 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendAll(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	registry := NewPoolRegistry()
+	defer registry.Close()
+
+	msgs := make([]*Mail, 10)
+	for i := range msgs {
+		msgs[i] = (&Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Host:    host,
+			Port:    port,
+			User:    "user",
+			Pass:    "pass",
+			Subject: "Test Subject",
+			Content: "Test Content",
+			To:      []string{"recipient@example.com"},
+		}).SetPoolSize(2).SetPoolRegistry(registry)
+	}
+	// An invalid message fails without affecting the others
+	msgs[3].To = nil
+
+	results := SendAll(context.Background(), msgs, 3)
+	if len(results) != len(msgs) {
+		t.Fatalf("SendAll() returned %d results, want %d", len(results), len(msgs))
+	}
+	for i, result := range results {
+		if result.Mail != msgs[i] {
+			t.Errorf("result %d belongs to a different message", i)
+		}
+		if (result.Err != nil) != (i == 3) {
+			t.Errorf("result %d error = %v", i, result.Err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := len(server.getMessages()); got != 9 {
+		t.Errorf("server received %d messages, want 9", got)
+	}
+}
+
+func TestSendAllBoundsConcurrency(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	server.stall(".")
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	registry := NewPoolRegistry()
+	defer registry.Close()
+
+	msgs := make([]*Mail, 6)
+	for i := range msgs {
+		msgs[i] = (&Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Host:    host,
+			Port:    port,
+			User:    "user",
+			Pass:    "pass",
+			Subject: "Test Subject",
+			Content: "Test Content",
+			To:      []string{"recipient@example.com"},
+		}).SetPoolSize(1).SetPoolRegistry(registry)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	failed := 0
+	for _, result := range SendAll(ctx, msgs, 2) {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed != len(msgs) {
+		t.Errorf("%d sends failed, want all %d to fail on the stalled server", failed, len(msgs))
+	}
+	// Only two sends may have been in flight, so at most two connections were dialed
+	if got := server.connectionCount(); got > 2 {
+		t.Errorf("server accepted %d connections, want at most 2", got)
+	}
+}
+
+func TestSendMany(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	newMail := func(to ...string) *Mail {
+		return (&Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Host:    host,
+			Port:    port,
+			User:    "user",
+			Pass:    "pass",
+			Subject: "Daily report",
+			Content: "Test Content",
+			To:      to,
+		}).SetPoolSize(1)
+	}
+
+	report, err := SendMany(context.Background(), newMail("a@example.com"), newMail("b@example.com"))
+	if err != nil {
+		t.Fatalf("SendMany() error = %v", err)
+	}
+	if report.Total != 2 || report.Sent != 2 || report.Failed != 0 {
+		t.Errorf("SendMany() report = %+v", report)
+	}
+	if report.Started.IsZero() || report.Duration <= 0 || report.Results[0].Duration <= 0 {
+		t.Error("SendMany() report is missing timing information")
+	}
+
+	report, err = SendMany(context.Background(), newMail("a@example.com"), newMail("invalid"))
+	if err == nil {
+		t.Fatal("SendMany() with an invalid message should return an error")
+	}
+	if report.Sent != 1 || report.Failed != 1 {
+		t.Errorf("SendMany() report sent=%d failed=%d, want 1 and 1", report.Sent, report.Failed)
+	}
+}
+
+func TestSendToAudience(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	newMail := func(to ...string) *Mail {
+		return (&Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Host:    host,
+			Port:    port,
+			User:    "user",
+			Pass:    "pass",
+			Subject: "Newsletter",
+			Content: "Test Content",
+			To:      to,
+		}).SetPoolSize(1)
+	}
+	audience := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"}
+
+	t.Run("batches", func(t *testing.T) {
+		msg := newMail("list@example.com").SetRateLimit(&RateLimit{Enabled: true, PerSecond: 10})
+		defer msg.SetRateLimit(nil)
+
+		start := time.Now()
+		report, err := SendToAudience(context.Background(), msg, audience, 2)
+		if err != nil {
+			t.Fatalf("SendToAudience() error = %v", err)
+		}
+		// Every batch waits for the rate limiter: 3 batches at 10 per second
+		if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+			t.Errorf("SendToAudience() took %v, expected the rate limit to space the batches", elapsed)
+		}
+		if report.Total != 5 || report.Sent != 5 || report.Failed != 0 || len(report.Batches) != 3 {
+			t.Errorf("SendToAudience() report = %+v", report)
+		}
+		if report.Started.IsZero() || report.Duration <= 0 {
+			t.Error("SendToAudience() report is missing timing information")
+		}
+		if len(msg.Bcc) != 0 {
+			t.Error("SendToAudience() should not modify the message")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		messages := server.getMessages()
+		if len(messages) != 3 {
+			t.Fatalf("server received %d messages, want 3", len(messages))
+		}
+		wantRcpts := []int{3, 2, 1}
+		for i, message := range messages {
+			if got := strings.Count(message, "RCPT TO"); got != wantRcpts[i] {
+				t.Errorf("batch %d has %d recipients, want %d", i, got, wantRcpts[i])
+			}
+			if !strings.Contains(message, "To: list@example.com\r\n") {
+				t.Errorf("batch %d is missing the To header", i)
+			}
+			if strings.Contains(message, "Bcc:") {
+				t.Errorf("batch %d discloses its Bcc recipients", i)
+			}
+		}
+		if !strings.Contains(messages[0], "RCPT TO:<list@example.com>") || strings.Contains(messages[1], "RCPT TO:<list@example.com>") {
+			t.Error("the To recipient should receive only the first batch")
+		}
+	})
+
+	t.Run("failed batch", func(t *testing.T) {
+		report, err := SendToAudience(context.Background(), newMail(), []string{"a@example.com", "invalid", "c@example.com"}, 2)
+		if err == nil {
+			t.Fatal("SendToAudience() with an invalid address should return an error")
+		}
+		if report.Sent != 1 || report.Failed != 2 {
+			t.Errorf("SendToAudience() report sent=%d failed=%d, want 1 and 2", report.Sent, report.Failed)
+		}
+		if report.Batches[0].Err == nil || report.Batches[1].Err != nil {
+			t.Errorf("SendToAudience() batch errors = %v, %v", report.Batches[0].Err, report.Batches[1].Err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		report, err := SendToAudience(ctx, newMail(), audience, 2)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("SendToAudience() error = %v, want context.Canceled", err)
+		}
+		if report.Failed != 5 || len(report.Batches) != 3 {
+			t.Errorf("SendToAudience() report = %+v", report)
+		}
+	})
+}
+
+func TestSendToAudienceAt(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	clock := newFakeClock() // 2024-05-01 09:00 UTC
+	msg := (&Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Newsletter",
+		Content: "Test Content",
+		To:      []string{"list@example.com"},
+	}).SetPoolSize(1).SetClock(clock)
+
+	istanbul := time.FixedZone("TRT", 3*60*60)
+	newYork := time.FixedZone("EDT", -4*60*60)
+	recipients := []LocalRecipient{
+		{Address: "istanbul@example.com", Location: istanbul}, // 10:00 TRT has passed: 07:00 UTC tomorrow
+		{Address: "new-york@example.com", Location: newYork},  // 14:00 UTC
+		{Address: "london@example.com"},                       // 10:00 UTC
+		{Address: "utc@example.com", Location: time.UTC},      // 10:00 UTC
+	}
+
+	type result struct {
+		report AudienceReport
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		report, err := SendToAudienceAt(context.Background(), msg, recipients, 10*time.Hour, 0)
+		done <- result{report, err}
+	}()
+
+	wantMessages := []struct {
+		advance time.Duration
+		rcpts   []string
+	}{
+		{time.Hour, []string{"list@example.com", "london@example.com", "utc@example.com"}},
+		{4 * time.Hour, []string{"new-york@example.com"}},
+		{17 * time.Hour, []string{"istanbul@example.com"}},
+	}
+	for i, want := range wantMessages {
+		waitPending(t, clock, 1)
+		clock.Advance(want.advance - time.Minute)
+		time.Sleep(50 * time.Millisecond)
+		if got := len(server.getMessages()); got != i {
+			t.Fatalf("server received %d messages before delivery %d is due", got, i)
+		}
+		clock.Advance(time.Minute)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for len(server.getMessages()) <= i && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		messages := server.getMessages()
+		if len(messages) <= i {
+			t.Fatalf("delivery %d was not sent when due", i)
+		}
+		if got := strings.Count(messages[i], "RCPT TO"); got != len(want.rcpts) {
+			t.Errorf("delivery %d has %d recipients, want %d", i, got, len(want.rcpts))
+		}
+		for _, rcpt := range want.rcpts {
+			if !strings.Contains(messages[i], "RCPT TO:<"+rcpt+">") {
+				t.Errorf("delivery %d is missing recipient %s", i, rcpt)
+			}
+		}
+	}
+
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("SendToAudienceAt() error = %v", r.err)
+	}
+	if r.report.Total != 4 || r.report.Sent != 4 || len(r.report.Batches) != 3 {
+		t.Errorf("SendToAudienceAt() report = %+v", r.report)
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		clock.Advance(time.Minute) // past 10:00 in Istanbul, so nobody is due
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan result, 1)
+		go func() {
+			report, err := SendToAudienceAt(ctx, msg, recipients, 10*time.Hour, 0)
+			done <- result{report, err}
+		}()
+		waitPending(t, clock, 1)
+		cancel()
+
+		r := <-done
+		if !errors.Is(r.err, context.Canceled) {
+			t.Errorf("SendToAudienceAt() error = %v, want context.Canceled", r.err)
+		}
+		if r.report.Failed != 4 {
+			t.Errorf("SendToAudienceAt() report = %+v", r.report)
+		}
+	})
+}
+
+func TestNextLocalTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		loc  *time.Location
+		at   time.Duration
+		want time.Time
+	}{
+		{"later today", nil, 10 * time.Hour, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{"now", time.UTC, 9 * time.Hour, now},
+		{"passed", time.UTC, 8*time.Hour + 30*time.Minute, time.Date(2024, 5, 2, 8, 30, 0, 0, time.UTC)},
+		{"ahead of UTC", time.FixedZone("JST", 9*60*60), 9 * time.Hour, time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+		{"behind UTC", time.FixedZone("PDT", -7*60*60), 9 * time.Hour, time.Date(2024, 5, 1, 16, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextLocalTime(now, tt.loc, tt.at); !got.Equal(tt.want) {
+				t.Errorf("nextLocalTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextLocalTimeDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"clocks go forward", time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC), time.Date(2024, 3, 10, 13, 0, 0, 0, time.UTC)},
+		{"clocks go back", time.Date(2024, 11, 3, 4, 0, 0, 0, time.UTC), time.Date(2024, 11, 3, 14, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextLocalTime(tt.now, loc, 9*time.Hour)
+			if !got.Equal(tt.want) {
+				t.Errorf("nextLocalTime() = %v, want %v", got, tt.want)
+			}
+			if got.In(loc).Hour() != 9 {
+				t.Errorf("nextLocalTime() = %v, want 09:00 local time", got.In(loc))
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package gomail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ReturnPathStrategy computes the envelope sender (Return-Path) of each email when
+// it is sent, after its Message-ID is assigned. An empty address keeps the
+// envelope sender of m, which defaults to From.
+type ReturnPathStrategy interface {
+	ReturnPath(ctx context.Context, m *Mail, messageID string) (string, error)
+}
+
+// VERPReturnPath is a ReturnPathStrategy that gives every email its own bounce
+// address, <Prefix>+<Message-ID>@<Domain> with the @ of the Message-ID replaced
+// by =, so that a bounce identifies the email it is for. Prefix defaults to
+// "bounce". Record, when set, is called with the bounce address and Message-ID of
+// each email to store the mapping, e.g. in the delivery database; an error stops
+// the send.
+type VERPReturnPath struct {
+	Prefix string
+	Domain string
+	Record func(ctx context.Context, address, messageID string) error
+}
+
+// ReturnPath implements ReturnPathStrategy
+func (v *VERPReturnPath) ReturnPath(ctx context.Context, m *Mail, messageID string) (string, error) {
+	prefix := v.Prefix
+	if prefix == "" {
+		prefix = "bounce"
+	}
+	address := prefix + "+" + strings.ReplaceAll(messageID, "@", "=") + "@" + v.Domain
+	if v.Record != nil {
+		if err := v.Record(ctx, address, messageID); err != nil {
+			return "", err
+		}
+	}
+	return address, nil
+}
+
+// SetReturnPathStrategy computes the envelope sender of every email with strategy,
+// taking precedence over SetEnvelopeFrom and the envelope sender of a Sender or Identity
+func (m *Mail) SetReturnPathStrategy(strategy ReturnPathStrategy) *Mail {
+	m.returnPath = strategy
+	return m
+}
+
+// applyReturnPath sets the envelope sender computed by strategy for a send under
+// ctx, and returns the function that restores the previous envelope sender
+func (m *Mail) applyReturnPath(ctx context.Context, strategy ReturnPathStrategy) (func(), error) {
+	if strategy == nil {
+		return func() {}, nil
+	}
+	address, err := strategy.ReturnPath(ctx, m, m.messageID)
+	if err != nil {
+		return nil, fmt.Errorf("error computing return path: %w", err)
+	}
+	if address == "" {
+		return func() {}, nil
+	}
+	if hasLineBreak(address) {
+		return nil, fmt.Errorf("invalid return path %q", address)
+	}
+
+	previous := m.envelopeFrom
+	m.envelopeFrom = address
+	return func() { m.envelopeFrom = previous }, nil
+}
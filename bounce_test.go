@@ -0,0 +1,94 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReturnPathStrategy(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	host, port, _ := net.SplitHostPort(server.addr())
+
+	recorded := map[string]string{}
+	errStore := errors.New("store unavailable")
+	tests := []struct {
+		name     string
+		strategy ReturnPathStrategy
+		want     string
+		wantErr  error
+	}{
+		{
+			name: "VERP",
+			strategy: &VERPReturnPath{
+				Domain: "bounces.example.com",
+				Record: func(ctx context.Context, address, messageID string) error {
+					recorded[address] = messageID
+					return nil
+				},
+			},
+			want: "bounce+123.abc=example.com@bounces.example.com",
+		},
+		{
+			name:     "custom prefix",
+			strategy: &VERPReturnPath{Prefix: "b", Domain: "bounces.example.com"},
+			want:     "b+123.abc=example.com@bounces.example.com",
+		},
+		{
+			name: "record fails",
+			strategy: &VERPReturnPath{
+				Domain: "bounces.example.com",
+				Record: func(ctx context.Context, address, messageID string) error { return errStore },
+			},
+			wantErr: errStore,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "pass",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+			}
+			m.SetMessageID("123.abc@example.com").SetEnvelopeFrom("owner@example.com").SetReturnPathStrategy(tt.strategy)
+
+			before := len(server.getMessages())
+			err := m.Send()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Send() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(server.getMessages()) != before {
+					t.Error("email was sent although the return path failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			messages := server.getMessages()
+			if len(messages) != before+1 || !strings.Contains(messages[before], "MAIL FROM:<"+tt.want+">") {
+				t.Errorf("email not sent from %s", tt.want)
+			}
+			// The envelope sender of m is left as it was
+			if got := m.getEnvelopeFrom(); got != "owner@example.com" {
+				t.Errorf("envelope sender after the send = %q", got)
+			}
+		})
+	}
+
+	if got := recorded["bounce+123.abc=example.com@bounces.example.com"]; got != "123.abc@example.com" {
+		t.Errorf("recorded Message-ID = %q, want 123.abc@example.com", got)
+	}
+}
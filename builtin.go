@@ -0,0 +1,25 @@
+package gomail
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// builtinTemplates holds the built-in transactional templates and their sample data
+//
+//go:embed templates/*.html templates/*.sample.json
+var builtinTemplates embed.FS
+
+// UseBuiltin makes the engine render the built-in transactional templates:
+// welcome, password_reset, verification_code and invoice. The data each one
+// expects is documented by its sample data, see SampleData.
+func (e *TemplateEngine) UseBuiltin() *TemplateEngine {
+	templates, err := fs.Sub(builtinTemplates, "templates")
+	if err != nil {
+		panic(err)
+	}
+	e.FS = templates
+	e.BaseDir = "."
+	e.DefaultExt = ".html"
+	return e
+}
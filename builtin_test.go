@@ -0,0 +1,56 @@
+package gomail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltinTemplates(t *testing.T) {
+	engine := (&TemplateEngine{}).UseBuiltin()
+	if err := engine.LintTemplates(); err != nil {
+		t.Fatalf("LintTemplates() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"welcome", []string{"Welcome to Example App, Ada!", "https://app.example.com/start"}},
+		{"password_reset", []string{"https://app.example.com/reset?token=3f9c2b", "30 minutes"}},
+		{"verification_code", []string{"482913", "10 minutes"}},
+		{"invoice", []string{"INV-2024-0042", "Extra seats", "44.00 USD"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := engine.LintTemplate(tt.name); err != nil {
+				t.Fatalf("LintTemplate() error = %v", err)
+			}
+			m := (&Mail{}).SetTemplateEngine(engine)
+			if err := m.RenderTemplateSample(tt.name); err != nil {
+				t.Fatalf("RenderTemplateSample() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(m.Content, want) {
+					t.Errorf("content missing %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuiltinTemplatesEscapeData(t *testing.T) {
+	m := (&Mail{}).SetTemplateEngine((&TemplateEngine{}).UseBuiltin())
+	err := m.RenderTemplate("verification_code", map[string]any{
+		"Name":        "<script>alert(1)</script>",
+		"ProductName": "Example App",
+		"Code":        "123456",
+		"ExpiresIn":   "10 minutes",
+	})
+	if err != nil {
+		t.Fatalf("RenderTemplate() error = %v", err)
+	}
+	if strings.Contains(m.Content, "<script>") {
+		t.Error("template data should be HTML escaped")
+	}
+}
@@ -0,0 +1,162 @@
+package gomail
+
+import (
+	"mime/multipart"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// iTIP (RFC 5546) methods of the calendar part
+const (
+	calendarRequest = "REQUEST"
+	calendarCancel  = "CANCEL"
+)
+
+// calendarTimeFormat is the UTC date-time format of iCalendar
+const calendarTimeFormat = "20060102T150405Z"
+
+// calendarPart is the calendar event carried by an email
+type calendarPart struct {
+	method string
+	event  CalendarEvent
+}
+
+// SetCalendarEvent attaches an invitation to event. A missing UID is generated and
+// stored in event, so later updates and cancellations refer to the same event.
+func (m *Mail) SetCalendarEvent(event *CalendarEvent) *Mail {
+	if event.UID == "" {
+		event.UID = newMessageID(m.From)
+	}
+	m.calendar = &calendarPart{method: calendarRequest, event: *event}
+	return m
+}
+
+// UpdateCalendarEvent attaches an update of the event previously sent with the same
+// UID. The sequence of event is incremented so calendar clients replace the original.
+func (m *Mail) UpdateCalendarEvent(event *CalendarEvent) *Mail {
+	event.Sequence++
+	m.calendar = &calendarPart{method: calendarRequest, event: *event}
+	return m
+}
+
+// CancelCalendarEvent attaches a cancellation of the event previously sent with the
+// same UID. The sequence of event is incremented so calendar clients accept it.
+func (m *Mail) CancelCalendarEvent(event *CalendarEvent) *Mail {
+	event.Sequence++
+	m.calendar = &calendarPart{method: calendarCancel, event: *event}
+	return m
+}
+
+// writeCalendarPart writes the calendar event as a text/calendar part
+func (m *Mail) writeCalendarPart(writer *multipart.Writer) error {
+	content := []byte(m.calendarContent(time.Now()))
+	encoding, err := chooseTransferEncoding(m.getTransferEncoding(), content)
+	if err != nil {
+		return err
+	}
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              []string{"text/calendar; charset=UTF-8; method=" + m.calendar.method},
+		"Content-Transfer-Encoding": []string{string(encoding)},
+	})
+	if err != nil {
+		return err
+	}
+	return writeTextPart(part, encoding, content)
+}
+
+// calendarContent returns the iCalendar object of the calendar event, stamped at now
+func (m *Mail) calendarContent(now time.Time) string {
+	event := m.calendar.event
+	var b strings.Builder
+	line := func(name, value string) {
+		writeCalendarLine(&b, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("PRODID", "-//mstgnz//gomail//EN")
+	line("VERSION", "2.0")
+	line("METHOD", m.calendar.method)
+	line("BEGIN", "VEVENT")
+	line("UID", escapeCalendarText(event.UID))
+	line("SEQUENCE", strconv.Itoa(event.Sequence))
+	line("DTSTAMP", now.UTC().Format(calendarTimeFormat))
+	if !event.Start.IsZero() {
+		line("DTSTART", event.Start.UTC().Format(calendarTimeFormat))
+	}
+	if !event.End.IsZero() {
+		line("DTEND", event.End.UTC().Format(calendarTimeFormat))
+	}
+	if event.Summary != "" {
+		line("SUMMARY", escapeCalendarText(event.Summary))
+	}
+	if event.Description != "" {
+		line("DESCRIPTION", escapeCalendarText(event.Description))
+	}
+	if event.Location != "" {
+		line("LOCATION", escapeCalendarText(event.Location))
+	}
+
+	organizer := event.Organizer
+	if organizer == "" {
+		organizer = m.From
+	}
+	if m.Name != "" && organizer == m.From {
+		writeCalendarLine(&b, "ORGANIZER;CN="+calendarParam(m.Name)+":mailto:"+organizer)
+	} else {
+		line("ORGANIZER", "mailto:"+organizer)
+	}
+
+	attendees := event.Attendees
+	if len(attendees) == 0 {
+		attendees = append(append([]string{}, m.To...), m.Cc...)
+	}
+	for _, attendee := range attendees {
+		if m.calendar.method == calendarCancel {
+			line("ATTENDEE", "mailto:"+attendee)
+		} else {
+			writeCalendarLine(&b, "ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:"+attendee)
+		}
+	}
+
+	if m.calendar.method == calendarCancel {
+		line("STATUS", "CANCELLED")
+	} else {
+		line("STATUS", "CONFIRMED")
+	}
+	line("END", "VEVENT")
+	line("END", "VCALENDAR")
+	return b.String()
+}
+
+// calendarTextEscaper escapes the characters that are special in iCalendar text values
+var calendarTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escapeCalendarText escapes s for use as an iCalendar text value
+func escapeCalendarText(s string) string {
+	return calendarTextEscaper.Replace(s)
+}
+
+// calendarParam quotes s for use as an iCalendar parameter value
+func calendarParam(s string) string {
+	return `"` + strings.NewReplacer(`"`, "", "\r", " ", "\n", " ").Replace(s) + `"`
+}
+
+// writeCalendarLine writes a content line, folded at 75 octets without splitting
+// UTF-8 sequences, followed by CRLF
+func writeCalendarLine(b *strings.Builder, line string) {
+	const maxLength = 75
+	for limit := maxLength; len(line) > limit; limit = maxLength - 1 {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
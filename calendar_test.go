@@ -0,0 +1,175 @@
+package gomail
+
+import (
+	"bytes"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarEvent(t *testing.T) {
+	newMail := func() *Mail {
+		return &Mail{
+			From:    "organizer@example.com",
+			Name:    "Organizer",
+			Subject: "Planning",
+			Content: "<p>Planning meeting</p>",
+			To:      []string{"alice@example.com"},
+			Cc:      []string{"bob@example.com"},
+		}
+	}
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	event := &CalendarEvent{
+		Summary:  "Planning, Q3",
+		Location: "Room 1",
+		Start:    start,
+		End:      start.Add(time.Hour),
+	}
+
+	invite := newMail().SetCalendarEvent(event)
+	if event.UID == "" {
+		t.Fatal("SetCalendarEvent() should assign a UID")
+	}
+	uid := event.UID
+	update := newMail().UpdateCalendarEvent(event)
+	cancel := newMail().CancelCalendarEvent(event)
+	if event.UID != uid {
+		t.Errorf("UID changed from %s to %s", uid, event.UID)
+	}
+
+	tests := []struct {
+		name    string
+		mail    *Mail
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "invite",
+			mail: invite,
+			want: []string{
+				"Content-Type: text/calendar; charset=UTF-8; method=REQUEST",
+				"METHOD:REQUEST\r\n",
+				"UID:" + uid + "\r\n",
+				"SEQUENCE:0\r\n",
+				"DTSTART:20240501T090000Z\r\n",
+				"DTEND:20240501T100000Z\r\n",
+				`SUMMARY:Planning\, Q3` + "\r\n",
+				`ORGANIZER;CN="Organizer":mailto:organizer@example.com` + "\r\n",
+				"RSVP=TRUE:mailto:alice@example.com\r\n",
+				"RSVP=TRUE:mailto:bob@example.com\r\n",
+				"STATUS:CONFIRMED\r\n",
+			},
+		},
+		{
+			name:    "update",
+			mail:    update,
+			want:    []string{"METHOD:REQUEST\r\n", "UID:" + uid + "\r\n", "SEQUENCE:1\r\n"},
+			notWant: []string{"SEQUENCE:0\r\n"},
+		},
+		{
+			name: "cancel",
+			mail: cancel,
+			want: []string{
+				"Content-Type: text/calendar; charset=UTF-8; method=CANCEL",
+				"METHOD:CANCEL\r\n",
+				"UID:" + uid + "\r\n",
+				"SEQUENCE:2\r\n",
+				"ATTENDEE:mailto:alice@example.com\r\n",
+				"STATUS:CANCELLED\r\n",
+			},
+			notWant: []string{"RSVP=TRUE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.mail.validateMessage() {
+				t.Fatal("validateMessage() = false, want true")
+			}
+			var buf bytes.Buffer
+			if err := tt.mail.writeMessage(&buf); err != nil {
+				t.Fatalf("writeMessage() error = %v", err)
+			}
+			// Unfold the content lines of the calendar part
+			output := strings.ReplaceAll(buf.String(), "\r\n ", "")
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("message missing %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(output, notWant) {
+					t.Errorf("message should not contain %q", notWant)
+				}
+			}
+
+			msg, err := mail.ReadMessage(&buf)
+			if err != nil {
+				t.Fatalf("failed to parse message: %v", err)
+			}
+			want := "multipart/mixed(multipart/alternative(text/html,text/calendar))"
+			if got := mimeTree(t, msg.Header.Get("Content-Type"), msg.Body); got != want {
+				t.Errorf("MIME structure = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCalendarEventValidation(t *testing.T) {
+	m := &Mail{
+		From:    "organizer@example.com",
+		Name:    "Organizer",
+		Subject: "Planning",
+		Content: "Planning meeting",
+		To:      []string{"alice@example.com"},
+	}
+	if m.UpdateCalendarEvent(&CalendarEvent{}).validateMessage() {
+		t.Error("update of an event without UID should be invalid")
+	}
+	if !m.CancelCalendarEvent(&CalendarEvent{UID: "event@example.com"}).validateMessage() {
+		t.Error("cancellation with UID should be valid")
+	}
+}
+
+func TestPlainTextCalendarEvent(t *testing.T) {
+	m := &Mail{
+		From:        "organizer@example.com",
+		Name:        "Organizer",
+		Subject:     "Planning",
+		Content:     "Planning meeting",
+		To:          []string{"alice@example.com"},
+		ContentType: TextPlain,
+	}
+	m.SetCalendarEvent(&CalendarEvent{Summary: "Planning"})
+
+	var buf bytes.Buffer
+	if err := m.writeMessage(&buf); err != nil {
+		t.Fatalf("writeMessage() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "text/calendar") {
+		t.Error("plain text invitation should carry the calendar part")
+	}
+}
+
+func TestWriteCalendarLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"short", "SUMMARY:Planning", "SUMMARY:Planning\r\n"},
+		{"long", "DESCRIPTION:" + strings.Repeat("a", 70), "DESCRIPTION:" + strings.Repeat("a", 63) + "\r\n " + strings.Repeat("a", 7) + "\r\n"},
+		{"multibyte", "SUMMARY:" + strings.Repeat("a", 66) + "ü", "SUMMARY:" + strings.Repeat("a", 66) + "\r\n ü\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeCalendarLine(&b, tt.line)
+			if got := b.String(); got != tt.want {
+				t.Errorf("writeCalendarLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
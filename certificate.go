@@ -0,0 +1,51 @@
+package gomail
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"time"
+)
+
+// certificate returns the client certificate loaded from CertFile and KeyFile.
+// It is loaded once, or again whenever either file has been modified since
+// the last load when ReloadCertificate is set.
+func (c *TLSConfig) certificate() (*tls.Certificate, error) {
+	c.certMu.Lock()
+	defer c.certMu.Unlock()
+
+	if c.cert != nil && !c.ReloadCertificate {
+		return c.cert, nil
+	}
+
+	modTime, err := latestModTime(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading client certificate: %w", err)
+	}
+	if c.cert != nil && !modTime.After(c.certModTime) {
+		return c.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading client certificate: %w", err)
+	}
+	c.cert = &cert
+	c.certModTime = modTime
+	return c.cert, nil
+}
+
+// latestModTime returns the most recent modification time of the given files
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,109 @@
+package gomail
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate for commonName and its key as PEM files
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commonName returns the subject common name of the client certificate in config
+func commonName(t *testing.T, config *TLSConfig) string {
+	t.Helper()
+	tlsConfig, err := config.clientConfig("smtp.example.com")
+	if err != nil {
+		t.Fatalf("clientConfig() error = %v", err)
+	}
+	if len(tlsConfig.Certificates) != 1 {
+		t.Fatalf("got %d certificates, want 1", len(tlsConfig.Certificates))
+	}
+	leaf, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestClientCertificateFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		reload bool
+		want   string
+	}{
+		{"loaded once", false, "first"},
+		{"reloaded on change", true, "second"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certFile := filepath.Join(dir, "client.pem")
+			keyFile := filepath.Join(dir, "client.key")
+			writeCertificate(t, certFile, keyFile, "first")
+
+			config := &TLSConfig{CertFile: certFile, KeyFile: keyFile, ReloadCertificate: tt.reload}
+			if got := commonName(t, config); got != "first" {
+				t.Fatalf("certificate = %q, want first", got)
+			}
+
+			writeCertificate(t, certFile, keyFile, "second")
+			later := time.Now().Add(time.Minute)
+			for _, file := range []string{certFile, keyFile} {
+				if err := os.Chtimes(file, later, later); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := commonName(t, config); got != tt.want {
+				t.Errorf("certificate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientCertificateFilesMissing(t *testing.T) {
+	dir := t.TempDir()
+	config := &TLSConfig{
+		CertFile: filepath.Join(dir, "missing.pem"),
+		KeyFile:  filepath.Join(dir, "missing.key"),
+	}
+	if _, err := config.clientConfig("smtp.example.com"); err == nil {
+		t.Error("clientConfig() with missing certificate files should fail")
+	}
+}
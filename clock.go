@@ -0,0 +1,66 @@
+package gomail
+
+import "time"
+
+// Clock is the source of time of the rate limiter, the retry backoff and the
+// failover cooldown. The default clock is the system clock; tests can set one
+// that is advanced by hand instead of waiting.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks at intervals like time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SetClock sets the clock of the email. Set it before SetRateLimit, which
+// starts the rate limiter on the clock.
+func (m *Mail) SetClock(clock Clock) *Mail {
+	m.clock = clock
+	return m
+}
+
+// getClock returns the clock of the email, the system clock by default
+func (m *Mail) getClock() Clock {
+	if m.clock == nil {
+		return systemClock{}
+	}
+	return m.clock
+}
+
+// systemClock is the Clock of the time package
+type systemClock struct{}
+
+// Now implements Clock
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After implements Clock
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// NewTicker implements Clock
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{ticker: time.NewTicker(d)}
+}
+
+// systemTicker is the Ticker of the time package
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+// C implements Ticker
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+// Stop implements Ticker
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
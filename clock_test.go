@@ -0,0 +1,133 @@
+package gomail
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when advanced
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending After channel or a ticker of a fakeClock
+type fakeWaiter struct {
+	clock    *fakeClock
+	at       time.Time
+	interval time.Duration
+	c        chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.wait(d, 0).c
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	return c.wait(d, d)
+}
+
+func (c *fakeClock) wait(d, interval time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{clock: c, at: c.now.Add(d), interval: interval, c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers due.
+// Like time.Ticker, a ticker drops ticks its reader is not keeping up with.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		for !w.at.After(c.now) {
+			select {
+			case w.c <- w.at:
+			default:
+			}
+			if w.interval == 0 {
+				break
+			}
+			w.at = w.at.Add(w.interval)
+		}
+		if w.interval > 0 || w.at.After(c.now) {
+			pending = append(pending, w)
+		}
+	}
+	c.waiters = pending
+}
+
+// pending returns the number of pending timers and tickers
+func (c *fakeClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// waitPending waits until clock has n pending timers and tickers
+func waitPending(t *testing.T, clock *fakeClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.pending() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("clock has %d pending timers, want %d", clock.pending(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *fakeWaiter) Stop() {
+	c := w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, waiter := range c.waiters {
+		if waiter == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	after := clock.After(time.Second)
+	ticker := clock.NewTicker(300 * time.Millisecond)
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-after:
+		t.Fatal("After() fired early")
+	default:
+	}
+	if _, ok := <-ticker.C(); !ok {
+		t.Fatal("ticker did not tick")
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	<-after
+	<-ticker.C()
+	ticker.Stop()
+	if clock.pending() != 0 {
+		t.Errorf("pending() = %d after all timers fired or stopped, want 0", clock.pending())
+	}
+}
@@ -0,0 +1,164 @@
+package gomail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ErrNotFeedbackReport is returned when parsing a message that is not an ARF feedback report
+var ErrNotFeedbackReport = errors.New("not an ARF feedback report")
+
+// Complaint is a feedback report (RFC 5965 ARF) sent by a mailbox provider when
+// a recipient marks an email as spam, normalized from the report and the
+// original message it carries
+type Complaint struct {
+	// FeedbackType is the kind of feedback, e.g. "abuse", "fraud", "virus" or "not-spam"
+	FeedbackType string
+	// Recipients are the recipients who complained: the Original-Rcpt-To fields
+	// of the report, or the To recipients of the original message when the
+	// provider leaves them out
+	Recipients []string
+	// MailFrom is the envelope sender of the original message, e.g. its VERP address
+	MailFrom string
+	// MessageID is the Message-ID of the original message, without angle brackets
+	MessageID    string
+	Subject      string
+	UserAgent    string
+	ReportingMTA string
+	SourceIP     string
+	ArrivalDate  time.Time
+}
+
+// SuppressionList stores the addresses email must no longer be sent to
+type SuppressionList interface {
+	Suppress(ctx context.Context, address, reason string) error
+}
+
+// ParseComplaint parses the ARF feedback report read from r, e.g. a message
+// fetched from the feedback loop mailbox or the body of a webhook
+func ParseComplaint(r io.Reader) (*Complaint, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "feedback-report") {
+		return nil, ErrNotFeedbackReport
+	}
+
+	complaint := &Complaint{}
+	var report, original textproto.MIMEHeader
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/feedback-report":
+			if report, err = readHeaderPart(part); err != nil {
+				return nil, fmt.Errorf("invalid feedback report: %w", err)
+			}
+		case "message/rfc822", "text/rfc822-headers":
+			// Only the headers of the original message are needed
+			if original, err = readHeaderPart(part); err != nil {
+				return nil, fmt.Errorf("invalid original message: %w", err)
+			}
+		}
+	}
+	if report == nil {
+		return nil, ErrNotFeedbackReport
+	}
+
+	complaint.FeedbackType = strings.ToLower(report.Get("Feedback-Type"))
+	complaint.UserAgent = report.Get("User-Agent")
+	complaint.ReportingMTA = strings.TrimSpace(strings.TrimPrefix(report.Get("Reporting-MTA"), "dns;"))
+	complaint.SourceIP = report.Get("Source-IP")
+	complaint.MailFrom = trimAngles(report.Get("Original-Mail-From"))
+	for _, recipient := range report.Values("Original-Rcpt-To") {
+		complaint.Recipients = append(complaint.Recipients, trimAngles(recipient))
+	}
+	if date := report.Get("Arrival-Date"); date != "" {
+		complaint.ArrivalDate, _ = mail.ParseDate(date)
+	}
+
+	if original != nil {
+		complaint.MessageID = trimAngles(original.Get("Message-ID"))
+		complaint.Subject, _ = new(mime.WordDecoder).DecodeHeader(original.Get("Subject"))
+		if complaint.MailFrom == "" {
+			complaint.MailFrom = trimAngles(original.Get("Return-Path"))
+		}
+		if len(complaint.Recipients) == 0 {
+			if addresses, err := mail.ParseAddressList(original.Get("To")); err == nil {
+				for _, address := range addresses {
+					complaint.Recipients = append(complaint.Recipients, address.Address)
+				}
+			}
+		}
+	}
+	return complaint, nil
+}
+
+// HandleComplaint parses the ARF feedback report read from r like ParseComplaint
+// and adds the recipients who complained to list. Reports of the "not-spam"
+// type suppress nobody.
+func HandleComplaint(ctx context.Context, r io.Reader, list SuppressionList) (*Complaint, error) {
+	complaint, err := ParseComplaint(r)
+	if err != nil {
+		return nil, err
+	}
+	if complaint.FeedbackType == "not-spam" {
+		return complaint, nil
+	}
+
+	reason := "complaint: " + complaint.FeedbackType
+	var errs []error
+	for _, recipient := range complaint.Recipients {
+		if err := list.Suppress(ctx, recipient, reason); err != nil {
+			errs = append(errs, fmt.Errorf("suppressing %s: %w", recipient, err))
+		}
+	}
+	return complaint, errors.Join(errs...)
+}
+
+// readHeaderPart reads the header fields that make up the body of part, such
+// as the fields of a feedback report or the header of an attached message
+func readHeaderPart(part *multipart.Part) (textproto.MIMEHeader, error) {
+	var body io.Reader = part
+	if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+		body = base64.NewDecoder(base64.StdEncoding, part)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// The fields may end without the blank line of a message header
+	data = append(bytes.TrimRight(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")), "\n"), "\n\n"...)
+	data = data[:bytes.Index(data, []byte("\n\n"))+2]
+	header, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(data))).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return header, nil
+}
+
+// trimAngles returns address without surrounding whitespace and angle brackets
+func trimAngles(address string) string {
+	return strings.Trim(strings.TrimSpace(address), "<>")
+}
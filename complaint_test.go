@@ -0,0 +1,152 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// arfReport is a feedback report based on the example of RFC 5965, with the recipient
+// fields given by feedbackFields
+func arfReport(feedbackFields string) string {
+	return strings.ReplaceAll(`From: <abusedesk@example.com>
+Date: Thu, 8 Mar 2005 17:40:36 EDT
+Subject: FW: Earn money
+To: <abuse@example.net>
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report;
+     boundary="part1_13d.2e68ed54_boundary"
+
+--part1_13d.2e68ed54_boundary
+Content-Type: text/plain; charset="US-ASCII"
+Content-Transfer-Encoding: 7bit
+
+This is an email abuse report for an email message received from IP
+192.0.2.1 on Thu, 8 Mar 2005 14:00:00 EDT.  For more information
+about this format please see http://www.mipassoc.org/arf/.
+
+--part1_13d.2e68ed54_boundary
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: SomeGenerator/1.0
+Version: 1
+Original-Mail-From: <bounce+1234=example.net@bounces.example.net>
+`+feedbackFields+`Arrival-Date: Thu, 8 Mar 2005 14:00:00 -0400
+Reporting-MTA: dns; mail.example.com
+Source-IP: 192.0.2.1
+Authentication-Results: mail.example.com;
+               spf=fail smtp.mail=somespammer@example.com
+Reported-Domain: example.net
+
+--part1_13d.2e68ed54_boundary
+Content-Type: message/rfc822
+Content-Disposition: inline
+
+From: <somespammer@example.net>
+Received: from mailserver.example.net (mailserver.example.net
+        [192.0.2.1]) by example.com with ESMTP id M63d4137594e46;
+        Thu, 08 Mar 2005 14:00:00 -0400
+To: Undisclosed Recipients <user@example.com>, <other@example.com>
+Subject: =?utf-8?q?Earn_money_=C5=9Fimdi?=
+MIME-Version: 1.0
+Content-type: text/plain
+Message-ID: <8787KJKJ3K4J3K4J3K4J3.mail@example.net>
+Date: Thu, 02 Sep 2004 12:31:03 -0500
+
+Spam Spam Spam
+Spam Spam Spam
+--part1_13d.2e68ed54_boundary--
+`, "\n", "\r\n")
+}
+
+// memorySuppressionList records the suppressed addresses
+type memorySuppressionList struct {
+	suppressed map[string]string
+	err        error
+}
+
+func (l *memorySuppressionList) Suppress(ctx context.Context, address, reason string) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.suppressed[address] = reason
+	return nil
+}
+
+func TestParseComplaint(t *testing.T) {
+	tests := []struct {
+		name           string
+		fields         string
+		wantRecipients []string
+	}{
+		{
+			name:           "original recipient",
+			fields:         "Original-Rcpt-To: <user@example.com>\n",
+			wantRecipients: []string{"user@example.com"},
+		},
+		{
+			name:           "redacted recipient",
+			wantRecipients: []string{"user@example.com", "other@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseComplaint(strings.NewReader(arfReport(tt.fields)))
+			if err != nil {
+				t.Fatalf("ParseComplaint() error = %v", err)
+			}
+			want := &Complaint{
+				FeedbackType: "abuse",
+				Recipients:   tt.wantRecipients,
+				MailFrom:     "bounce+1234=example.net@bounces.example.net",
+				MessageID:    "8787KJKJ3K4J3K4J3K4J3.mail@example.net",
+				Subject:      "Earn money şimdi",
+				UserAgent:    "SomeGenerator/1.0",
+				ReportingMTA: "mail.example.com",
+				SourceIP:     "192.0.2.1",
+				ArrivalDate:  time.Date(2005, 3, 8, 14, 0, 0, 0, time.FixedZone("EDT", -4*60*60)),
+			}
+			if !got.ArrivalDate.Equal(want.ArrivalDate) {
+				t.Errorf("ArrivalDate = %v, want %v", got.ArrivalDate, want.ArrivalDate)
+			}
+			got.ArrivalDate = want.ArrivalDate
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseComplaint() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	t.Run("not a report", func(t *testing.T) {
+		_, err := ParseComplaint(strings.NewReader("Subject: Hello\r\nContent-Type: text/plain\r\n\r\nHi"))
+		if !errors.Is(err, ErrNotFeedbackReport) {
+			t.Errorf("ParseComplaint() error = %v, want ErrNotFeedbackReport", err)
+		}
+	})
+}
+
+func TestHandleComplaint(t *testing.T) {
+	list := &memorySuppressionList{suppressed: make(map[string]string)}
+	report := arfReport("Original-Rcpt-To: user@example.com\n")
+	if _, err := HandleComplaint(context.Background(), strings.NewReader(report), list); err != nil {
+		t.Fatalf("HandleComplaint() error = %v", err)
+	}
+	if want := map[string]string{"user@example.com": "complaint: abuse"}; !reflect.DeepEqual(list.suppressed, want) {
+		t.Errorf("suppressed = %v, want %v", list.suppressed, want)
+	}
+
+	notSpam := strings.Replace(report, "Feedback-Type: abuse", "Feedback-Type: not-spam", 1)
+	list.suppressed = make(map[string]string)
+	if _, err := HandleComplaint(context.Background(), strings.NewReader(notSpam), list); err != nil || len(list.suppressed) != 0 {
+		t.Errorf("HandleComplaint() of a not-spam report suppressed %v, error = %v", list.suppressed, err)
+	}
+
+	list.err = errors.New("database down")
+	if _, err := HandleComplaint(context.Background(), strings.NewReader(report), list); !errors.Is(err, list.err) {
+		t.Errorf("HandleComplaint() error = %v, want the suppression list error", err)
+	}
+}
@@ -0,0 +1,68 @@
+package gomail
+
+import "context"
+
+// UpdateConfig replaces the server, credentials, TLS configuration and rate limit
+// of m while it may be sending, for instance to move to another SMTP provider on
+// a configuration reload. The settings are swapped together, so a send uses
+// either the old or the new ones. The pools are rebuilt as with UpdateCredentials:
+// idle connections are closed right away and connections in use once released.
+// Sends waiting for the old rate limit go ahead.
+func (m *Mail) UpdateConfig(cfg Config) *Mail {
+	var rateLimiter Ticker
+	if interval := cfg.RateLimit.interval(); interval > 0 {
+		rateLimiter = m.getClock().NewTicker(interval)
+	}
+
+	m.configMutex.Lock()
+	oldUser, oldPass, oldLimiter := m.User, m.Pass, m.rateLimiter
+	m.Host, m.Port, m.User, m.Pass = cfg.Host, cfg.Port, cfg.User, cfg.Pass
+	m.tlsConfig = cfg.TLS
+	m.rateLimiter = rateLimiter
+	if m.configChanged != nil {
+		close(m.configChanged)
+		m.configChanged = nil
+	}
+	m.configMutex.Unlock()
+
+	if oldLimiter != nil {
+		oldLimiter.Stop()
+	}
+	m.retirePools(oldUser, oldPass, m.credentials)
+	return m
+}
+
+// getTLSConfig returns the TLS configuration
+func (m *Mail) getTLSConfig() *TLSConfig {
+	m.configMutex.RLock()
+	defer m.configMutex.RUnlock()
+	return m.tlsConfig
+}
+
+// getConfigChanged returns the channel closed when UpdateConfig replaces the
+// rate limiter, creating it when m is rate limited. m.configMutex must be held.
+func (m *Mail) getConfigChanged() chan struct{} {
+	if m.rateLimiter != nil && m.configChanged == nil {
+		m.configChanged = make(chan struct{})
+	}
+	return m.configChanged
+}
+
+// waitRateLimit waits under ctx until the rate limiter allows a send
+func (m *Mail) waitRateLimit(ctx context.Context) error {
+	m.configMutex.Lock()
+	rateLimiter := m.rateLimiter
+	changed := m.getConfigChanged()
+	m.configMutex.Unlock()
+
+	if rateLimiter == nil {
+		return nil
+	}
+	select {
+	case <-rateLimiter.C():
+	case <-changed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package gomail
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry *PoolRegistry
+	}{
+		{"own pools", nil},
+		{"pool registry", NewPoolRegistry()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldServer := newMockSMTPServer(t)
+			defer oldServer.close()
+			newServer := newMockSMTPServer(t)
+			defer newServer.close()
+
+			host, port, _ := net.SplitHostPort(oldServer.addr())
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "old",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+			}
+			m.SetPoolSize(1).SetCommandTimeouts(&CommandTimeouts{Quit: time.Second})
+			if tt.registry != nil {
+				m.SetPoolRegistry(tt.registry)
+				defer tt.registry.Close()
+			}
+			if err := m.Send(); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			host, port, _ = net.SplitHostPort(newServer.addr())
+			m.UpdateConfig(Config{
+				Host:      host,
+				Port:      port,
+				User:      "other",
+				Pass:      "new",
+				RateLimit: &RateLimit{Enabled: true, PerSecond: 100},
+			})
+			// The idle connection to the old server is closed
+			waitForQuits(t, oldServer, 1)
+
+			if err := m.Send(); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if got := len(oldServer.getMessages()); got != 1 {
+				t.Errorf("old server received %d messages, want 1", got)
+			}
+			messages := newServer.getMessages()
+			auth := base64.StdEncoding.EncodeToString([]byte("\x00other\x00new"))
+			if len(messages) != 1 || !strings.Contains(messages[0], "AUTH PLAIN "+auth) {
+				t.Error("send after the update did not reach the new server with the new credentials")
+			}
+			if m.rateLimiter == nil {
+				t.Error("rateLimiter should be set by the update")
+			}
+		})
+	}
+}
+
+func TestUpdateConfigReleasesRateLimitedSends(t *testing.T) {
+	clock := newFakeClock()
+	m := (&Mail{}).SetClock(clock).SetRateLimit(&RateLimit{Enabled: true, PerSecond: 1})
+
+	// Take the first send's slot so the clone has to wait
+	clock.Advance(time.Second)
+	if err := m.waitRateLimit(context.Background()); err != nil {
+		t.Fatalf("waitRateLimit() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	c := m.clone()
+	go func() { done <- c.waitRateLimit(context.Background()) }()
+	select {
+	case <-done:
+		t.Fatal("send went ahead before the rate limit allowed it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	m.UpdateConfig(Config{Host: "smtp.example.com", Port: "587"})
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("waitRateLimit() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("send waiting for the old rate limit was not released")
+	}
+	if m.rateLimiter != nil {
+		t.Error("rateLimiter should be removed by the update")
+	}
+	if got := m.endpoint(); got != (Endpoint{Host: "smtp.example.com", Port: "587"}) {
+		t.Errorf("endpoint() = %v", got)
+	}
+}
@@ -0,0 +1,94 @@
+package gomail
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+)
+
+// CustomPart is a MIME part composed by the caller, such as a proprietary XML
+// payload for B2B document exchange, appended to the message after the
+// attachments. Header is written as given and must have a Content-Type. The body
+// is Body or, when set, what WriteBody writes, which is called every time the
+// message is written. It is encoded per the Content-Transfer-Encoding of
+// Header: base64 or quoted-printable, and written as is for 7bit, 8bit, binary
+// or no encoding.
+type CustomPart struct {
+	Header    textproto.MIMEHeader
+	Body      []byte
+	WriteBody func(w io.Writer) error
+}
+
+// SetCustomPart adds custom MIME parts to the email. Custom parts are not
+// attachments: attachment policies and content scanners do not see them, and
+// the SendGrid, Mailgun and Microsoft Graph transports cannot send them.
+func (m *Mail) SetCustomPart(parts ...CustomPart) *Mail {
+	m.customParts = append(m.customParts, parts...)
+	return m
+}
+
+// errCustomPartsUnsupported is returned by the delivery API transports that take
+// attachments rather than the message for an email with custom parts
+var errCustomPartsUnsupported = errors.New("custom MIME parts cannot be sent through a delivery API")
+
+// writePart writes the custom part as a part of writer
+func (p CustomPart) writePart(writer *multipart.Writer) error {
+	if p.Header.Get("Content-Type") == "" {
+		return errors.New("custom part without Content-Type")
+	}
+	header := make(textproto.MIMEHeader, len(p.Header))
+	for key, values := range p.Header {
+		if key == "" || strings.ContainsAny(key, ": \t\r\n") {
+			return fmt.Errorf("invalid custom part header name: %q", key)
+		}
+		for _, value := range values {
+			header.Add(key, sanitizeHeaderValue(value))
+		}
+	}
+
+	encoding := strings.ToLower(header.Get("Content-Transfer-Encoding"))
+	switch encoding {
+	case "", "7bit", "8bit", "binary", "base64", "quoted-printable":
+	default:
+		return fmt.Errorf("unsupported transfer encoding of custom part: %s", encoding)
+	}
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	switch encoding {
+	case "base64":
+		wrapper := &lineWrapper{w: part}
+		encoder := base64.NewEncoder(base64.StdEncoding, wrapper)
+		if err := p.writeBody(encoder); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+		return wrapper.Close()
+	case "quoted-printable":
+		qp := quotedprintable.NewWriter(part)
+		if err := p.writeBody(qp); err != nil {
+			return err
+		}
+		return qp.Close()
+	default:
+		return p.writeBody(part)
+	}
+}
+
+// writeBody writes the unencoded body of the part to w
+func (p CustomPart) writeBody(w io.Writer) error {
+	if p.WriteBody != nil {
+		return p.WriteBody(w)
+	}
+	_, err := w.Write(p.Body)
+	return err
+}
@@ -0,0 +1,196 @@
+package gomail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestCustomPart(t *testing.T) {
+	order := `<?xml version="1.0"?><Order id="42">Müller</Order>`
+
+	tests := []struct {
+		name     string
+		part     CustomPart
+		wantErr  bool
+		wantBody string
+		wantRaw  string
+		wantHead map[string]string
+	}{
+		{
+			name: "caller headers",
+			part: CustomPart{
+				Header: textproto.MIMEHeader{
+					"Content-Type":        {`application/edi-x12+xml; charset=UTF-8`},
+					"Content-Disposition": {`attachment; filename="order.xml"`},
+					"X-Document-Type":     {"PurchaseOrder"},
+				},
+				Body: []byte("<Order/>"),
+			},
+			wantBody: "<Order/>",
+			wantHead: map[string]string{
+				"Content-Type":        "application/edi-x12+xml; charset=UTF-8",
+				"Content-Disposition": `attachment; filename="order.xml"`,
+				"X-Document-Type":     "PurchaseOrder",
+			},
+		},
+		{
+			name: "base64",
+			part: CustomPart{
+				Header: textproto.MIMEHeader{"Content-Type": {"application/xml"}, "Content-Transfer-Encoding": {"base64"}},
+				Body:   []byte(order),
+			},
+			wantBody: order,
+			wantRaw:  "PD94bWwgdmVyc2lvbj0iMS4wIj8+",
+		},
+		{
+			name: "quoted-printable",
+			part: CustomPart{
+				Header: textproto.MIMEHeader{"Content-Type": {"application/xml"}, "Content-Transfer-Encoding": {"Quoted-Printable"}},
+				Body:   []byte(order),
+			},
+			wantBody: order,
+			wantRaw:  "M=C3=BCller",
+		},
+		{
+			name: "body writer",
+			part: CustomPart{
+				Header: textproto.MIMEHeader{"Content-Type": {"text/csv"}},
+				WriteBody: func(w io.Writer) error {
+					_, err := io.WriteString(w, "id,total\r\n42,9.90\r\n")
+					return err
+				},
+			},
+			wantBody: "id,total\r\n42,9.90\r\n",
+		},
+		{
+			name: "header injection",
+			part: CustomPart{
+				Header: textproto.MIMEHeader{"Content-Type": {"application/xml\r\nBcc: victim@example.com"}},
+				Body:   []byte("<a/>"),
+			},
+			wantBody: "<a/>",
+			wantHead: map[string]string{"Content-Type": "application/xml Bcc: victim@example.com"},
+		},
+		{
+			name:    "missing Content-Type",
+			part:    CustomPart{Header: textproto.MIMEHeader{"X-Document-Type": {"Invoice"}}, Body: []byte("<a/>")},
+			wantErr: true,
+		},
+		{
+			name:    "invalid header name",
+			part:    CustomPart{Header: textproto.MIMEHeader{"Content-Type": {"text/plain"}, "Bad Name": {"x"}}},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported encoding",
+			part:    CustomPart{Header: textproto.MIMEHeader{"Content-Type": {"text/plain"}, "Content-Transfer-Encoding": {"uuencode"}}},
+			wantErr: true,
+		},
+		{
+			name: "body writer error",
+			part: CustomPart{
+				Header:    textproto.MIMEHeader{"Content-Type": {"text/plain"}},
+				WriteBody: func(w io.Writer) error { return errors.New("export failed") },
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newSandboxMail().SetCustomPart(tt.part)
+			var buf bytes.Buffer
+			_, err := m.WriteTo(&buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteTo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantRaw != "" && !strings.Contains(buf.String(), tt.wantRaw) {
+				t.Errorf("message does not contain the encoded body %q", tt.wantRaw)
+			}
+
+			header, got := lastPart(t, buf.Bytes())
+			for key, want := range tt.wantHead {
+				if got := header.Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+			if string(got) != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("after the attachments", func(t *testing.T) {
+		m := newSandboxMail().
+			SetAttachment(map[string][]byte{"terms.txt": []byte("terms")}).
+			SetCustomPart(CustomPart{Header: textproto.MIMEHeader{"Content-Type": {"application/xml"}}, Body: []byte("<a/>")})
+		var buf bytes.Buffer
+		if _, err := m.WriteTo(&buf); err != nil {
+			t.Fatalf("WriteTo() error = %v", err)
+		}
+		message := buf.String()
+		if !strings.Contains(message, "multipart/mixed") {
+			t.Error("email with a custom part is not multipart/mixed")
+		}
+		if strings.Index(message, "terms.txt") > strings.Index(message, "<a/>") {
+			t.Error("custom part written before the attachments")
+		}
+	})
+
+	t.Run("delivery API", func(t *testing.T) {
+		m := newSandboxMail().SetCustomPart(CustomPart{Header: textproto.MIMEHeader{"Content-Type": {"application/xml"}}})
+		if _, err := m.apiAttachments(); !errors.Is(err, errCustomPartsUnsupported) {
+			t.Errorf("apiAttachments() error = %v, want %v", err, errCustomPartsUnsupported)
+		}
+	})
+}
+
+// lastPart returns the header and the decoded body of the last part of message
+func lastPart(t *testing.T, message []byte) (textproto.MIMEHeader, []byte) {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("invalid Content-Type: %v", err)
+	}
+
+	// multipart.Reader decodes quoted-printable parts itself
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	var header textproto.MIMEHeader
+	var body []byte
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		var r io.Reader = part
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			r = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		if body, err = io.ReadAll(r); err != nil {
+			t.Fatalf("failed to decode part: %v", err)
+		}
+		header = part.Header
+	}
+	if header == nil {
+		t.Fatal("message has no parts")
+	}
+	return header, body
+}
@@ -0,0 +1,60 @@
+package gomail
+
+import (
+	"bufio"
+	"io"
+	"time"
+)
+
+// SetDataBuffer configures the buffering of the message data. Without it the
+// data is buffered in DefaultDataBufferSize writes and flushed when full.
+func (m *Mail) SetDataBuffer(buffer *DataBuffer) *Mail {
+	m.dataBuffer = buffer
+	return m
+}
+
+// dataBufferWriter buffers the message data written to the server, flushing
+// when the buffer is full or, with an interval, when data has waited that long
+type dataBufferWriter struct {
+	buf      *bufio.Writer
+	interval time.Duration
+	clock    Clock
+	flushed  time.Time
+}
+
+// newDataBufferWriter returns the buffered writer of the message data written to w
+func (m *Mail) newDataBufferWriter(w io.Writer) *dataBufferWriter {
+	var config DataBuffer
+	if m.dataBuffer != nil {
+		config = *m.dataBuffer
+	}
+	size := config.Size
+	if size <= 0 {
+		size = DefaultDataBufferSize
+	}
+	clock := m.getClock()
+	return &dataBufferWriter{
+		buf:      bufio.NewWriterSize(w, size),
+		interval: config.FlushInterval,
+		clock:    clock,
+		flushed:  clock.Now(),
+	}
+}
+
+// Write implements io.Writer
+func (w *dataBufferWriter) Write(p []byte) (int, error) {
+	n, err := w.buf.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if w.interval > 0 && w.buf.Buffered() > 0 && w.clock.Now().Sub(w.flushed) >= w.interval {
+		err = w.Flush()
+	}
+	return n, err
+}
+
+// Flush writes the buffered data to the server
+func (w *dataBufferWriter) Flush() error {
+	w.flushed = w.clock.Now()
+	return w.buf.Flush()
+}
@@ -0,0 +1,115 @@
+package gomail
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeRecorder records the size of every write
+type writeRecorder struct {
+	bytes.Buffer
+	writes []int
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestDataBufferWriter(t *testing.T) {
+	newMail := func() *Mail {
+		return &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Subject: "Test Subject",
+			Content: strings.Repeat("Line of content\n", 100),
+			To:      []string{"recipient@example.com"},
+			// A single part, so both writes of the message are identical
+			ContentType: TextPlain,
+		}
+	}
+
+	t.Run("coalesces writes", func(t *testing.T) {
+		var direct, buffered writeRecorder
+		m := newMail()
+		if _, err := m.writeTo(&direct); err != nil {
+			t.Fatalf("writeTo() error = %v", err)
+		}
+
+		w := m.newDataBufferWriter(&buffered)
+		if _, err := m.writeTo(w); err != nil {
+			t.Fatalf("writeTo() error = %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if len(buffered.writes) != 1 {
+			t.Errorf("buffered message took %d writes, want 1 (unbuffered took %d)", len(buffered.writes), len(direct.writes))
+		}
+		if !bytes.Equal(buffered.Bytes(), direct.Bytes()) {
+			t.Error("buffering altered the message")
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		var rec writeRecorder
+		w := newMail().SetDataBuffer(&DataBuffer{Size: 16}).newDataBufferWriter(&rec)
+		for i := 0; i < 10; i++ {
+			w.Write([]byte("12345"))
+		}
+		w.Flush()
+		for _, n := range rec.writes[:len(rec.writes)-1] {
+			if n != 16 {
+				t.Errorf("writes = %v, want writes of 16 bytes", rec.writes)
+				break
+			}
+		}
+	})
+
+	t.Run("flush interval", func(t *testing.T) {
+		var rec writeRecorder
+		clock := newFakeClock()
+		w := newMail().SetClock(clock).SetDataBuffer(&DataBuffer{FlushInterval: time.Second}).newDataBufferWriter(&rec)
+
+		w.Write([]byte("first"))
+		if len(rec.writes) != 0 {
+			t.Fatal("data was flushed before the interval")
+		}
+		clock.Advance(time.Second)
+		w.Write([]byte("second"))
+		if rec.String() != "firstsecond" {
+			t.Errorf("flushed %q after the interval, want %q", rec.String(), "firstsecond")
+		}
+	})
+}
+
+func TestSendWithSmallDataBuffer(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Buffered content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetDataBuffer(&DataBuffer{Size: 7})
+
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	messages := server.getMessages()
+	if len(messages) != 1 || !strings.Contains(messages[0], "Buffered content") {
+		t.Errorf("server received %q", messages)
+	}
+}
@@ -0,0 +1,108 @@
+package gomail
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+)
+
+// SetAttachmentDedup sets what happens to attachments whose content is already
+// attached under another name, e.g. the same report attached twice by an
+// automated pipeline. Inline and streaming attachments are never deduplicated.
+func (m *Mail) SetAttachmentDedup(dedup AttachmentDedup) *Mail {
+	m.attachmentDedup = dedup
+	return m
+}
+
+// DuplicateAttachments returns the names of the attachments that have the same
+// content, one group per content attached more than once, the first name of
+// each group being the one kept by DedupRemove. Regular attachments come first,
+// in name order, followed by prepared attachments.
+func (m *Mail) DuplicateAttachments() [][]string {
+	var duplicates [][]string
+	for _, group := range m.duplicateAttachments() {
+		names := make([]string, len(group))
+		for i, ref := range group {
+			names[i] = ref.name
+		}
+		duplicates = append(duplicates, names)
+	}
+	return duplicates
+}
+
+// attachmentRef identifies a regular attachment by name, or a prepared attachment by its index
+type attachmentRef struct {
+	name string
+	part int
+}
+
+// duplicateAttachments returns the groups of attachments with the same content
+// in the order of DuplicateAttachments
+func (m *Mail) duplicateAttachments() [][]attachmentRef {
+	groups := make(map[[sha256.Size]byte][]attachmentRef)
+	var order [][sha256.Size]byte
+	add := func(ref attachmentRef, content []byte) {
+		sum := sha256.Sum256(content)
+		if _, ok := groups[sum]; !ok {
+			order = append(order, sum)
+		}
+		groups[sum] = append(groups[sum], ref)
+	}
+
+	for _, name := range sortedKeys(m.Attachments) {
+		add(attachmentRef{name: name, part: -1}, m.Attachments[name])
+	}
+	for i, attachment := range m.attachmentParts {
+		content, err := base64.StdEncoding.DecodeString(string(attachment.encoded))
+		if err != nil {
+			continue
+		}
+		add(attachmentRef{name: attachment.Name(), part: i}, content)
+	}
+
+	var duplicates [][]attachmentRef
+	for _, sum := range order {
+		if group := groups[sum]; len(group) > 1 {
+			duplicates = append(duplicates, group)
+		}
+	}
+	return duplicates
+}
+
+// dedupeAttachments applies dedup to the attachments of the email for a send,
+// and returns the function that restores the attachments it left out
+func (m *Mail) dedupeAttachments(dedup AttachmentDedup) func() {
+	if dedup == DedupOff {
+		return func() {}
+	}
+
+	removed := make(map[attachmentRef]bool)
+	for _, group := range m.duplicateAttachments() {
+		for _, ref := range group[1:] {
+			if dedup == DedupRemove {
+				log.Printf("Removing duplicate attachment %s: same content as %s", ref.name, group[0].name)
+				removed[ref] = true
+			} else {
+				log.Printf("Duplicate attachment %s: same content as %s", ref.name, group[0].name)
+			}
+		}
+	}
+	if len(removed) == 0 {
+		return func() {}
+	}
+
+	attachments, parts := m.Attachments, m.attachmentParts
+	m.Attachments = make(map[string][]byte, len(attachments))
+	for name, content := range attachments {
+		if !removed[attachmentRef{name: name, part: -1}] {
+			m.Attachments[name] = content
+		}
+	}
+	m.attachmentParts = nil
+	for i, attachment := range parts {
+		if !removed[attachmentRef{name: attachment.Name(), part: i}] {
+			m.attachmentParts = append(m.attachmentParts, attachment)
+		}
+	}
+	return func() { m.Attachments, m.attachmentParts = attachments, parts }
+}
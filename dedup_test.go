@@ -0,0 +1,67 @@
+package gomail
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateAttachments(t *testing.T) {
+	prepared, err := PrepareAttachment("copy.csv", strings.NewReader("a,b,c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newSandboxMail().SetAttachment(map[string][]byte{
+		"report.csv":  []byte("a,b,c"),
+		"summary.txt": []byte("summary"),
+		"again.csv":   []byte("a,b,c"),
+		"notes.txt":   []byte("summary"),
+		"unique.txt":  []byte("unique"),
+	})
+	m.attachmentParts = []*PreparedAttachment{prepared}
+
+	want := [][]string{{"again.csv", "report.csv", "copy.csv"}, {"notes.txt", "summary.txt"}}
+	if got := m.DuplicateAttachments(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DuplicateAttachments() = %v, want %v", got, want)
+	}
+	if got := newSandboxMail().DuplicateAttachments(); got != nil {
+		t.Errorf("DuplicateAttachments() without attachments = %v", got)
+	}
+}
+
+func TestAttachmentDedup(t *testing.T) {
+	attachments := map[string][]byte{"report.csv": []byte("a,b,c"), "report-copy.csv": []byte("a,b,c")}
+
+	tests := []struct {
+		name      string
+		dedup     AttachmentDedup
+		wantNames []string
+	}{
+		{name: "off", dedup: DedupOff, wantNames: []string{"report.csv", "report-copy.csv"}},
+		{name: "warn", dedup: DedupWarn, wantNames: []string{"report.csv", "report-copy.csv"}},
+		{name: "remove", dedup: DedupRemove, wantNames: []string{"report-copy.csv"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			m := newSandboxMail().SetSandboxOutput(&output).SetAttachment(attachments).SetAttachmentDedup(tt.dedup)
+			if err := m.Send(); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			if got := strings.Count(output.String(), "filename="); got != len(tt.wantNames) {
+				t.Errorf("message has %d attachments, want %d", got, len(tt.wantNames))
+			}
+			for _, name := range tt.wantNames {
+				if !strings.Contains(output.String(), `filename="`+name+`"`) {
+					t.Errorf("message is missing attachment %s", name)
+				}
+			}
+			if len(m.Attachments) != 2 {
+				t.Error("Send() should restore the attachments of the email")
+			}
+		})
+	}
+}
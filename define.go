@@ -1,8 +1,11 @@
 package gomail
 
 import (
+	"crypto"
 	"crypto/tls"
 	"io"
+	"io/fs"
+	"sync"
 	"text/template"
 	"time"
 )
@@ -18,24 +21,424 @@ const (
 	DefaultTimeout   = 30 * time.Second
 	DefaultKeepAlive = 60 * time.Second
 	DefaultPoolSize  = 10
+	DefaultCharset   = "UTF-8"
+
+	// DefaultConnectTimeout limits dialing and the greeting, DefaultCommandTimeout
+	// the other commands and DefaultDataTimeout the message data and the reply to
+	// it, the 10 minutes RFC 5321 recommends for a large message
+	DefaultConnectTimeout = 5 * time.Second
+	DefaultCommandTimeout = 5 * time.Second
+	DefaultDataTimeout    = 10 * time.Minute
+
+	DefaultRetryBackoff    = time.Second
+	DefaultMaxRetryBackoff = 5 * time.Minute
+
+	DefaultFailoverCooldown = 30 * time.Second
+
+	DefaultQueueSize = 1000
+
+	DefaultDataBufferSize = 32 * 1024
+
+	DefaultDuplicateWindow = time.Hour
+
+	DefaultTemplateCacheSize = 256
 )
 
-// TLSConfig represents TLS configuration options
+// QueueOverflow selects what SendAsync does when its queue is full
+type QueueOverflow int
+
+const (
+	// QueueBlock makes SendAsync wait until the queue has room
+	QueueBlock QueueOverflow = iota
+	// QueueError fails the new email with ErrQueueFull
+	QueueError
+	// QueueDropOldest fails the oldest queued email with ErrQueueDropped to make room
+	QueueDropOldest
+)
+
+// QueueState is the state of an AsyncQueue
+type QueueState int
+
+const (
+	// QueueRunning sends the queued emails
+	QueueRunning QueueState = iota
+	// QueuePaused holds the queued emails until Resume, whether or not the queue is closed
+	QueuePaused
+	// QueueClosed accepts no more emails and sends those left
+	QueueClosed
+)
+
+// QueueStatus is a snapshot of an AsyncQueue. Queued counts the emails accepted
+// and not yet sent, including those waiting for room in a full queue, and
+// InFlight the emails being sent.
+type QueueStatus struct {
+	State    QueueState
+	Queued   int
+	InFlight int
+}
+
+// TLSPolicy selects how STARTTLS is negotiated on a plain connection
+type TLSPolicy int
+
+const (
+	// TLSPolicyDefault derives the policy from StartTLS: TLSMandatory when it is
+	// set, direct TLS otherwise
+	TLSPolicyDefault TLSPolicy = iota
+	// TLSMandatory upgrades with STARTTLS and fails with ErrTLSRequired when the
+	// server does not offer it or the handshake fails
+	TLSMandatory
+	// TLSOpportunistic upgrades with STARTTLS when the server offers it and
+	// otherwise continues in plaintext, logging a warning
+	TLSOpportunistic
+	// TLSDisabled never issues STARTTLS
+	TLSDisabled
+)
+
+// TLSConfig represents TLS configuration options. A Policy other than the
+// default dials a plain connection and negotiates STARTTLS accordingly. With
+// RequireTLS the connection is abandoned, before authenticating, unless it is
+// encrypted: a server that does not offer STARTTLS or fails the handshake
+// yields ErrTLSRequired. CertFile and KeyFile name a PEM client certificate
+// for mutual TLS, which is re-read on new connections after either file
+// changes when ReloadCertificate is set. With ExternalAuth the server
+// authenticates the certificate through SASL EXTERNAL and no password is
+// needed; User, if set, is sent as the authorization identity.
 type TLSConfig struct {
 	StartTLS           bool
+	RequireTLS         bool
+	Policy             TLSPolicy
 	InsecureSkipVerify bool
 	ServerName         string
 	Certificates       []tls.Certificate
+	CertFile           string
+	KeyFile            string
+	ReloadCertificate  bool
+	ExternalAuth       bool
+
+	certMu      sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+}
+
+// CommandTimeouts represents the deadlines of the individual SMTP commands.
+// Zero values fall back to the mail timeout. The Data timeout is applied
+// as an idle timeout that is extended while the message is being written.
+type CommandTimeouts struct {
+	Greeting time.Duration
+	Hello    time.Duration
+	Auth     time.Duration
+	Mail     time.Duration
+	Rcpt     time.Duration
+	Data     time.Duration
+	Quit     time.Duration
+}
+
+// DataBuffer configures the buffering of the message data sent with DATA. The
+// many small writes of headers, boundaries and template output are coalesced
+// into writes of Size bytes (DefaultDataBufferSize when zero). A positive
+// FlushInterval also sends data that has been buffered that long, so a slowly
+// streamed attachment keeps reaching the server.
+type DataBuffer struct {
+	Size          int
+	FlushInterval time.Duration
+}
+
+// RetryJitter selects how the retry backoff is randomized, so that many
+// instances retrying after the same outage do not reconnect in lockstep
+type RetryJitter int
+
+const (
+	// JitterNone waits the backoff exactly
+	JitterNone RetryJitter = iota
+	// JitterFull waits a random duration between zero and the backoff
+	JitterFull
+	// JitterEqual waits half the backoff plus a random duration up to the other half
+	JitterEqual
+)
+
+// HTMLProblemKind classifies a problem found by the HTML check
+type HTMLProblemKind int
+
+const (
+	// HTMLRelativeURL is a relative URL, which email clients have nothing to resolve against
+	HTMLRelativeURL HTMLProblemKind = iota
+	// HTMLMissingCID is a cid: reference to an inline attachment the email does not carry
+	HTMLMissingCID
+	// HTMLInsecureAsset is an image, stylesheet or other asset loaded over plain http://
+	HTMLInsecureAsset
+)
+
+// AttachmentDedup is what happens to attachments whose content is already
+// attached to the email under another name
+type AttachmentDedup int
+
+const (
+	// DedupOff sends every attachment as is
+	DedupOff AttachmentDedup = iota
+	// DedupWarn logs every duplicate attachment and sends it anyway
+	DedupWarn
+	// DedupRemove logs and leaves out every duplicate attachment, keeping the first
+	DedupRemove
+)
+
+// DuplicateAction is what the duplicate content guard does when an email is
+// about to be sent to a recipient who already received the same subject and body
+type DuplicateAction int
+
+const (
+	// DuplicateSkip leaves the recipient out and sends to the others, or sends
+	// nothing when no recipient is left
+	DuplicateSkip DuplicateAction = iota
+	// DuplicateReject fails the send with a *DuplicateContentError
+	DuplicateReject
+	// DuplicateFlag logs the duplicate and sends anyway
+	DuplicateFlag
+)
+
+// AuthMethod is the SASL mechanism used to authenticate with a password
+type AuthMethod string
+
+const (
+	// AuthAuto picks the strongest mechanism the server offers in its EHLO
+	// response: CRAM-MD5, then LOGIN, then PLAIN. It is the default.
+	AuthAuto AuthMethod = ""
+	// AuthPlain sends the password in the clear, which is only done over an
+	// encrypted connection or to localhost
+	AuthPlain AuthMethod = "PLAIN"
+	// AuthLogin sends the user and password in the clear in answer to the
+	// server's prompts, like PLAIN only over an encrypted connection or to localhost
+	AuthLogin AuthMethod = "LOGIN"
+	// AuthCRAMMD5 proves knowledge of the password with a challenge-response,
+	// for servers that refuse PLAIN over connections without TLS
+	AuthCRAMMD5 AuthMethod = "CRAM-MD5"
+)
+
+// SQLPlaceholder is the style of the query parameters of a database driver
+type SQLPlaceholder int
+
+const (
+	// PlaceholderQuestion is ? for every parameter, as MySQL and SQLite expect
+	PlaceholderQuestion SQLPlaceholder = iota
+	// PlaceholderDollar numbers the parameters: $1, $2, ..., as PostgreSQL expects
+	PlaceholderDollar
+)
+
+// MailgunRegion is the Mailgun region a sending domain is hosted in
+type MailgunRegion string
+
+// Mailgun regions. The zero value is the US region.
+const (
+	MailgunUS MailgunRegion = "us"
+	MailgunEU MailgunRegion = "eu"
+)
+
+// RetryPolicy represents the retry configuration for temporary failures.
+// Backoff doubles from InitialBackoff up to MaxBackoff and is then randomized
+// by Jitter; a retry hint in the server response (e.g. "try again in 300
+// seconds") is waited at least, also up to MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         RetryJitter
+}
+
+// Endpoint represents the address of an SMTP server
+type Endpoint struct {
+	Host string
+	Port string
+}
+
+// FailoverConfig represents the fallback servers used when the primary server
+// (Host and Port) fails. A failed server is skipped for Cooldown, after which it
+// is tried again, so sending returns to the primary once it recovers. Health is
+// tracked per FailoverConfig and shared by every Mail it is set on.
+type FailoverConfig struct {
+	Fallbacks []Endpoint
+	Cooldown  time.Duration
+	mu        sync.Mutex
+	downUntil map[Endpoint]time.Time
+}
+
+// WarmUp is the volume ramp of a new sending domain or IP address, whose
+// reputation mailbox providers build from a small, steadily growing volume.
+// DailyLimits is the number of recipients email may be sent to on each day from
+// Start, the first day first; from the day after the last one sending is not
+// limited. When Spread is set, sends wait so that a day's volume is spread evenly
+// over the day instead of going out in a burst. The volume sent is tracked per
+// WarmUp and shared by every Mail it is set on.
+type WarmUp struct {
+	Start       time.Time
+	DailyLimits []int
+	Spread      bool
+	mu          sync.Mutex
+	day         int
+	sent        int
+	next        time.Time
+}
+
+// Quota is the number of recipients the emails of a quota key may be sent to
+// per hour and per day; a zero limit is no limit
+type Quota struct {
+	Hourly int
+	Daily  int
+}
+
+// Quotas limits the volume of the emails an AsyncQueue sends per quota key,
+// e.g. a tenant id set with SetQuotaKey, so one tenant of a shared platform
+// cannot use up the sending volume of the others. Keys holds the quota of each
+// key and Default that of the keys not in Keys; emails without a quota key are
+// not limited. Usage is counted in recipients per clock hour and day, is
+// tracked per Quotas and shared by every queue it is set on.
+type Quotas struct {
+	Default Quota
+	Keys    map[string]Quota
+	// Location is the time zone of the clock hours and days; nil is UTC
+	Location *time.Location
+	mu       sync.Mutex
+	usage    map[string]*quotaUsage
+}
+
+// SMTPPhase is a phase of an SMTP send whose latency Metrics records
+type SMTPPhase string
+
+const (
+	// PhaseConnect is the TCP connection to the server
+	PhaseConnect SMTPPhase = "connect"
+	// PhaseTLS is the TLS handshake, including the STARTTLS command
+	PhaseTLS SMTPPhase = "tls"
+	// PhaseAuth is the AUTH exchange
+	PhaseAuth SMTPPhase = "auth"
+	// PhaseMail is the MAIL command
+	PhaseMail SMTPPhase = "mail"
+	// PhaseRcpt is the RCPT command of a recipient
+	PhaseRcpt SMTPPhase = "rcpt"
+	// PhaseData is the DATA command, the message data and the response to it
+	PhaseData SMTPPhase = "data"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the latency histogram buckets of Metrics
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond, time.Second,
+	2500 * time.Millisecond, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+}
+
+// Metrics records the latency of the SMTP phases of the emails it is set on in a
+// histogram per phase, telling a slow AUTH from a slow DATA when a relay
+// degrades. Buckets are the increasing upper bounds of the histogram buckets,
+// DefaultLatencyBuckets when empty. Observe, when set, is called with every
+// latency as well, e.g. to feed the histograms of a monitoring system. The
+// histograms are kept per Metrics and shared by every Mail it is set on.
+type Metrics struct {
+	Buckets    []time.Duration
+	Observe    func(phase SMTPPhase, latency time.Duration)
+	mu         sync.Mutex
+	histograms map[SMTPPhase]*LatencyHistogram
+}
+
+// LatencyHistogram is the distribution of the latencies of an SMTP phase.
+// Counts[i] is the number of latencies up to Buckets[i] and above the bucket
+// before it; the last count, one more than there are buckets, is of the
+// latencies above every bucket.
+type LatencyHistogram struct {
+	Buckets []time.Duration
+	Counts  []uint64
+	Count   uint64
+	Sum     time.Duration
+}
+
+// DuplicateGuard catches an email sent with the same subject and body to the
+// same recipient more than once within Window, DefaultDuplicateWindow when zero,
+// e.g. by a retry storm or a buggy loop, and handles it per Action. Sends are
+// remembered in memory per DuplicateGuard and shared by every Mail it is set on;
+// a failed send is forgotten, so retrying it is not a duplicate.
+type DuplicateGuard struct {
+	Window time.Duration
+	Action DuplicateAction
+	mu     sync.Mutex
+	sent   map[string]time.Time
+	pruned time.Time
+}
+
+// RecipientPolicy restricts the domains email may be sent to. Blocked domains are
+// always refused; when AllowedDomains is not empty, every other domain is refused
+// too. Domains match case-insensitively and include their subdomains.
+type RecipientPolicy struct {
+	AllowedDomains []string
+	BlockedDomains []string
+}
+
+// AttachmentPolicy limits the attachments of an email, so that dangerous or huge
+// payloads are not mailed by accident. MaxAttachments and MaxTotalSize apply when
+// positive; the total size counts the content of the attachments, with the
+// declared Size of streaming attachments. ForbiddenExtensions lists file name
+// extensions refused case-insensitively, with or without the dot, e.g. ".exe" and ".js".
+type AttachmentPolicy struct {
+	MaxAttachments      int
+	MaxTotalSize        int64
+	ForbiddenExtensions []string
+}
+
+// ArchiveMetadata describes an archived email
+type ArchiveMetadata struct {
+	From       string    `json:"from"`
+	Recipients []string  `json:"recipients"`
+	Subject    string    `json:"subject"`
+	SentAt     time.Time `json:"sent_at"`
+}
+
+// SpamScore represents the verdict of a spam checker on a message
+type SpamScore struct {
+	Score     float64
+	Threshold float64
+	IsSpam    bool
+	Symbols   []string
+}
+
+// SpamCheckConfig represents the pre-send spam check. Every email is scored by
+// Checker before it is sent; OnScore, when set, receives the score. Emails scoring
+// above MaxScore are not sent. Zero MaxScore only reports the score.
+type SpamCheckConfig struct {
+	Checker  SpamChecker
+	MaxScore float64
+	OnScore  func(*Mail, SpamScore)
 }
 
 // ContentType represents email content type
 type ContentType string
 
+// TransferEncoding represents the Content-Transfer-Encoding of a text part
+type TransferEncoding string
+
+// Transfer encodings for text parts. EncodingAuto picks one based on the content.
+// Encoding8Bit sends text unencoded to servers offering 8BITMIME and falls back
+// to quoted-printable otherwise.
+const (
+	EncodingAuto            TransferEncoding = ""
+	Encoding7Bit            TransferEncoding = "7bit"
+	Encoding8Bit            TransferEncoding = "8bit"
+	EncodingQuotedPrintable TransferEncoding = "quoted-printable"
+	EncodingBase64          TransferEncoding = "base64"
+)
+
 // TemplateEngine represents template engine configuration
 type TemplateEngine struct {
 	BaseDir    string
 	DefaultExt string
 	FuncMap    template.FuncMap
+	// FS, when set, holds the templates instead of the file system, with BaseDir
+	// relative to its root
+	FS fs.FS
+	// Loader, when set, loads the templates instead of BaseDir and FS
+	Loader TemplateLoader
+	// Cache, when set, holds the parsed templates by name, e.g. to bound them
+	// differently or share them between engines over the same templates. Without
+	// one the engine caches up to DefaultTemplateCacheSize templates, shared by
+	// every Mail using the engine.
+	Cache        TemplateCache
+	cacheOnce    sync.Once
+	defaultCache TemplateCache
 }
 
 // Attachment represents an email attachment with metadata
@@ -51,4 +454,85 @@ type AttachmentReader struct {
 	Name   string
 	Reader io.Reader
 	Size   int64
+	// BytesPerSecond throttles reading the attachment when positive
+	BytesPerSecond int64
+	// Progress, when set, is called with the bytes read so far and Size
+	Progress func(read, total int64)
+}
+
+// DKIMConfig represents DKIM signing configuration.
+// PrivateKey must be an *rsa.PrivateKey or an ed25519.PrivateKey.
+type DKIMConfig struct {
+	Domain     string
+	Selector   string
+	PrivateKey crypto.Signer
+	Headers    []string
+}
+
+// DKIMKeys selects the DKIM keys of a message by the domain of its From address,
+// for sending on behalf of several domains. Domains is keyed by lower case domain
+// name; messages from other domains are signed with Fallback, if any, e.g. the
+// keys of the sending service's own domain.
+type DKIMKeys struct {
+	Domains  map[string][]*DKIMConfig
+	Fallback []*DKIMConfig
+}
+
+// Identity represents a sending identity. EnvelopeFrom, when set, is used as the
+// SMTP envelope sender (Return-Path) instead of From. Weight is the identity's
+// relative share of messages in an IdentityPool; zero counts as one.
+type Identity struct {
+	From         string
+	Name         string
+	EnvelopeFrom string
+	DKIM         []*DKIMConfig
+	Weight       int
+}
+
+// Sender overrides the sender and, optionally, the server and credentials of a
+// single send. Empty fields keep the value configured on the Mail.
+type Sender struct {
+	From         string
+	Name         string
+	EnvelopeFrom string
+	DKIM         []*DKIMConfig
+	Host         string
+	Port         string
+	User         string
+	Pass         string `json:"-"`
+}
+
+// LocalRecipient is a recipient of SendToAudienceAt with the time zone they read
+// their email in. A nil Location is UTC.
+type LocalRecipient struct {
+	Address  string
+	Location *time.Location
+}
+
+// Config holds the server settings of a Mail that UpdateConfig replaces while the
+// Mail may be sending. A nil TLS connects without TLS and a nil RateLimit sends
+// without a rate limit.
+type Config struct {
+	Host      string
+	Port      string
+	User      string
+	Pass      string `json:"-"`
+	TLS       *TLSConfig
+	RateLimit *RateLimit
+}
+
+// CalendarEvent represents a meeting sent as an iCalendar (RFC 5545) part.
+// UID identifies the event across its invitation, updates and cancellation, and
+// Sequence counts its revisions. Organizer defaults to From and Attendees to the
+// To and Cc recipients.
+type CalendarEvent struct {
+	UID         string
+	Sequence    int
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	Organizer   string
+	Attendees   []string
 }
@@ -0,0 +1,182 @@
+package gomail
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+)
+
+// ServerResponses holds what the server said on a connection, for diagnosing
+// delivery problems without a packet capture. Each response is the raw reply
+// with its code, one line per reply line, e.g. "250-mx.example.com\n250 SIZE 10240000".
+type ServerResponses struct {
+	// Greeting is the banner the server greeted the connection with
+	Greeting string
+	// EHLO is the response to EHLO. On a connection upgraded with STARTTLS it is
+	// the response after STARTTLS in a send transcript and the response before
+	// STARTTLS otherwise, so the upgrade costs no extra round trip.
+	EHLO string
+	// LastReply is the final response of the last transaction: the reply to the
+	// end of the message data, or to the command that failed the transaction
+	LastReply string
+}
+
+// ServerResponses returns the server responses of the connection used by the last send made with m
+func (m *Mail) ServerResponses() ServerResponses {
+	m.responsesMutex.Lock()
+	defer m.responsesMutex.Unlock()
+	return m.serverResponses
+}
+
+// setServerResponses records the server responses of the connection used by a
+// send on the email the send was made of
+func (m *Mail) setServerResponses(responses ServerResponses) {
+	if m.origin != nil {
+		m.origin.setServerResponses(responses)
+		return
+	}
+	m.responsesMutex.Lock()
+	defer m.responsesMutex.Unlock()
+	m.serverResponses = responses
+}
+
+// ServerResponses returns the server responses of the session's connection
+func (s *Session) ServerResponses() ServerResponses {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.serverResponses()
+}
+
+// serverResponses returns the server responses recorded on c
+func (c *connection) serverResponses() ServerResponses {
+	return ServerResponses{Greeting: c.greeting, EHLO: c.ehlo, LastReply: c.lastReply}
+}
+
+// responseRecorder keeps the last complete SMTP response in the data read from a server
+type responseRecorder struct {
+	mu      sync.Mutex
+	stopped bool
+	partial []byte
+	lines   []string
+	last    string
+}
+
+// record scans p for reply lines. A line with a space after the code ends the response.
+func (r *responseRecorder) record(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+
+	r.partial = append(r.partial, p...)
+	for {
+		end := bytes.IndexByte(r.partial, '\n')
+		if end < 0 {
+			return
+		}
+		line := strings.TrimRight(string(r.partial[:end]), "\r")
+		r.partial = r.partial[end+1:]
+
+		r.lines = append(r.lines, line)
+		if len(line) < 4 || line[3] != '-' {
+			r.last = strings.Join(r.lines, "\n")
+			r.lines = nil
+		}
+	}
+}
+
+// stop stops recording for good, before the data read becomes encrypted
+func (r *responseRecorder) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+}
+
+// lastResponse returns the last complete response recorded
+func (r *responseRecorder) lastResponse() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// recordingConn is a network connection whose reads are recorded, and whose
+// reads and writes are recorded in transcript when it is not nil
+type recordingConn struct {
+	net.Conn
+	recorder   *responseRecorder
+	transcript *transcript
+}
+
+// Read implements net.Conn
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.recorder.record(p[:n])
+	c.transcript.record(p[:n], false, true)
+	return n, err
+}
+
+// Write implements net.Conn
+func (c *recordingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.transcript.record(p[:n], true, true)
+	return n, err
+}
+
+// recordingReader is a reader whose reads are recorded, and recorded in
+// transcript when it is not nil
+type recordingReader struct {
+	r          io.Reader
+	recorder   *responseRecorder
+	transcript *transcript
+}
+
+// Read implements io.Reader
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.recorder.record(p[:n])
+	rr.transcript.record(p[:n], false, false)
+	return n, err
+}
+
+// recordUpgraded records the responses of client after STARTTLS, above the TLS
+// layer. net/smtp reads the EHLO response of the encrypted session itself, so
+// when the send is transcribed EHLO is sent again as name to record it; the
+// EHLO response before STARTTLS is kept otherwise.
+func (c *connection) recordUpgraded(client *smtp.Client, name string) error {
+	c.recorder = new(responseRecorder)
+	client.Text.Reader.R = bufio.NewReader(&recordingReader{r: client.Text.Reader.R, recorder: c.recorder, transcript: c.transcript})
+	if c.transcript == nil {
+		return nil
+	}
+	client.Text.Writer.W = bufio.NewWriter(&recordingWriter{w: client.Text.Writer.W, transcript: c.transcript})
+
+	id, err := client.Text.Cmd("EHLO %s", name)
+	if err != nil {
+		return err
+	}
+	client.Text.StartResponse(id)
+	defer client.Text.EndResponse(id)
+	if _, _, err := client.Text.ReadResponse(250); err != nil {
+		return err
+	}
+	c.ehlo = c.recorder.lastResponse()
+	return nil
+}
+
+// encryptedAuth passes an authentication mechanism the TLS state net/smtp
+// cannot see through the recordingConn of a direct TLS connection
+type encryptedAuth struct {
+	smtp.Auth
+}
+
+// Start implements smtp.Auth
+func (a *encryptedAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	info := *server
+	info.TLS = true
+	return a.Auth.Start(&info)
+}
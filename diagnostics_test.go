@@ -0,0 +1,218 @@
+package gomail
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// serverTLSConfig returns a TLS configuration with a self-signed server certificate
+func serverTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mock.server"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// newMockTLSServer returns a mock SMTP server that is reached over direct TLS
+func newMockTLSServer(t *testing.T) *mockSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create mock SMTP server: %v", err)
+	}
+	server := &mockSMTPServer{
+		listener: tls.NewListener(listener, serverTLSConfig(t)),
+		quit:     make(chan bool),
+	}
+	go server.serve()
+	return server
+}
+
+func TestServerResponses(t *testing.T) {
+	plain := newMockSMTPServer(t)
+	defer plain.close()
+	startTLS := newMockSMTPServer(t)
+	startTLS.tlsConfig = serverTLSConfig(t)
+	startTLS.advertise("SIZE 1000000")
+	defer startTLS.close()
+	direct := newMockTLSServer(t)
+	defer direct.close()
+
+	tests := []struct {
+		name      string
+		server    *mockSMTPServer
+		tlsConfig *TLSConfig
+		wantEHLO  string
+	}{
+		{
+			name:     "plain",
+			server:   plain,
+			wantEHLO: "250-mock.server\n250 AUTH PLAIN",
+		},
+		{
+			name:      "STARTTLS",
+			server:    startTLS,
+			tlsConfig: &TLSConfig{StartTLS: true, InsecureSkipVerify: true},
+			wantEHLO:  "250-mock.server\n250-SIZE 1000000\n250-STARTTLS\n250 AUTH PLAIN",
+		},
+		{
+			name:      "direct TLS",
+			server:    direct,
+			tlsConfig: &TLSConfig{InsecureSkipVerify: true},
+			wantEHLO:  "250-mock.server\n250 AUTH PLAIN",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port, _ := net.SplitHostPort(tt.server.addr())
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "pass",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+				Timeout: 5 * time.Second,
+			}
+			m.SetTLSConfig(tt.tlsConfig)
+
+			if err := m.Send(); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			want := ServerResponses{
+				Greeting:  "220 mock.server ESMTP ready",
+				EHLO:      tt.wantEHLO,
+				LastReply: "250 Message accepted",
+			}
+			if got := m.ServerResponses(); got != want {
+				t.Errorf("ServerResponses() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestServerResponsesLocalName(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcript bool
+		wantEHLOs  int
+		wantEHLO   string
+	}{
+		{
+			name:      "send",
+			wantEHLOs: 2,
+			wantEHLO:  "250-mock.server\n250-STARTTLS\n250 AUTH PLAIN",
+		},
+		{
+			name:       "transcribed send",
+			transcript: true,
+			wantEHLOs:  3,
+			wantEHLO:   "250-mock.server\n250 AUTH PLAIN",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			server.tlsConfig = serverTLSConfig(t)
+			defer server.close()
+
+			host, port, _ := net.SplitHostPort(server.addr())
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "pass",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+				Timeout: 5 * time.Second,
+			}
+			m.SetTLSConfig(&TLSConfig{StartTLS: true, InsecureSkipVerify: true}).SetLocalName("mta.example.com")
+
+			var err error
+			if tt.transcript {
+				_, err = m.SendWithTranscript(context.Background())
+			} else {
+				err = m.Send()
+			}
+			if err != nil {
+				t.Fatalf("send error = %v", err)
+			}
+
+			// EHLO is sent before and after STARTTLS, and once more in a transcribed send
+			ehlos := server.ehloCommands()
+			if want := tt.wantEHLOs * server.connectionCount(); len(ehlos) != want {
+				t.Errorf("server received %d EHLO commands, want %d", len(ehlos), want)
+			}
+			for _, ehlo := range ehlos {
+				if ehlo != "EHLO mta.example.com" {
+					t.Errorf("server received %q, want EHLO mta.example.com", ehlo)
+				}
+			}
+			if got := m.ServerResponses().EHLO; got != tt.wantEHLO {
+				t.Errorf("EHLO = %q, want %q", got, tt.wantEHLO)
+			}
+		})
+	}
+}
+
+func TestServerResponsesRejected(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	server.replyToRcpt("550-5.1.1 Mailbox unavailable\r\n550 5.1.1 User unknown")
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	config := &Mail{Host: host, Port: port, User: "user", Pass: "pass"}
+	session, err := config.Dial(context.Background())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer session.Close()
+
+	if got := session.ServerResponses().Greeting; got != "220 mock.server ESMTP ready" {
+		t.Errorf("Greeting = %q", got)
+	}
+
+	msg := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"unknown@example.com"},
+	}
+	if err := session.Send(msg); err == nil {
+		t.Fatal("Session.Send() to a rejected recipient should fail")
+	}
+	if got := session.ServerResponses().LastReply; got != "550-5.1.1 Mailbox unavailable\n550 5.1.1 User unknown" {
+		t.Errorf("LastReply = %q", got)
+	}
+}
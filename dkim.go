@@ -0,0 +1,253 @@
+package gomail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// defaultDKIMHeaders is the list of headers signed when DKIMConfig.Headers is empty
+var defaultDKIMHeaders = []string{
+	"From", "To", "Cc", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type",
+}
+
+// SetDKIM sets the DKIM signing configuration. Passing more than one
+// configuration signs the message once per configuration, e.g. with both an
+// RSA and an ed25519 key for maximum receiver compatibility.
+func (m *Mail) SetDKIM(configs ...*DKIMConfig) *Mail {
+	m.dkim = configs
+	return m
+}
+
+// SetDKIMKeys signs each message with the DKIM keys of the domain it is sent
+// from. Keys set with SetDKIM, a Sender or an Identity take precedence.
+func (m *Mail) SetDKIMKeys(keys *DKIMKeys) *Mail {
+	m.dkimKeys = keys
+	return m
+}
+
+// dkimConfigs returns the DKIM keys to sign the message with
+func (m *Mail) dkimConfigs() []*DKIMConfig {
+	if len(m.dkim) > 0 || m.dkimKeys == nil {
+		return m.dkim
+	}
+	if configs, ok := m.dkimKeys.Domains[strings.ToLower(addressDomain(m.From))]; ok {
+		return configs
+	}
+	return m.dkimKeys.Fallback
+}
+
+// dkimKeyNames returns the DNS names of the public keys of the DKIM keys the
+// message is signed with, selector._domainkey.domain
+func (m *Mail) dkimKeyNames() []string {
+	var names []string
+	for _, config := range m.dkimConfigs() {
+		names = append(names, config.Selector+"._domainkey."+config.Domain)
+	}
+	return names
+}
+
+// ParseDKIMPrivateKey parses a PEM encoded RSA (PKCS#1 or PKCS#8) or ed25519 (PKCS#8) private key
+func ParseDKIMPrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("dkim: no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("dkim: failed to parse private key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("dkim: unsupported private key type %T", key)
+	}
+}
+
+// writeSignedMessage serializes the message, signs it with every configured
+// DKIM key and writes the signatures followed by the message to w
+func (m *Mail) writeSignedMessage(w io.Writer) error {
+	var buf bytes.Buffer
+	if err := m.writeMessage(&buf); err != nil {
+		return err
+	}
+
+	var signatures strings.Builder
+	for _, config := range m.dkimConfigs() {
+		signature, err := config.sign(buf.Bytes(), time.Now())
+		if err != nil {
+			return err
+		}
+		signatures.WriteString(signature)
+	}
+
+	if _, err := io.WriteString(w, signatures.String()); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// algorithm returns the DKIM algorithm tag for the configured key
+func (c *DKIMConfig) algorithm() (string, error) {
+	switch c.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		return "rsa-sha256", nil
+	case ed25519.PrivateKey:
+		return "ed25519-sha256", nil
+	default:
+		return "", fmt.Errorf("dkim: unsupported private key type %T", c.PrivateKey)
+	}
+}
+
+// sign computes the DKIM-Signature header line (including the trailing CRLF) for message
+func (c *DKIMConfig) sign(message []byte, now time.Time) (string, error) {
+	if c == nil || c.PrivateKey == nil || c.Domain == "" || c.Selector == "" {
+		return "", errors.New("dkim: domain, selector and private key are required")
+	}
+
+	algorithm, err := c.algorithm()
+	if err != nil {
+		return "", err
+	}
+
+	header, body := splitMessage(message)
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	headerNames := c.Headers
+	if len(headerNames) == 0 {
+		headerNames = defaultDKIMHeaders
+	}
+	fields := parseHeaderFields(header)
+
+	var signed []string
+	hash := sha256.New()
+	for _, name := range headerNames {
+		field, ok := lastHeaderField(fields, name)
+		if !ok {
+			continue
+		}
+		signed = append(signed, strings.ToLower(name))
+		hash.Write([]byte(relaxedHeader(field)))
+	}
+
+	value := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		algorithm, c.Domain, c.Selector, now.Unix(),
+		strings.Join(signed, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+	hash.Write([]byte(strings.TrimSuffix(relaxedHeader("DKIM-Signature: "+value), "\r\n")))
+	digest := hash.Sum(nil)
+
+	var signature []byte
+	switch key := c.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	case ed25519.PrivateKey:
+		// RFC 8463 signs the SHA-256 digest with pure ed25519
+		signature = ed25519.Sign(key, digest)
+	}
+	if err != nil {
+		return "", fmt.Errorf("dkim: signing failed: %w", err)
+	}
+
+	return "DKIM-Signature: " + value + base64.StdEncoding.EncodeToString(signature) + "\r\n", nil
+}
+
+// splitMessage splits a serialized message into its header block and body
+func splitMessage(message []byte) ([]byte, []byte) {
+	if i := bytes.Index(message, []byte("\r\n\r\n")); i >= 0 {
+		return message[:i+2], message[i+4:]
+	}
+	return message, nil
+}
+
+// parseHeaderFields splits a header block into raw (possibly folded) fields
+func parseHeaderFields(header []byte) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+// lastHeaderField returns the bottom-most field with the given name
+func lastHeaderField(fields []string, name string) (string, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		key, _, ok := strings.Cut(fields[i], ":")
+		if ok && strings.EqualFold(strings.TrimSpace(key), name) {
+			return fields[i], true
+		}
+	}
+	return "", false
+}
+
+// relaxedHeader applies the "relaxed" header canonicalization of RFC 6376 section 3.4.2
+func relaxedHeader(field string) string {
+	key, value, _ := strings.Cut(field, ":")
+	value = strings.NewReplacer("\r\n", "", "\n", "").Replace(value)
+	return strings.ToLower(strings.TrimSpace(key)) + ":" + strings.TrimSpace(compressWSP(value)) + "\r\n"
+}
+
+// relaxedBody applies the "relaxed" body canonicalization of RFC 6376 section 3.4.4
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(compressWSP(strings.TrimSuffix(line, "\r")), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// compressWSP reduces every run of spaces and tabs to a single space
+func compressWSP(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	space := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(s[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
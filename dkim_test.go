@@ -0,0 +1,227 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDKIMCanonicalization(t *testing.T) {
+	// Example from RFC 6376 section 3.4.5
+	header := "A: X\r\nB : Y\t\r\n\tZ  \r\n"
+	var got strings.Builder
+	for _, field := range parseHeaderFields([]byte(header)) {
+		got.WriteString(relaxedHeader(field))
+	}
+	if want := "a:X\r\nb:Y Z\r\n"; got.String() != want {
+		t.Errorf("relaxedHeader() = %q, want %q", got.String(), want)
+	}
+
+	body := " C \r\nD \t E\r\n\r\n\r\n"
+	if got, want := string(relaxedBody([]byte(body))), " C\r\nD E\r\n"; got != want {
+		t.Errorf("relaxedBody() = %q, want %q", got, want)
+	}
+}
+
+// verifyDKIM recomputes the signed hash of message and verifies it against the signature header
+func verifyDKIM(t *testing.T, signatureLine string, message []byte, pub crypto.PublicKey) {
+	t.Helper()
+
+	value := strings.TrimSuffix(strings.TrimPrefix(signatureLine, "DKIM-Signature: "), "\r\n")
+	tags := map[string]string{}
+	for _, tag := range strings.Split(value, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(tag), "=")
+		tags[k] = v
+	}
+
+	header, body := splitMessage(message)
+	bodyHash := sha256.Sum256(relaxedBody(body))
+	if tags["bh"] != base64.StdEncoding.EncodeToString(bodyHash[:]) {
+		t.Fatalf("body hash mismatch")
+	}
+
+	hash := sha256.New()
+	fields := parseHeaderFields(header)
+	for _, name := range strings.Split(tags["h"], ":") {
+		field, ok := lastHeaderField(fields, name)
+		if !ok {
+			t.Fatalf("signed header %q missing from message", name)
+		}
+		hash.Write([]byte(relaxedHeader(field)))
+	}
+	unsigned := strings.TrimSuffix(signatureLine, tags["b"]+"\r\n")
+	hash.Write([]byte(strings.TrimSuffix(relaxedHeader(unsigned), "\r\n")))
+	digest := hash.Sum(nil)
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		t.Fatalf("invalid signature encoding: %v", err)
+	}
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if tags["a"] != "rsa-sha256" {
+			t.Errorf("algorithm = %s, want rsa-sha256", tags["a"])
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig); err != nil {
+			t.Errorf("rsa signature verification failed: %v", err)
+		}
+	case ed25519.PublicKey:
+		if tags["a"] != "ed25519-sha256" {
+			t.Errorf("algorithm = %s, want ed25519-sha256", tags["a"])
+		}
+		if !ed25519.Verify(key, digest, sig) {
+			t.Error("ed25519 signature verification failed")
+		}
+	}
+}
+
+func TestDKIMSigning(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Subject: "Test Subject",
+		Content: "Test   Content \r\n",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetDKIM(
+		&DKIMConfig{Domain: "example.com", Selector: "rsa", PrivateKey: rsaKey},
+		&DKIMConfig{Domain: "example.com", Selector: "ed", PrivateKey: edKey},
+	)
+
+	var buf bytes.Buffer
+	if err := m.writeSignedMessage(&buf); err != nil {
+		t.Fatalf("writeSignedMessage() error = %v", err)
+	}
+
+	output := buf.String()
+	lines := strings.SplitAfterN(output, "\r\n", 3)
+	if !strings.HasPrefix(lines[0], "DKIM-Signature: ") || !strings.HasPrefix(lines[1], "DKIM-Signature: ") {
+		t.Fatalf("expected two DKIM-Signature headers, got %q", output[:200])
+	}
+
+	message := []byte(lines[2])
+	verifyDKIM(t, lines[0], message, &rsaKey.PublicKey)
+	verifyDKIM(t, lines[1], message, edPub)
+}
+
+func TestDKIMSignErrors(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name   string
+		config *DKIMConfig
+	}{
+		{"missing domain", &DKIMConfig{Selector: "s", PrivateKey: edKey}},
+		{"missing selector", &DKIMConfig{Domain: "example.com", PrivateKey: edKey}},
+		{"missing key", &DKIMConfig{Domain: "example.com", Selector: "s"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.config.sign([]byte("From: a\r\n\r\nbody"), time.Now()); err == nil {
+				t.Error("sign() expected error")
+			}
+		})
+	}
+}
+
+func TestParseDKIMPrivateKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edKey)
+
+	tests := []struct {
+		name    string
+		pem     []byte
+		wantErr bool
+	}{
+		{"rsa pkcs1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), false},
+		{"ed25519 pkcs8", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}), false},
+		{"not pem", []byte("garbage"), true},
+		{"bad der", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("bad")}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDKIMPrivateKey(tt.pem)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDKIMPrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDKIMKeys(t *testing.T) {
+	_, tenantKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, serviceKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &DKIMKeys{
+		Domains: map[string][]*DKIMConfig{
+			"tenant.example": {{Domain: "tenant.example", Selector: "t1", PrivateKey: tenantKey}},
+		},
+		Fallback: []*DKIMConfig{{Domain: "service.example", Selector: "s1", PrivateKey: serviceKey}},
+	}
+
+	tests := []struct {
+		name string
+		from string
+		dkim []*DKIMConfig
+		want []string
+	}{
+		{"tenant domain", "billing@Tenant.Example", nil, []string{"t1._domainkey.tenant.example"}},
+		{"fallback", "billing@other.example", nil, []string{"s1._domainkey.service.example"}},
+		{
+			name: "explicit keys take precedence",
+			from: "billing@tenant.example",
+			dkim: []*DKIMConfig{{Domain: "explicit.example", Selector: "e1", PrivateKey: serviceKey}},
+			want: []string{"e1._domainkey.explicit.example"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			m := newSandboxMail().SetSandboxOutput(&out).SetDKIMKeys(keys).SetDKIM(tt.dkim...)
+			m.From = tt.from
+
+			report := m.SendWithReport(context.Background())
+			if report.Err != nil {
+				t.Fatalf("SendWithReport() error = %v", report.Err)
+			}
+			if !reflect.DeepEqual(report.DKIM, tt.want) {
+				t.Errorf("report.DKIM = %v, want %v", report.DKIM, tt.want)
+			}
+			selector, domain, _ := strings.Cut(tt.want[0], "._domainkey.")
+			if signature := out.String(); !strings.Contains(signature, "d="+domain+";") ||
+				!strings.Contains(signature, "s="+selector+";") {
+				t.Errorf("message not signed with %s", tt.want[0])
+			}
+		})
+	}
+}
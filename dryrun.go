@@ -0,0 +1,73 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DryRun checks under ctx that the email would be accepted without delivering it.
+// It dials and authenticates, issues MAIL and RCPT for every recipient and then
+// resets the transaction instead of sending DATA. Every rejected recipient is
+// reported in the returned error. The email is prepared as for a send, with its
+// identity, resolved recipients and return path.
+func (m *Mail) DryRun(ctx context.Context) error {
+	m = m.sendCopy()
+	m.applyIdentity()
+	restoreRecipients, err := m.resolveRecipients(ctx, m.resolver)
+	if err != nil {
+		return err
+	}
+	defer restoreRecipients()
+	if !m.validate() {
+		return errors.New("missing parameter")
+	}
+	m.assignMessageID()
+	restore, err := m.applyReturnPath(ctx, m.returnPath)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	if err := m.recipientPolicy.check(m.recipients()); err != nil {
+		return err
+	}
+
+	conn, err := dialConnection(ctx, m)
+	if err != nil {
+		return err
+	}
+	unbind := conn.bind(ctx)
+	defer func() {
+		unbind()
+		conn.quit(m.getCommandTimeouts().Quit)
+	}()
+
+	from, to, err := m.envelope(conn.extensions.SMTPUTF8)
+	if err != nil {
+		return err
+	}
+	timeouts := m.getCommandTimeouts()
+	if err := conn.setDeadline(timeouts.Mail); err != nil {
+		return err
+	}
+	if err := conn.client.Mail(from); err != nil {
+		return fmt.Errorf("sender %s rejected: %w", m.getEnvelopeFrom(), contextError(ctx, err))
+	}
+
+	var errs []error
+	for i, recipient := range m.recipients() {
+		conn.setDeadline(timeouts.Rcpt)
+		if err := conn.client.Rcpt(to[i]); err != nil {
+			if ctx.Err() != nil {
+				return contextError(ctx, err)
+			}
+			errs = append(errs, fmt.Errorf("recipient %s rejected: %w", recipient, err))
+		}
+	}
+
+	conn.setDeadline(timeouts.Mail)
+	if err := conn.client.Reset(); err != nil {
+		errs = append(errs, fmt.Errorf("RSET failed: %w", contextError(ctx, err)))
+	}
+	return errors.Join(errs...)
+}
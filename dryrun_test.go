@@ -0,0 +1,137 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	newMail := func() *Mail {
+		return &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Host:    host,
+			Port:    port,
+			User:    "user",
+			Pass:    "pass",
+			Subject: "Test Subject",
+			Content: "Test Content",
+			To:      []string{"recipient@example.com", "unknown@example.com"},
+			Bcc:     []string{"hidden@example.com"},
+		}
+	}
+
+	if err := newMail().DryRun(context.Background()); err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+
+	server.replyToRcpt("250 Recipient OK", "550 5.1.1 No such user")
+	err := newMail().DryRun(context.Background())
+	if err == nil {
+		t.Fatal("DryRun() expected error for the rejected recipient")
+	}
+	if !strings.Contains(err.Error(), "unknown@example.com") || strings.Contains(err.Error(), "hidden@example.com") {
+		t.Errorf("DryRun() error = %v, want only the rejected recipient", err)
+	}
+
+	if got := len(server.getMessages()); got != 0 {
+		t.Errorf("server received %d messages, want none", got)
+	}
+	if got := server.resetCount(); got != 2 {
+		t.Errorf("server received %d RSET commands, want 2", got)
+	}
+	if got := server.quitCount(); got != 2 {
+		t.Errorf("server received %d QUIT commands, want 2", got)
+	}
+}
+
+func TestDryRunInternationalized(t *testing.T) {
+	tests := []struct {
+		name     string
+		smtpUTF8 bool
+		to       string
+		want     []string
+		wantErr  error
+	}{
+		{
+			name:     "SMTPUTF8",
+			smtpUTF8: true,
+			to:       "用户@例子.中国",
+			want:     []string{"RCPT TO:<用户@例子.中国>"},
+		},
+		{
+			name: "punycode fallback",
+			to:   "user@例子.中国",
+			want: []string{"RCPT TO:<user@xn--fsqu00a.xn--fiqs8s>"},
+		},
+		{
+			name:    "local part without SMTPUTF8",
+			to:      "用户@例子.中国",
+			wantErr: ErrSMTPUTF8Required,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+			if tt.smtpUTF8 {
+				server.advertise("SMTPUTF8")
+			}
+			host, port, _ := net.SplitHostPort(server.addr())
+
+			m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).SetTo(tt.to)
+			m.Bcc = nil
+			if err := m.DryRun(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DryRun() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := server.rcptCommands(); !slices.Equal(got, tt.want) {
+				t.Errorf("server received %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRunPreparation(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	host, port, _ := net.SplitHostPort(server.addr())
+
+	var recorded []string
+	m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).SetTo("team:oncall").
+		SetRecipientResolver(directory{"team:oncall": {"ada@example.com", "grace@example.com"}}).
+		SetReturnPathStrategy(&VERPReturnPath{
+			Domain: "bounces.example.com",
+			Record: func(ctx context.Context, address, messageID string) error {
+				recorded = append(recorded, address)
+				return nil
+			},
+		})
+	m.Bcc = nil
+	if err := m.DryRun(context.Background()); err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+
+	wantRcpt := []string{"RCPT TO:<ada@example.com>", "RCPT TO:<grace@example.com>"}
+	if got := server.rcptCommands(); !slices.Equal(got, wantRcpt) {
+		t.Errorf("server received %q, want %q", got, wantRcpt)
+	}
+	if len(recorded) != 1 {
+		t.Fatalf("return path computed %d times, want 1", len(recorded))
+	}
+	if got, want := server.mailCommands(), []string{"MAIL FROM:<" + recorded[0] + ">"}; !slices.Equal(got, want) {
+		t.Errorf("server received %q, want %q", got, want)
+	}
+	// The email itself is left as it was
+	if !slices.Equal(m.To, []string{"team:oncall"}) || m.messageID != "" || m.getEnvelopeFrom() != "sender@example.com" {
+		t.Errorf("DryRun() changed the email: To %v, Message-ID %q, envelope sender %q", m.To, m.messageID, m.getEnvelopeFrom())
+	}
+}
@@ -0,0 +1,128 @@
+package gomail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// DuplicateContentError reports an email refused because its recipients already
+// received the same subject and body within the window of the duplicate guard
+type DuplicateContentError struct {
+	Recipients []string
+}
+
+// Error implements error
+func (e *DuplicateContentError) Error() string {
+	return fmt.Sprintf("same content already sent to %s", strings.Join(e.Recipients, ", "))
+}
+
+// SetDuplicateGuard guards against sending the same subject and body to the
+// same recipient twice within the window of guard
+func (m *Mail) SetDuplicateGuard(guard *DuplicateGuard) *Mail {
+	m.duplicateGuard = guard
+	return m
+}
+
+// guardDuplicates checks the To, Cc and Bcc recipients of m against guard at
+// now and remembers the send to them. With DuplicateSkip the recipients who
+// already received the content are left out, and skip reports that none is
+// left. The returned function, to be called with the outcome of the send,
+// restores the recipients and forgets a failed send.
+func (m *Mail) guardDuplicates(guard *DuplicateGuard, now time.Time) (done func(err error), skip bool, err error) {
+	if guard == nil {
+		return func(error) {}, false, nil
+	}
+	window := guard.Window
+	if window <= 0 {
+		window = DefaultDuplicateWindow
+	}
+	digest := sha256.Sum256([]byte(m.Subject + "\x00" + m.Content))
+	fingerprint := hex.EncodeToString(digest[:])
+
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	if guard.sent == nil {
+		guard.sent = make(map[string]time.Time)
+	}
+	if now.Sub(guard.pruned) >= window {
+		for key, sent := range guard.sent {
+			if now.Sub(sent) >= window {
+				delete(guard.sent, key)
+			}
+		}
+		guard.pruned = now
+	}
+
+	var duplicates []string
+	isDuplicate := func(address string) bool {
+		sent, ok := guard.sent[strings.ToLower(address)+" "+fingerprint]
+		return ok && now.Sub(sent) < window
+	}
+	for _, addresses := range [][]string{m.To, m.Cc, m.Bcc} {
+		for _, address := range addresses {
+			if isDuplicate(address) {
+				duplicates = append(duplicates, address)
+			}
+		}
+	}
+
+	restore := func() {}
+	if len(duplicates) > 0 {
+		switch guard.Action {
+		case DuplicateReject:
+			return nil, false, &DuplicateContentError{Recipients: duplicates}
+		case DuplicateFlag:
+			log.Printf("Duplicate content: %q already sent to %s", m.Subject, strings.Join(duplicates, ", "))
+		default:
+			log.Printf("Skipping duplicate content: %q already sent to %s", m.Subject, strings.Join(duplicates, ", "))
+			to, cc, bcc := m.To, m.Cc, m.Bcc
+			restore = func() { m.To, m.Cc, m.Bcc = to, cc, bcc }
+			m.To, m.Cc, m.Bcc = withoutDuplicates(to, isDuplicate), withoutDuplicates(cc, isDuplicate), withoutDuplicates(bcc, isDuplicate)
+			if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+				restore()
+				return func(error) {}, true, nil
+			}
+		}
+	}
+
+	// A flagged duplicate keeps the time it was first sent
+	var keys []string
+	for _, addresses := range [][]string{m.To, m.Cc, m.Bcc} {
+		for _, address := range addresses {
+			if isDuplicate(address) {
+				continue
+			}
+			key := strings.ToLower(address) + " " + fingerprint
+			guard.sent[key] = now
+			keys = append(keys, key)
+		}
+	}
+	return func(err error) {
+		restore()
+		if err == nil {
+			return
+		}
+		guard.mu.Lock()
+		defer guard.mu.Unlock()
+		for _, key := range keys {
+			if guard.sent[key].Equal(now) {
+				delete(guard.sent, key)
+			}
+		}
+	}, false, nil
+}
+
+// withoutDuplicates returns the addresses for which isDuplicate is false
+func withoutDuplicates(addresses []string, isDuplicate func(string) bool) []string {
+	var kept []string
+	for _, address := range addresses {
+		if !isDuplicate(address) {
+			kept = append(kept, address)
+		}
+	}
+	return kept
+}
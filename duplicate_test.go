@@ -0,0 +1,110 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDuplicateGuard(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  DuplicateAction
+		wantTo  [][]string
+		wantErr bool
+	}{
+		{"skip", DuplicateSkip, [][]string{{"a@example.com", "b@example.com"}, {"c@example.com"}}, false},
+		{"reject", DuplicateReject, [][]string{{"a@example.com", "b@example.com"}}, true},
+		{"flag", DuplicateFlag, [][]string{{"a@example.com", "b@example.com"}, {"A@example.com", "c@example.com"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			guard := &DuplicateGuard{Window: time.Hour, Action: tt.action}
+			var sent [][]string
+			newMail := func(to ...string) *Mail {
+				m := newSandboxMail().SetSandbox(false).SetClock(clock).SetDuplicateGuard(guard).
+					SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error {
+						sent = append(sent, m.To)
+						return nil
+					}))
+				m.To, m.Bcc = to, nil
+				return m
+			}
+
+			if err := newMail("a@example.com", "b@example.com").Send(); err != nil {
+				t.Fatalf("first Send() error = %v", err)
+			}
+			m := newMail("A@example.com", "c@example.com")
+			err := m.Send()
+			var dupErr *DuplicateContentError
+			if tt.wantErr != errors.As(err, &dupErr) {
+				t.Fatalf("second Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && (len(dupErr.Recipients) != 1 || dupErr.Recipients[0] != "A@example.com") {
+				t.Errorf("duplicate recipients = %v, want A@example.com", dupErr.Recipients)
+			}
+			if !jsonEqual(sent, tt.wantTo) {
+				t.Errorf("sent to %v, want %v", sent, tt.wantTo)
+			}
+			if len(m.To) != 2 {
+				t.Errorf("recipients of the email = %v, want them restored", m.To)
+			}
+		})
+	}
+
+	t.Run("all recipients skipped", func(t *testing.T) {
+		guard := &DuplicateGuard{}
+		sends := 0
+		m := newSandboxMail().SetSandbox(false).SetDuplicateGuard(guard).
+			SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error {
+				sends++
+				return nil
+			}))
+		for i := 0; i < 3; i++ {
+			if err := m.Send(); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+		}
+		if sends != 1 {
+			t.Errorf("%d sends, want the repeated sends skipped", sends)
+		}
+		if m.SetContent("Other Content").Send(); sends != 2 {
+			t.Errorf("%d sends, want different content sent", sends)
+		}
+	})
+
+	t.Run("window and failed sends", func(t *testing.T) {
+		clock := newFakeClock()
+		guard := &DuplicateGuard{Window: time.Hour, Action: DuplicateReject}
+		fail := true
+		m := newSandboxMail().SetSandbox(false).SetClock(clock).SetDuplicateGuard(guard).
+			SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error {
+				if fail {
+					return errors.New("connection refused")
+				}
+				return nil
+			}))
+
+		if err := m.Send(); err == nil {
+			t.Fatal("Send() succeeded, want the transport error")
+		}
+		fail = false
+		if err := m.Send(); err != nil {
+			t.Fatalf("retry after a failed send: Send() error = %v", err)
+		}
+		clock.Advance(59 * time.Minute)
+		if err := m.Send(); err == nil {
+			t.Error("Send() within the window succeeded, want a duplicate")
+		}
+		clock.Advance(time.Minute)
+		if err := m.Send(); err != nil {
+			t.Errorf("Send() after the window error = %v", err)
+		}
+		if len(guard.sent) != 2 {
+			t.Errorf("guard remembers %d sends, want the expired ones pruned", len(guard.sent))
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package gomail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+const (
+	// maxLineLength is the maximum length of an encoded line as defined in RFC 2045
+	maxLineLength = 76
+	// max7BitLineLength is the maximum length of a line sent without encoding as defined in RFC 5322
+	max7BitLineLength = 998
+)
+
+// crlf is the line terminator of encoded data, shared to avoid an allocation per line
+var crlf = []byte("\r\n")
+
+// encodeCharset transcodes UTF-8 text into the given IANA charset
+func encodeCharset(charset, s string) ([]byte, error) {
+	if isUTF8(charset) {
+		return []byte(s), nil
+	}
+
+	enc, err := ianaindex.IANA.Encoding(charset)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+
+	out, err := enc.NewEncoder().String(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode content as %s: %w", charset, err)
+	}
+	return []byte(out), nil
+}
+
+// isUTF8 reports whether charset names UTF-8
+func isUTF8(charset string) bool {
+	return strings.EqualFold(charset, "UTF-8") || strings.EqualFold(charset, "UTF8")
+}
+
+// isASCII reports whether s consists of 7-bit characters only
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// writeBase64 base64 encodes r into w, wrapping lines at 76 characters and
+// terminating the last line with CRLF
+func writeBase64(w io.Writer, r io.Reader) error {
+	wrapper := &lineWrapper{w: w}
+	encoder := base64.NewEncoder(base64.StdEncoding, wrapper)
+	if _, err := io.Copy(encoder, r); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return wrapper.Close()
+}
+
+// lineWrapper inserts a CRLF after every maxLineLength bytes written to w
+type lineWrapper struct {
+	w      io.Writer
+	column int
+}
+
+// Write implements io.Writer
+func (lw *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if lw.column == maxLineLength {
+			if _, err := lw.w.Write(crlf); err != nil {
+				return written, err
+			}
+			lw.column = 0
+		}
+
+		n := maxLineLength - lw.column
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := lw.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		lw.column += n
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close terminates the current line, if any, with CRLF
+func (lw *lineWrapper) Close() error {
+	if lw.column == 0 {
+		return nil
+	}
+	lw.column = 0
+	_, err := lw.w.Write(crlf)
+	return err
+}
+
+// chooseTransferEncoding validates encoding, resolving EncodingAuto based on the content:
+// 7bit for short-lined ASCII, base64 when most bytes are non-ASCII and quoted-printable otherwise.
+// Encoding8Bit is resolved the same way, except that short-lined text is sent as 8bit.
+func chooseTransferEncoding(encoding TransferEncoding, content []byte) (TransferEncoding, error) {
+	switch encoding {
+	case Encoding7Bit, EncodingQuotedPrintable, EncodingBase64:
+		return encoding, nil
+	case EncodingAuto, Encoding8Bit:
+	default:
+		return "", fmt.Errorf("unsupported transfer encoding: %s", encoding)
+	}
+
+	nonASCII, lineLength, longLines, nul := 0, 0, false, false
+	for _, c := range content {
+		switch {
+		case c == '\n':
+			lineLength = 0
+			continue
+		case c == 0:
+			nul = true
+			nonASCII++
+		case c >= 0x80:
+			nonASCII++
+		}
+		lineLength++
+		if lineLength > max7BitLineLength {
+			longLines = true
+		}
+	}
+
+	switch {
+	case nonASCII == 0 && !longLines:
+		return Encoding7Bit, nil
+	case encoding == Encoding8Bit && !longLines && !nul:
+		return Encoding8Bit, nil
+	case nonASCII*3 > len(content):
+		return EncodingBase64, nil
+	default:
+		return EncodingQuotedPrintable, nil
+	}
+}
+
+// writeTextPart writes content to w using the given transfer encoding
+func writeTextPart(w io.Writer, encoding TransferEncoding, content []byte) error {
+	switch encoding {
+	case EncodingQuotedPrintable:
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(content); err != nil {
+			return err
+		}
+		return qp.Close()
+	case EncodingBase64:
+		return writeBase64(w, bytes.NewReader(content))
+	default:
+		_, err := w.Write(content)
+		return err
+	}
+}
@@ -0,0 +1,279 @@
+package gomail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestEncodeCharset(t *testing.T) {
+	tests := []struct {
+		name    string
+		charset string
+		input   string
+		want    []byte
+		wantErr bool
+	}{
+		{"utf-8 passthrough", "UTF-8", "şubat", []byte("şubat"), false},
+		{"iso-8859-9 turkish", "ISO-8859-9", "şğı", []byte{0xFE, 0xF0, 0xFD}, false},
+		{"shift_jis", "Shift_JIS", "日本", []byte{0x93, 0xFA, 0x96, 0x7B}, false},
+		{"unrepresentable rune", "ISO-8859-9", "日本", nil, true},
+		{"unknown charset", "x-unknown", "text", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeCharset(tt.charset, tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encodeCharset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, tt.want) {
+				t.Errorf("encodeCharset() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageCharset(t *testing.T) {
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Subject: "Test Subject",
+		Content: "Merhaba dünya",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetCharset("ISO-8859-9")
+
+	var buf bytes.Buffer
+	if err := m.writeMessage(&buf); err != nil {
+		t.Fatalf("writeMessage() error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "charset=ISO-8859-9") {
+		t.Error("message does not declare the configured charset")
+	}
+	if !strings.Contains(output, "Merhaba d=FCnya") {
+		t.Error("content was not transcoded to ISO-8859-9")
+	}
+}
+
+func TestHeaderCharset(t *testing.T) {
+	tests := []struct {
+		name    string
+		charset string
+		subject string
+		to      string
+		want    []string
+	}{
+		{
+			name:    "iso-8859-9",
+			charset: "ISO-8859-9",
+			subject: "Şubat raporu hazır",
+			to:      "Ömer Çelik <omer@example.com>",
+			want: []string{
+				"Subject: =?ISO-8859-9?q?=DEubat_raporu_haz=FDr?=\r\n",
+				"From: =?ISO-8859-9?q?=DEule_Y=FDlmaz?= <sender@example.com>\r\n",
+				"To: =?ISO-8859-9?q?=D6mer_=C7elik?= <omer@example.com>\r\n",
+			},
+		},
+		{
+			name:    "unrepresentable text",
+			charset: "ISO-8859-9",
+			subject: "日本 report",
+			to:      "recipient@example.com",
+			want:    []string{"Subject: =?UTF-8?q?=E6=97=A5=E6=9C=AC_report?=\r\n"},
+		},
+		{
+			name:    "ascii",
+			charset: "ISO-8859-9",
+			subject: "Monthly report",
+			to:      "Omer <omer@example.com>",
+			want:    []string{"Subject: Monthly report\r\n", "To: Omer <omer@example.com>\r\n"},
+		},
+		{
+			name:    "utf-8",
+			charset: "UTF-8",
+			subject: "Şubat raporu",
+			to:      "recipient@example.com",
+			want:    []string{"Subject: Şubat raporu\r\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Şule Yılmaz",
+				Subject: tt.subject,
+				Content: "Merhaba",
+				To:      []string{tt.to},
+			}
+			headers := m.SetCharset(tt.charset).headers()
+			for _, want := range tt.want {
+				if !strings.Contains(headers, want) {
+					t.Errorf("headers do not contain %q:\n%s", want, headers)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteBase64LineWrapping(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"short", 10},
+		{"exactly one line", 57},
+		{"multiple lines", 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte{0xAB}, tt.size)
+			var buf bytes.Buffer
+			if err := writeBase64(&buf, bytes.NewReader(data)); err != nil {
+				t.Fatalf("writeBase64() error = %v", err)
+			}
+
+			output := buf.String()
+			if tt.size == 0 {
+				if output != "" {
+					t.Errorf("writeBase64() = %q, want empty", output)
+				}
+				return
+			}
+			if !strings.HasSuffix(output, "\r\n") {
+				t.Error("encoded data does not end with CRLF")
+			}
+
+			lines := strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n")
+			for i, line := range lines {
+				if len(line) > maxLineLength {
+					t.Errorf("line %d has length %d, want <= %d", i, len(line), maxLineLength)
+				}
+			}
+
+			decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(output, "\r\n", ""))
+			if err != nil || !bytes.Equal(decoded, data) {
+				t.Errorf("decoded data does not match input: %v", err)
+			}
+		})
+	}
+}
+
+func TestChooseTransferEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding TransferEncoding
+		content  string
+		want     TransferEncoding
+		wantErr  bool
+	}{
+		{"ascii", EncodingAuto, "Hello world", Encoding7Bit, false},
+		{"long ascii line", EncodingAuto, strings.Repeat("a", 1000), EncodingQuotedPrintable, false},
+		{"mostly ascii", EncodingAuto, "Merhaba dünya", EncodingQuotedPrintable, false},
+		{"mostly non-ascii", EncodingAuto, "日本語のテキスト", EncodingBase64, false},
+		{"explicit base64", EncodingBase64, "Hello", EncodingBase64, false},
+		{"8bit", Encoding8Bit, "Merhaba dünya", Encoding8Bit, false},
+		{"8bit ascii", Encoding8Bit, "Hello world", Encoding7Bit, false},
+		{"8bit long line", Encoding8Bit, strings.Repeat("Merhaba dünya ", 80), EncodingQuotedPrintable, false},
+		{"8bit NUL", Encoding8Bit, "Merhaba dünya\x00", EncodingQuotedPrintable, false},
+		{"unknown", TransferEncoding("binary"), "Hello", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chooseTransferEncoding(tt.encoding, []byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("chooseTransferEncoding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("chooseTransferEncoding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEightBitMIME(t *testing.T) {
+	tests := []struct {
+		name         string
+		eightBitMIME bool
+		want         []string
+	}{
+		{
+			name:         "offered",
+			eightBitMIME: true,
+			want:         []string{"MAIL FROM:<sender@example.com> BODY=8BITMIME\r\n", "Content-Transfer-Encoding: 8bit\r\n\r\nMerhaba dünya"},
+		},
+		{
+			name: "not offered",
+			want: []string{"MAIL FROM:<sender@example.com>\r\n", "Content-Transfer-Encoding: quoted-printable\r\n\r\nMerhaba d=C3=BCnya"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+			if tt.eightBitMIME {
+				server.advertise("8BITMIME")
+			}
+			host, port, _ := net.SplitHostPort(server.addr())
+
+			m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).
+				SetContentType(TextPlain).SetContent("Merhaba dünya").SetTransferEncoding(Encoding8Bit)
+			if err := m.Send(); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			messages := server.getMessages()
+			if len(messages) != 1 {
+				t.Fatalf("server received %d messages, want 1", len(messages))
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(messages[0], want) {
+					t.Errorf("transaction does not contain %q:\n%s", want, messages[0])
+				}
+			}
+		})
+	}
+
+	// Written outside a send, the message is valid for any server
+	var buf bytes.Buffer
+	m := newSandboxMail().SetContent("Merhaba dünya").SetTransferEncoding(Encoding8Bit)
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Content-Transfer-Encoding: quoted-printable") {
+		t.Error("WriteTo() did not fall back to quoted-printable")
+	}
+}
+
+func TestWriteTextPart(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding TransferEncoding
+		content  string
+		want     string
+	}{
+		{"7bit", Encoding7Bit, "Hello", "Hello"},
+		{"quoted-printable", EncodingQuotedPrintable, "a=b ü", "a=3Db =C3=BC"},
+		{"base64", EncodingBase64, "Hello", "SGVsbG8=\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeTextPart(&buf, tt.encoding, []byte(tt.content)); err != nil {
+				t.Fatalf("writeTextPart() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("writeTextPart() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
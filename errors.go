@@ -0,0 +1,125 @@
+package gomail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrSenderRejected matches an SMTPError for a MAIL command the server refused
+	ErrSenderRejected = errors.New("sender rejected")
+	// ErrRecipientRejected matches an SMTPError for a RCPT command the server refused
+	ErrRecipientRejected = errors.New("recipient rejected")
+	// ErrMessageRejected matches an SMTPError for message data the server refused
+	ErrMessageRejected = errors.New("message rejected")
+)
+
+// SMTPError is an SMTP command of a send that failed. It unwraps to the server's
+// *textproto.Error, or to the network error when the connection failed, and
+// matches ErrSenderRejected, ErrRecipientRejected or ErrMessageRejected when the
+// server refused the command.
+type SMTPError struct {
+	// Command is MAIL, RCPT or DATA
+	Command string
+	// Address is the sender of MAIL or the recipient of RCPT
+	Address string
+	Err     error
+}
+
+// Error implements error
+func (e *SMTPError) Error() string {
+	if e.Address == "" {
+		return e.Command + " failed: " + e.Err.Error()
+	}
+	return e.Command + " " + e.Address + " failed: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *SMTPError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the server refused the command that target stands for
+func (e *SMTPError) Is(target error) bool {
+	var protoErr *textproto.Error
+	if !errors.As(e.Err, &protoErr) {
+		return false
+	}
+	switch target {
+	case ErrSenderRejected:
+		return e.Command == "MAIL"
+	case ErrRecipientRejected:
+		return e.Command == "RCPT"
+	case ErrMessageRejected:
+		return e.Command == "DATA"
+	}
+	return false
+}
+
+// APIError is a request to the HTTP API of a delivery service that failed with
+// an error status. Rate limiting (429) and server errors (5xx) are temporary,
+// any other status is permanent.
+type APIError struct {
+	// Service is the name of the delivery service, e.g. "sendgrid"
+	Service    string
+	StatusCode int
+	// Message is the error the service responded with
+	Message string
+	// RetryAfter is the delay the service asked for before the next request, if any
+	RetryAfter time.Duration
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %d %s: %s", e.Service, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// temporary reports whether the request may succeed when repeated
+func (e *APIError) temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// newAPIError returns the *APIError of the failed response resp of service,
+// with up to 1 KiB of the response body as its message
+func newAPIError(service string, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err := &APIError{Service: service, StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(body))}
+	if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
+
+// PanicError is a panic raised while sending an email, for example by a
+// callback, recovered so it fails the send instead of crashing the program
+type PanicError struct {
+	// Value is the value passed to panic
+	Value any
+	// Stack is the stack trace of the goroutine that panicked
+	Stack []byte
+}
+
+// Error implements error
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic while sending email: %v\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value when it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanic recovers a panic of the calling function and stores it in err as a *PanicError
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
@@ -0,0 +1,176 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSMTPErrorIs(t *testing.T) {
+	rejected := &textproto.Error{Code: 550, Msg: "5.1.1 No such user"}
+	tests := []struct {
+		name   string
+		err    *SMTPError
+		target error
+		want   bool
+	}{
+		{"sender rejected", &SMTPError{Command: "MAIL", Err: rejected}, ErrSenderRejected, true},
+		{"recipient rejected", &SMTPError{Command: "RCPT", Err: rejected}, ErrRecipientRejected, true},
+		{"message rejected", &SMTPError{Command: "DATA", Err: rejected}, ErrMessageRejected, true},
+		{"other command", &SMTPError{Command: "MAIL", Err: rejected}, ErrRecipientRejected, false},
+		{"connection lost", &SMTPError{Command: "RCPT", Err: io.EOF}, ErrRecipientRejected, false},
+		{"unwraps to network error", &SMTPError{Command: "RCPT", Err: io.EOF}, io.EOF, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendErrorChain(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	server.replyToRcpt("550 5.1.1 No such user")
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"nobody@example.com"},
+	}
+	m.SetPoolSize(1)
+
+	err := m.Send()
+	if !errors.Is(err, ErrRecipientRejected) {
+		t.Errorf("Send() error = %v, want ErrRecipientRejected", err)
+	}
+	var smtpErr *SMTPError
+	if !errors.As(err, &smtpErr) || smtpErr.Address != "nobody@example.com" {
+		t.Errorf("Send() error = %v, want an SMTPError for the recipient", err)
+	}
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) || protoErr.Code != 550 {
+		t.Errorf("Send() error = %v, want the 550 reply", err)
+	}
+}
+
+func TestContextErrorChain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := contextError(ctx, io.ErrUnexpectedEOF)
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("contextError() = %v, want both the context and the network error", err)
+	}
+}
+
+// lateContext is a context whose deadline has passed without it being done yet,
+// as seen by a socket read timing out at the deadline
+type lateContext struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c lateContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func TestContextError(t *testing.T) {
+	timeout := fmt.Errorf("read tcp: %w", os.ErrDeadlineExceeded)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want error
+	}{
+		{"cancelled", cancelled, timeout, context.Canceled},
+		{"timeout at the deadline", lateContext{context.Background(), time.Now().Add(-time.Millisecond)}, timeout, context.DeadlineExceeded},
+		{"timeout before the deadline", lateContext{context.Background(), time.Now().Add(time.Hour)}, timeout, nil},
+		{"other error at the deadline", lateContext{context.Background(), time.Now().Add(-time.Millisecond)}, errors.New("550 rejected"), nil},
+		{"no deadline", context.Background(), timeout, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := contextError(tt.ctx, tt.err)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("contextError() = %v, want it to wrap %v", err, tt.err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("contextError() = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && err != tt.err {
+				t.Errorf("contextError() = %v, want the error unchanged", err)
+			}
+		})
+	}
+}
+
+func TestSendRecoversPanic(t *testing.T) {
+	newMail := func() *Mail {
+		m := &Mail{
+			From:        "sender@example.com",
+			Name:        "Test Sender",
+			Host:        "localhost",
+			Port:        "25",
+			User:        "user",
+			Pass:        "pass",
+			Subject:     "Test Subject",
+			Content:     "Test Content",
+			ContentType: TextHTML,
+			To:          []string{"recipient@example.com"},
+		}
+		return m.SetSandbox(true).
+			SetSandboxOutput(io.Discard).
+			SetBoundaryGenerator(func() string { panic("boundary generator failed") })
+	}
+
+	tests := []struct {
+		name string
+		send func(m *Mail) error
+	}{
+		{"Send", func(m *Mail) error { return m.Send() }},
+		{"SendAsync", func(m *Mail) error { return <-m.SendAsync() }},
+		{"async queue", func(m *Mail) error {
+			queue := NewAsyncQueue(1, QueueBlock)
+			defer queue.Close()
+			return <-m.SetAsyncQueue(queue).SendAsync()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var panicErr *PanicError
+			err := tt.send(newMail())
+			if !errors.As(err, &panicErr) {
+				t.Fatalf("error = %v, want a PanicError", err)
+			}
+			if panicErr.Value != "boundary generator failed" {
+				t.Errorf("Value = %v, want the panic value", panicErr.Value)
+			}
+			if !strings.Contains(string(panicErr.Stack), "newBoundary") {
+				t.Error("Stack should contain the panicking function")
+			}
+		})
+	}
+}
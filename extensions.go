@@ -0,0 +1,61 @@
+package gomail
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// Extensions represents the SMTP service extensions advertised by a server in
+// its EHLO response
+type Extensions struct {
+	// StartTLS is whether STARTTLS was offered on the plain connection
+	StartTLS bool
+	// TLS is whether the connection is encrypted, by direct TLS or STARTTLS
+	TLS bool
+	// Auth lists the supported AUTH mechanisms, e.g. PLAIN and LOGIN
+	Auth []string
+	// Size is the maximum message size in bytes, zero if none was advertised
+	Size                int64
+	Pipelining          bool
+	EightBitMIME        bool
+	SMTPUTF8            bool
+	Chunking            bool
+	DSN                 bool
+	EnhancedStatusCodes bool
+}
+
+// Extensions returns the service extensions of the server, connecting under ctx if needed
+func (m *Mail) Extensions(ctx context.Context) (Extensions, error) {
+	pool, err := m.getPool(ctx, m.endpoint())
+	if err != nil {
+		return Extensions{}, fmt.Errorf("error creating pool: %w", err)
+	}
+	conn, err := pool.getConnectionContext(ctx)
+	if err != nil {
+		return Extensions{}, err
+	}
+	defer pool.releaseConnection(conn)
+	return conn.extensions, nil
+}
+
+// parseExtensions returns the extensions client found in the last EHLO response
+func parseExtensions(client *smtp.Client) Extensions {
+	var ext Extensions
+	ext.StartTLS, _ = client.Extension("STARTTLS")
+	_, ext.TLS = client.TLSConnectionState()
+	if ok, mechanisms := client.Extension("AUTH"); ok {
+		ext.Auth = strings.Fields(mechanisms)
+	}
+	if ok, size := client.Extension("SIZE"); ok {
+		ext.Size = parseMaxSize(size)
+	}
+	ext.Pipelining, _ = client.Extension("PIPELINING")
+	ext.EightBitMIME, _ = client.Extension("8BITMIME")
+	ext.SMTPUTF8, _ = client.Extension("SMTPUTF8")
+	ext.Chunking, _ = client.Extension("CHUNKING")
+	ext.DSN, _ = client.Extension("DSN")
+	ext.EnhancedStatusCodes, _ = client.Extension("ENHANCEDSTATUSCODES")
+	return ext
+}
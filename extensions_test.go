@@ -0,0 +1,43 @@
+package gomail
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestExtensions(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	server.advertise("SIZE 35882577", "8BITMIME", "PIPELINING", "SMTPUTF8", "ENHANCEDSTATUSCODES")
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{Host: host, Port: port, User: "user", Pass: "pass"}
+	m.SetPoolSize(1)
+
+	got, err := m.Extensions(context.Background())
+	if err != nil {
+		t.Fatalf("Extensions() error = %v", err)
+	}
+	want := Extensions{
+		Auth:                []string{"PLAIN"},
+		Size:                35882577,
+		Pipelining:          true,
+		EightBitMIME:        true,
+		SMTPUTF8:            true,
+		EnhancedStatusCodes: true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Extensions() = %+v, want %+v", got, want)
+	}
+
+	session, err := m.Dial(context.Background())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer session.Close()
+	if ext := session.Extensions(); ext.Size != 35882577 || ext.StartTLS {
+		t.Errorf("Session.Extensions() = %+v", ext)
+	}
+}
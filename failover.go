@@ -0,0 +1,115 @@
+package gomail
+
+import (
+	"errors"
+	"net/textproto"
+	"time"
+)
+
+// SetFailover sets the fallback servers the email is sent through when the
+// primary server cannot be reached, rejects the credentials or fails permanently
+func (m *Mail) SetFailover(config *FailoverConfig) *Mail {
+	m.failover = config
+	return m
+}
+
+// getCooldown returns how long a failed server is skipped
+func (f *FailoverConfig) getCooldown() time.Duration {
+	if f.Cooldown <= 0 {
+		return DefaultFailoverCooldown
+	}
+	return f.Cooldown
+}
+
+// endpoints returns the servers to try in order at now: primary followed by the
+// fallbacks, leaving out those still cooling down. When every server is cooling
+// down all of them are returned, since skipping them all would not deliver anything.
+func (f *FailoverConfig) endpoints(primary Endpoint, now time.Time) []Endpoint {
+	if f == nil {
+		return []Endpoint{primary}
+	}
+
+	all := append([]Endpoint{primary}, f.Fallbacks...)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	healthy := make([]Endpoint, 0, len(all))
+	for _, endpoint := range all {
+		if now.After(f.downUntil[endpoint]) {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	if len(healthy) == 0 {
+		return all
+	}
+	return healthy
+}
+
+// failed marks endpoint as down until the cooldown has passed
+func (f *FailoverConfig) failed(endpoint Endpoint, now time.Time) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.downUntil == nil {
+		f.downUntil = make(map[Endpoint]time.Time)
+	}
+	f.downUntil[endpoint] = now.Add(f.getCooldown())
+}
+
+// recovered marks endpoint as healthy again
+func (f *FailoverConfig) recovered(endpoint Endpoint) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.downUntil, endpoint)
+}
+
+// isServerFailure reports whether err means the server itself is unusable, as
+// opposed to a temporary condition or a rejection of the message or a recipient
+func isServerFailure(err error) bool {
+	// The email itself cannot be sent, which no other server would change
+	var composeErr *compositionError
+	if errors.As(err, &composeErr) || errors.Is(err, ErrMessageTooLarge) || errors.Is(err, ErrSMTPUTF8Required) {
+		return false
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		switch {
+		case protoErr.Code < 500:
+			// Temporary failures are left to the retry policy
+			return false
+		case protoErr.Code >= 550 && protoErr.Code <= 553:
+			// Mailbox specific rejections would fail on any server
+			return false
+		}
+		return true
+	}
+
+	// Anything other than an SMTP reply comes from dialing, TLS or the connection
+	return true
+}
+
+// compositionError is an error raised while writing the email, such as text
+// the charset cannot represent, rather than by the connection it is written to
+type compositionError struct {
+	err error
+}
+
+// Error implements error
+func (e *compositionError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *compositionError) Unwrap() error {
+	return e.err
+}
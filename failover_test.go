@@ -0,0 +1,207 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsServerFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"auth failed", &textproto.Error{Code: 535, Msg: "Authentication failed"}, true},
+		{"transaction failed", &textproto.Error{Code: 554, Msg: "Service unavailable"}, true},
+		{"temporary", &textproto.Error{Code: 421, Msg: "Try again later"}, false},
+		{"unknown mailbox", &textproto.Error{Code: 550, Msg: "No such user"}, false},
+		{"mailbox full", &textproto.Error{Code: 552, Msg: "Mailbox full"}, false},
+		{"message too large", &MessageTooLargeError{Size: 2048, MaxSize: 1024}, false},
+		{"SMTPUTF8 required", fmt.Errorf("%w: 用户@example.com", ErrSMTPUTF8Required), false},
+		{"composition", &compositionError{err: errors.New("unsupported charset: x-unknown")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isServerFailure(tt.err); got != tt.want {
+				t.Errorf("isServerFailure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailoverEndpoints(t *testing.T) {
+	primary := Endpoint{Host: "primary", Port: "25"}
+	fallback := Endpoint{Host: "fallback", Port: "25"}
+	config := &FailoverConfig{Fallbacks: []Endpoint{fallback}, Cooldown: time.Minute}
+	now := time.Now()
+
+	if got := config.endpoints(primary, now); len(got) != 2 || got[0] != primary {
+		t.Errorf("endpoints() = %v, want primary first", got)
+	}
+
+	config.failed(primary, now)
+	if got := config.endpoints(primary, now.Add(time.Second)); len(got) != 1 || got[0] != fallback {
+		t.Errorf("endpoints() = %v, want only the fallback while primary is down", got)
+	}
+	if got := config.endpoints(primary, now.Add(2*time.Minute)); len(got) != 2 || got[0] != primary {
+		t.Errorf("endpoints() = %v, want primary back after the cooldown", got)
+	}
+
+	config.failed(fallback, now)
+	if got := config.endpoints(primary, now.Add(time.Second)); len(got) != 2 {
+		t.Errorf("endpoints() = %v, want every server when all are down", got)
+	}
+
+	var none *FailoverConfig
+	if got := none.endpoints(primary, now); len(got) != 1 || got[0] != primary {
+		t.Errorf("endpoints() without failover = %v, want only primary", got)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	primary := newMockSMTPServer(t)
+	defer primary.close()
+	fallback := newMockSMTPServer(t)
+	defer fallback.close()
+
+	// The primary fails the first transaction permanently, then recovers
+	primary.replyToRcpt("554 5.3.0 Service unavailable")
+
+	host, port, _ := net.SplitHostPort(primary.addr())
+	fallbackHost, fallbackPort, _ := net.SplitHostPort(fallback.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	clock := newFakeClock()
+	m.SetClock(clock).SetPoolSize(1).SetFailover(&FailoverConfig{
+		Fallbacks: []Endpoint{{Host: fallbackHost, Port: fallbackPort}},
+		Cooldown:  200 * time.Millisecond,
+	})
+
+	// Fails over to the fallback, then keeps using it while the primary cools down
+	for i := 0; i < 2; i++ {
+		if err := m.Send(); err != nil {
+			t.Fatalf("Send() #%d error = %v", i+1, err)
+		}
+	}
+	if got := len(fallback.getMessages()); got != 2 {
+		t.Errorf("fallback received %d messages, want 2", got)
+	}
+	connections := primary.connectionCount()
+
+	clock.Advance(250 * time.Millisecond)
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() after cooldown error = %v", err)
+	}
+	if got := len(primary.getMessages()); got != 1 {
+		t.Errorf("primary received %d messages after recovering, want 1", got)
+	}
+	if primary.connectionCount() == connections {
+		t.Error("primary should be dialed again after the cooldown")
+	}
+}
+
+func TestFailoverUnreachablePrimary(t *testing.T) {
+	// Reserve a port and close it so dialing is refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	fallback := newMockSMTPServer(t)
+	defer fallback.close()
+	fallbackHost, fallbackPort, _ := net.SplitHostPort(fallback.addr())
+
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1).SetFailover(&FailoverConfig{
+		Fallbacks: []Endpoint{{Host: fallbackHost, Port: fallbackPort}},
+	})
+
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := len(fallback.getMessages()); got != 1 {
+		t.Errorf("fallback received %d messages, want 1", got)
+	}
+}
+
+func TestFailoverMessageError(t *testing.T) {
+	tests := []struct {
+		name    string
+		mail    func(m *Mail) *Mail
+		wantErr error
+	}{
+		{
+			name:    "too large",
+			mail:    func(m *Mail) *Mail { return m.SetContent(strings.Repeat("x", 4096)) },
+			wantErr: ErrMessageTooLarge,
+		},
+		{
+			name: "unrepresentable text",
+			mail: func(m *Mail) *Mail { return m.SetCharset("ISO-8859-9").SetContent("日本") },
+		},
+		{
+			name:    "SMTPUTF8 required",
+			mail:    func(m *Mail) *Mail { return m.SetTo("用户@example.com") },
+			wantErr: ErrSMTPUTF8Required,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := newMockSMTPServer(t)
+			defer primary.close()
+			primary.advertise("SIZE 2048")
+			fallback := newMockSMTPServer(t)
+			defer fallback.close()
+
+			host, port, _ := net.SplitHostPort(primary.addr())
+			fallbackHost, fallbackPort, _ := net.SplitHostPort(fallback.addr())
+			m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).SetPoolSize(1).
+				SetFailover(&FailoverConfig{Fallbacks: []Endpoint{{Host: fallbackHost, Port: fallbackPort}}})
+
+			err := tt.mail(m.clone()).Send()
+			if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Send() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := fallback.connectionCount(); got != 0 {
+				t.Errorf("fallback received %d connections, want 0", got)
+			}
+
+			// The primary stays up for the next email
+			if err := m.Send(); err != nil {
+				t.Fatalf("Send() of the next email error = %v", err)
+			}
+			if got := len(primary.getMessages()); got != 1 {
+				t.Errorf("primary received %d messages, want 1", got)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package gomail
 import (
 	"bytes"
 	"os"
+	"sort"
 	"text/template"
 )
 
@@ -25,3 +26,13 @@ func SimpleRenderTemplate(filePath string, data map[string]any) (string, error)
 
 	return renderedContent.String(), nil
 }
+
+// sortedKeys returns the keys of an attachment map in sorted order so output is deterministic
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package gomail
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// HTMLProblem is a problem with a URL of the HTML body, found by CheckHTML
+type HTMLProblem struct {
+	Kind HTMLProblemKind
+	// Tag is the element the URL is in, e.g. "img"
+	Tag string
+	URL string
+}
+
+// String describes the problem
+func (p HTMLProblem) String() string {
+	switch p.Kind {
+	case HTMLRelativeURL:
+		return fmt.Sprintf("relative URL %q in <%s>", p.URL, p.Tag)
+	case HTMLMissingCID:
+		return fmt.Sprintf("%q in <%s> references no inline attachment", p.URL, p.Tag)
+	default:
+		return fmt.Sprintf("insecure asset %q in <%s>", p.URL, p.Tag)
+	}
+}
+
+// HTMLCheckError reports an email that was not sent because the HTML check found problems
+type HTMLCheckError struct {
+	Problems []HTMLProblem
+}
+
+// Error implements error
+func (e *HTMLCheckError) Error() string {
+	descriptions := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		descriptions[i] = problem.String()
+	}
+	return "email not sent: " + strings.Join(descriptions, "; ")
+}
+
+// SetHTMLCheck enables checking the HTML body with CheckHTML before every send,
+// failing the send with an HTMLCheckError when problems are found
+func (m *Mail) SetHTMLCheck(enabled bool) *Mail {
+	m.htmlCheck = enabled
+	return m
+}
+
+var (
+	// htmlComment matches an HTML comment, including conditional comments
+	htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+	// htmlTag matches a start tag with its name and attributes
+	htmlTag = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9]*)(\s[^>]*)?>`)
+	// htmlURLAttribute matches an attribute holding a URL with its name and value
+	htmlURLAttribute = regexp.MustCompile(`(?i)(?:^|\s)(href|src|background)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// CheckHTML checks the URLs of the HTML body for the most common template bugs:
+// relative URLs, cid: references to missing inline attachments and assets loaded
+// over plain http://. Links followed by the reader, the href of <a> and <area>,
+// may use http://. A body that is not HTML has no problems.
+func (m *Mail) CheckHTML() []HTMLProblem {
+	if mediaType, err := m.bodyMediaType(); err != nil || mediaType != "text/html" {
+		return nil
+	}
+
+	inline := make(map[string]bool, len(m.inlineAttachments))
+	for _, attachment := range m.inlineAttachments {
+		inline[attachment.Name] = true
+	}
+
+	var problems []HTMLProblem
+	body := htmlComment.ReplaceAllString(m.Content, "")
+	for _, tag := range htmlTag.FindAllStringSubmatch(body, -1) {
+		name := strings.ToLower(tag[1])
+		for _, attribute := range htmlURLAttribute.FindAllStringSubmatch(tag[2], -1) {
+			url := strings.TrimSpace(html.UnescapeString(attribute[2] + attribute[3] + attribute[4]))
+			link := strings.EqualFold(attribute[1], "href") && (name == "a" || name == "area")
+			if kind, ok := checkURL(url, link, inline); !ok {
+				problems = append(problems, HTMLProblem{Kind: kind, Tag: name, URL: url})
+			}
+		}
+	}
+	return problems
+}
+
+// checkURL reports whether url is fine in an email, and the kind of problem when
+// it is not. link is true for a URL followed by the reader rather than loaded.
+func checkURL(url string, link bool, inline map[string]bool) (HTMLProblemKind, bool) {
+	scheme, rest, hasScheme := strings.Cut(url, ":")
+	if !hasScheme || strings.ContainsAny(scheme, "/?#") {
+		// Fragments point into the email itself; protocol-relative URLs are left alone
+		if strings.HasPrefix(url, "#") || strings.HasPrefix(url, "//") {
+			return 0, true
+		}
+		return HTMLRelativeURL, false
+	}
+
+	switch strings.ToLower(scheme) {
+	case "cid":
+		if !inline[rest] {
+			return HTMLMissingCID, false
+		}
+	case "http":
+		if !link {
+			return HTMLInsecureAsset, false
+		}
+	}
+	return 0, true
+}
@@ -0,0 +1,74 @@
+package gomail
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []HTMLProblem
+	}{
+		{
+			name: "clean",
+			content: `<a href="https://example.com/a?b=1&amp;c=2">Link</a> <a href="http://example.com">Plain link</a>
+				<a href="#top">Top</a> <a href="mailto:support@example.com">Mail</a>
+				<img src="cid:logo.png" alt="Logo"> <img data-src="relative.png" src='https://cdn.example.com/x.png'>`,
+		},
+		{
+			name:    "relative URLs",
+			content: `<a href="/account">Account</a><IMG SRC=images/banner.png>`,
+			want: []HTMLProblem{
+				{Kind: HTMLRelativeURL, Tag: "a", URL: "/account"},
+				{Kind: HTMLRelativeURL, Tag: "img", URL: "images/banner.png"},
+			},
+		},
+		{
+			name:    "missing CID",
+			content: `<img src="cid:header.png"><img src="cid:logo.png">`,
+			want:    []HTMLProblem{{Kind: HTMLMissingCID, Tag: "img", URL: "cid:header.png"}},
+		},
+		{
+			name:    "insecure assets",
+			content: `<link rel="stylesheet" href="http://example.com/style.css"><table background="http://example.com/bg.png"></table>`,
+			want: []HTMLProblem{
+				{Kind: HTMLInsecureAsset, Tag: "link", URL: "http://example.com/style.css"},
+				{Kind: HTMLInsecureAsset, Tag: "table", URL: "http://example.com/bg.png"},
+			},
+		},
+		{
+			name:    "comments are ignored",
+			content: `<!--[if mso]><img src="spacer.gif"><![endif]--><p>Hello</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newSandboxMail()
+			m.Content = tt.content
+			m.SetInlineAttachment([]Attachment{{Name: "logo.png", Data: []byte("png")}})
+
+			if got := m.CheckHTML(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckHTML() = %v, want %v", got, tt.want)
+			}
+
+			err := m.SetHTMLCheck(true).Send()
+			var checkErr *HTMLCheckError
+			if len(tt.want) == 0 && err != nil {
+				t.Errorf("Send() error = %v", err)
+			}
+			if len(tt.want) > 0 && (!errors.As(err, &checkErr) || !reflect.DeepEqual(checkErr.Problems, tt.want)) {
+				t.Errorf("Send() error = %v, want an HTMLCheckError", err)
+			}
+		})
+	}
+
+	m := newSandboxMail().SetContentType(TextPlain)
+	m.Content = `<img src="images/banner.png">`
+	if got := m.CheckHTML(); got != nil {
+		t.Errorf("CheckHTML() of a plain text body = %v, want none", got)
+	}
+}
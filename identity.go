@@ -0,0 +1,93 @@
+package gomail
+
+import (
+	"errors"
+	"sync"
+)
+
+// IdentityPool rotates bulk sends among several sending identities, e.g. to
+// spread volume over several From domains or to warm up a new domain gradually
+// by raising its weight over time. It is safe for concurrent use.
+type IdentityPool struct {
+	identities []Identity
+	current    []int
+	mu         sync.Mutex
+}
+
+// NewIdentityPool creates an identity pool rotating among identities
+func NewIdentityPool(identities ...Identity) (*IdentityPool, error) {
+	if len(identities) == 0 {
+		return nil, errors.New("identity pool requires at least one identity")
+	}
+	for _, identity := range identities {
+		if identity.From == "" || identity.Name == "" {
+			return nil, errors.New("identity pool requires a From address and name for every identity")
+		}
+		if identity.Weight < 0 {
+			return nil, errors.New("identity weight cannot be negative")
+		}
+	}
+
+	return &IdentityPool{
+		identities: identities,
+		current:    make([]int, len(identities)),
+	}, nil
+}
+
+// SetIdentityPool makes every send of the email use the next identity of pool,
+// replacing From, Name and the DKIM configuration with those of the identity
+func (m *Mail) SetIdentityPool(pool *IdentityPool) *Mail {
+	m.identities = pool
+	return m
+}
+
+// SetWeight changes the weight of the identity with the given From address
+func (p *IdentityPool) SetWeight(from string, weight int) error {
+	if weight < 0 {
+		return errors.New("identity weight cannot be negative")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range p.identities {
+		if p.identities[i].From == from {
+			p.identities[i].Weight = weight
+			return nil
+		}
+	}
+	return errors.New("identity not found: " + from)
+}
+
+// Next returns the identity to send the next message with. Identities are
+// picked by smooth weighted round-robin, so their messages are interleaved
+// evenly rather than sent in bursts.
+func (p *IdentityPool) Next() Identity {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	total, best := 0, 0
+	for i, identity := range p.identities {
+		weight := max(identity.Weight, 1)
+		p.current[i] += weight
+		total += weight
+		if p.current[i] > p.current[best] {
+			best = i
+		}
+	}
+	p.current[best] -= total
+	return p.identities[best]
+}
+
+// applyIdentity switches the email to the next identity of its identity pool, if any
+func (m *Mail) applyIdentity() {
+	if m.identities == nil {
+		return
+	}
+
+	identity := m.identities.Next()
+	m.From = identity.From
+	m.Name = identity.Name
+	m.envelopeFrom = identity.EnvelopeFrom
+	m.dkim = identity.DKIM
+}
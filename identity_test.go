@@ -0,0 +1,121 @@
+package gomail
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestIdentityPoolRotation(t *testing.T) {
+	pool, err := NewIdentityPool(
+		Identity{From: "news@old.example.com", Name: "News", Weight: 3},
+		Identity{From: "news@new.example.com", Name: "News", Weight: 1},
+	)
+	if err != nil {
+		t.Fatalf("NewIdentityPool() error = %v", err)
+	}
+
+	var picks []string
+	for i := 0; i < 8; i++ {
+		picks = append(picks, pool.Next().From)
+	}
+
+	counts := map[string]int{}
+	for i, from := range picks {
+		counts[from]++
+		if i > 0 && from == "news@new.example.com" && picks[i-1] == from {
+			t.Errorf("low weight identity picked twice in a row: %v", picks)
+		}
+	}
+	if counts["news@old.example.com"] != 6 || counts["news@new.example.com"] != 2 {
+		t.Errorf("picks = %v, want a 3:1 split", counts)
+	}
+
+	// Ramp the new domain up to an equal share
+	if err := pool.SetWeight("news@new.example.com", 3); err != nil {
+		t.Fatalf("SetWeight() error = %v", err)
+	}
+	counts = map[string]int{}
+	for i := 0; i < 6; i++ {
+		counts[pool.Next().From]++
+	}
+	if counts["news@old.example.com"] != 3 || counts["news@new.example.com"] != 3 {
+		t.Errorf("picks after SetWeight() = %v, want an even split", counts)
+	}
+
+	if err := pool.SetWeight("unknown@example.com", 1); err == nil {
+		t.Error("SetWeight() expected error for an unknown identity")
+	}
+}
+
+func TestNewIdentityPoolErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		identities []Identity
+	}{
+		{"empty", nil},
+		{"missing from", []Identity{{Name: "News"}}},
+		{"missing name", []Identity{{From: "news@example.com"}}},
+		{"negative weight", []Identity{{From: "news@example.com", Name: "News", Weight: -1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewIdentityPool(tt.identities...); err == nil {
+				t.Error("NewIdentityPool() expected error")
+			}
+		})
+	}
+}
+
+func TestSendWithIdentityPool(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	pool, err := NewIdentityPool(
+		Identity{From: "news@a.example.com", Name: "News A", EnvelopeFrom: "bounces@a.example.com"},
+		Identity{From: "news@b.example.com", Name: "News B"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1).SetIdentityPool(pool)
+
+	for i := 0; i < 2; i++ {
+		if err := m.Send(); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if m.From != "sender@example.com" || m.getEnvelopeFrom() != "sender@example.com" {
+		t.Errorf("Send() left the identity %s, %s on the email", m.From, m.getEnvelopeFrom())
+	}
+
+	messages := server.getMessages()
+	if len(messages) != 2 {
+		t.Fatalf("server received %d messages, want 2", len(messages))
+	}
+	if !strings.Contains(messages[0], "MAIL FROM:<bounces@a.example.com>") {
+		t.Error("first email not sent from the envelope sender of the identity")
+	}
+	if !strings.Contains(messages[1], "MAIL FROM:<news@b.example.com>") {
+		t.Error("second email not sent from the From address of the identity")
+	}
+	for i, want := range []string{"From: News A <news@a.example.com>", "From: News B <news@b.example.com>"} {
+		if !strings.Contains(messages[i], want) {
+			t.Errorf("message %d missing %q", i+1, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package ldapresolver provides a gomail.RecipientResolver that expands LDAP and
+// Active Directory groups to the mail addresses of their members, following
+// nested groups and caching the result.
+//
+// The resolver reads the directory through the Directory interface, so it works
+// with any LDAP client. With github.com/go-ldap/ldap/v3 a base object search is
+// all that is needed:
+//
+//	type directory struct{ conn *ldap.Conn }
+//
+//	func (d directory) Lookup(ctx context.Context, dn string, attributes []string) (map[string][]string, error) {
+//		result, err := d.conn.Search(ldap.NewSearchRequest(dn, ldap.ScopeBaseObject,
+//			ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", attributes, nil))
+//		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+//			return nil, nil
+//		}
+//		if err != nil || len(result.Entries) == 0 {
+//			return nil, err
+//		}
+//		values := make(map[string][]string)
+//		for _, attribute := range result.Entries[0].Attributes {
+//			values[attribute.Name] = attribute.Values
+//		}
+//		return values, nil
+//	}
+//
+//	mail.SetRecipientResolver(ldapresolver.New(directory{conn: conn}))
+//	mail.SetTo("cn=oncall,ou=groups,dc=example,dc=com")
+package ldapresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mstgnz/gomail"
+)
+
+// DefaultTTL is how long the members of a group are cached by default
+const DefaultTTL = 5 * time.Minute
+
+// ErrNotFound is returned for a group that does not exist in the directory
+var ErrNotFound = errors.New("not found")
+
+// Directory reads the entries of an LDAP directory
+type Directory interface {
+	// Lookup returns the values of the requested attributes of the entry named
+	// dn, keyed by attribute name. A missing entry has no attributes.
+	Lookup(ctx context.Context, dn string, attributes []string) (map[string][]string, error)
+}
+
+// Resolver expands symbolic recipients naming LDAP groups to the mail addresses
+// of the members of the groups
+type Resolver struct {
+	directory        Directory
+	mailAttribute    string
+	memberAttributes []string
+	groupDN          func(recipient string) (string, bool)
+	ttl              time.Duration
+	now              func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedGroup
+}
+
+// cachedGroup holds the addresses a group expanded to until they expire
+type cachedGroup struct {
+	addresses []string
+	expires   time.Time
+}
+
+var _ gomail.RecipientResolver = (*Resolver)(nil)
+
+// New returns a resolver reading directory. Recipients are group DNs, members
+// are listed in the member and uniqueMember attributes, and their addresses in
+// the mail attribute, as in Active Directory and OpenLDAP.
+func New(directory Directory) *Resolver {
+	return &Resolver{
+		directory:        directory,
+		mailAttribute:    "mail",
+		memberAttributes: []string{"member", "uniqueMember"},
+		groupDN:          func(recipient string) (string, bool) { return recipient, true },
+		ttl:              DefaultTTL,
+		now:              time.Now,
+		cache:            make(map[string]cachedGroup),
+	}
+}
+
+// SetMailAttribute sets the attribute holding the address of an entry
+func (r *Resolver) SetMailAttribute(attribute string) *Resolver {
+	r.mailAttribute = attribute
+	return r
+}
+
+// SetMemberAttributes sets the attributes listing the members of a group
+func (r *Resolver) SetMemberAttributes(attributes ...string) *Resolver {
+	r.memberAttributes = attributes
+	return r
+}
+
+// SetGroupDN sets the function mapping a recipient to the DN of its group, e.g.
+// "team:oncall" to "cn=oncall,ou=teams,dc=example,dc=com". Recipients it does
+// not map, returning false, fail to resolve.
+func (r *Resolver) SetGroupDN(groupDN func(recipient string) (string, bool)) *Resolver {
+	r.groupDN = groupDN
+	return r
+}
+
+// SetTTL sets how long the members of a group are cached; zero disables caching
+func (r *Resolver) SetTTL(ttl time.Duration) *Resolver {
+	r.ttl = ttl
+	return r
+}
+
+// Resolve implements gomail.RecipientResolver
+func (r *Resolver) Resolve(ctx context.Context, recipient string) ([]string, error) {
+	dn, ok := r.groupDN(recipient)
+	if !ok {
+		return nil, fmt.Errorf("ldapresolver: %s is not an LDAP group", recipient)
+	}
+
+	r.mu.Lock()
+	cached, ok := r.cache[dn]
+	r.mu.Unlock()
+	if ok && r.now().Before(cached.expires) {
+		return slices.Clone(cached.addresses), nil
+	}
+
+	addresses, err := r.expand(ctx, dn)
+	if err != nil {
+		return nil, err
+	}
+	if r.ttl > 0 {
+		r.mu.Lock()
+		r.cache[dn] = cachedGroup{addresses: slices.Clone(addresses), expires: r.now().Add(r.ttl)}
+		r.mu.Unlock()
+	}
+	return addresses, nil
+}
+
+// expand returns the addresses of the members of the group dn, following nested
+// groups. An entry with members is a group, whose own address is not used.
+func (r *Resolver) expand(ctx context.Context, dn string) ([]string, error) {
+	attributes := append([]string{r.mailAttribute}, r.memberAttributes...)
+
+	var addresses []string
+	seen := map[string]bool{strings.ToLower(dn): true}
+	queue := []string{dn}
+	for len(queue) > 0 {
+		entry, err := r.directory.Lookup(ctx, queue[0], attributes)
+		if err != nil {
+			return nil, fmt.Errorf("ldapresolver: looking up %s: %w", queue[0], err)
+		}
+		if entry == nil && queue[0] == dn {
+			return nil, fmt.Errorf("ldapresolver: group %s: %w", dn, ErrNotFound)
+		}
+		queue = queue[1:]
+
+		var members []string
+		for _, attribute := range r.memberAttributes {
+			members = append(members, values(entry, attribute)...)
+		}
+		if len(members) == 0 {
+			addresses = append(addresses, values(entry, r.mailAttribute)...)
+			continue
+		}
+		for _, member := range members {
+			if key := strings.ToLower(member); !seen[key] {
+				seen[key] = true
+				queue = append(queue, member)
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// values returns the values of an attribute of entry, whose names are case-insensitive
+func values(entry map[string][]string, attribute string) []string {
+	for name, values := range entry {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package ldapresolver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDirectory is a Directory over entries held in memory that counts its lookups
+type fakeDirectory struct {
+	entries map[string]map[string][]string
+	lookups int
+}
+
+func (d *fakeDirectory) Lookup(ctx context.Context, dn string, attributes []string) (map[string][]string, error) {
+	d.lookups++
+	if dn == "cn=broken,dc=example,dc=com" {
+		return nil, errors.New("connection reset")
+	}
+	return d.entries[dn], nil
+}
+
+func newFakeDirectory() *fakeDirectory {
+	return &fakeDirectory{entries: map[string]map[string][]string{
+		"cn=oncall,ou=groups,dc=example,dc=com": {
+			"member": {"uid=ada,ou=people,dc=example,dc=com", "cn=sre,ou=groups,dc=example,dc=com"},
+		},
+		"cn=sre,ou=groups,dc=example,dc=com": {
+			"mail":         {"sre-list@example.com"},
+			"uniqueMember": {"uid=grace,ou=people,dc=example,dc=com", "cn=oncall,ou=groups,dc=example,dc=com"},
+		},
+		"uid=ada,ou=people,dc=example,dc=com":   {"mail": {"ada@example.com"}},
+		"uid=grace,ou=people,dc=example,dc=com": {"Mail": {"grace@example.com"}},
+		"cn=empty,ou=groups,dc=example,dc=com":  {},
+	}}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		recipient string
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:      "nested groups",
+			recipient: "cn=oncall,ou=groups,dc=example,dc=com",
+			want:      []string{"ada@example.com", "grace@example.com"},
+		},
+		{name: "empty group", recipient: "cn=empty,ou=groups,dc=example,dc=com"},
+		{name: "missing group", recipient: "cn=missing,dc=example,dc=com", wantErr: "not found"},
+		{name: "lookup error", recipient: "cn=broken,dc=example,dc=com", wantErr: "connection reset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(newFakeDirectory()).Resolve(context.Background(), tt.recipient)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCache(t *testing.T) {
+	directory := newFakeDirectory()
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	r := New(directory).SetTTL(time.Minute).SetGroupDN(func(recipient string) (string, bool) {
+		team, ok := strings.CutPrefix(recipient, "team:")
+		return "cn=" + team + ",ou=groups,dc=example,dc=com", ok
+	})
+	r.now = func() time.Time { return now }
+
+	resolve := func() []string {
+		t.Helper()
+		addresses, err := r.Resolve(context.Background(), "team:oncall")
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		return addresses
+	}
+
+	resolve()[0] = "changed@example.com"
+	lookups := directory.lookups
+	if got := resolve(); got[0] != "ada@example.com" {
+		t.Errorf("Resolve() returned %v after the previous result was changed", got)
+	}
+	resolve()[0] = "changed@example.com"
+	if got := resolve(); got[0] != "ada@example.com" {
+		t.Errorf("Resolve() returned %v after a cached result was changed", got)
+	}
+	if directory.lookups != lookups {
+		t.Error("cached group was looked up again")
+	}
+
+	// Once expired, the group is looked up again and changes are picked up
+	directory.entries["uid=ada,ou=people,dc=example,dc=com"]["mail"] = []string{"ada@example.org"}
+	now = now.Add(time.Minute)
+	if got := resolve(); got[0] != "ada@example.org" {
+		t.Errorf("Resolve() after expiry = %v", got)
+	}
+
+	if _, err := r.Resolve(context.Background(), "role:admins"); err == nil {
+		t.Error("Resolve() of a recipient that is not a group should fail")
+	}
+}
@@ -2,18 +2,17 @@ package gomail
 
 import (
 	"bytes"
-	"encoding/base64"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
-	"mime/multipart"
+	"mime"
+	"net/mail"
 	"net/textproto"
-	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
-	"text/template"
 	"time"
 )
 
@@ -27,21 +26,79 @@ type Mail struct {
 	Pass              string `json:"-"` // Password will be omitted from JSON
 	Subject           string
 	Content           string
+	AltContent        string
 	To                []string
 	Cc                []string
 	Bcc               []string
 	Attachments       map[string][]byte
 	Timeout           time.Duration
 	KeepAlive         time.Duration
-	pool              *Pool
+	pools             map[Endpoint]*Pool
+	poolRegistry      *PoolRegistry
+	senderPools       *PoolRegistry
+	poolMutex         sync.Mutex
 	poolSize          int
 	streamAttachments []AttachmentReader
+	inlineAttachments []Attachment
 	tlsConfig         *TLSConfig
-	rateLimiter       *time.Ticker
+	rateLimiter       Ticker
 	ContentType       ContentType
 	TemplateEngine    *TemplateEngine
-	templateCache     map[string]*template.Template
-	templateMutex     sync.RWMutex
+	dkim              []*DKIMConfig
+	charset           string
+	transferEncoding  TransferEncoding
+	boundaryGenerator BoundaryGenerator
+	commandTimeouts   *CommandTimeouts
+	retryPolicy       *RetryPolicy
+	failover          *FailoverConfig
+	identities        *IdentityPool
+	envelopeFrom      string
+	sandbox           bool
+	sandboxOutput     io.Writer
+	redirectTo        string
+	recipientPolicy   *RecipientPolicy
+	archiver          Archiver
+	credentials       CredentialsProvider
+	configMutex       sync.RWMutex
+	asyncQueue        *AsyncQueue
+	asyncLimit        chan struct{}
+	messageID         string
+	journal           []string
+	spamCheck         *SpamCheckConfig
+	prepared          []byte
+	calendar          *calendarPart
+	attachmentParts   []*PreparedAttachment
+	envelopeTo        []string
+	serverResponses   ServerResponses
+	responsesMutex    sync.Mutex
+	clock             Clock
+	connectTimeout    time.Duration
+	commandTimeout    time.Duration
+	dataTimeout       time.Duration
+	dataBuffer        *DataBuffer
+	configChanged     chan struct{}
+	contentScanner    ContentScanner
+	dkimKeys          *DKIMKeys
+	returnPath        ReturnPathStrategy
+	attachmentPolicy  *AttachmentPolicy
+	htmlCheck         bool
+	resolver          RecipientResolver
+	transport         Transport
+	capture           *bytes.Buffer
+	tags              []string
+	warmUp            *WarmUp
+	attachmentDedup   AttachmentDedup
+	oauth2            TokenSource
+	duplicateGuard    *DuplicateGuard
+	authMethod        AuthMethod
+	transcript        *transcript
+	eightBitMIME      bool
+	quotaKey          string
+	customParts       []CustomPart
+	metrics           *Metrics
+	origin            *Mail
+	session           *Session
+	localName         string
 }
 
 // SetFrom sets the sender's email address
@@ -92,6 +149,12 @@ func (m *Mail) SetContent(content string) *Mail {
 	return m
 }
 
+// SetAltContent sets the plain text alternative of the HTML content
+func (m *Mail) SetAltContent(content string) *Mail {
+	m.AltContent = content
+	return m
+}
+
 // SetTo sets the email recipients
 func (m *Mail) SetTo(to ...string) *Mail {
 	m.To = to
@@ -116,6 +179,52 @@ func (m *Mail) SetTimeout(timeout time.Duration) *Mail {
 	return m
 }
 
+// SetCommandTimeouts sets the deadlines of the individual SMTP commands
+func (m *Mail) SetCommandTimeouts(timeouts *CommandTimeouts) *Mail {
+	m.commandTimeouts = timeouts
+	return m
+}
+
+// SetConnectTimeout sets the timeout of dialing the server and of its greeting
+func (m *Mail) SetConnectTimeout(timeout time.Duration) *Mail {
+	m.connectTimeout = timeout
+	return m
+}
+
+// SetCommandTimeout sets the timeout of the SMTP commands other than DATA
+func (m *Mail) SetCommandTimeout(timeout time.Duration) *Mail {
+	m.commandTimeout = timeout
+	return m
+}
+
+// SetDataTimeout sets the idle timeout of transferring the message data and
+// the timeout of the reply to it
+func (m *Mail) SetDataTimeout(timeout time.Duration) *Mail {
+	m.dataTimeout = timeout
+	return m
+}
+
+// SetRetryPolicy sets the retry policy for temporary (4xx) failures
+func (m *Mail) SetRetryPolicy(policy *RetryPolicy) *Mail {
+	m.retryPolicy = policy
+	return m
+}
+
+// SetLocalName sets the host name the client introduces itself with in EHLO,
+// "localhost" by default
+func (m *Mail) SetLocalName(name string) *Mail {
+	m.localName = name
+	return m
+}
+
+// getLocalName returns the host name to send in EHLO
+func (m *Mail) getLocalName() string {
+	if m.localName == "" {
+		return "localhost"
+	}
+	return m.localName
+}
+
 // SetKeepAlive sets the keep-alive duration
 func (m *Mail) SetKeepAlive(keepAlive time.Duration) *Mail {
 	m.KeepAlive = keepAlive
@@ -128,6 +237,62 @@ func (m *Mail) SetAttachment(attachments map[string][]byte) *Mail {
 	return m
 }
 
+// SetCharset sets the charset used for the message parts, e.g. "ISO-8859-9" or "Shift_JIS".
+// The content is transcoded from UTF-8 when the message is written, and non-ASCII
+// text in the Subject and display names is written as RFC 2047 encoded words.
+func (m *Mail) SetCharset(charset string) *Mail {
+	m.charset = charset
+	return m
+}
+
+// SetTransferEncoding sets the transfer encoding of the text parts.
+// EncodingAuto (the default) chooses one based on the content.
+func (m *Mail) SetTransferEncoding(encoding TransferEncoding) *Mail {
+	m.transferEncoding = encoding
+	return m
+}
+
+// getTransferEncoding returns the transfer encoding of the text parts. Encoding8Bit
+// falls back to quoted-printable unless the email is being sent to a server
+// offering 8BITMIME, so a message written outside a send is valid for any server.
+func (m *Mail) getTransferEncoding() TransferEncoding {
+	if m.transferEncoding == Encoding8Bit && !m.eightBitMIME {
+		return EncodingQuotedPrintable
+	}
+	return m.transferEncoding
+}
+
+// SetBoundaryGenerator sets the generator of MIME boundaries, e.g. a
+// SeededBoundaryGenerator for reproducible output. Boundaries are random by default.
+func (m *Mail) SetBoundaryGenerator(generator BoundaryGenerator) *Mail {
+	m.boundaryGenerator = generator
+	return m
+}
+
+// RedirectAllTo delivers the email to address instead of its recipients, e.g. to
+// keep staging from emailing real customers. The original recipients are kept in
+// X-Original-To, X-Original-Cc and X-Original-Bcc headers. An empty address
+// disables the redirect.
+func (m *Mail) RedirectAllTo(address string) *Mail {
+	m.redirectTo = address
+	return m
+}
+
+// JournalBcc silently adds addresses as envelope recipients of the email, e.g. a
+// journaling mailbox required for regulatory archiving. Like Bcc recipients they
+// never appear in the headers; unlike them they are kept when RedirectAllTo is set.
+func (m *Mail) JournalBcc(addresses ...string) *Mail {
+	m.journal = addresses
+	return m
+}
+
+// SetPoolRegistry makes the email use the pool that registry shares between all
+// Mail values with the same server and credentials, instead of a pool of its own
+func (m *Mail) SetPoolRegistry(registry *PoolRegistry) *Mail {
+	m.poolRegistry = registry
+	return m
+}
+
 // SetPoolSize sets the connection pool size
 func (m *Mail) SetPoolSize(size int) *Mail {
 	m.poolSize = size
@@ -139,6 +304,20 @@ func (m *Mail) Send() error {
 	return m.send()
 }
 
+// SendContext sends the email, aborting dialing, authentication and data
+// transfer as soon as ctx is cancelled or its deadline passes
+func (m *Mail) SendContext(ctx context.Context) error {
+	return m.sendContext(ctx)
+}
+
+// SendWithTimeout sends the email, giving up once timeout has passed in total
+// across dialing, authentication, retries and data transfer
+func (m *Mail) SendWithTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return m.sendContext(ctx)
+}
+
 // SendFile loads an HTML file and renders it with dynamic data
 func (m *Mail) SendHtml(filePath string, data map[string]any) error {
 	content, err := SimpleRenderTemplate(filePath, data)
@@ -151,99 +330,371 @@ func (m *Mail) SendHtml(filePath string, data map[string]any) error {
 
 // Send sends the email
 func (m *Mail) send() error {
+	return m.sendContext(context.Background())
+}
+
+// sendContext sends the email under ctx, retrying temporary failures per the retry policy
+func (m *Mail) sendContext(ctx context.Context) error {
+	return m.deliver(ctx, new(SendReport))
+}
+
+// deliver sends the email under ctx like sendContext, recording the Message-ID,
+// recipients and attempts of the send in report. A panic during the send, for
+// example in a callback, is returned as a *PanicError.
+func (m *Mail) deliver(ctx context.Context, report *SendReport) (err error) {
+	defer recoverPanic(&err)
+	m = m.sendCopy()
+	m.applyIdentity()
+	restoreRecipients, err := m.resolveRecipients(ctx, m.resolver)
+	if err != nil {
+		return err
+	}
+	defer restoreRecipients()
 	if !m.validate() {
 		return errors.New("missing parameter")
 	}
+	done, skip, err := m.guardDuplicates(m.duplicateGuard, m.getClock().Now())
+	if err != nil {
+		return err
+	}
+	defer func() { done(err) }()
+	if skip {
+		return nil
+	}
+	m.assignMessageID()
+	restore, err := m.applyReturnPath(ctx, m.returnPath)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	report.MessageID = m.messageID
+	report.Recipients = m.recipients()
+	report.DKIM = m.dkimKeyNames()
+	if err := m.recipientPolicy.check(m.recipients()); err != nil {
+		return err
+	}
+	defer m.dedupeAttachments(m.attachmentDedup)()
+	if err := m.attachmentPolicy.check(m); err != nil {
+		return err
+	}
+	if m.htmlCheck {
+		if problems := m.CheckHTML(); len(problems) > 0 {
+			return &HTMLCheckError{Problems: problems}
+		}
+	}
+	if err := m.scanAttachments(ctx, m.contentScanner); err != nil {
+		return err
+	}
+	if m.spamCheck != nil {
+		// The message checked is the message sent, so it is serialized only once
+		if err := m.prepare(); err != nil {
+			return err
+		}
+		defer func() { m.prepared = nil }()
+		if err := m.checkSpam(ctx); err != nil {
+			return err
+		}
+	}
+	return m.dispatch(ctx, report)
+}
+
+// dispatch hands the checked email to the sandbox or, within the warm-up
+// volume, to the transport, retrying temporary failures per the retry policy,
+// and archives it once sent. The warm-up volume of an email that is not sent
+// is given back.
+func (m *Mail) dispatch(ctx context.Context, report *SendReport) error {
+	if m.sandbox {
+		return m.sendSandbox()
+	}
+	release, err := m.warmUp.reserve(ctx, m.getClock(), len(m.recipients()))
+	if err != nil {
+		return err
+	}
+
+	// Capture the message as sent when it is to be archived
+	if m.archiver != nil {
+		m.capture = new(bytes.Buffer)
+		defer func() { m.capture = nil }()
+	}
+
+	transport := m.getTransport()
+	for attempt := 1; ; attempt++ {
+		report.Attempts = attempt
+		err := transport.Send(ctx, m)
+		if err == nil {
+			return m.archive(ctx, m.archiver, m.capture)
+		}
+		// An email that was not delivered does not count against the warm-up volume
+		if !m.retryPolicy.shouldRetry(attempt, err) {
+			release()
+			return err
+		}
+
+		select {
+		case <-m.getClock().After(m.retryPolicy.delay(attempt, err)):
+		case <-ctx.Done():
+			release()
+			return contextError(ctx, err)
+		}
+	}
+}
 
+// sendOnce makes a single attempt at sending the email under ctx, failing over
+// to the next healthy server when one is configured. When capture is not nil
+// it receives a copy of the message as sent.
+func (m *Mail) sendOnce(ctx context.Context, capture *bytes.Buffer) error {
 	// Apply rate limiting if enabled
-	if m.rateLimiter != nil {
-		<-m.rateLimiter.C
+	if err := m.waitRateLimit(ctx); err != nil {
+		return err
+	}
+	if m.session != nil {
+		return m.session.send(ctx, m, capture)
+	}
+
+	var err error
+	for _, endpoint := range m.failover.endpoints(m.endpoint(), m.getClock().Now()) {
+		err = m.sendTo(ctx, endpoint, capture)
+		if err == nil {
+			m.failover.recovered(endpoint)
+			return nil
+		}
+		if contextDone(ctx, err) != nil || !isServerFailure(err) {
+			return err
+		}
+		m.failover.failed(endpoint, m.getClock().Now())
+	}
+	return err
+}
+
+// sendTo sends the email to the server at endpoint under ctx
+func (m *Mail) sendTo(ctx context.Context, endpoint Endpoint, capture *bytes.Buffer) error {
+	if m.transcript != nil {
+		return m.sendTranscribed(ctx, endpoint, capture)
 	}
 
 	// Initialize or use existing pool
-	if m.pool == nil {
-		pool, err := NewPool(m, m.poolSize)
+	pool, err := m.getPool(ctx, endpoint)
+	if err != nil {
+		return fmt.Errorf("error creating pool: %w", err)
+	}
+
+	// Get connection from pool
+	conn, err := pool.getConnectionContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	unbind := conn.bind(ctx)
+	err = m.transmit(conn, m.getCommandTimeouts(), capture)
+	unbind()
+
+	// The server may have closed the idle pooled connection (common with
+	// Office365); retry once on a fresh connection
+	var stale *staleConnectionError
+	if errors.As(err, &stale) && ctx.Err() == nil {
+		pool.discardConnection(conn)
+		conn, err = pool.createConnectionContext(ctx)
 		if err != nil {
-			return fmt.Errorf("error creating pool: %v", err)
+			return err
 		}
-		m.pool = pool
+		unbind := conn.bind(ctx)
+		err = m.transmit(conn, m.getCommandTimeouts(), capture)
+		unbind()
 	}
 
-	// Get connection from pool
-	client, err := m.pool.getConnection()
+	m.setServerResponses(conn.serverResponses())
+
+	// A connection interrupted by the context or a failed transaction is in
+	// an unknown protocol state and must not be reused
+	if contextDone(ctx, err) != nil {
+		pool.discardConnection(conn)
+		if err != nil {
+			return contextError(ctx, err)
+		}
+		return nil
+	}
 	if err != nil {
+		pool.discardConnection(conn)
 		return err
 	}
-	defer m.pool.releaseConnection(client)
+	pool.releaseConnection(conn)
+	return nil
+}
 
-	// Send email process
-	if err := client.Mail(m.From); err != nil {
+// transmit runs the MAIL, RCPT and DATA transaction of the email over conn,
+// copying the message data to capture when it is not nil
+func (m *Mail) transmit(conn *connection, timeouts CommandTimeouts, capture *bytes.Buffer) error {
+	client := conn.client
+
+	// Refuse emails the server is known to reject before transferring them
+	if maxSize := conn.extensions.Size; maxSize > 0 {
+		if size := m.minimumSize(); size > maxSize {
+			return &MessageTooLargeError{Size: size, MaxSize: maxSize}
+		}
+	}
+
+	// Internationalized addresses are sent as they are with SMTPUTF8 and with
+	// punycode domains otherwise, so the whole envelope is checked first
+	from, to, err := m.envelope(conn.extensions.SMTPUTF8)
+	if err != nil {
 		return err
 	}
 
-	allRecipients := append(append(m.To, m.Cc...), m.Bcc...)
-	for _, recipient := range allRecipients {
-		if err := client.Rcpt(recipient); err != nil {
-			return err
+	if err := conn.setDeadline(timeouts.Mail); err != nil {
+		return err
+	}
+
+	// Clear any transaction state left behind by the previous message, which
+	// would otherwise fail MAIL with a "nested MAIL command" error
+	if conn.used {
+		if err := client.Reset(); err != nil {
+			if isConnectionLost(err) {
+				return &staleConnectionError{err: err}
+			}
+			return fmt.Errorf("RSET failed: %w", err)
 		}
 	}
+	conn.used = true
+	defer func() { conn.lastReply = conn.recorder.lastResponse() }()
+
+	// Send email process
+	sendEnvelope := m.sendEnvelope
+	if conn.extensions.Pipelining {
+		sendEnvelope = m.pipelineEnvelope
+	}
+	if err := sendEnvelope(conn, timeouts, from, to); err != nil {
+		return err
+	}
 
+	conn.setDeadline(timeouts.Data)
+	dataStart := time.Now()
 	w, err := client.Data()
 	if err != nil {
+		m.metrics.observe(PhaseData, dataStart)
+		return &SMTPError{Command: "DATA", Err: err}
+	}
+
+	// The data deadline is an idle timeout: it is extended on every write
+	// so large messages are not cut off while they are making progress
+	connWriter := &deadlineWriter{w: w, conn: conn, timeout: timeouts.Data}
+	var data io.Writer = connWriter
+	if maxSize := conn.extensions.Size; maxSize > 0 {
+		data = &sizeLimitWriter{w: data, max: maxSize}
+	}
+	if capture != nil {
+		capture.Reset()
+		data = io.MultiWriter(data, capture)
+	}
+	// net/smtp declares BODY=8BITMIME whenever the server offers it
+	m.eightBitMIME = conn.extensions.EightBitMIME
+	buffered := m.newDataBufferWriter(data)
+	_, err = m.writeTo(buffered)
+	m.eightBitMIME = false
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		// Closing w would terminate the data with "." and have the server accept
+		// a truncated message; the connection is abandoned instead
+		client.Close()
+		m.metrics.observe(PhaseData, dataStart)
+		if connWriter.err == nil {
+			return &compositionError{err: err}
+		}
 		return err
 	}
-	defer w.Close()
 
-	// Write email content
-	writer := multipart.NewWriter(w)
-	defer writer.Close()
+	// Closing the data writer returns the server's response to the message
+	conn.setDeadline(timeouts.Data)
+	err = w.Close()
+	m.metrics.observe(PhaseData, dataStart)
+	if err != nil {
+		return &SMTPError{Command: "DATA", Err: err}
+	}
+	return nil
+}
 
-	// Write headers
-	headers := fmt.Sprintf("From: %s <%s>\r\n"+
-		"To: %s\r\n"+
-		"Cc: %s\r\n"+
-		"Bcc: %s\r\n"+
-		"Subject: %s\r\n"+
-		"MIME-Version: 1.0\r\n"+
-		"Content-Type: multipart/mixed; boundary=%s\r\n\r\n",
-		m.Name, m.From,
-		strings.Join(m.To, ", "),
-		strings.Join(m.Cc, ", "),
-		strings.Join(m.Bcc, ", "),
-		m.Subject,
-		writer.Boundary())
+// sendEnvelope sends MAIL with from and RCPT for every address of to, waiting
+// for the response to each command
+func (m *Mail) sendEnvelope(conn *connection, timeouts CommandTimeouts, from string, to []string) error {
+	// net/smtp declares SMTPUTF8 whenever the server offers it
+	start := time.Now()
+	err := conn.client.Mail(from)
+	m.metrics.observe(PhaseMail, start)
+	if err != nil {
+		if isConnectionLost(err) {
+			return &staleConnectionError{err: err}
+		}
+		return &SMTPError{Command: "MAIL", Address: m.getEnvelopeFrom(), Err: err}
+	}
 
-	if _, err := w.Write([]byte(headers)); err != nil {
-		return err
+	for i, recipient := range m.recipients() {
+		conn.setDeadline(timeouts.Rcpt)
+		start := time.Now()
+		err := conn.client.Rcpt(to[i])
+		m.metrics.observe(PhaseRcpt, start)
+		if err != nil {
+			return &SMTPError{Command: "RCPT", Address: recipient, Err: err}
+		}
 	}
+	return nil
+}
 
-	// Content section
-	contentPart, err := writer.CreatePart(textproto.MIMEHeader{
-		"Content-Type": []string{"text/html; charset=UTF-8"},
-	})
+// recipients returns the envelope recipients of the email: To, Cc and Bcc, the
+// recipients of a SendToAudience batch, or only the redirect address when
+// RedirectAllTo is set, followed by the journal addresses
+func (m *Mail) recipients() []string {
+	if m.redirectTo != "" {
+		return append([]string{m.redirectTo}, m.journal...)
+	}
+	if m.envelopeTo != nil {
+		return append(append([]string{}, m.envelopeTo...), m.journal...)
+	}
+	recipients := make([]string, 0, len(m.To)+len(m.Cc)+len(m.Bcc)+len(m.journal))
+	recipients = append(recipients, m.To...)
+	recipients = append(recipients, m.Cc...)
+	recipients = append(recipients, m.Bcc...)
+	return append(recipients, m.journal...)
+}
+
+// writeMessage writes the headers and the body of the email to w
+func (m *Mail) writeMessage(w io.Writer) error {
+	if m.ContentType == TextPlain && !m.hasAttachments() && m.calendar == nil {
+		return m.writePlainMessage(w)
+	}
+
+	writer, err := m.newMultipartWriter(w)
 	if err != nil {
 		return err
 	}
-	if _, err := contentPart.Write([]byte(m.Content)); err != nil {
+	defer writer.Close()
+
+	// Write headers
+	if _, err := io.WriteString(w, m.headers()+
+		"Content-Type: "+multipartType("mixed", writer.Boundary())+"\r\n\r\n"); err != nil {
+		return err
+	}
+
+	// Content section
+	if err := m.writeContent(writer); err != nil {
 		return err
 	}
 
 	// Regular attachments
-	for filename, data := range m.Attachments {
+	for _, filename := range sortedKeys(m.Attachments) {
+		data := m.Attachments[filename]
 		attachmentPart, err := writer.CreatePart(textproto.MIMEHeader{
 			"Content-Type":              []string{"application/octet-stream"},
 			"Content-Transfer-Encoding": []string{"base64"},
-			"Content-Disposition":       []string{fmt.Sprintf(`attachment; filename="%s"`, filename)},
+			"Content-Disposition":       []string{contentDisposition("attachment", filename)},
 		})
 		if err != nil {
 			return err
 		}
 
-		encoder := base64.NewEncoder(base64.StdEncoding, attachmentPart)
-		if _, err := encoder.Write(data); err != nil {
+		if err := writeBase64(attachmentPart, bytes.NewReader(data)); err != nil {
 			return err
 		}
-		encoder.Close()
 	}
 
 	// Streaming attachments
@@ -251,28 +702,188 @@ func (m *Mail) send() error {
 		attachmentPart, err := writer.CreatePart(textproto.MIMEHeader{
 			"Content-Type":              []string{"application/octet-stream"},
 			"Content-Transfer-Encoding": []string{"base64"},
-			"Content-Disposition":       []string{fmt.Sprintf(`attachment; filename="%s"`, attachment.Name)},
+			"Content-Disposition":       []string{contentDisposition("attachment", attachment.Name)},
 		})
 		if err != nil {
 			return err
 		}
 
-		encoder := base64.NewEncoder(base64.StdEncoding, attachmentPart)
-		if _, err := io.Copy(encoder, attachment.Reader); err != nil {
+		if err := writeBase64(attachmentPart, newAttachmentStream(attachment)); err != nil {
+			return err
+		}
+	}
+
+	// Prepared attachments
+	for _, attachment := range m.attachmentParts {
+		if err := attachment.writePart(writer); err != nil {
+			return err
+		}
+	}
+
+	// Custom parts
+	for _, part := range m.customParts {
+		if err := part.writePart(writer); err != nil {
 			return err
 		}
-		encoder.Close()
 	}
 
 	return nil
 }
 
+// writePlainMessage writes a single part text/plain message without a multipart wrapper
+func (m *Mail) writePlainMessage(w io.Writer) error {
+	content, err := encodeCharset(m.getCharset(), m.Content)
+	if err != nil {
+		return err
+	}
+	encoding, err := chooseTransferEncoding(m.getTransferEncoding(), content)
+	if err != nil {
+		return err
+	}
+
+	headers := m.headers() +
+		fmt.Sprintf("Content-Type: text/plain; charset=%s\r\n", m.getCharset()) +
+		fmt.Sprintf("Content-Transfer-Encoding: %s\r\n\r\n", encoding)
+
+	if _, err := w.Write([]byte(headers)); err != nil {
+		return err
+	}
+	return writeTextPart(w, encoding, content)
+}
+
+// headers returns the address, subject, Message-ID and MIME-Version headers of the
+// email in canonical order (From, To, Cc, Subject, Message-ID, MIME-Version),
+// folded per RFC 5322. The Message-ID is only written while one is assigned.
+// Bcc recipients are never written to the headers.
+func (m *Mail) headers() string {
+	var b strings.Builder
+	b.Grow(128 + len(m.Name) + len(m.From) + len(m.Subject))
+
+	writeFoldedHeader(&b, "From", strings.Split(m.headerText(sanitizeHeaderValue(m.Name))+" <"+sanitizeHeaderValue(m.From)+">", " "))
+	if m.redirectTo != "" {
+		writeFoldedHeader(&b, "To", m.addressTokens([]string{m.redirectTo}))
+	} else {
+		writeFoldedHeader(&b, "To", m.addressTokens(m.To))
+		if len(m.Cc) > 0 {
+			writeFoldedHeader(&b, "Cc", m.addressTokens(m.Cc))
+		}
+	}
+	writeFoldedHeader(&b, "Subject", strings.Split(m.headerText(sanitizeHeaderValue(m.Subject)), " "))
+	if m.redirectTo != "" {
+		// Keep the original recipients visible to whoever reads the redirected email
+		writeFoldedHeader(&b, "X-Original-To", m.addressTokens(m.To))
+		if len(m.Cc) > 0 {
+			writeFoldedHeader(&b, "X-Original-Cc", m.addressTokens(m.Cc))
+		}
+		if len(m.Bcc) > 0 {
+			writeFoldedHeader(&b, "X-Original-Bcc", m.addressTokens(m.Bcc))
+		}
+	}
+	if m.messageID != "" {
+		b.WriteString("Message-ID: <" + sanitizeHeaderValue(m.messageID) + ">\r\n")
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	return b.String()
+}
+
+// maxHeaderLineLength is the recommended maximum header line length of RFC 5322
+const maxHeaderLineLength = 78
+
+// writeFoldedHeader writes a header whose value is tokens separated by single
+// spaces, folding the line before a token that would exceed 78 characters
+func writeFoldedHeader(b *strings.Builder, name string, tokens []string) {
+	b.WriteString(name)
+	b.WriteByte(':')
+	lineLength := len(name) + 1
+	for i, token := range tokens {
+		if i > 0 && lineLength+1+len(token) > maxHeaderLineLength {
+			b.WriteString("\r\n")
+			lineLength = 0
+		}
+		b.WriteByte(' ')
+		b.WriteString(token)
+		lineLength += 1 + len(token)
+	}
+	b.WriteString("\r\n")
+}
+
+// addressTokens returns the addresses as tokens of a comma separated header
+// value, with display names encoded as header text
+func (m *Mail) addressTokens(addresses []string) []string {
+	tokens := make([]string, len(addresses))
+	for i, address := range addresses {
+		tokens[i] = sanitizeHeaderValue(address)
+		if parsed, err := mail.ParseAddress(tokens[i]); err == nil && parsed.Name != "" && !isASCII(parsed.Name) {
+			tokens[i] = m.headerText(parsed.Name) + " <" + parsed.Address + ">"
+		}
+		if i < len(addresses)-1 {
+			tokens[i] += ","
+		}
+	}
+	return tokens
+}
+
+// headerText returns s as RFC 2047 encoded words in the message charset when
+// s is not ASCII and the charset is not UTF-8, and s unchanged otherwise. Text
+// the charset cannot represent is encoded as UTF-8.
+func (m *Mail) headerText(s string) string {
+	charset := m.getCharset()
+	if isASCII(s) || isUTF8(charset) {
+		return s
+	}
+	encoded, err := encodeCharset(charset, s)
+	if err != nil {
+		return mime.QEncoding.Encode("UTF-8", s)
+	}
+	return mime.QEncoding.Encode(charset, string(encoded))
+}
+
+// headerSanitizer replaces line breaks, which would start a new header, with spaces
+var headerSanitizer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// sanitizeHeaderValue makes value safe to embed in a single header
+func sanitizeHeaderValue(value string) string {
+	if !hasLineBreak(value) {
+		return value
+	}
+	return headerSanitizer.Replace(value)
+}
+
+// hasLineBreak reports whether s contains a CR or LF
+func hasLineBreak(s string) bool {
+	return strings.ContainsAny(s, "\r\n")
+}
+
+// hasAttachments reports whether the email carries any attachments or custom parts
+func (m *Mail) hasAttachments() bool {
+	return len(m.Attachments) > 0 || len(m.streamAttachments) > 0 || len(m.inlineAttachments) > 0 ||
+		len(m.attachmentParts) > 0 || len(m.customParts) > 0
+}
+
 // validate checks if all required fields are set and valid
 func (m *Mail) validate() bool {
+	// Check required connection fields
+	if endpoint := m.endpoint(); endpoint.Host == "" || endpoint.Port == "" || !m.hasCredentials() {
+		return false
+	}
+	return m.validateMessage()
+}
+
+// validateMessage checks if the message fields are set and valid
+func (m *Mail) validateMessage() bool {
 	// Check required fields
-	if m.From == "" || m.Name == "" || m.Host == "" || m.Port == "" ||
-		m.User == "" || m.Pass == "" || m.Subject == "" || m.Content == "" ||
-		len(m.To) == 0 {
+	if m.From == "" || m.Name == "" || m.Subject == "" || m.Content == "" || len(m.To) == 0 {
+		return false
+	}
+
+	if _, err := m.bodyMediaType(); err != nil {
+		log.Printf("Invalid content type: %s", m.ContentType)
+		return false
+	}
+
+	// Reject line breaks that would inject headers
+	if hasLineBreak(m.Name) || hasLineBreak(m.Subject) {
+		log.Printf("Invalid header value: name and subject must not contain line breaks")
 		return false
 	}
 
@@ -306,13 +917,36 @@ func (m *Mail) validate() bool {
 		}
 	}
 
+	if m.calendar != nil && m.calendar.event.UID == "" {
+		log.Printf("Invalid calendar event: missing UID")
+		return false
+	}
+
+	if m.redirectTo != "" && !m.isEmailValid(m.redirectTo) {
+		log.Printf("Invalid redirect email address: %s", m.redirectTo)
+		return false
+	}
+
+	for _, email := range m.journal {
+		if !m.isEmailValid(email) {
+			log.Printf("Invalid journal email address: %s", email)
+			return false
+		}
+	}
+
 	return true
 }
 
+// emailRegex matches valid email addresses; compiled once as it is used on every send
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// internationalEmailRegex matches valid email addresses with letters and digits
+// of any script, such as 用户@例子.中国
+var internationalEmailRegex = regexp.MustCompile(`^[\p{L}\p{M}\p{N}._%+-]+@[\p{L}\p{M}\p{N}.-]+\.(?:[\p{L}\p{M}]{2,}|xn--[a-zA-Z0-9-]+)$`)
+
 // isEmailValid checks if the email address format is valid
 func (m *Mail) isEmailValid(email string) bool {
-	regex := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
-	return regexp.MustCompile(regex).MatchString(email)
+	return emailRegex.MatchString(email) || (!isASCII(email) && internationalEmailRegex.MatchString(email))
 }
 
 // getTimeout returns the timeout duration with a default of 5 seconds
@@ -323,6 +957,84 @@ func (m *Mail) getTimeout() time.Duration {
 	return m.Timeout
 }
 
+// endpoint returns the primary SMTP server of the email
+func (m *Mail) endpoint() Endpoint {
+	m.configMutex.RLock()
+	defer m.configMutex.RUnlock()
+	return Endpoint{Host: m.Host, Port: m.Port}
+}
+
+// getEnvelopeFrom returns the SMTP envelope sender, which defaults to From
+func (m *Mail) getEnvelopeFrom() string {
+	if m.envelopeFrom == "" {
+		return m.From
+	}
+	return m.envelopeFrom
+}
+
+// getCharset returns the message charset with a default of UTF-8
+func (m *Mail) getCharset() string {
+	if m.charset == "" {
+		return DefaultCharset
+	}
+	return m.charset
+}
+
+// getCommandTimeouts returns the per command timeouts. Unset values fall back to
+// the connect timeout for the greeting, the data timeout for DATA and the command
+// timeout for the other commands.
+func (m *Mail) getCommandTimeouts() CommandTimeouts {
+	var timeouts CommandTimeouts
+	if m.commandTimeouts != nil {
+		timeouts = *m.commandTimeouts
+	}
+	fallbacks := map[*time.Duration]time.Duration{
+		&timeouts.Greeting: m.getConnectTimeout(),
+		&timeouts.Hello:    m.getCommandTimeout(),
+		&timeouts.Auth:     m.getCommandTimeout(),
+		&timeouts.Mail:     m.getCommandTimeout(),
+		&timeouts.Rcpt:     m.getCommandTimeout(),
+		&timeouts.Data:     m.getDataTimeout(),
+		&timeouts.Quit:     m.getCommandTimeout(),
+	}
+	for d, fallback := range fallbacks {
+		if *d == 0 {
+			*d = fallback
+		}
+	}
+	return timeouts
+}
+
+// getConnectTimeout returns the connect timeout, falling back to the mail
+// timeout and then to DefaultConnectTimeout
+func (m *Mail) getConnectTimeout() time.Duration {
+	return m.phaseTimeout(m.connectTimeout, DefaultConnectTimeout)
+}
+
+// getCommandTimeout returns the command timeout, falling back to the mail
+// timeout and then to DefaultCommandTimeout
+func (m *Mail) getCommandTimeout() time.Duration {
+	return m.phaseTimeout(m.commandTimeout, DefaultCommandTimeout)
+}
+
+// getDataTimeout returns the data timeout, falling back to the mail timeout
+// and then to DefaultDataTimeout
+func (m *Mail) getDataTimeout() time.Duration {
+	return m.phaseTimeout(m.dataTimeout, DefaultDataTimeout)
+}
+
+// phaseTimeout returns timeout when set, else the mail timeout when set, else fallback
+func (m *Mail) phaseTimeout(timeout, fallback time.Duration) time.Duration {
+	switch {
+	case timeout > 0:
+		return timeout
+	case m.Timeout > 0:
+		return m.Timeout
+	default:
+		return fallback
+	}
+}
+
 // getKeepAlive returns the keep-alive duration with a default of 10 seconds
 func (m *Mail) getKeepAlive() time.Duration {
 	if m.KeepAlive == 0 {
@@ -331,13 +1043,11 @@ func (m *Mail) getKeepAlive() time.Duration {
 	return m.KeepAlive
 }
 
-// SendAsync sends the email asynchronously and returns a channel for the result
+// SendAsync sends the email asynchronously and returns a channel for the result.
+// With an async queue set, the email waits in the queue for a free worker.
 func (m *Mail) SendAsync() chan error {
 	result := make(chan error, 1)
-	go func() {
-		result <- m.Send()
-		close(result)
-	}()
+	m.sendAsync(asyncJob{result: result})
 	return result
 }
 
@@ -347,6 +1057,16 @@ func (m *Mail) SetStreamAttachment(attachments []AttachmentReader) *Mail {
 	return m
 }
 
+// SetInlineAttachment sets attachments embedded in the HTML content.
+// Each attachment is referenced from the HTML as cid:<Name>.
+func (m *Mail) SetInlineAttachment(attachments []Attachment) *Mail {
+	for i := range attachments {
+		attachments[i].Inline = true
+	}
+	m.inlineAttachments = attachments
+	return m
+}
+
 // SetTLSConfig sets the TLS configuration
 func (m *Mail) SetTLSConfig(config *TLSConfig) *Mail {
 	m.tlsConfig = config
@@ -359,6 +1079,14 @@ type RateLimit struct {
 	PerSecond int
 }
 
+// interval returns the time between sends allowed by the rate limit, or zero when it is disabled
+func (r *RateLimit) interval() time.Duration {
+	if r == nil || !r.Enabled || r.PerSecond <= 0 {
+		return 0
+	}
+	return time.Second / time.Duration(r.PerSecond)
+}
+
 // SetRateLimit configures rate limiting
 func (m *Mail) SetRateLimit(limit *RateLimit) *Mail {
 	if limit != nil && limit.Enabled {
@@ -371,7 +1099,7 @@ func (m *Mail) SetRateLimit(limit *RateLimit) *Mail {
 			return m
 		}
 		interval := time.Second / time.Duration(limit.PerSecond)
-		m.rateLimiter = time.NewTicker(interval)
+		m.rateLimiter = m.getClock().NewTicker(interval)
 	} else {
 		if m.rateLimiter != nil {
 			m.rateLimiter.Stop()
@@ -399,32 +1127,14 @@ func (m *Mail) RenderTemplate(name string, data any) error {
 		return errors.New("template engine not configured")
 	}
 
-	m.templateMutex.RLock()
-	tmpl, exists := m.templateCache[name]
-	m.templateMutex.RUnlock()
-
-	if !exists {
-		// Load and cache template
-		filePath := filepath.Join(m.TemplateEngine.BaseDir, name+m.TemplateEngine.DefaultExt)
-		var err error
-		tmpl, err = template.New(name).
-			Funcs(m.TemplateEngine.FuncMap).
-			ParseFiles(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to parse template: %v", err)
-		}
-
-		m.templateMutex.Lock()
-		if m.templateCache == nil {
-			m.templateCache = make(map[string]*template.Template)
-		}
-		m.templateCache[name] = tmpl
-		m.templateMutex.Unlock()
+	tmpl, err := m.TemplateEngine.cachedTemplate(name)
+	if err != nil {
+		return err
 	}
 
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to execute template: %v", err)
+		return fmt.Errorf("failed to execute template: %w", err)
 	}
 
 	m.Content = buf.String()
@@ -3,6 +3,7 @@ package gomail
 import (
 	"bufio"
 	"bytes"
+	"crypto/tls"
 	"errors"
 	"log"
 	"net"
@@ -22,6 +23,27 @@ type mockSMTPServer struct {
 	messages []string
 	quit     chan bool
 	mu       sync.Mutex
+	// stallOn makes the server stop responding once a line with this prefix is received
+	stallOn string
+	// connections counts the accepted connections
+	connections int
+	// quits counts the QUIT commands received
+	quits int
+	// hangUpAfterMessage makes the server close the connection after every accepted message
+	hangUpAfterMessage bool
+	// rcptReplies are returned, in order, instead of accepting the next recipients
+	rcptReplies []string
+	// resets counts the RSET commands received
+	resets int
+	// mails and rcpts hold the MAIL and RCPT commands received, without the line break
+	mails []string
+	rcpts []string
+	// ehlos holds the EHLO commands received, without the line break
+	ehlos []string
+	// extensions are advertised in the EHLO response besides AUTH
+	extensions []string
+	// tlsConfig, when set, makes the server offer STARTTLS
+	tlsConfig *tls.Config
 }
 
 func newMockSMTPServer(tb testingTB) *mockSMTPServer {
@@ -66,6 +88,10 @@ func (s *mockSMTPServer) handleConnection(conn net.Conn) {
 		}
 	}()
 
+	s.mu.Lock()
+	s.connections++
+	s.mu.Unlock()
+
 	// Set connection timeouts
 	conn.SetDeadline(time.Now().Add(5 * time.Second))
 
@@ -85,14 +111,50 @@ func (s *mockSMTPServer) handleConnection(conn net.Conn) {
 
 		message.WriteString(line)
 
+		if s.shouldStall(line) {
+			<-s.quit
+			return
+		}
+
 		switch {
 		case strings.HasPrefix(line, "EHLO"):
-			conn.Write([]byte("250-mock.server\r\n250 AUTH PLAIN\r\n"))
+			s.mu.Lock()
+			s.ehlos = append(s.ehlos, strings.TrimSuffix(line, "\r\n"))
+			reply := "250-mock.server\r\n"
+			for _, extension := range s.extensions {
+				reply += "250-" + extension + "\r\n"
+			}
+			if _, encrypted := conn.(*tls.Conn); s.tlsConfig != nil && !encrypted {
+				reply += "250-STARTTLS\r\n"
+			}
+			s.mu.Unlock()
+			conn.Write([]byte(reply + "250 AUTH PLAIN\r\n"))
+		case strings.HasPrefix(line, "STARTTLS"):
+			s.mu.Lock()
+			config := s.tlsConfig
+			s.mu.Unlock()
+			if config == nil {
+				conn.Write([]byte("454 4.7.0 TLS not available\r\n"))
+				continue
+			}
+			conn.Write([]byte("220 Ready to start TLS\r\n"))
+			conn = tls.Server(conn, config)
+			reader = bufio.NewReader(conn)
 		case strings.HasPrefix(line, "AUTH"):
 			conn.Write([]byte("235 Authentication successful\r\n"))
 		case strings.HasPrefix(line, "MAIL FROM"):
+			s.mu.Lock()
+			s.mails = append(s.mails, strings.TrimSuffix(line, "\r\n"))
+			s.mu.Unlock()
 			conn.Write([]byte("250 Sender OK\r\n"))
 		case strings.HasPrefix(line, "RCPT TO"):
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, strings.TrimSuffix(line, "\r\n"))
+			s.mu.Unlock()
+			if reply := s.nextRcptReply(); reply != "" {
+				conn.Write([]byte(reply + "\r\n"))
+				continue
+			}
 			conn.Write([]byte("250 Recipient OK\r\n"))
 		case strings.HasPrefix(line, "DATA"):
 			conn.Write([]byte("354 Start mail input\r\n"))
@@ -103,15 +165,31 @@ func (s *mockSMTPServer) handleConnection(conn net.Conn) {
 				}
 				message.WriteString(line)
 				if line == ".\r\n" {
+					if s.shouldStall(line) {
+						<-s.quit
+						return
+					}
 					break
 				}
 			}
 			conn.Write([]byte("250 Message accepted\r\n"))
 			s.mu.Lock()
 			s.messages = append(s.messages, message.String())
+			hangUp := s.hangUpAfterMessage
 			s.mu.Unlock()
 			message.Reset()
+			if hangUp {
+				return
+			}
+		case strings.HasPrefix(line, "RSET"):
+			s.mu.Lock()
+			s.resets++
+			s.mu.Unlock()
+			conn.Write([]byte("250 Reset OK\r\n"))
 		case strings.HasPrefix(line, "QUIT"):
+			s.mu.Lock()
+			s.quits++
+			s.mu.Unlock()
 			conn.Write([]byte("221 Bye\r\n"))
 			return
 		}
@@ -126,6 +204,77 @@ func (s *mockSMTPServer) close() {
 	s.messages = nil
 }
 
+func (s *mockSMTPServer) stall(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stallOn = prefix
+}
+
+func (s *mockSMTPServer) connectionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+func (s *mockSMTPServer) quitCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quits
+}
+
+func (s *mockSMTPServer) advertise(extensions ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.extensions = append(s.extensions, extensions...)
+}
+
+func (s *mockSMTPServer) resetCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resets
+}
+
+func (s *mockSMTPServer) ehloCommands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.ehlos...)
+}
+
+func (s *mockSMTPServer) mailCommands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.mails...)
+}
+
+func (s *mockSMTPServer) rcptCommands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.rcpts...)
+}
+
+func (s *mockSMTPServer) replyToRcpt(replies ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rcptReplies = append(s.rcptReplies, replies...)
+}
+
+func (s *mockSMTPServer) nextRcptReply() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.rcptReplies) == 0 {
+		return ""
+	}
+	reply := s.rcptReplies[0]
+	s.rcptReplies = s.rcptReplies[1:]
+	return reply
+}
+
+func (s *mockSMTPServer) shouldStall(line string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stallOn != "" && strings.HasPrefix(line, s.stallOn)
+}
+
 func (s *mockSMTPServer) addr() string {
 	return s.listener.Addr().String()
 }
@@ -2,6 +2,9 @@ package gomail
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
@@ -403,11 +406,12 @@ func TestEmailContentAndHeaders(t *testing.T) {
 	// Wait for message processing
 	time.Sleep(100 * time.Millisecond)
 
-	if len(server.messages) == 0 {
+	messages := server.getMessages()
+	if len(messages) == 0 {
 		t.Fatal("No messages received")
 	}
 
-	msg := server.messages[0]
+	msg := messages[0]
 	expectedHeaders := []string{
 		"From: Test Sender <sender@example.com>",
 		"To: recipient@example.com",
@@ -693,6 +697,101 @@ func TestTLSConfiguration(t *testing.T) {
 	}
 }
 
+func TestRequireTLS(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions []string
+		tlsConfig  *TLSConfig
+		wantErr    string
+	}{
+		{"not offered", nil, &TLSConfig{StartTLS: true, RequireTLS: true}, "server does not offer STARTTLS"},
+		{"handshake failed", []string{"STARTTLS"}, &TLSConfig{StartTLS: true, RequireTLS: true}, "STARTTLS failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+			server.advertise(tt.extensions...)
+
+			host, port, _ := net.SplitHostPort(server.addr())
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "pass",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+			}
+			m.SetPoolSize(1).SetTLSConfig(tt.tlsConfig)
+
+			err := m.Send()
+			if !errors.Is(err, ErrTLSRequired) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Send() error = %v, want ErrTLSRequired: %s", err, tt.wantErr)
+			}
+			if got := len(server.getMessages()); got != 0 {
+				t.Errorf("server received %d messages, want none", got)
+			}
+		})
+	}
+}
+
+func TestTLSPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		extensions  []string
+		policy      TLSPolicy
+		wantErr     bool
+		tlsRequired bool
+	}{
+		{"mandatory not offered", nil, TLSMandatory, true, true},
+		{"mandatory handshake failed", []string{"STARTTLS"}, TLSMandatory, true, true},
+		{"opportunistic not offered", nil, TLSOpportunistic, false, false},
+		{"opportunistic handshake failed", []string{"STARTTLS"}, TLSOpportunistic, true, false},
+		{"disabled", []string{"STARTTLS"}, TLSDisabled, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+			server.advertise(tt.extensions...)
+
+			host, port, _ := net.SplitHostPort(server.addr())
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "pass",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+			}
+			m.SetPoolSize(1).SetTLSConfig(&TLSConfig{Policy: tt.policy})
+
+			err := m.Send()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrTLSRequired) != tt.tlsRequired {
+				t.Errorf("Send() error = %v, want ErrTLSRequired %v", err, tt.tlsRequired)
+			}
+			want := 1
+			if tt.wantErr {
+				want = 0
+			}
+			if got := len(server.getMessages()); got != want {
+				t.Errorf("server received %d messages, want %d", got, want)
+			}
+		})
+	}
+}
+
 func TestRateLimiting(t *testing.T) {
 	server := newMockSMTPServer(t)
 	defer server.close()
@@ -712,24 +811,36 @@ func TestRateLimiting(t *testing.T) {
 	}
 
 	// Configure rate limiting to 2 emails per second
-	m.SetRateLimit(&RateLimit{
+	clock := newFakeClock()
+	m.SetClock(clock).SetRateLimit(&RateLimit{
 		Enabled:   true,
 		PerSecond: 2,
 	})
+	defer m.SetRateLimit(nil)
 
-	// Send 3 emails and measure time
-	start := time.Now()
-	for i := 0; i < 3; i++ {
-		err := m.Send()
-		if err != nil {
-			t.Errorf("Send() error = %v", err)
+	sent := make(chan error)
+	go func() {
+		for i := 0; i < 3; i++ {
+			sent <- m.Send()
 		}
-	}
-	duration := time.Since(start)
+	}()
 
-	// Should take at least 1 second due to rate limiting
-	if duration < time.Second {
-		t.Errorf("Rate limiting not working properly, took %v, expected > 1s", duration)
+	// Every email waits for the next tick, 500ms apart
+	for i := 0; i < 3; i++ {
+		select {
+		case <-sent:
+			t.Fatalf("email %d was sent before the rate limiter allowed it", i+1)
+		case <-time.After(20 * time.Millisecond):
+		}
+		clock.Advance(500 * time.Millisecond)
+		select {
+		case err := <-sent:
+			if err != nil {
+				t.Errorf("Send() error = %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("email %d was not sent after the tick", i+1)
+		}
 	}
 }
 
@@ -1230,3 +1341,652 @@ func TestRateLimitingComprehensive(t *testing.T) {
 		})
 	}
 }
+
+func TestPlainTextMessage(t *testing.T) {
+	newMail := func() *Mail {
+		return &Mail{
+			From:        "sender@example.com",
+			Name:        "Test Sender",
+			Subject:     "Disk usage alert",
+			Content:     "Disk usage above 90%",
+			To:          []string{"pager@example.com"},
+			ContentType: TextPlain,
+		}
+	}
+
+	t.Run("without attachments", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := newMail().writeMessage(&buf); err != nil {
+			t.Fatalf("writeMessage() error = %v", err)
+		}
+
+		output := buf.String()
+		if strings.Contains(output, "multipart") {
+			t.Error("plain text message should not be multipart")
+		}
+		if !strings.Contains(output, "Content-Type: text/plain; charset=UTF-8\r\n") {
+			t.Error("message missing text/plain Content-Type header")
+		}
+		if !strings.HasSuffix(output, "\r\n\r\nDisk usage above 90%") {
+			t.Errorf("unexpected message body: %q", output)
+		}
+	})
+
+	t.Run("with attachments", func(t *testing.T) {
+		m := newMail().SetAttachment(map[string][]byte{"log.txt": []byte("log")})
+		var buf bytes.Buffer
+		if err := m.writeMessage(&buf); err != nil {
+			t.Fatalf("writeMessage() error = %v", err)
+		}
+		if !strings.Contains(buf.String(), "Content-Type: multipart/mixed;") {
+			t.Error("plain text message with attachments should be multipart")
+		}
+	})
+}
+
+func TestPhaseTimeouts(t *testing.T) {
+	tests := []struct {
+		name                   string
+		mail                   *Mail
+		connect, command, data time.Duration
+	}{
+		{
+			name:    "defaults",
+			mail:    &Mail{},
+			connect: DefaultConnectTimeout,
+			command: DefaultCommandTimeout,
+			data:    DefaultDataTimeout,
+		},
+		{
+			name:    "mail timeout",
+			mail:    &Mail{Timeout: 20 * time.Second},
+			connect: 20 * time.Second,
+			command: 20 * time.Second,
+			data:    20 * time.Second,
+		},
+		{
+			name: "independent timeouts",
+			mail: (&Mail{Timeout: 20 * time.Second}).
+				SetConnectTimeout(2 * time.Second).
+				SetCommandTimeout(10 * time.Second).
+				SetDataTimeout(30 * time.Minute),
+			connect: 2 * time.Second,
+			command: 10 * time.Second,
+			data:    30 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := CommandTimeouts{
+				Greeting: tt.connect,
+				Hello:    tt.command,
+				Auth:     tt.command,
+				Mail:     tt.command,
+				Rcpt:     tt.command,
+				Data:     tt.data,
+				Quit:     tt.command,
+			}
+			if got := tt.mail.getCommandTimeouts(); got != want {
+				t.Errorf("getCommandTimeouts() = %+v, want %+v", got, want)
+			}
+			if got := tt.mail.getConnectTimeout(); got != tt.connect {
+				t.Errorf("getConnectTimeout() = %v, want %v", got, tt.connect)
+			}
+		})
+	}
+
+	// Per command timeouts take precedence
+	m := (&Mail{}).SetDataTimeout(time.Hour).SetCommandTimeouts(&CommandTimeouts{Data: time.Minute})
+	if got := m.getCommandTimeouts().Data; got != time.Minute {
+		t.Errorf("Data timeout = %v, want %v", got, time.Minute)
+	}
+}
+
+func TestCommandTimeouts(t *testing.T) {
+	m := &Mail{Timeout: 3 * time.Second}
+	m.SetCommandTimeouts(&CommandTimeouts{Data: time.Minute})
+
+	timeouts := m.getCommandTimeouts()
+	if timeouts.Data != time.Minute {
+		t.Errorf("Data timeout = %v, want %v", timeouts.Data, time.Minute)
+	}
+	if timeouts.Rcpt != 3*time.Second || timeouts.Greeting != 3*time.Second {
+		t.Errorf("unset timeouts should fall back to the mail timeout, got %+v", timeouts)
+	}
+
+	tests := []struct {
+		name    string
+		stallOn string
+	}{
+		{"stalled RCPT", "RCPT"},
+		{"stalled DATA", "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+			server.stall(tt.stallOn)
+
+			host, port, _ := net.SplitHostPort(server.addr())
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "pass",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+			}
+			m.SetPoolSize(1).SetCommandTimeouts(&CommandTimeouts{
+				Rcpt: 200 * time.Millisecond,
+				Data: 200 * time.Millisecond,
+			})
+
+			start := time.Now()
+			if err := m.Send(); err == nil {
+				t.Fatal("Send() expected timeout error")
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Send() took %v, expected the command timeout to abort it", elapsed)
+			}
+		})
+	}
+}
+
+func TestSendWithTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		stallOn string
+		wantErr bool
+	}{
+		{"sent in time", "", false},
+		{"stalled during auth", "AUTH", true},
+		{"stalled during data", ".", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+			if tt.stallOn != "" {
+				server.stall(tt.stallOn)
+			}
+
+			host, port, _ := net.SplitHostPort(server.addr())
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "pass",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+				Timeout: time.Minute,
+			}
+			m.SetPoolSize(1)
+
+			start := time.Now()
+			err := m.SendWithTimeout(200 * time.Millisecond)
+			if tt.wantErr != errors.Is(err, context.DeadlineExceeded) || (!tt.wantErr && err != nil) {
+				t.Errorf("SendWithTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("SendWithTimeout() took %v, expected the timeout to abort it", elapsed)
+			}
+		})
+	}
+}
+
+func TestSendContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		stallOn string
+	}{
+		{"cancel during greeting", "EHLO"},
+		{"cancel during auth", "AUTH"},
+		{"cancel during data", "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+			server.stall(tt.stallOn)
+
+			host, port, _ := net.SplitHostPort(server.addr())
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "pass",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+				Timeout: time.Minute,
+			}
+			m.SetPoolSize(1)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			err := m.SendContext(ctx)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("SendContext() error = %v, want context.DeadlineExceeded", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("SendContext() took %v, expected the context to abort it", elapsed)
+			}
+		})
+	}
+
+	t.Run("already cancelled", func(t *testing.T) {
+		m := &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Host:    "127.0.0.1",
+			Port:    "1",
+			User:    "user",
+			Pass:    "pass",
+			Subject: "Test Subject",
+			Content: "Test Content",
+			To:      []string{"recipient@example.com"},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := m.SendContext(ctx); err == nil {
+			t.Error("SendContext() with cancelled context should fail")
+		}
+	})
+}
+
+func TestPoolCloseSendsQuit(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From: "sender@example.com",
+		Name: "Test Sender",
+		Host: host,
+		Port: port,
+		User: "user",
+		Pass: "pass",
+	}
+	m.SetCommandTimeouts(&CommandTimeouts{Quit: time.Second})
+
+	pool, err := NewPool(m, 3)
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	pool.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.quitCount() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := server.quitCount(); got != 3 {
+		t.Errorf("server received %d QUIT commands, want 3", got)
+	}
+}
+
+func TestReconnectAfterServerDisconnect(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	server.mu.Lock()
+	server.hangUpAfterMessage = true
+	server.mu.Unlock()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1)
+
+	for i := 0; i < 3; i++ {
+		if err := m.Send(); err != nil {
+			t.Fatalf("Send() #%d error = %v", i+1, err)
+		}
+		// Give the server time to hang up the pooled connection
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if got := len(server.getMessages()); got != 3 {
+		t.Errorf("server received %d messages, want 3", got)
+	}
+}
+
+func TestPooledConnectionReset(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1)
+
+	for i := 0; i < 3; i++ {
+		if err := m.Send(); err != nil {
+			t.Fatalf("Send() #%d error = %v", i+1, err)
+		}
+	}
+
+	// Every transaction on a reused connection starts with RSET
+	messages := server.getMessages()
+	if len(messages) != 3 {
+		t.Fatalf("server received %d messages, want 3", len(messages))
+	}
+	if strings.Contains(messages[0], "RSET") {
+		t.Error("first transaction on a new connection should not be preceded by RSET")
+	}
+	if got := server.resetCount(); got != 2 {
+		t.Errorf("server received %d RSET commands, want 2", got)
+	}
+	if got := server.connectionCount(); got != 1 {
+		t.Errorf("server accepted %d connections, want 1", got)
+	}
+}
+
+func TestPoolRegistry(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	registry := NewPoolRegistry()
+	defer registry.Close()
+
+	// A Mail per request, as a web handler would create them
+	for i := 0; i < 5; i++ {
+		m := &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Host:    host,
+			Port:    port,
+			User:    "user",
+			Pass:    "pass",
+			Subject: "Test Subject",
+			Content: "Test Content",
+			To:      []string{"recipient@example.com"},
+		}
+		m.SetPoolSize(1).SetPoolRegistry(registry)
+		if err := m.Send(); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	if registry.Len() != 1 {
+		t.Errorf("registry has %d pools, want 1", registry.Len())
+	}
+	if got := server.connectionCount(); got != 1 {
+		t.Errorf("server accepted %d connections, want 1", got)
+	}
+
+	other := &Mail{Host: host, Port: port, User: "other", Pass: "pass"}
+	if _, err := registry.get(context.Background(), other, other.endpoint()); err != nil {
+		t.Fatalf("registry.get() error = %v", err)
+	}
+	if registry.Len() != 2 {
+		t.Errorf("registry has %d pools, want 2 for different users", registry.Len())
+	}
+
+	registry.Close()
+	if registry.Len() != 0 {
+		t.Errorf("registry has %d pools after Close(), want 0", registry.Len())
+	}
+}
+
+func TestRedirectAllTo(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"customer@example.com"},
+		Cc:      []string{"manager@example.com"},
+		Bcc:     []string{"audit@example.com"},
+	}
+	m.SetPoolSize(1).RedirectAllTo("qa-inbox@example.com")
+
+	if got := m.recipients(); len(got) != 1 || got[0] != "qa-inbox@example.com" {
+		t.Errorf("recipients() = %v, want only the redirect address", got)
+	}
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	messages := server.getMessages()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	for _, want := range []string{
+		"RCPT TO:<qa-inbox@example.com>",
+		"To: qa-inbox@example.com\r\n",
+		"X-Original-To: customer@example.com\r\n",
+		"X-Original-Cc: manager@example.com\r\n",
+		"X-Original-Bcc: audit@example.com\r\n",
+	} {
+		if !strings.Contains(messages[0], want) {
+			t.Errorf("message missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"RCPT TO:<customer@example.com>", "\r\nCc:"} {
+		if strings.Contains(messages[0], unwanted) {
+			t.Errorf("redirected message should not contain %q", unwanted)
+		}
+	}
+
+	m.RedirectAllTo("not an address")
+	if m.validateMessage() {
+		t.Error("validateMessage() should reject an invalid redirect address")
+	}
+}
+
+func TestJournalBcc(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1).JournalBcc("journal@example.com")
+
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	message := server.getMessages()[0]
+	if !strings.Contains(message, "RCPT TO:<journal@example.com>") {
+		t.Error("journal address should be an envelope recipient")
+	}
+	if strings.Count(message, "journal@example.com") != 1 {
+		t.Error("journal address should not appear in the headers")
+	}
+
+	// Journaling survives the staging redirect
+	m.RedirectAllTo("qa@example.com")
+	if got := m.recipients(); len(got) != 2 || got[1] != "journal@example.com" {
+		t.Errorf("recipients() = %v, want the redirect and journal addresses", got)
+	}
+
+	m.JournalBcc("invalid")
+	if m.validateMessage() {
+		t.Error("validateMessage() should reject an invalid journal address")
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Subject: "Test Subject",
+		To:      []string{"a@example.com", "b@example.com"},
+		Cc:      []string{"cc@example.com"},
+	}
+
+	want := "From: Test Sender <sender@example.com>\r\n" +
+		"To: a@example.com, b@example.com\r\n" +
+		"Cc: cc@example.com\r\n" +
+		"Subject: Test Subject\r\n" +
+		"MIME-Version: 1.0\r\n"
+	if got := m.headers(); got != want {
+		t.Errorf("headers() = %q, want %q", got, want)
+	}
+
+	// Bcc recipients and empty Cc lists are not disclosed in the headers
+	m.Cc = nil
+	m.Bcc = []string{"secret@example.com"}
+	if got := m.headers(); strings.Contains(got, "Bcc") || strings.Contains(got, "Cc") {
+		t.Errorf("headers() = %q, should not contain Cc or Bcc", got)
+	}
+
+	// Long recipient lists are folded at 78 characters
+	m.To = nil
+	for i := 0; i < 20; i++ {
+		m.To = append(m.To, fmt.Sprintf("recipient%02d@example.com", i))
+	}
+	folded := m.headers()
+	for _, line := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+		if len(line) > maxHeaderLineLength {
+			t.Errorf("header line %q exceeds %d characters", line, maxHeaderLineLength)
+		}
+	}
+	unfolded := strings.ReplaceAll(folded, "\r\n ", " ")
+	if !strings.Contains(unfolded, "To: "+strings.Join(m.To, ", ")+"\r\n") {
+		t.Errorf("folded To header does not unfold to the recipient list: %q", folded)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { m.isEmailValid("a@example.com") }); allocs > 0 {
+		t.Errorf("isEmailValid() allocates %v times per call, want 0", allocs)
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	newMail := func() *Mail {
+		return &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Host:    "smtp.example.com",
+			Port:    "587",
+			User:    "user",
+			Pass:    "pass",
+			Subject: "Test Subject",
+			Content: "Test Content",
+			To:      []string{"recipient@example.com"},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		setup func(*Mail)
+	}{
+		{"subject", func(m *Mail) { m.Subject = "Hello\r\nBcc: victim@example.com" }},
+		{"name", func(m *Mail) { m.Name = "Sender\nBcc: victim@example.com" }},
+		{"recipient", func(m *Mail) { m.To = []string{"recipient@example.com\r\nBcc: victim@example.com"} }},
+		{"sender", func(m *Mail) { m.From = "sender@example.com\r\nBcc: victim@example.com" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMail()
+			tt.setup(m)
+			if m.validate() {
+				t.Error("validate() should reject header values containing line breaks")
+			}
+			if strings.Contains(m.headers(), "\nBcc:") {
+				t.Error("headers() should not allow injecting a header")
+			}
+		})
+	}
+
+	t.Run("attachment filename", func(t *testing.T) {
+		m := newMail().SetAttachment(map[string][]byte{"a.txt\"\r\nX-Injected: yes": []byte("a")})
+		var buf bytes.Buffer
+		if err := m.writeMessage(&buf); err != nil {
+			t.Fatalf("writeMessage() error = %v", err)
+		}
+		if strings.Contains(buf.String(), "\r\nX-Injected") {
+			t.Error("attachment filename should not allow injecting a header")
+		}
+	})
+}
+
+func FuzzHeaders(f *testing.F) {
+	f.Add("Test Sender", "Test Subject", "recipient@example.com")
+	f.Add("Sender\r\nBcc: x@example.com", "Hi\nX-Injected: 1", "a@example.com\r\nCc: b@example.com")
+	f.Add("", "\r\n\r\n", "")
+
+	f.Fuzz(func(t *testing.T, name, subject, to string) {
+		m := &Mail{From: "sender@example.com", Name: name, Subject: subject, To: []string{to}}
+		headers := m.headers()
+
+		// Every line must either start a known header or be a folded continuation
+		for _, line := range strings.Split(strings.TrimSuffix(headers, "\r\n"), "\r\n") {
+			if strings.HasPrefix(line, " ") {
+				continue
+			}
+			key, _, _ := strings.Cut(line, ":")
+			switch key {
+			case "From", "To", "Subject", "MIME-Version":
+			default:
+				t.Fatalf("unexpected header line %q in %q", line, headers)
+			}
+		}
+		if strings.Count(headers, "\r") != strings.Count(headers, "\n") {
+			t.Fatalf("headers contain a bare CR or LF: %q", headers)
+		}
+	})
+}
+
+func FuzzIsEmailValid(f *testing.F) {
+	f.Add("recipient@example.com")
+	f.Add("a@example.com\r\nBcc: b@example.com")
+	f.Add("a b@example.com")
+
+	f.Fuzz(func(t *testing.T, email string) {
+		m := &Mail{}
+		if m.isEmailValid(email) && strings.ContainsAny(email, "\r\n \t<>,\"") {
+			t.Fatalf("isEmailValid(%q) accepted an address with header syntax characters", email)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+// Package mailtest provides a client for the HTTP APIs of the Mailpit and
+// MailHog development mail servers, so end-to-end tests can assert on the
+// emails those servers received.
+//
+//	inbox := mailtest.NewClient(mailtest.Mailpit, "http://localhost:8025")
+//	if err := inbox.Clear(ctx); err != nil {
+//		t.Fatal(err)
+//	}
+//	// send the email through the server's SMTP port
+//	messages, err := inbox.Messages(ctx)
+package mailtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Server selects the HTTP API of the development mail server
+type Server int
+
+const (
+	// Mailpit speaks the Mailpit API (https://mailpit.axllent.org/docs/api-v1/)
+	Mailpit Server = iota
+	// MailHog speaks the MailHog API (https://github.com/mailhog/MailHog/tree/master/docs/APIv2)
+	MailHog
+)
+
+// Message summarizes an email received by the server
+type Message struct {
+	ID      string
+	From    string
+	To      []string
+	Subject string
+	Created time.Time
+}
+
+// Client queries the HTTP API of a Mailpit or MailHog server
+type Client struct {
+	server     Server
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the API of server at baseURL, e.g. "http://localhost:8025"
+func NewClient(server Server, baseURL string) *Client {
+	return &Client{
+		server:     server,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+}
+
+// SetHTTPClient sets the HTTP client used for the API requests
+func (c *Client) SetHTTPClient(client *http.Client) *Client {
+	c.httpClient = client
+	return c
+}
+
+// Messages returns the emails received by the server, newest first
+func (c *Client) Messages(ctx context.Context) ([]Message, error) {
+	if c.server == MailHog {
+		return c.mailHogMessages(ctx)
+	}
+	return c.mailpitMessages(ctx)
+}
+
+// Raw returns the source of the email with the given ID as received by the server
+func (c *Client) Raw(ctx context.Context, id string) ([]byte, error) {
+	path := "/api/v1/message/" + url.PathEscape(id) + "/raw"
+	if c.server == MailHog {
+		path = "/api/v1/messages/" + url.PathEscape(id) + "/download"
+	}
+
+	body, err := c.do(ctx, http.MethodGet, path)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// Clear deletes every email received by the server
+func (c *Client) Clear(ctx context.Context) error {
+	body, err := c.do(ctx, http.MethodDelete, "/api/v1/messages")
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+// mailpitAddress is an address in the Mailpit API
+type mailpitAddress struct {
+	Name    string `json:"Name"`
+	Address string `json:"Address"`
+}
+
+// mailpitMessages lists the emails through the Mailpit API
+func (c *Client) mailpitMessages(ctx context.Context) ([]Message, error) {
+	var response struct {
+		Messages []struct {
+			ID      string           `json:"ID"`
+			From    mailpitAddress   `json:"From"`
+			To      []mailpitAddress `json:"To"`
+			Subject string           `json:"Subject"`
+			Created time.Time        `json:"Created"`
+		} `json:"messages"`
+	}
+	if err := c.getJSON(ctx, "/api/v1/messages", &response); err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, len(response.Messages))
+	for i, m := range response.Messages {
+		messages[i] = Message{ID: m.ID, From: m.From.Address, Subject: m.Subject, Created: m.Created}
+		for _, to := range m.To {
+			messages[i].To = append(messages[i].To, to.Address)
+		}
+	}
+	return messages, nil
+}
+
+// mailHogPath is an address in the MailHog API
+type mailHogPath struct {
+	Mailbox string `json:"Mailbox"`
+	Domain  string `json:"Domain"`
+}
+
+// address returns the email address of p
+func (p mailHogPath) address() string {
+	return p.Mailbox + "@" + p.Domain
+}
+
+// mailHogMessages lists the emails through the MailHog API
+func (c *Client) mailHogMessages(ctx context.Context) ([]Message, error) {
+	var response struct {
+		Items []struct {
+			ID      string        `json:"ID"`
+			From    mailHogPath   `json:"From"`
+			To      []mailHogPath `json:"To"`
+			Content struct {
+				Headers map[string][]string `json:"Headers"`
+			} `json:"Content"`
+			Created time.Time `json:"Created"`
+		} `json:"items"`
+	}
+	if err := c.getJSON(ctx, "/api/v2/messages", &response); err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, len(response.Items))
+	for i, m := range response.Items {
+		messages[i] = Message{ID: m.ID, From: m.From.address(), Created: m.Created}
+		if subject := m.Content.Headers["Subject"]; len(subject) > 0 {
+			messages[i].Subject = subject[0]
+		}
+		for _, to := range m.To {
+			messages[i].To = append(messages[i].To, to.address())
+		}
+	}
+	return messages, nil
+}
+
+// getJSON decodes the JSON response to a GET request of path into v
+func (c *Client) getJSON(ctx context.Context, path string, v any) error {
+	body, err := c.do(ctx, http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response to %s: %w", path, err)
+	}
+	return nil
+}
+
+// do sends a request to path and returns the response body, failing on any status but 2xx
+func (c *Client) do(ctx context.Context, method, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: unexpected status %s", method, path, resp.Status)
+	}
+	return resp.Body, nil
+}
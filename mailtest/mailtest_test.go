@@ -0,0 +1,109 @@
+package mailtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newAPI returns a server answering the given paths, keyed by method and path,
+// and recording the requests it served
+func newAPI(t *testing.T, responses map[string]string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path
+		requests = append(requests, key)
+		body, ok := responses[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		server    Server
+		responses map[string]string
+	}{
+		{
+			name:   "Mailpit",
+			server: Mailpit,
+			responses: map[string]string{
+				"GET /api/v1/messages": `{"total":1,"messages":[{"ID":"abc","From":{"Name":"Sender","Address":"sender@example.com"},
+					"To":[{"Name":"","Address":"recipient@example.com"}],"Subject":"Welcome","Created":"2024-05-01T09:00:00Z"}]}`,
+				"GET /api/v1/message/abc/raw": "Subject: Welcome\r\n\r\nHello",
+				"DELETE /api/v1/messages":     "ok",
+			},
+		},
+		{
+			name:   "MailHog",
+			server: MailHog,
+			responses: map[string]string{
+				"GET /api/v2/messages": `{"total":1,"items":[{"ID":"abc","From":{"Mailbox":"sender","Domain":"example.com"},
+					"To":[{"Mailbox":"recipient","Domain":"example.com"}],"Content":{"Headers":{"Subject":["Welcome"]}},"Created":"2024-05-01T09:00:00Z"}]}`,
+				"GET /api/v1/messages/abc/download": "Subject: Welcome\r\n\r\nHello",
+				"DELETE /api/v1/messages":           "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, _ := newAPI(t, tt.responses)
+			client := NewClient(tt.server, api.URL+"/")
+			ctx := context.Background()
+
+			messages, err := client.Messages(ctx)
+			if err != nil {
+				t.Fatalf("Messages() error = %v", err)
+			}
+			if len(messages) != 1 {
+				t.Fatalf("Messages() returned %d messages, want 1", len(messages))
+			}
+			got := messages[0]
+			if got.ID != "abc" || got.From != "sender@example.com" || got.Subject != "Welcome" ||
+				!reflect.DeepEqual(got.To, []string{"recipient@example.com"}) || got.Created.IsZero() {
+				t.Errorf("Messages()[0] = %+v", got)
+			}
+
+			raw, err := client.Raw(ctx, got.ID)
+			if err != nil {
+				t.Fatalf("Raw() error = %v", err)
+			}
+			if string(raw) != "Subject: Welcome\r\n\r\nHello" {
+				t.Errorf("Raw() = %q", raw)
+			}
+
+			if err := client.Clear(ctx); err != nil {
+				t.Errorf("Clear() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	api, requests := newAPI(t, map[string]string{"GET /api/v1/messages": "not json"})
+	client := NewClient(Mailpit, api.URL)
+	ctx := context.Background()
+
+	if _, err := client.Messages(ctx); err == nil {
+		t.Error("Messages() with an invalid response should fail")
+	}
+	if _, err := client.Raw(ctx, "missing"); err == nil {
+		t.Error("Raw() of a missing message should fail")
+	}
+	if err := client.Clear(ctx); err == nil {
+		t.Error("Clear() with an error status should fail")
+	}
+	if len(*requests) != 3 {
+		t.Errorf("server received %d requests, want 3", len(*requests))
+	}
+}
@@ -0,0 +1,60 @@
+package gomail
+
+import (
+	"slices"
+	"time"
+)
+
+// SetMetrics records the latency of the SMTP phases of the email in metrics
+func (m *Mail) SetMetrics(metrics *Metrics) *Mail {
+	m.metrics = metrics
+	return m
+}
+
+// Histogram returns a copy of the latency histogram of phase
+func (mt *Metrics) Histogram(phase SMTPPhase) LatencyHistogram {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	if h, ok := mt.histograms[phase]; ok {
+		return LatencyHistogram{Buckets: h.Buckets, Counts: slices.Clone(h.Counts), Count: h.Count, Sum: h.Sum}
+	}
+	buckets := mt.buckets()
+	return LatencyHistogram{Buckets: buckets, Counts: make([]uint64, len(buckets)+1)}
+}
+
+// buckets returns the upper bounds of the histogram buckets
+func (mt *Metrics) buckets() []time.Duration {
+	if len(mt.Buckets) == 0 {
+		return DefaultLatencyBuckets
+	}
+	return mt.Buckets
+}
+
+// observe records the latency of phase, which started at start. It does
+// nothing on a nil Metrics.
+func (mt *Metrics) observe(phase SMTPPhase, start time.Time) {
+	if mt == nil {
+		return
+	}
+	latency := time.Since(start)
+
+	mt.mu.Lock()
+	h, ok := mt.histograms[phase]
+	if !ok {
+		buckets := mt.buckets()
+		h = &LatencyHistogram{Buckets: buckets, Counts: make([]uint64, len(buckets)+1)}
+		if mt.histograms == nil {
+			mt.histograms = make(map[SMTPPhase]*LatencyHistogram)
+		}
+		mt.histograms[phase] = h
+	}
+	bucket, _ := slices.BinarySearch(h.Buckets, latency)
+	h.Counts[bucket]++
+	h.Count++
+	h.Sum += latency
+	mt.mu.Unlock()
+
+	if mt.Observe != nil {
+		mt.Observe(phase, latency)
+	}
+}
@@ -0,0 +1,105 @@
+package gomail
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMetricsHistogram(t *testing.T) {
+	var observed []time.Duration
+	metrics := &Metrics{
+		Buckets: []time.Duration{time.Second, time.Minute},
+		Observe: func(phase SMTPPhase, latency time.Duration) {
+			if phase == PhaseAuth {
+				observed = append(observed, latency.Round(time.Second))
+			}
+		},
+	}
+	now := time.Now()
+	for _, latency := range []time.Duration{0, 30 * time.Second, 40 * time.Second, time.Hour} {
+		metrics.observe(PhaseAuth, now.Add(-latency))
+	}
+
+	h := metrics.Histogram(PhaseAuth)
+	if !slices.Equal(h.Counts, []uint64{1, 2, 1}) || h.Count != 4 {
+		t.Errorf("Histogram() counts = %v, count %d, want [1 2 1], 4", h.Counts, h.Count)
+	}
+	if h.Sum < time.Hour+70*time.Second {
+		t.Errorf("Histogram() sum = %v, want at least 1h1m10s", h.Sum)
+	}
+	if want := []time.Duration{0, 30 * time.Second, 40 * time.Second, time.Hour}; !slices.Equal(observed, want) {
+		t.Errorf("observed %v, want %v", observed, want)
+	}
+
+	// The histogram returned is a copy
+	h.Counts[0] = 100
+	if got := metrics.Histogram(PhaseAuth).Counts[0]; got != 1 {
+		t.Errorf("Histogram() shares its counts, first count = %d", got)
+	}
+
+	empty := (&Metrics{}).Histogram(PhaseData)
+	if empty.Count != 0 || len(empty.Counts) != len(DefaultLatencyBuckets)+1 {
+		t.Errorf("Histogram() of an unobserved phase = %+v", empty)
+	}
+
+	// A Mail without metrics records nothing
+	var none *Metrics
+	none.observe(PhaseConnect, now)
+}
+
+func TestSendMetrics(t *testing.T) {
+	plain := newMockSMTPServer(t)
+	defer plain.close()
+	startTLS := newMockSMTPServer(t)
+	startTLS.tlsConfig = serverTLSConfig(t)
+	defer startTLS.close()
+	direct := newMockTLSServer(t)
+	defer direct.close()
+	pipelining := newMockSMTPServer(t)
+	pipelining.advertise("PIPELINING")
+	defer pipelining.close()
+
+	tests := []struct {
+		name      string
+		server    *mockSMTPServer
+		tlsConfig *TLSConfig
+		wantTLS   uint64
+	}{
+		{name: "plain", server: plain},
+		{name: "STARTTLS", server: startTLS, tlsConfig: &TLSConfig{StartTLS: true, InsecureSkipVerify: true}, wantTLS: 1},
+		{name: "direct TLS", server: direct, tlsConfig: &TLSConfig{InsecureSkipVerify: true}, wantTLS: 1},
+		{name: "pipelining", server: pipelining},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port, _ := net.SplitHostPort(tt.server.addr())
+			metrics := new(Metrics)
+			m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).SetPoolSize(1).
+				SetTLSConfig(tt.tlsConfig).SetMetrics(metrics)
+
+			for i := 0; i < 2; i++ {
+				if err := m.Send(); err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+			}
+
+			// Both emails go to To and Bcc over the one pooled connection
+			want := map[SMTPPhase]uint64{
+				PhaseConnect: 1,
+				PhaseTLS:     tt.wantTLS,
+				PhaseAuth:    1,
+				PhaseMail:    2,
+				PhaseRcpt:    4,
+				PhaseData:    2,
+			}
+			for phase, count := range want {
+				if got := metrics.Histogram(phase).Count; got != count {
+					t.Errorf("%s latencies = %d, want %d", phase, got, count)
+				}
+			}
+		})
+	}
+}
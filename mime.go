@@ -0,0 +1,298 @@
+package gomail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mail implements io.WriterTo so composed messages can be piped to any consumer
+var _ io.WriterTo = (*Mail)(nil)
+
+// WriteTo writes the composed message, as it would be transmitted in the SMTP
+// DATA phase, to w. It lets the message be piped into other consumers such as
+// an upload, a virus scanner or an external signer, before or instead of sending it.
+func (m *Mail) WriteTo(w io.Writer) (int64, error) {
+	if !m.validateMessage() {
+		return 0, errors.New("missing parameter")
+	}
+	if err := m.attachmentPolicy.check(m); err != nil {
+		return 0, err
+	}
+	// A transport writing the message provides the copy to archive
+	if m.capture != nil {
+		m.capture.Reset()
+		w = io.MultiWriter(w, m.capture)
+	}
+	return m.writeTo(w)
+}
+
+// writeTo streams the serialized message into w and returns the number of bytes written.
+// Attachments are encoded as they are read, so memory usage does not grow with their
+// size; only DKIM signing needs the whole message buffered. A message prepared
+// for a spam check is written as it was checked.
+func (m *Mail) writeTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	var err error
+	if m.prepared != nil {
+		_, err = cw.Write(m.prepared)
+	} else if len(m.dkimConfigs()) > 0 {
+		err = m.writeSignedMessage(cw)
+	} else {
+		err = m.writeMessage(cw)
+	}
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write implements io.Writer
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// writeContent writes the body of the email into parent, nesting it as
+// multipart/related (inline attachments) > multipart/alternative (text, HTML and calendar)
+// depending on what the email carries
+func (m *Mail) writeContent(parent *multipart.Writer) error {
+	mediaType, err := m.bodyMediaType()
+	if err != nil {
+		return err
+	}
+
+	body := parent
+	if len(m.inlineAttachments) > 0 {
+		related, err := m.createMultipart(parent, "related")
+		if err != nil {
+			return err
+		}
+		body = related
+	}
+
+	if m.AltContent != "" || m.calendar != nil {
+		alternative, err := m.createMultipart(body, "alternative")
+		if err != nil {
+			return err
+		}
+		if m.AltContent != "" {
+			if err := m.writeTextBody(alternative, "text/plain", m.AltContent); err != nil {
+				return err
+			}
+		}
+		if err := m.writeTextBody(alternative, mediaType, m.Content); err != nil {
+			return err
+		}
+		if m.calendar != nil {
+			if err := m.writeCalendarPart(alternative); err != nil {
+				return err
+			}
+		}
+		if err := alternative.Close(); err != nil {
+			return err
+		}
+	} else if err := m.writeTextBody(body, mediaType, m.Content); err != nil {
+		return err
+	}
+
+	if body == parent {
+		return nil
+	}
+	for _, attachment := range m.inlineAttachments {
+		if err := writeInlinePart(body, attachment); err != nil {
+			return err
+		}
+	}
+	return body.Close()
+}
+
+// bodyMediaType returns the media type of the body part for the configured
+// ContentType, which defaults to HTML
+func (m *Mail) bodyMediaType() (string, error) {
+	switch m.ContentType {
+	case "", TextHTML:
+		return string(TextHTML), nil
+	case TextPlain, TextMarkdown:
+		return string(m.ContentType), nil
+	default:
+		return "", fmt.Errorf("unsupported content type: %q", m.ContentType)
+	}
+}
+
+// writeTextBody writes text as a part of the given media type, applying the charset and transfer encoding
+func (m *Mail) writeTextBody(writer *multipart.Writer, mediaType, text string) error {
+	content, err := encodeCharset(m.getCharset(), text)
+	if err != nil {
+		return err
+	}
+	encoding, err := chooseTransferEncoding(m.getTransferEncoding(), content)
+	if err != nil {
+		return err
+	}
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              []string{mediaType + "; charset=" + m.getCharset()},
+		"Content-Transfer-Encoding": []string{string(encoding)},
+	})
+	if err != nil {
+		return err
+	}
+	return writeTextPart(part, encoding, content)
+}
+
+// writeInlinePart writes an attachment referenced from the HTML content by its Content-ID
+func writeInlinePart(writer *multipart.Writer, attachment Attachment) error {
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              []string{sanitizeHeaderValue(attachment.contentType())},
+		"Content-Transfer-Encoding": []string{"base64"},
+		"Content-ID":                []string{"<" + sanitizeHeaderValue(attachment.Name) + ">"},
+		"Content-Disposition":       []string{contentDisposition("inline", attachment.Name)},
+	})
+	if err != nil {
+		return err
+	}
+	return writeBase64(part, bytes.NewReader(attachment.Data))
+}
+
+// contentType returns the content type of the attachment, derived from the
+// extension of its name when not set
+func (a Attachment) contentType() string {
+	if a.ContentType != "" {
+		return a.ContentType
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(a.Name)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// filenameEscaper escapes a filename for use inside a quoted-string
+var filenameEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// contentDisposition returns a Content-Disposition value of the given kind for filename.
+// Non-ASCII filenames are encoded per RFC 2231 with an ASCII fallback for older clients.
+func contentDisposition(kind, filename string) string {
+	filename = sanitizeHeaderValue(filename)
+	if isASCII(filename) {
+		return kind + `; filename="` + filenameEscaper.Replace(filename) + `"`
+	}
+	return kind + `; filename="` + filenameEscaper.Replace(asciiFilename(filename)) +
+		`"; filename*=UTF-8''` + percentEncode(filename)
+}
+
+// asciiFilename approximates filename in ASCII by stripping diacritics and
+// replacing any remaining non-ASCII character with an underscore
+func asciiFilename(filename string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(filename) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// percentEncode encodes s as an RFC 5987 ext-value, escaping every byte that is not an attr-char
+func percentEncode(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+// isAttrChar reports whether c may appear unescaped in an RFC 5987 ext-value
+func isAttrChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
+
+// createMultipart creates a nested multipart body of the given subtype as a part of parent
+func (m *Mail) createMultipart(parent *multipart.Writer, subtype string) (*multipart.Writer, error) {
+	boundary := m.newBoundary()
+	part, err := parent.CreatePart(textproto.MIMEHeader{
+		"Content-Type": []string{multipartType(subtype, boundary)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	child := multipart.NewWriter(part)
+	if err := child.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+// multipartType returns the Content-Type of a multipart body of the given
+// subtype, quoting the boundary when it contains characters such as "="
+func multipartType(subtype, boundary string) string {
+	return mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": boundary})
+}
+
+// BoundaryGenerator returns a new MIME boundary each time it is called
+type BoundaryGenerator func() string
+
+// SeededBoundaryGenerator returns a BoundaryGenerator that yields the same sequence
+// of boundaries for the same seed, for golden-file tests and DKIM precomputation
+func SeededBoundaryGenerator(seed int64) BoundaryGenerator {
+	rng := mrand.New(mrand.NewSource(seed))
+	var mu sync.Mutex
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		var buf [30]byte
+		rng.Read(buf[:])
+		return fmt.Sprintf("%x", buf[:])
+	}
+}
+
+// newBoundary returns a boundary from the configured generator, or a random one
+func (m *Mail) newBoundary() string {
+	if m.boundaryGenerator != nil {
+		return m.boundaryGenerator()
+	}
+	var buf [30]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%x", buf[:])
+}
+
+// newMultipartWriter creates a multipart writer on w using the boundary generator
+func (m *Mail) newMultipartWriter(w io.Writer) (*multipart.Writer, error) {
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(m.newBoundary()); err != nil {
+		return nil, fmt.Errorf("invalid boundary: %w", err)
+	}
+	return writer, nil
+}
@@ -0,0 +1,355 @@
+package gomail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// mimeTree returns a compact description of the MIME structure of r, e.g.
+// "multipart/mixed(multipart/related(text/html,image/png),application/octet-stream)"
+func mimeTree(t *testing.T, contentType string, r io.Reader) string {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("invalid Content-Type %q: %v", contentType, err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return mediaType
+	}
+
+	var children []string
+	reader := multipart.NewReader(r, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		children = append(children, mimeTree(t, part.Header.Get("Content-Type"), part))
+	}
+	return mediaType + "(" + strings.Join(children, ",") + ")"
+}
+
+func TestNestedMIMEStructure(t *testing.T) {
+	newMail := func() *Mail {
+		return &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Subject: "Test Subject",
+			Content: `<img src="cid:logo.png">`,
+			To:      []string{"recipient@example.com"},
+		}
+	}
+	inline := []Attachment{{Name: "logo.png", Data: []byte("png")}}
+	attachments := map[string][]byte{"report.pdf": []byte("pdf")}
+
+	tests := []struct {
+		name string
+		mail *Mail
+		want string
+	}{
+		{
+			name: "html only",
+			mail: newMail(),
+			want: "multipart/mixed(text/html)",
+		},
+		{
+			name: "html and text",
+			mail: newMail().SetAltContent("text"),
+			want: "multipart/mixed(multipart/alternative(text/plain,text/html))",
+		},
+		{
+			name: "html and inline images",
+			mail: newMail().SetInlineAttachment(inline),
+			want: "multipart/mixed(multipart/related(text/html,image/png))",
+		},
+		{
+			name: "everything",
+			mail: newMail().SetAltContent("text").SetInlineAttachment(inline).SetAttachment(attachments),
+			want: "multipart/mixed(multipart/related(multipart/alternative(text/plain,text/html),image/png),application/octet-stream)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.mail.writeMessage(&buf); err != nil {
+				t.Fatalf("writeMessage() error = %v", err)
+			}
+
+			msg, err := mail.ReadMessage(&buf)
+			if err != nil {
+				t.Fatalf("failed to parse message: %v", err)
+			}
+			if got := mimeTree(t, msg.Header.Get("Content-Type"), msg.Body); got != tt.want {
+				t.Errorf("MIME structure = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBodyContentType(t *testing.T) {
+	newMail := func(contentType ContentType) *Mail {
+		return &Mail{
+			From:        "sender@example.com",
+			Name:        "Test Sender",
+			Subject:     "Test Subject",
+			Content:     "Body",
+			To:          []string{"recipient@example.com"},
+			ContentType: contentType,
+			Attachments: map[string][]byte{"report.pdf": []byte("pdf")},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		contentType ContentType
+		want        string
+		wantErr     bool
+	}{
+		{"default", "", "multipart/mixed(text/html,application/octet-stream)", false},
+		{"html", TextHTML, "multipart/mixed(text/html,application/octet-stream)", false},
+		{"plain", TextPlain, "multipart/mixed(text/plain,application/octet-stream)", false},
+		{"markdown", TextMarkdown, "multipart/mixed(text/markdown,application/octet-stream)", false},
+		{"unknown", ContentType("application/json"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMail(tt.contentType)
+			if valid := m.validateMessage(); valid == tt.wantErr {
+				t.Errorf("validateMessage() = %v, want %v", valid, !tt.wantErr)
+			}
+
+			var buf bytes.Buffer
+			err := m.writeMessage(&buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			output := buf.String()
+			if want := "Content-Type: " + string(tt.contentType) + "; charset=UTF-8\r\n"; tt.contentType != "" && !strings.Contains(output, want) {
+				t.Errorf("message missing %q", want)
+			}
+			msg, err := mail.ReadMessage(&buf)
+			if err != nil {
+				t.Fatalf("failed to parse message: %v", err)
+			}
+			if got := mimeTree(t, msg.Header.Get("Content-Type"), msg.Body); got != tt.want {
+				t.Errorf("MIME structure = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("plain with alternative", func(t *testing.T) {
+		m := newMail(TextPlain).SetAltContent("Alt")
+		var buf bytes.Buffer
+		if err := m.writeMessage(&buf); err != nil {
+			t.Fatalf("writeMessage() error = %v", err)
+		}
+		if strings.Contains(buf.String(), "text/html") {
+			t.Error("plain text message should not contain a text/html part")
+		}
+	})
+}
+
+func TestInlineAttachmentHeaders(t *testing.T) {
+	m := &Mail{Content: "<img src=\"cid:logo.png\">"}
+	m.SetInlineAttachment([]Attachment{{Name: "logo.png", Data: []byte("png")}})
+
+	if !m.inlineAttachments[0].Inline {
+		t.Error("SetInlineAttachment() should mark attachments as inline")
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := m.writeContent(writer); err != nil {
+		t.Fatalf("writeContent() error = %v", err)
+	}
+	writer.Close()
+
+	for _, header := range []string{"Content-ID: <logo.png>", `Content-Disposition: inline; filename="logo.png"`} {
+		if !strings.Contains(buf.String(), header) {
+			t.Errorf("inline part missing header: %s", header)
+		}
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{"ascii", "report.pdf", `attachment; filename="report.pdf"`},
+		{"quoted", `a "b".txt`, `attachment; filename="a \"b\".txt"`},
+		{"turkish", "rapor_şubat.pdf", `attachment; filename="rapor_subat.pdf"; filename*=UTF-8''rapor_%C5%9Fubat.pdf`},
+		{"no ascii fallback", "報告.txt", `attachment; filename="__.txt"; filename*=UTF-8''%E5%A0%B1%E5%91%8A.txt`},
+		{"space", "ödeme planı.xlsx", `attachment; filename="odeme plan_.xlsx"; filename*=UTF-8''%C3%B6deme%20plan%C4%B1.xlsx`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contentDisposition("attachment", tt.filename); got != tt.want {
+				t.Errorf("contentDisposition() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// patternReader produces an endless stream of non-zero bytes
+type patternReader struct{}
+
+func (patternReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(i%251) + 1
+	}
+	return len(p), nil
+}
+
+func TestStreamingSerializationMemory(t *testing.T) {
+	const size = 64 << 20
+
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetStreamAttachment([]AttachmentReader{
+		{Name: "large.bin", Reader: io.LimitReader(patternReader{}, size), Size: size},
+	})
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	n, err := m.writeTo(io.Discard)
+	if err != nil {
+		t.Fatalf("writeTo() error = %v", err)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	// base64 grows the payload by 4/3 plus a CRLF every 76 characters
+	if n < size*4/3 {
+		t.Errorf("writeTo() wrote %d bytes, want at least %d", n, size*4/3)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4<<20 {
+		t.Errorf("serializing a %d byte attachment allocated %d bytes", size, allocated)
+	}
+}
+
+func TestDeterministicBoundaries(t *testing.T) {
+	serialize := func() string {
+		m := &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Subject: "Test Subject",
+			Content: "<p>Test Content</p>",
+			To:      []string{"recipient@example.com"},
+		}
+		m.SetAltContent("Test Content").
+			SetAttachment(map[string][]byte{"a.txt": []byte("a"), "b.txt": []byte("b"), "c.txt": []byte("c")}).
+			SetBoundaryGenerator(SeededBoundaryGenerator(42))
+
+		var buf bytes.Buffer
+		if err := m.writeMessage(&buf); err != nil {
+			t.Fatalf("writeMessage() error = %v", err)
+		}
+		return buf.String()
+	}
+
+	first := serialize()
+	for i := 0; i < 5; i++ {
+		if got := serialize(); got != first {
+			t.Fatalf("serialization is not reproducible:\n%s\n---\n%s", first, got)
+		}
+	}
+
+	t.Run("boundary needing quotes", func(t *testing.T) {
+		part := 0
+		m := &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Subject: "Test Subject",
+			Content: "<p>Test Content</p>",
+			To:      []string{"recipient@example.com"},
+		}
+		m.SetAltContent("Test Content").
+			SetAttachment(map[string][]byte{"a.txt": []byte("a")}).
+			SetBoundaryGenerator(func() string {
+				part++
+				return fmt.Sprintf("----=_Part_%d", part)
+			})
+
+		var buf bytes.Buffer
+		if err := m.writeMessage(&buf); err != nil {
+			t.Fatalf("writeMessage() error = %v", err)
+		}
+		if !strings.Contains(buf.String(), `Content-Type: multipart/mixed; boundary="----=_Part_1"`) {
+			t.Errorf("boundary is not quoted:\n%s", buf.String())
+		}
+		msg, err := mail.ReadMessage(&buf)
+		if err != nil {
+			t.Fatalf("failed to parse message: %v", err)
+		}
+		want := "multipart/mixed(multipart/alternative(text/plain,text/html),application/octet-stream)"
+		if got := mimeTree(t, msg.Header.Get("Content-Type"), msg.Body); got != want {
+			t.Errorf("MIME structure = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("invalid boundary", func(t *testing.T) {
+		m := &Mail{Content: "x"}
+		m.SetBoundaryGenerator(func() string { return "" })
+		if err := m.writeMessage(io.Discard); err == nil {
+			t.Error("writeMessage() with an invalid boundary should fail")
+		}
+	})
+}
+
+func TestWriteTo(t *testing.T) {
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo() = %d, but wrote %d bytes", n, buf.Len())
+	}
+
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo() output is not a valid message: %v", err)
+	}
+	if msg.Header.Get("Subject") != "Test Subject" {
+		t.Errorf("Subject = %q, want %q", msg.Header.Get("Subject"), "Test Subject")
+	}
+
+	if _, err := (&Mail{}).WriteTo(io.Discard); err == nil {
+		t.Error("WriteTo() with missing fields should fail")
+	}
+}
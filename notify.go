@@ -0,0 +1,39 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+)
+
+// Notify sends a notification of subject and a short plain text to the
+// recipients of m under ctx, for high-rate alert streams such as monitoring or
+// webhook notifications. The message is a single text/plain part without a
+// multipart structure, serialized once and sent as is by every attempt. The
+// attachments, custom parts, alternative content and calendar event of m are
+// left out, and the checks of the message content are skipped: recipient
+// resolvers, the attachment policy, the HTML check, content scanning, the spam
+// check and the duplicate guard. m is not modified, so Notify may be called concurrently.
+func (m *Mail) Notify(ctx context.Context, subject, text string) (err error) {
+	defer recoverPanic(&err)
+	n := m.clone()
+	n.applyIdentity()
+	n.identities = nil
+	n.Subject, n.Content, n.AltContent, n.ContentType = subject, text, "", TextPlain
+	n.Attachments, n.streamAttachments, n.inlineAttachments, n.attachmentParts, n.calendar = nil, nil, nil, nil, nil
+	n.customParts = nil
+
+	if !n.validate() {
+		return errors.New("missing parameter")
+	}
+	if err := n.recipientPolicy.check(n.recipients()); err != nil {
+		return err
+	}
+	n.messageID = newMessageID(n.From)
+	if _, err := n.applyReturnPath(ctx, n.returnPath); err != nil {
+		return err
+	}
+	if err := n.prepare(); err != nil {
+		return err
+	}
+	return n.dispatch(ctx, new(SendReport))
+}
@@ -0,0 +1,125 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("single text part", func(t *testing.T) {
+		server := newMockSMTPServer(t)
+		defer server.close()
+		host, port, _ := net.SplitHostPort(server.addr())
+
+		m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).
+			SetAttachment(map[string][]byte{"report.pdf": []byte("%PDF")})
+		if err := m.Notify(ctx, "Disk full on db-1", "/var is at 98%"); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+
+		messages := server.getMessages()
+		if len(messages) != 1 {
+			t.Fatalf("server received %d messages, want 1", len(messages))
+		}
+		for _, want := range []string{"Subject: Disk full on db-1", "Content-Type: text/plain; charset=UTF-8", "/var is at 98%", "Message-ID: <"} {
+			if !strings.Contains(messages[0], want) {
+				t.Errorf("message does not contain %q", want)
+			}
+		}
+		if strings.Contains(messages[0], "multipart") || strings.Contains(messages[0], "report.pdf") {
+			t.Error("notification is not a single text part without attachments")
+		}
+		if m.Subject != "Test Subject" || m.ContentType != "" {
+			t.Error("Notify() modified the email")
+		}
+	})
+
+	t.Run("retries send the same message", func(t *testing.T) {
+		var attempts [][]byte
+		m := newSandboxMail().SetSandbox(false).
+			SetRetryPolicy(&RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}).
+			SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error {
+				var buf bytes.Buffer
+				if _, err := m.WriteTo(&buf); err != nil {
+					return err
+				}
+				attempts = append(attempts, buf.Bytes())
+				if len(attempts) == 1 {
+					return &textproto.Error{Code: 421, Msg: "4.3.2 Try again later"}
+				}
+				return nil
+			}))
+		if err := m.Notify(ctx, "Alert", "Queue backlog"); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+		if len(attempts) != 2 || !bytes.Equal(attempts[0], attempts[1]) {
+			t.Errorf("%d attempts, want 2 sending the same serialized message", len(attempts))
+		}
+	})
+
+	t.Run("concurrent notifications", func(t *testing.T) {
+		var mu sync.Mutex
+		subjects := make(map[string]bool)
+		m := newSandboxMail().SetSandbox(false).
+			SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error {
+				mu.Lock()
+				defer mu.Unlock()
+				subjects[m.Subject] = true
+				return nil
+			}))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := m.Notify(ctx, fmt.Sprintf("Alert %d", i), "text"); err != nil {
+					t.Errorf("Notify() error = %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		if len(subjects) != 20 {
+			t.Errorf("%d distinct notifications sent, want 20", len(subjects))
+		}
+	})
+
+	t.Run("missing text", func(t *testing.T) {
+		if err := newSandboxMail().Notify(ctx, "Alert", ""); err == nil {
+			t.Error("Notify() without text succeeded")
+		}
+	})
+}
+
+func BenchmarkMailNotify(b *testing.B) {
+	server := newMockSMTPServer(b)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+
+	m := &Mail{
+		From: "alerts@example.com",
+		Name: "Alerts",
+		Host: host,
+		Port: port,
+		User: "user",
+		Pass: "pass",
+		To:   []string{"oncall@example.com"},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := m.Notify(context.Background(), "Disk full on db-1", "/var is at 98%"); err != nil {
+			b.Fatalf("Notify() error = %v", err)
+		}
+	}
+}
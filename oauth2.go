@@ -0,0 +1,183 @@
+package gomail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token endpoints of the OAuth2 providers of common SMTP services. Microsoft's
+// is "https://login.microsoftonline.com/<tenant>/oauth2/v2.0/token".
+const (
+	GoogleTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+// TokenSource supplies the OAuth2 access tokens SMTP connections authenticate
+// with through XOAUTH2. It is consulted whenever a connection is dialed, so it
+// should cache its token until shortly before it expires. A source identifies
+// the pools of its connections in a PoolRegistry: pointer and other comparable
+// sources by their value, and others, such as func types, by the SetOAuth2 call
+// that set them.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// SetOAuth2 authenticates with XOAUTH2, as Gmail and Office 365 require, using
+// the access tokens of source in place of a password. User is the mailbox
+// authenticated as, From when empty.
+func (m *Mail) SetOAuth2(source TokenSource) *Mail {
+	if source != nil && !reflect.ValueOf(source).Comparable() {
+		source = &tokenSourceRef{source}
+	}
+	m.oauth2 = source
+	return m
+}
+
+// tokenSourceRef refers to a source that is not comparable, so that it can
+// identify pools by the pointer
+type tokenSourceRef struct {
+	TokenSource
+}
+
+// RefreshTokenSource is a TokenSource that obtains access tokens from TokenURL
+// with a refresh token, as issued to an application the mailbox owner has
+// authorized, and caches each until a minute before it expires. A refresh token
+// the provider rotates is replaced by the new one.
+type RefreshTokenSource struct {
+	// TokenURL is the token endpoint of the provider, e.g. GoogleTokenURL
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	// Scopes are requested with the token when set, e.g.
+	// "https://outlook.office.com/SMTP.Send offline_access" for Office 365
+	Scopes []string
+	// Client is the HTTP client used for the requests, http.DefaultClient when nil
+	Client *http.Client
+	// Clock is the source of time of the expiry, the system clock when nil
+	Clock Clock
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Token implements TokenSource
+func (s *RefreshTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && s.now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.RefreshToken},
+		"client_id":     {s.ClientID},
+	}
+	if s.ClientSecret != "" {
+		form.Set("client_secret", s.ClientSecret)
+	}
+	if len(s.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", contextError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError("oauth2", resp)
+	}
+
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("oauth2: no access token in the response")
+	}
+	if token.RefreshToken != "" {
+		s.RefreshToken = token.RefreshToken
+	}
+	// Renew a minute early so a token does not expire while authenticating
+	s.token = token.AccessToken
+	s.expires = s.now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+// now returns the current time of the source's clock
+func (s *RefreshTokenSource) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// xoauth2Auth implements the XOAUTH2 mechanism of Google and Microsoft, which
+// sends the user and a bearer access token in the initial response
+type xoauth2Auth struct {
+	user, token, host string
+}
+
+// Start implements smtp.Auth. Like PLAIN, the token is only sent over an
+// encrypted connection or to localhost.
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "XOAUTH2", []byte("user=" + a.user + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+// Next implements smtp.Auth. A rejected token is answered with a challenge
+// describing the error, to which an empty response makes the server fail the
+// authentication with its error reply.
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// isLocalhost reports whether host is the local machine
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// oauth2Credentials returns the user and access token to authenticate with under ctx
+func (m *Mail) oauth2Credentials(ctx context.Context) (string, string, error) {
+	user, _ := m.staticCredentials()
+	if user == "" {
+		user = m.From
+	}
+	token, err := m.oauth2.Token(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("error fetching OAuth2 token: %w", err)
+	}
+	return user, token, nil
+}
@@ -0,0 +1,142 @@
+package gomail
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingTokenSource is a TokenSource returning numbered tokens
+type countingTokenSource struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (s *countingTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "", s.err
+	}
+	s.calls++
+	return fmt.Sprintf("token-%d", s.calls), nil
+}
+
+func TestXOAUTH2Auth(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		server  smtp.ServerInfo
+		wantErr bool
+	}{
+		{"encrypted", "smtp.gmail.com", smtp.ServerInfo{Name: "smtp.gmail.com", TLS: true}, false},
+		{"localhost", "localhost", smtp.ServerInfo{Name: "localhost"}, false},
+		{"unencrypted", "smtp.gmail.com", smtp.ServerInfo{Name: "smtp.gmail.com"}, true},
+		{"other host", "smtp.gmail.com", smtp.ServerInfo{Name: "smtp.example.com", TLS: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &xoauth2Auth{user: "someone@gmail.com", token: "ya29.token", host: tt.host}
+			mech, resp, err := auth.Start(&tt.server)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if want := "user=someone@gmail.com\x01auth=Bearer ya29.token\x01\x01"; mech != "XOAUTH2" || string(resp) != want {
+				t.Errorf("Start() = %s %q, want XOAUTH2 %q", mech, resp, want)
+			}
+			if next, err := auth.Next([]byte(`{"status":"401"}`), true); err != nil || next == nil || len(next) != 0 {
+				t.Errorf("Next() = %q, %v, want an empty response to the error challenge", next, err)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenSource(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "secret" {
+			t.Errorf("unexpected token request %v", r.Form)
+		}
+		switch r.FormValue("refresh_token") {
+		case "refresh-1":
+			fmt.Fprint(w, `{"access_token":"access-1","expires_in":3600,"refresh_token":"refresh-2"}`)
+		case "refresh-2":
+			if r.FormValue("scope") != "https://mail.google.com/" {
+				t.Errorf("scope = %q", r.FormValue("scope"))
+			}
+			fmt.Fprint(w, `{"access_token":"access-2","expires_in":3600}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	clock := newFakeClock()
+	source := &RefreshTokenSource{TokenURL: server.URL, ClientID: "client", ClientSecret: "secret", RefreshToken: "refresh-1", Clock: clock}
+	for i := 0; i < 2; i++ {
+		if token, err := source.Token(ctx); err != nil || token != "access-1" {
+			t.Fatalf("Token() = %q, %v, want access-1", token, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("%d token requests, want the token cached", requests)
+	}
+
+	// The token is renewed a minute before it expires, with the rotated refresh token
+	source.Scopes = []string{"https://mail.google.com/"}
+	clock.Advance(59 * time.Minute)
+	if token, err := source.Token(ctx); err != nil || token != "access-2" {
+		t.Fatalf("Token() = %q, %v, want access-2", token, err)
+	}
+	if source.RefreshToken != "refresh-2" {
+		t.Errorf("RefreshToken = %q, want the rotated refresh-2", source.RefreshToken)
+	}
+
+	source.RefreshToken = "revoked"
+	clock.Advance(time.Hour)
+	var apiErr *APIError
+	if _, err := source.Token(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || !IsPermanent(err) {
+		t.Errorf("Token() error = %v, want a permanent *APIError", err)
+	}
+}
+
+func TestSetOAuth2(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	host, port, _ := net.SplitHostPort(server.addr())
+
+	source := &countingTokenSource{}
+	m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).SetUser("mailbox@example.com").SetOAuth2(source)
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte("user=mailbox@example.com\x01auth=Bearer token-1\x01\x01"))
+	if messages := server.getMessages(); len(messages) != 1 || !strings.Contains(messages[0], "AUTH XOAUTH2 "+auth) {
+		t.Errorf("messages = %q, want authentication with the access token", messages)
+	}
+
+	failing := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).SetOAuth2(&countingTokenSource{err: errors.New("consent revoked")})
+	if err := failing.Send(); err == nil || !strings.Contains(err.Error(), "consent revoked") {
+		t.Errorf("Send() error = %v, want the token source error", err)
+	}
+}
@@ -0,0 +1,463 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default configuration of the outbox
+const (
+	DefaultOutboxTable        = "gomail_outbox"
+	DefaultOutboxPollInterval = 5 * time.Second
+	DefaultOutboxBatchSize    = 100
+	// DefaultOutboxLockTimeout is how long a relay worker holds an email it
+	// claimed, after which another worker takes it over
+	DefaultOutboxLockTimeout = 10 * time.Minute
+)
+
+// Statuses of an email in the outbox table
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// defaultOutboxRetry retries failed sends of the outbox for several hours
+var defaultOutboxRetry = &RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Minute, MaxBackoff: time.Hour}
+
+// Outbox implements the transactional outbox pattern: the application stores
+// an email with Enqueue in its own database, in the transaction of the business
+// change it belongs to, and a relay worker polls the table and sends the emails
+// of committed transactions. An email is thus sent if and only if its
+// transaction commits.
+//
+// Delivery is at least once: an email whose send succeeded is sent again when
+// the worker fails to record it, e.g. because it crashed. Every attempt uses
+// the same Message-ID, so recipients and servers can detect the duplicate.
+// Several workers may relay the same table; each email is claimed by one of
+// them at a time.
+type Outbox struct {
+	DB *sql.DB
+	// Table is the name of the outbox table, DefaultOutboxTable when empty. It is
+	// used in the queries as is.
+	Table string
+	// Placeholder is the query parameter style of the database driver
+	Placeholder SQLPlaceholder
+	// PollInterval is how long the relay waits when no email is due,
+	// DefaultOutboxPollInterval when zero
+	PollInterval time.Duration
+	// BatchSize is the number of emails claimed per poll, DefaultOutboxBatchSize when zero
+	BatchSize int
+	// LockTimeout is how long a claimed email is held, DefaultOutboxLockTimeout when zero
+	LockTimeout time.Duration
+	// RetryPolicy reschedules emails that failed temporarily. Without one they are
+	// retried up to 10 times with a backoff from a minute to an hour. Emails that
+	// fail permanently or exhaust the attempts are marked OutboxFailed.
+	RetryPolicy *RetryPolicy
+	// Clock is the source of time of the outbox, the system clock when nil
+	Clock Clock
+}
+
+// outboxMessage is the payload of an email in the outbox table: the message
+// fields that Profiles take from an email
+type outboxMessage struct {
+	From             string            `json:"from,omitempty"`
+	Name             string            `json:"name,omitempty"`
+	EnvelopeFrom     string            `json:"envelope_from,omitempty"`
+	Subject          string            `json:"subject"`
+	Content          string            `json:"content"`
+	AltContent       string            `json:"alt_content,omitempty"`
+	ContentType      ContentType       `json:"content_type,omitempty"`
+	Charset          string            `json:"charset,omitempty"`
+	TransferEncoding TransferEncoding  `json:"transfer_encoding,omitempty"`
+	To               []string          `json:"to"`
+	Cc               []string          `json:"cc,omitempty"`
+	Bcc              []string          `json:"bcc,omitempty"`
+	Attachments      map[string][]byte `json:"attachments,omitempty"`
+	Inline           []Attachment      `json:"inline,omitempty"`
+	Streams          []Attachment      `json:"streams,omitempty"`
+	Prepared         []Attachment      `json:"prepared,omitempty"`
+	CalendarMethod   string            `json:"calendar_method,omitempty"`
+	CalendarEvent    *CalendarEvent    `json:"calendar_event,omitempty"`
+	Tags             []string          `json:"tags,omitempty"`
+	Parts            []outboxPart      `json:"parts,omitempty"`
+}
+
+// outboxPart is a custom part stored in an outbox payload, with the body its
+// WriteBody function wrote
+type outboxPart struct {
+	Header textproto.MIMEHeader `json:"header"`
+	Body   []byte               `json:"body"`
+}
+
+// CreateTable creates the outbox table unless it exists. The payload column is
+// a TEXT, which holds up to 64 KB on MySQL; create the table yourself with a
+// LONGTEXT there for emails with attachments, and add an index on status and
+// next_attempt for large tables.
+func (o *Outbox) CreateTable(ctx context.Context) error {
+	_, err := o.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+o.table()+` (
+	message_id VARCHAR(255) NOT NULL PRIMARY KEY,
+	payload TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	attempts INTEGER NOT NULL,
+	next_attempt BIGINT NOT NULL,
+	locked_until BIGINT NOT NULL,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL,
+	last_error TEXT
+)`)
+	return err
+}
+
+// Enqueue stores msg in the outbox within tx and returns its Message-ID. The
+// email is sent by the relay once tx commits, and never when it rolls back.
+// The message fields of msg are stored, as sent by Profiles; the server and
+// the other settings come from the sender the relay is given. Streaming
+// attachments are read into the outbox.
+func (o *Outbox) Enqueue(ctx context.Context, tx *sql.Tx, msg *Mail) (string, error) {
+	if !msg.validateMessage() {
+		return "", errors.New("missing parameter")
+	}
+	payload, err := newOutboxMessage(msg)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	id := msg.messageID
+	if id == "" {
+		id = newMessageID(msg.From)
+	}
+	now := o.now().Unix()
+	_, err = tx.ExecContext(ctx, o.query(`INSERT INTO `+o.table()+`
+	(message_id, payload, status, attempts, next_attempt, locked_until, created_at, updated_at)
+	VALUES (?, ?, ?, 0, ?, 0, ?, ?)`), id, string(data), OutboxPending, now, now, now)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Relay sends the emails of the outbox with sender until ctx is done, polling
+// the table every PollInterval, and returns the error of ctx. Database errors
+// are logged and retried at the next poll.
+func (o *Outbox) Relay(ctx context.Context, sender *Mail) error {
+	for {
+		claimed, _, err := o.relay(ctx, sender)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Outbox relay: %v", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// A full batch suggests more emails are due
+		if err == nil && claimed == o.batchSize() {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-o.clock().After(o.pollInterval()):
+		}
+	}
+}
+
+// RelayOnce sends the emails of the outbox that are due with sender and returns
+// the number of emails sent. Failed sends are recorded in the table and not
+// returned; the error reports failures of the database.
+func (o *Outbox) RelayOnce(ctx context.Context, sender *Mail) (int, error) {
+	_, sent, err := o.relay(ctx, sender)
+	return sent, err
+}
+
+// relay sends the emails that are due with sender and returns the number of
+// emails claimed and sent
+func (o *Outbox) relay(ctx context.Context, sender *Mail) (claimed, sent int, err error) {
+	now := o.now()
+	rows, err := o.DB.QueryContext(ctx, o.query(`SELECT message_id, payload, attempts FROM `+o.table()+`
+	WHERE status = ? AND next_attempt <= ? AND locked_until <= ?
+	ORDER BY next_attempt LIMIT `+strconv.Itoa(o.batchSize())), OutboxPending, now.Unix(), now.Unix())
+	if err != nil {
+		return 0, 0, err
+	}
+	type outboxRow struct {
+		id       string
+		payload  string
+		attempts int
+	}
+	var due []outboxRow
+	for rows.Next() {
+		var row outboxRow
+		if err := rows.Scan(&row.id, &row.payload, &row.attempts); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		due = append(due, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	var errs []error
+	for _, row := range due {
+		ok, err := o.claim(ctx, row.id, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			// Another worker claimed or sent the email
+			continue
+		}
+		claimed++
+		sendErr := o.send(ctx, sender, row.id, row.payload)
+		if ctx.Err() != nil {
+			// The lock expires and the email is sent again later
+			break
+		}
+		if err := o.record(ctx, row.id, row.attempts+1, sendErr); err != nil {
+			errs = append(errs, fmt.Errorf("recording %s: %w", row.id, err))
+		}
+		if sendErr == nil {
+			sent++
+		}
+	}
+	return claimed, sent, errors.Join(errs...)
+}
+
+// claim locks the email id for this worker and reports whether it was still
+// pending and unlocked
+func (o *Outbox) claim(ctx context.Context, id string, now time.Time) (bool, error) {
+	result, err := o.DB.ExecContext(ctx, o.query(`UPDATE `+o.table()+` SET locked_until = ?
+	WHERE message_id = ? AND status = ? AND locked_until <= ?`),
+		now.Add(o.lockTimeout()).Unix(), id, OutboxPending, now.Unix())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// send sends the email id stored as payload with sender
+func (o *Outbox) send(ctx context.Context, sender *Mail, id, payload string) error {
+	var message outboxMessage
+	if err := json.Unmarshal([]byte(payload), &message); err != nil {
+		return fmt.Errorf("invalid outbox payload: %w", err)
+	}
+	msg, err := message.mail()
+	if err != nil {
+		return err
+	}
+	return sender.withMessage(msg).SetMessageID(id).sendContext(ctx)
+}
+
+// record records the outcome err of the given attempt to send the email id:
+// sent, rescheduled or failed
+func (o *Outbox) record(ctx context.Context, id string, attempt int, err error) error {
+	now := o.now()
+	status, next, lastError := OutboxSent, now, sql.NullString{}
+	var warmUp *WarmUpLimitError
+	switch {
+	case err == nil:
+	case errors.As(err, &warmUp):
+		// A day's volume being used up does not count as an attempt
+		status, next, attempt = OutboxPending, warmUp.Resume, attempt-1
+	case o.retryPolicy().shouldRetry(attempt, err):
+		status, next = OutboxPending, now.Add(o.retryPolicy().delay(attempt, err))
+	default:
+		status = OutboxFailed
+	}
+	if err != nil {
+		lastError = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	_, err = o.DB.ExecContext(ctx, o.query(`UPDATE `+o.table()+`
+	SET status = ?, attempts = ?, next_attempt = ?, locked_until = 0, updated_at = ?, last_error = ?
+	WHERE message_id = ?`), status, attempt, next.Unix(), now.Unix(), lastError, id)
+	return err
+}
+
+// query returns q with its ? parameters in the placeholder style of the driver
+func (o *Outbox) query(q string) string {
+	if o.Placeholder != PlaceholderDollar {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// table returns the name of the outbox table
+func (o *Outbox) table() string {
+	if o.Table == "" {
+		return DefaultOutboxTable
+	}
+	return o.Table
+}
+
+// pollInterval returns how long the relay waits between polls
+func (o *Outbox) pollInterval() time.Duration {
+	if o.PollInterval <= 0 {
+		return DefaultOutboxPollInterval
+	}
+	return o.PollInterval
+}
+
+// batchSize returns the number of emails claimed per poll
+func (o *Outbox) batchSize() int {
+	if o.BatchSize <= 0 {
+		return DefaultOutboxBatchSize
+	}
+	return o.BatchSize
+}
+
+// lockTimeout returns how long a claimed email is held
+func (o *Outbox) lockTimeout() time.Duration {
+	if o.LockTimeout <= 0 {
+		return DefaultOutboxLockTimeout
+	}
+	return o.LockTimeout
+}
+
+// retryPolicy returns the policy rescheduling failed sends
+func (o *Outbox) retryPolicy() *RetryPolicy {
+	if o.RetryPolicy == nil {
+		return defaultOutboxRetry
+	}
+	return o.RetryPolicy
+}
+
+// clock returns the source of time of the outbox
+func (o *Outbox) clock() Clock {
+	if o.Clock == nil {
+		return systemClock{}
+	}
+	return o.Clock
+}
+
+// now returns the current time of the outbox's clock
+func (o *Outbox) now() time.Time {
+	return o.clock().Now()
+}
+
+// newOutboxMessage returns the payload storing the message fields of msg,
+// reading its streaming attachments
+func newOutboxMessage(msg *Mail) (*outboxMessage, error) {
+	message := &outboxMessage{
+		From:             msg.From,
+		Name:             msg.Name,
+		EnvelopeFrom:     msg.envelopeFrom,
+		Subject:          msg.Subject,
+		Content:          msg.Content,
+		AltContent:       msg.AltContent,
+		ContentType:      msg.ContentType,
+		Charset:          msg.charset,
+		TransferEncoding: msg.transferEncoding,
+		To:               msg.To,
+		Cc:               msg.Cc,
+		Bcc:              msg.Bcc,
+		Attachments:      msg.Attachments,
+		Inline:           msg.inlineAttachments,
+		Tags:             msg.tags,
+	}
+
+	streams := make([]AttachmentReader, len(msg.streamAttachments))
+	for i, attachment := range msg.streamAttachments {
+		data, err := io.ReadAll(attachment.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("reading attachment %s: %w", attachment.Name, err)
+		}
+		message.Streams = append(message.Streams, Attachment{Name: attachment.Name, Data: data})
+		streams[i] = attachment
+		streams[i].Reader, streams[i].Size = bytes.NewReader(data), int64(len(data))
+	}
+	msg.streamAttachments = streams
+
+	for _, attachment := range msg.attachmentParts {
+		data, err := base64.StdEncoding.DecodeString(string(attachment.encoded))
+		if err != nil {
+			return nil, err
+		}
+		message.Prepared = append(message.Prepared, Attachment{Name: attachment.Name(), Data: data})
+	}
+	if msg.calendar != nil {
+		event := msg.calendar.event
+		message.CalendarMethod, message.CalendarEvent = msg.calendar.method, &event
+	}
+	for _, part := range msg.customParts {
+		var body bytes.Buffer
+		if err := part.writeBody(&body); err != nil {
+			return nil, fmt.Errorf("writing custom part: %w", err)
+		}
+		message.Parts = append(message.Parts, outboxPart{Header: part.Header, Body: body.Bytes()})
+	}
+	return message, nil
+}
+
+// mail returns an email with the message fields of the payload
+func (p *outboxMessage) mail() (*Mail, error) {
+	msg := &Mail{
+		From:              p.From,
+		Name:              p.Name,
+		envelopeFrom:      p.EnvelopeFrom,
+		Subject:           p.Subject,
+		Content:           p.Content,
+		AltContent:        p.AltContent,
+		ContentType:       p.ContentType,
+		charset:           p.Charset,
+		transferEncoding:  p.TransferEncoding,
+		To:                p.To,
+		Cc:                p.Cc,
+		Bcc:               p.Bcc,
+		Attachments:       p.Attachments,
+		inlineAttachments: p.Inline,
+		tags:              p.Tags,
+	}
+	for _, attachment := range p.Streams {
+		msg.streamAttachments = append(msg.streamAttachments, AttachmentReader{
+			Name:   attachment.Name,
+			Reader: bytes.NewReader(attachment.Data),
+			Size:   int64(len(attachment.Data)),
+		})
+	}
+	for _, attachment := range p.Prepared {
+		prepared, err := PrepareAttachment(attachment.Name, bytes.NewReader(attachment.Data))
+		if err != nil {
+			return nil, err
+		}
+		msg.attachmentParts = append(msg.attachmentParts, prepared)
+	}
+	if p.CalendarEvent != nil {
+		msg.calendar = &calendarPart{method: p.CalendarMethod, event: *p.CalendarEvent}
+	}
+	for _, part := range p.Parts {
+		msg.customParts = append(msg.customParts, CustomPart{Header: part.Header, Body: part.Body})
+	}
+	return msg, nil
+}
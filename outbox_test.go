@@ -0,0 +1,427 @@
+package gomail
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// outboxRecord is a row of the outbox table of fakeOutboxDriver
+type outboxRecord struct {
+	id          string
+	payload     string
+	status      string
+	attempts    int64
+	nextAttempt int64
+	lockedUntil int64
+	lastError   any
+}
+
+// fakeOutboxDriver is a database/sql driver keeping the outbox table in memory.
+// It understands the queries of Outbox only; inserts become visible on commit.
+type fakeOutboxDriver struct {
+	mu      sync.Mutex
+	records map[string]*outboxRecord
+}
+
+func (d *fakeOutboxDriver) Open(name string) (driver.Conn, error) {
+	return &fakeOutboxConn{driver: d}, nil
+}
+
+func (d *fakeOutboxDriver) Connect(ctx context.Context) (driver.Conn, error) {
+	return d.Open("")
+}
+
+func (d *fakeOutboxDriver) Driver() driver.Driver { return d }
+
+func (d *fakeOutboxDriver) record(id string) *outboxRecord {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if r, ok := d.records[id]; ok {
+		copied := *r
+		return &copied
+	}
+	return nil
+}
+
+// newFakeOutboxDB opens a database on a new fakeOutboxDriver
+func newFakeOutboxDB(t *testing.T) (*sql.DB, *fakeOutboxDriver) {
+	t.Helper()
+	d := &fakeOutboxDriver{records: make(map[string]*outboxRecord)}
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+type fakeOutboxConn struct {
+	driver  *fakeOutboxDriver
+	pending []*outboxRecord
+	inTx    bool
+}
+
+func (c *fakeOutboxConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeOutboxStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeOutboxConn) Close() error { return nil }
+
+func (c *fakeOutboxConn) Begin() (driver.Tx, error) {
+	c.inTx, c.pending = true, nil
+	return c, nil
+}
+
+func (c *fakeOutboxConn) Commit() error {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	for _, r := range c.pending {
+		if _, ok := c.driver.records[r.id]; ok {
+			return fmt.Errorf("duplicate message_id %s", r.id)
+		}
+		c.driver.records[r.id] = r
+	}
+	c.inTx, c.pending = false, nil
+	return nil
+}
+
+func (c *fakeOutboxConn) Rollback() error {
+	c.inTx, c.pending = false, nil
+	return nil
+}
+
+type fakeOutboxStmt struct {
+	conn  *fakeOutboxConn
+	query string
+}
+
+func (s *fakeOutboxStmt) Close() error  { return nil }
+func (s *fakeOutboxStmt) NumInput() int { return -1 }
+
+func (s *fakeOutboxStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.conn.driver
+	query := strings.Join(strings.Fields(s.query), " ")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE"):
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "INSERT INTO"):
+		r := &outboxRecord{id: args[0].(string), payload: args[1].(string), status: args[2].(string), nextAttempt: args[3].(int64)}
+		if s.conn.inTx {
+			s.conn.pending = append(s.conn.pending, r)
+		} else {
+			d.records[r.id] = r
+		}
+		return driver.RowsAffected(1), nil
+	case strings.Contains(query, "SET locked_until = ?"):
+		r, ok := d.records[args[1].(string)]
+		if !ok || r.status != args[2].(string) || r.lockedUntil > args[3].(int64) {
+			return driver.RowsAffected(0), nil
+		}
+		r.lockedUntil = args[0].(int64)
+		return driver.RowsAffected(1), nil
+	case strings.Contains(query, "SET status = ?"):
+		r, ok := d.records[args[5].(string)]
+		if !ok {
+			return driver.RowsAffected(0), nil
+		}
+		r.status, r.attempts, r.nextAttempt, r.lockedUntil, r.lastError = args[0].(string), args[1].(int64), args[2].(int64), 0, args[4]
+		return driver.RowsAffected(1), nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", query)
+}
+
+func (s *fakeOutboxStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.conn.driver
+	query := strings.Join(strings.Fields(s.query), " ")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	limit, err := strconv.Atoi(query[strings.LastIndex(query, " ")+1:])
+	if !strings.HasPrefix(query, "SELECT message_id, payload, attempts") || err != nil {
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	var due []*outboxRecord
+	for _, r := range d.records {
+		if r.status == args[0].(string) && r.nextAttempt <= args[1].(int64) && r.lockedUntil <= args[2].(int64) {
+			due = append(due, r)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].nextAttempt != due[j].nextAttempt {
+			return due[i].nextAttempt < due[j].nextAttempt
+		}
+		return due[i].id < due[j].id
+	})
+	rows := &fakeOutboxRows{}
+	for i, r := range due {
+		if i == limit {
+			break
+		}
+		rows.values = append(rows.values, []driver.Value{r.id, r.payload, r.attempts})
+	}
+	return rows, nil
+}
+
+type fakeOutboxRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeOutboxRows) Columns() []string { return []string{"message_id", "payload", "attempts"} }
+func (r *fakeOutboxRows) Close() error      { return nil }
+
+func (r *fakeOutboxRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// newOutboxSender returns a sender for the relay that passes the emails to send
+func newOutboxSender(send func(m *Mail) error) *Mail {
+	return new(Mail).SetHost("smtp.example.com").SetPort("587").SetUser("user").SetPass("pass").
+		SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error { return send(m) }))
+}
+
+// enqueueOutbox stores msg in outbox in a transaction that commits when commit is set
+func enqueueOutbox(t *testing.T, db *sql.DB, outbox *Outbox, msg *Mail, commit bool) string {
+	t.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := outbox.Enqueue(context.Background(), tx, msg)
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if commit {
+		err = tx.Commit()
+	} else {
+		err = tx.Rollback()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sends committed emails only", func(t *testing.T) {
+		db, d := newFakeOutboxDB(t)
+		outbox := &Outbox{DB: db, Clock: newFakeClock()}
+		if err := outbox.CreateTable(ctx); err != nil {
+			t.Fatalf("CreateTable() error = %v", err)
+		}
+
+		committed := enqueueOutbox(t, db, outbox, newSandboxMail().SetSubject("Order confirmed"), true)
+		rolledBack := enqueueOutbox(t, db, outbox, newSandboxMail().SetSubject("Order cancelled"), false)
+
+		var sent []*Mail
+		sender := newOutboxSender(func(m *Mail) error {
+			sent = append(sent, m)
+			return nil
+		})
+		n, err := outbox.RelayOnce(ctx, sender)
+		if err != nil || n != 1 {
+			t.Fatalf("RelayOnce() = %d, %v, want 1 email sent", n, err)
+		}
+		if len(sent) != 1 || sent[0].Subject != "Order confirmed" || sent[0].messageID != committed {
+			t.Fatalf("sent %d emails, want Order confirmed with Message-ID %s", len(sent), committed)
+		}
+		if r := d.record(committed); r.status != OutboxSent || r.attempts != 1 {
+			t.Errorf("committed email is %s after %d attempts, want sent after 1", r.status, r.attempts)
+		}
+		if d.record(rolledBack) != nil {
+			t.Error("rolled back email is in the outbox")
+		}
+
+		if n, err := outbox.RelayOnce(ctx, sender); err != nil || n != 0 {
+			t.Errorf("second RelayOnce() = %d, %v, want nothing sent", n, err)
+		}
+	})
+
+	t.Run("keeps the message fields", func(t *testing.T) {
+		db, _ := newFakeOutboxDB(t)
+		outbox := &Outbox{DB: db}
+		prepared, err := PrepareAttachment("report.csv", strings.NewReader("a,b\n1,2\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := newSandboxMail().SetEnvelopeFrom("bounces@example.com").SetTags("orders").
+			SetAttachment(map[string][]byte{"terms.txt": []byte("terms")}).
+			SetStreamAttachment([]AttachmentReader{{Name: "log.txt", Reader: strings.NewReader("log lines")}}).
+			SetPreparedAttachment(prepared).
+			SetCustomPart(CustomPart{Header: textproto.MIMEHeader{"Content-Type": {"application/xml"}}, WriteBody: func(w io.Writer) error {
+				_, err := io.WriteString(w, "<Order/>")
+				return err
+			}}).
+			SetCalendarEvent(&CalendarEvent{Summary: "Delivery", Start: time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC), End: time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)})
+		enqueueOutbox(t, db, outbox, msg, true)
+
+		var sent *Mail
+		if _, err := outbox.RelayOnce(ctx, newOutboxSender(func(m *Mail) error {
+			sent = m
+			return nil
+		})); err != nil {
+			t.Fatalf("RelayOnce() error = %v", err)
+		}
+		if sent == nil {
+			t.Fatal("no email sent")
+		}
+		parts := sent.customParts
+		sent.customParts = nil
+		attachments, err := sent.apiAttachments()
+		sent.customParts = parts
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, attachment := range attachments {
+			got = append(got, attachment.Name+"="+string(attachment.Data))
+		}
+		want := []string{"terms.txt=terms", "log.txt=log lines", "report.csv=a,b\n1,2\n"}
+		if !jsonEqual(got, want) {
+			t.Errorf("attachments = %q, want %q", got, want)
+		}
+		if sent.getEnvelopeFrom() != "bounces@example.com" || !jsonEqual(sent.tags, []string{"orders"}) ||
+			!jsonEqual(sent.Bcc, []string{"hidden@example.com"}) {
+			t.Errorf("envelope sender %s, tags %v, Bcc %v not kept", sent.getEnvelopeFrom(), sent.tags, sent.Bcc)
+		}
+		if sent.calendar == nil || sent.calendar.event.Summary != "Delivery" || sent.calendar.event.UID != msg.calendar.event.UID {
+			t.Errorf("calendar event = %+v, want the event of the email", sent.calendar)
+		}
+		if len(sent.customParts) != 1 || string(sent.customParts[0].Body) != "<Order/>" ||
+			sent.customParts[0].Header.Get("Content-Type") != "application/xml" {
+			t.Errorf("custom parts = %+v, want the part of the email", sent.customParts)
+		}
+	})
+
+	t.Run("reschedules temporary failures", func(t *testing.T) {
+		db, d := newFakeOutboxDB(t)
+		clock := newFakeClock()
+		outbox := &Outbox{DB: db, Clock: clock, RetryPolicy: &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Minute}}
+		temporary := enqueueOutbox(t, db, outbox, newSandboxMail().SetSubject("Temporary"), true)
+		clock.Advance(time.Second)
+		permanent := enqueueOutbox(t, db, outbox, newSandboxMail().SetSubject("Permanent"), true)
+
+		sender := newOutboxSender(func(m *Mail) error {
+			if m.Subject == "Permanent" {
+				return &textproto.Error{Code: 550, Msg: "5.1.1 No such user"}
+			}
+			return &textproto.Error{Code: 451, Msg: "4.3.0 Try again later"}
+		})
+		clock.Advance(time.Second)
+		if n, err := outbox.RelayOnce(ctx, sender); err != nil || n != 0 {
+			t.Fatalf("RelayOnce() = %d, %v, want nothing sent", n, err)
+		}
+		r := d.record(temporary)
+		if r.status != OutboxPending || r.attempts != 1 || r.nextAttempt != clock.Now().Add(time.Minute).Unix() || r.lastError == nil {
+			t.Errorf("temporary failure recorded as %+v, want pending a minute later", r)
+		}
+		if r := d.record(permanent); r.status != OutboxFailed || !strings.Contains(fmt.Sprint(r.lastError), "No such user") {
+			t.Errorf("permanent failure recorded as %+v, want failed", r)
+		}
+
+		clock.Advance(time.Minute)
+		if _, err := outbox.RelayOnce(ctx, sender); err != nil {
+			t.Fatal(err)
+		}
+		if r := d.record(temporary); r.status != OutboxFailed || r.attempts != 2 {
+			t.Errorf("email is %s after %d attempts, want failed after 2", r.status, r.attempts)
+		}
+	})
+
+	t.Run("skips emails claimed by another worker", func(t *testing.T) {
+		db, d := newFakeOutboxDB(t)
+		clock := newFakeClock()
+		outbox := &Outbox{DB: db, Clock: clock}
+		id := enqueueOutbox(t, db, outbox, newSandboxMail(), true)
+		d.records[id].lockedUntil = clock.Now().Add(time.Minute).Unix()
+
+		sender := newOutboxSender(func(m *Mail) error { return nil })
+		if n, err := outbox.RelayOnce(ctx, sender); err != nil || n != 0 {
+			t.Fatalf("RelayOnce() = %d, %v, want the locked email skipped", n, err)
+		}
+		clock.Advance(time.Minute)
+		if n, err := outbox.RelayOnce(ctx, sender); err != nil || n != 1 {
+			t.Errorf("RelayOnce() = %d, %v, want the email taken over after its lock expired", n, err)
+		}
+	})
+
+	t.Run("relay polls until cancelled", func(t *testing.T) {
+		db, _ := newFakeOutboxDB(t)
+		clock := newFakeClock()
+		outbox := &Outbox{DB: db, Clock: clock, PollInterval: time.Minute}
+
+		sent := make(chan string, 1)
+		sender := newOutboxSender(func(m *Mail) error {
+			sent <- m.Subject
+			return nil
+		})
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- outbox.Relay(runCtx, sender) }()
+
+		waitPending(t, clock, 1)
+		enqueueOutbox(t, db, outbox, newSandboxMail(), true)
+		clock.Advance(time.Minute)
+		select {
+		case subject := <-sent:
+			if subject != "Test Subject" {
+				t.Errorf("relayed %q", subject)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("email not relayed after the poll interval")
+		}
+
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Relay() error = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("rejects an incomplete email", func(t *testing.T) {
+		db, _ := newFakeOutboxDB(t)
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		if _, err := (&Outbox{DB: db}).Enqueue(ctx, tx, newSandboxMail().SetSubject("")); err == nil {
+			t.Error("Enqueue() accepted an email without subject")
+		}
+	})
+}
+
+func TestOutboxQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		placeholder SQLPlaceholder
+		want        string
+	}{
+		{"question", PlaceholderQuestion, "UPDATE t SET a = ? WHERE b = ? AND c = ?"},
+		{"dollar", PlaceholderDollar, "UPDATE t SET a = $1 WHERE b = $2 AND c = $3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Outbox{Placeholder: tt.placeholder}
+			if got := o.query("UPDATE t SET a = ? WHERE b = ? AND c = ?"); got != tt.want {
+				t.Errorf("query() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// pipelineEnvelope sends MAIL with from and RCPT for every address of to in one
+// batch to a server offering PIPELINING (RFC 2920), then reads their responses,
+// saving a round trip per recipient. Every response is read, so the connection
+// stays in sync when a command is rejected; the first rejection is returned. The
+// latency of each command is measured from the batch to its response.
+func (m *Mail) pipelineEnvelope(conn *connection, timeouts CommandTimeouts, from string, to []string) error {
+	// The parameters net/smtp declares for MAIL when the server offers them
+	mail := "MAIL FROM:<" + from + ">"
+	if conn.extensions.EightBitMIME {
+		mail += " BODY=8BITMIME"
+	}
+	if conn.extensions.SMTPUTF8 {
+		mail += " SMTPUTF8"
+	}
+	commands := []string{mail}
+	for _, address := range to {
+		commands = append(commands, "RCPT TO:<"+address+">")
+	}
+
+	text := conn.client.Text
+	for _, command := range commands {
+		if strings.ContainsAny(command, "\r\n") {
+			return errors.New("smtp: A line must not contain CR or LF")
+		}
+		fmt.Fprintf(text.W, "%s\r\n", command)
+	}
+	start := time.Now()
+	if err := text.W.Flush(); err != nil {
+		if isConnectionLost(err) {
+			return &staleConnectionError{err: err}
+		}
+		return &SMTPError{Command: "MAIL", Address: m.getEnvelopeFrom(), Err: err}
+	}
+
+	var rejected error
+	recipients := m.recipients()
+	for i := range commands {
+		command, address, expect, phase := "MAIL", m.getEnvelopeFrom(), 250, PhaseMail
+		conn.setDeadline(timeouts.Mail)
+		if i > 0 {
+			// 251 accepts a recipient that is forwarded
+			command, address, expect, phase = "RCPT", recipients[i-1], 25, PhaseRcpt
+			conn.setDeadline(timeouts.Rcpt)
+		}
+
+		_, _, err := text.ReadResponse(expect)
+		m.metrics.observe(phase, start)
+		var protocolErr *textproto.Error
+		switch {
+		case err == nil:
+		case errors.As(err, &protocolErr):
+			if rejected == nil {
+				rejected = &SMTPError{Command: command, Address: address, Err: err}
+			}
+		case i == 0 && isConnectionLost(err):
+			return &staleConnectionError{err: err}
+		default:
+			return &SMTPError{Command: command, Address: address, Err: err}
+		}
+	}
+	return rejected
+}
@@ -0,0 +1,75 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestPipelining(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("batches the envelope", func(t *testing.T) {
+		server := newMockSMTPServer(t)
+		defer server.close()
+		server.advertise("PIPELINING", "8BITMIME")
+		host, port, _ := net.SplitHostPort(server.addr())
+
+		m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).
+			SetCc("cc@example.com")
+		transcript, err := m.SendWithTranscript(ctx)
+		if err != nil {
+			t.Fatalf("SendWithTranscript() error = %v", err)
+		}
+		want := strings.Join([]string{
+			"C: MAIL FROM:<sender@example.com> BODY=8BITMIME",
+			"C: RCPT TO:<recipient@example.com>",
+			"C: RCPT TO:<cc@example.com>",
+			"C: RCPT TO:<hidden@example.com>",
+			"S: 250 Sender OK",
+			"S: 250 Recipient OK",
+			"S: 250 Recipient OK",
+			"S: 250 Recipient OK",
+			"C: DATA",
+		}, "\n")
+		if !strings.Contains(transcript, want) {
+			t.Errorf("transcript does not contain the batched envelope:\n%s", transcript)
+		}
+	})
+
+	t.Run("without PIPELINING", func(t *testing.T) {
+		server := newMockSMTPServer(t)
+		defer server.close()
+		host, port, _ := net.SplitHostPort(server.addr())
+
+		m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port)
+		transcript, err := m.SendWithTranscript(ctx)
+		if err != nil {
+			t.Fatalf("SendWithTranscript() error = %v", err)
+		}
+		if !strings.Contains(transcript, "C: MAIL FROM:<sender@example.com>\nS: 250 Sender OK\nC: RCPT TO:") {
+			t.Errorf("transcript does not wait for the response to MAIL:\n%s", transcript)
+		}
+	})
+
+	t.Run("rejected recipient", func(t *testing.T) {
+		server := newMockSMTPServer(t)
+		defer server.close()
+		server.advertise("PIPELINING")
+		server.replyToRcpt("250 Recipient OK", "550 5.1.1 User unknown", "452 4.5.3 Too many recipients")
+		host, port, _ := net.SplitHostPort(server.addr())
+
+		m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).SetCc("cc@example.com")
+		transcript, err := m.SendWithTranscript(ctx)
+		var smtpErr *SMTPError
+		if !errors.As(err, &smtpErr) || smtpErr.Command != "RCPT" || smtpErr.Address != "cc@example.com" {
+			t.Fatalf("SendWithTranscript() error = %v, want the rejection of cc@example.com", err)
+		}
+		// Every response is read, leaving the connection in sync
+		if !strings.HasSuffix(transcript, "S: 250 Recipient OK\nS: 550 5.1.1 User unknown\nS: 452 4.5.3 Too many recipients") {
+			t.Errorf("transcript does not end with the responses to every RCPT:\n%s", transcript)
+		}
+	})
+}
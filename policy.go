@@ -0,0 +1,146 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// RecipientBlockedError reports a recipient refused by the recipient policy
+type RecipientBlockedError struct {
+	Recipient string
+	Domain    string
+	// Allowlist is true when the domain is refused for not being allowed,
+	// rather than for being blocked
+	Allowlist bool
+}
+
+// Error implements error
+func (e *RecipientBlockedError) Error() string {
+	if e.Allowlist {
+		return "recipient " + e.Recipient + " refused: domain " + e.Domain + " is not allowed"
+	}
+	return "recipient " + e.Recipient + " refused: domain " + e.Domain + " is blocked"
+}
+
+// SetRecipientPolicy restricts the domains the email may be sent to
+func (m *Mail) SetRecipientPolicy(policy *RecipientPolicy) *Mail {
+	m.recipientPolicy = policy
+	return m
+}
+
+// check returns a RecipientBlockedError for every recipient the policy refuses
+func (p *RecipientPolicy) check(recipients []string) error {
+	if p == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, recipient := range recipients {
+		_, domain, _ := strings.Cut(recipient, "@")
+		domain = strings.ToLower(domain)
+
+		switch {
+		case matchesDomain(domain, p.BlockedDomains):
+			errs = append(errs, &RecipientBlockedError{Recipient: recipient, Domain: domain})
+		case len(p.AllowedDomains) > 0 && !matchesDomain(domain, p.AllowedDomains):
+			errs = append(errs, &RecipientBlockedError{Recipient: recipient, Domain: domain, Allowlist: true})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// matchesDomain reports whether domain is one of domains or a subdomain of one
+func matchesDomain(domain string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "@"))
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// TooManyAttachmentsError reports an email with more attachments than the attachment policy allows
+type TooManyAttachmentsError struct {
+	Count int
+	Max   int
+}
+
+// Error implements error
+func (e *TooManyAttachmentsError) Error() string {
+	return fmt.Sprintf("email has %d attachments, more than the limit of %d", e.Count, e.Max)
+}
+
+// AttachmentsTooLargeError reports attachments larger in total than the attachment policy allows
+type AttachmentsTooLargeError struct {
+	Size    int64
+	MaxSize int64
+}
+
+// Error implements error
+func (e *AttachmentsTooLargeError) Error() string {
+	return fmt.Sprintf("attachments of %d bytes exceed the limit of %d bytes", e.Size, e.MaxSize)
+}
+
+// ForbiddenAttachmentError reports an attachment whose extension the attachment policy forbids
+type ForbiddenAttachmentError struct {
+	Name      string
+	Extension string
+}
+
+// Error implements error
+func (e *ForbiddenAttachmentError) Error() string {
+	return "attachment " + e.Name + " refused: extension " + e.Extension + " is forbidden"
+}
+
+// SetAttachmentPolicy limits the attachments of the email. An email that breaks
+// the policy is neither sent nor written by WriteTo.
+func (m *Mail) SetAttachmentPolicy(policy *AttachmentPolicy) *Mail {
+	m.attachmentPolicy = policy
+	return m
+}
+
+// check returns an error for every limit of the policy the attachments of m break
+func (p *AttachmentPolicy) check(m *Mail) error {
+	if p == nil {
+		return nil
+	}
+
+	var errs []error
+	var count int
+	var size int64
+	add := func(name string, length int64) {
+		count++
+		size += length
+		ext := strings.ToLower(filepath.Ext(name))
+		for _, forbidden := range p.ForbiddenExtensions {
+			if ext != "" && ext == "."+strings.ToLower(strings.TrimPrefix(forbidden, ".")) {
+				errs = append(errs, &ForbiddenAttachmentError{Name: name, Extension: ext})
+				break
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(m.Attachments) {
+		add(name, int64(len(m.Attachments[name])))
+	}
+	for _, attachment := range m.streamAttachments {
+		add(attachment.Name, attachment.Size)
+	}
+	for _, attachment := range m.attachmentParts {
+		add(attachment.name, attachment.size)
+	}
+	for _, attachment := range m.inlineAttachments {
+		add(attachment.Name, int64(len(attachment.Data)))
+	}
+
+	if p.MaxAttachments > 0 && count > p.MaxAttachments {
+		errs = append(errs, &TooManyAttachmentsError{Count: count, Max: p.MaxAttachments})
+	}
+	if p.MaxTotalSize > 0 && size > p.MaxTotalSize {
+		errs = append(errs, &AttachmentsTooLargeError{Size: size, MaxSize: p.MaxTotalSize})
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,140 @@
+package gomail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecipientPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     *RecipientPolicy
+		recipients []string
+		wantBlock  []string
+	}{
+		{"no policy", nil, []string{"a@anywhere.com"}, nil},
+		{"allowed", &RecipientPolicy{AllowedDomains: []string{"mycompany.com"}}, []string{"dev@MyCompany.com", "ops@eu.mycompany.com"}, nil},
+		{"not allowed", &RecipientPolicy{AllowedDomains: []string{"@mycompany.com"}}, []string{"dev@mycompany.com", "customer@gmail.com"}, []string{"customer@gmail.com"}},
+		{"suffix is not a subdomain", &RecipientPolicy{AllowedDomains: []string{"mycompany.com"}}, []string{"x@evilmycompany.com"}, []string{"x@evilmycompany.com"}},
+		{"blocked", &RecipientPolicy{BlockedDomains: []string{"competitor.com"}}, []string{"ceo@competitor.com", "a@example.com"}, []string{"ceo@competitor.com"}},
+		{"blocked wins over allowed", &RecipientPolicy{AllowedDomains: []string{"competitor.com"}, BlockedDomains: []string{"competitor.com"}}, []string{"ceo@competitor.com"}, []string{"ceo@competitor.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.check(tt.recipients)
+			if len(tt.wantBlock) == 0 {
+				if err != nil {
+					t.Errorf("check() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var blocked *RecipientBlockedError
+			if !errors.As(err, &blocked) {
+				t.Fatalf("check() error = %v, want a RecipientBlockedError", err)
+			}
+			if blocked.Recipient != tt.wantBlock[0] {
+				t.Errorf("blocked recipient = %s, want %s", blocked.Recipient, tt.wantBlock[0])
+			}
+		})
+	}
+}
+
+func TestSendRecipientPolicy(t *testing.T) {
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    "smtp.invalid",
+		Port:    "25",
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"customer@gmail.com"},
+	}
+	m.SetRecipientPolicy(&RecipientPolicy{AllowedDomains: []string{"mycompany.com"}})
+
+	// The policy is enforced before any connection is made
+	var blocked *RecipientBlockedError
+	if err := m.Send(); !errors.As(err, &blocked) || !blocked.Allowlist {
+		t.Errorf("Send() error = %v, want an allowlist RecipientBlockedError", err)
+	}
+
+	// Redirected email is checked against the address it is actually sent to
+	m.RedirectAllTo("qa@mycompany.com").SetSandbox(true).SetSandboxOutput(io.Discard)
+	if err := m.Send(); err != nil {
+		t.Errorf("Send() of redirected email error = %v", err)
+	}
+}
+
+func TestAttachmentPolicy(t *testing.T) {
+	prepared, err := PrepareAttachment("report.pdf", strings.NewReader("0123456789"))
+	if err != nil {
+		t.Fatalf("PrepareAttachment() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		policy *AttachmentPolicy
+		want   []error
+	}{
+		{name: "no policy"},
+		{name: "within limits", policy: &AttachmentPolicy{MaxAttachments: 4, MaxTotalSize: 21, ForbiddenExtensions: []string{".exe"}}},
+		{
+			name:   "too many",
+			policy: &AttachmentPolicy{MaxAttachments: 3},
+			want:   []error{&TooManyAttachmentsError{Count: 4, Max: 3}},
+		},
+		{
+			name:   "too large",
+			policy: &AttachmentPolicy{MaxTotalSize: 20},
+			want:   []error{&AttachmentsTooLargeError{Size: 21, MaxSize: 20}},
+		},
+		{
+			name:   "forbidden extensions",
+			policy: &AttachmentPolicy{ForbiddenExtensions: []string{".JS", "csv"}},
+			want: []error{
+				&ForbiddenAttachmentError{Name: "script.js", Extension: ".js"},
+				&ForbiddenAttachmentError{Name: "data.csv", Extension: ".csv"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			m := newSandboxMail().SetSandboxOutput(&out).SetAttachmentPolicy(tt.policy)
+			m.Attachments = map[string][]byte{"script.js": []byte("alert")}
+			m.SetStreamAttachment([]AttachmentReader{{Name: "data.csv", Reader: strings.NewReader("a,b"), Size: 3}}).
+				SetPreparedAttachment(prepared).
+				SetInlineAttachment([]Attachment{{Name: "logo.png", Data: []byte("png")}})
+
+			_, writeErr := m.WriteTo(io.Discard)
+			sendErr := m.Send()
+			for _, err := range []error{writeErr, sendErr} {
+				if len(tt.want) == 0 {
+					if err != nil {
+						t.Errorf("error = %v", err)
+					}
+					continue
+				}
+				if want := errors.Join(tt.want...); err == nil || err.Error() != want.Error() {
+					t.Errorf("error = %v, want %v", err, want)
+				}
+				for _, want := range tt.want {
+					if !errors.As(err, reflect.New(reflect.TypeOf(want)).Interface()) {
+						t.Errorf("error = %v, want a %T", err, want)
+					}
+				}
+			}
+			if len(tt.want) > 0 && out.Len() > 0 {
+				t.Error("email breaking the policy was sent")
+			}
+		})
+	}
+}
@@ -1,11 +1,17 @@
 package gomail
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net"
 	"net/smtp"
 	"sync"
+	"syscall"
+	"time"
 )
 
 // Variables for Pool configuration
@@ -15,29 +21,39 @@ var (
 
 // Pool structure
 type Pool struct {
-	connections chan *smtp.Client
+	connections chan *connection
 	config      *Mail
+	endpoint    Endpoint
 	size        int
 	mu          sync.Mutex
+	// retired is set once the pool is no longer handed out
+	retired bool
 }
 
 // NewPool creates a new connection pool
 func NewPool(config *Mail, size int) (*Pool, error) {
+	return newPoolContext(context.Background(), config, config.endpoint(), size)
+}
+
+// newPoolContext creates a new connection pool to endpoint, dialing its connections under ctx
+func newPoolContext(ctx context.Context, config *Mail, endpoint Endpoint, size int) (*Pool, error) {
 	if size <= 0 {
 		size = defaultPoolSize
 	}
 
 	pool := &Pool{
-		connections: make(chan *smtp.Client, size),
+		connections: make(chan *connection, size),
 		config:      config,
+		endpoint:    endpoint,
 		size:        size,
 	}
 
 	// Initialize pool with connections
 	for i := 0; i < size; i++ {
-		client, err := pool.createConnection()
+		client, err := pool.createConnectionContext(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("error initializing pool: %v", err)
+			pool.Close()
+			return nil, fmt.Errorf("error initializing pool: %w", err)
 		}
 		pool.connections <- client
 	}
@@ -45,68 +61,365 @@ func NewPool(config *Mail, size int) (*Pool, error) {
 	return pool, nil
 }
 
+// PoolRegistry shares connection pools between Mail values that use the same
+// server and credentials, so code creating a Mail per request reuses connections
+type PoolRegistry struct {
+	pools map[poolKey]*Pool
+	mu    sync.Mutex
+}
+
+// poolKey identifies the server and credentials a pool is connected with
+type poolKey struct {
+	host, port, user, pass string
+	credentials            CredentialsProvider
+	oauth2                 TokenSource
+}
+
+// DefaultPoolRegistry is a process wide registry for use with Mail.SetPoolRegistry
+var DefaultPoolRegistry = NewPoolRegistry()
+
+// NewPoolRegistry creates an empty pool registry
+func NewPoolRegistry() *PoolRegistry {
+	return &PoolRegistry{pools: make(map[poolKey]*Pool)}
+}
+
+// get returns the pool registered for endpoint and the credentials of config, creating it
+// under ctx if needed. A new pool takes its size, timeouts and TLS settings from config.
+func (r *PoolRegistry) get(ctx context.Context, config *Mail, endpoint Endpoint) (*Pool, error) {
+	user, pass := config.staticCredentials()
+	key := poolKey{endpoint.Host, endpoint.Port, user, pass, config.credentials, config.oauth2}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if pool, ok := r.pools[key]; ok {
+		return pool, nil
+	}
+	pool, err := newPoolContext(ctx, config, endpoint, config.poolSize)
+	if err != nil {
+		return nil, err
+	}
+	r.pools[key] = pool
+	return pool, nil
+}
+
+// Len returns the number of pools in the registry
+func (r *PoolRegistry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pools)
+}
+
+// Close closes and removes every pool in the registry
+func (r *PoolRegistry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, pool := range r.pools {
+		pool.Close()
+		delete(r.pools, key)
+	}
+}
+
+// getPool returns the pool the email is sent to endpoint through, creating it under ctx on first use
+func (m *Mail) getPool(ctx context.Context, endpoint Endpoint) (*Pool, error) {
+	if m.poolRegistry != nil {
+		return m.poolRegistry.get(ctx, m, endpoint)
+	}
+	if m.origin != nil {
+		return m.origin.getPool(ctx, endpoint)
+	}
+
+	m.poolMutex.Lock()
+	defer m.poolMutex.Unlock()
+
+	if pool, ok := m.pools[endpoint]; ok {
+		return pool, nil
+	}
+	pool, err := newPoolContext(ctx, m, endpoint, m.poolSize)
+	if err != nil {
+		return nil, err
+	}
+	if m.pools == nil {
+		m.pools = make(map[Endpoint]*Pool)
+	}
+	m.pools[endpoint] = pool
+	return pool, nil
+}
+
+// connection is a pooled SMTP client together with its underlying network connection
+type connection struct {
+	client *smtp.Client
+	conn   net.Conn
+	ctx    context.Context
+	// extensions are the service extensions advertised by the server
+	extensions Extensions
+	// used is set once a transaction was started on the connection
+	used bool
+	// user and pass are the credentials the connection authenticated with
+	user, pass string
+	// recorder records the server responses read from the connection
+	recorder *responseRecorder
+	// transcript, when not nil, records the whole session for SendWithTranscript
+	transcript *transcript
+	// greeting, ehlo and lastReply are the server responses kept for diagnostics
+	greeting, ehlo, lastReply string
+}
+
+// bind makes the network operations of c observe ctx until the returned function is called.
+// Cancelling ctx expires the socket deadline, interrupting any blocked read or write.
+func (c *connection) bind(ctx context.Context) func() {
+	c.ctx = ctx
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Unix(1, 0))
+	})
+	return func() {
+		stop()
+		c.ctx = nil
+	}
+}
+
+// setDeadline sets the deadline of the next network operations to d from now,
+// capped by the deadline of the bound context
+func (c *connection) setDeadline(d time.Duration) error {
+	deadline := time.Now().Add(d)
+	if c.ctx != nil {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		if ctxDeadline, ok := c.ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+	}
+	return c.conn.SetDeadline(deadline)
+}
+
+// quit ends the SMTP session politely with QUIT before closing the connection,
+// giving the server at most timeout to respond
+func (c *connection) quit(timeout time.Duration) {
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	if err := c.client.Quit(); err != nil {
+		c.client.Close()
+	}
+}
+
+// clearDeadline removes any deadline from the underlying network connection
+func (c *connection) clearDeadline() error {
+	return c.conn.SetDeadline(time.Time{})
+}
+
 // Create a new connection
-func (p *Pool) createConnection() (*smtp.Client, error) {
+func (p *Pool) createConnection() (*connection, error) {
+	return p.createConnectionContext(context.Background())
+}
+
+// createConnectionContext creates a new connection for the pool under ctx
+func (p *Pool) createConnectionContext(ctx context.Context) (*connection, error) {
 	if p == nil || p.config == nil {
 		return nil, fmt.Errorf("pool or config is not initialized")
 	}
 
-	addr := fmt.Sprintf("%s:%s", p.config.Host, p.config.Port)
+	return dialEndpoint(ctx, p.config, p.endpoint)
+}
+
+// dialConnection dials, greets, upgrades and authenticates a new connection to the server of config under ctx
+func dialConnection(ctx context.Context, config *Mail) (*connection, error) {
+	return dialEndpoint(ctx, config, config.endpoint())
+}
+
+// dialEndpoint dials, greets, upgrades and authenticates a new connection to endpoint
+// under ctx, using the credentials and settings of config
+func dialEndpoint(ctx context.Context, config *Mail, endpoint Endpoint) (*connection, error) {
+	addr := net.JoinHostPort(endpoint.Host, endpoint.Port)
 
 	dialer := &net.Dialer{
-		Timeout:   p.config.getTimeout(),
-		KeepAlive: p.config.getKeepAlive(),
+		Timeout:   config.getConnectTimeout(),
+		KeepAlive: config.getKeepAlive(),
 	}
 
-	var conn net.Conn
-	var err error
-
-	if p.config.tlsConfig != nil && !p.config.tlsConfig.StartTLS {
-		// Direct TLS connection
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: p.config.tlsConfig.InsecureSkipVerify,
-			ServerName:         p.config.tlsConfig.ServerName,
-			Certificates:       p.config.tlsConfig.Certificates,
+	settings := config.getTLSConfig()
+	var tlsConfig *tls.Config
+	if settings != nil {
+		var err error
+		if tlsConfig, err = settings.clientConfig(endpoint.Host); err != nil {
+			return nil, err
 		}
-		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
-	} else {
-		// Plain connection for STARTTLS
-		conn, err = dialer.Dial("tcp", addr)
 	}
 
+	config.transcript.connect(addr)
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	config.metrics.observe(PhaseConnect, start)
 	if err != nil {
 		return nil, err
 	}
 
-	client, err := smtp.NewClient(conn, p.config.Host)
-	if err != nil {
+	if settings.directTLS() {
+		// Direct TLS connection, handshaken apart from the dial so its latency
+		// is known; a plain connection is upgraded with STARTTLS below
+		handshakeCtx, cancel := context.WithTimeout(ctx, dialer.Timeout)
+		start = time.Now()
+		tlsConn := tls.Client(conn, tlsConfig)
+		err = tlsConn.HandshakeContext(handshakeCtx)
+		config.metrics.observe(PhaseTLS, start)
+		cancel()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	timeouts := config.getCommandTimeouts()
+	c := &connection{conn: conn, recorder: new(responseRecorder), transcript: config.transcript}
+	unbind := c.bind(ctx)
+	defer unbind()
+
+	// The greeting is read by smtp.NewClient
+	if err := c.setDeadline(timeouts.Greeting); err != nil {
 		conn.Close()
 		return nil, err
 	}
+	client, err := smtp.NewClient(&recordingConn{Conn: conn, recorder: c.recorder, transcript: c.transcript}, endpoint.Host)
+	if err != nil {
+		conn.Close()
+		return nil, contextError(ctx, err)
+	}
+	c.client = client
+	c.greeting = c.recorder.lastResponse()
+
+	c.setDeadline(timeouts.Hello)
+	if err := client.Hello(config.getLocalName()); err != nil {
+		client.Close()
+		return nil, contextError(ctx, err)
+	}
+	c.ehlo = c.recorder.lastResponse()
+
+	// The EHLO response after STARTTLS no longer offers STARTTLS
+	offersStartTLS, _ := client.Extension("STARTTLS")
 
-	if p.config.tlsConfig != nil && p.config.tlsConfig.StartTLS {
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: p.config.tlsConfig.InsecureSkipVerify,
-			ServerName:         p.config.tlsConfig.ServerName,
-			Certificates:       p.config.tlsConfig.Certificates,
+	switch settings.policy() {
+	case TLSMandatory:
+		if !offersStartTLS {
+			client.Close()
+			return nil, fmt.Errorf("%w: server does not offer STARTTLS", ErrTLSRequired)
+		}
+		c.recorder.stop()
+		start := time.Now()
+		err := client.StartTLS(tlsConfig)
+		config.metrics.observe(PhaseTLS, start)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("%w: STARTTLS failed: %w", ErrTLSRequired, contextError(ctx, err))
+		}
+	case TLSOpportunistic:
+		if !offersStartTLS {
+			log.Printf("Warning: %s does not offer STARTTLS, continuing without TLS", addr)
+			break
 		}
-		if err := client.StartTLS(tlsConfig); err != nil {
+		c.recorder.stop()
+		start := time.Now()
+		err := client.StartTLS(tlsConfig)
+		config.metrics.observe(PhaseTLS, start)
+		if err != nil {
 			client.Close()
-			return nil, fmt.Errorf("STARTTLS failed: %v", err)
+			return nil, fmt.Errorf("STARTTLS failed: %w", contextError(ctx, err))
 		}
 	}
 
-	auth := smtp.PlainAuth("", p.config.User, p.config.Pass, p.config.Host)
-	if err := client.Auth(auth); err != nil {
+	// After STARTTLS net/smtp sees the TLS connection; a direct TLS connection
+	// is hidden behind the recordingConn
+	_, upgraded := client.TLSConnectionState()
+	encrypted := upgraded || settings.directTLS()
+	if upgraded {
+		if err := c.recordUpgraded(client, config.getLocalName()); err != nil {
+			client.Close()
+			return nil, contextError(ctx, err)
+		}
+	}
+	if settings != nil && settings.RequireTLS && !encrypted {
+		client.Close()
+		return nil, fmt.Errorf("%w: connection is not encrypted", ErrTLSRequired)
+	}
+
+	user, pass, err := config.getCredentials(ctx)
+	if err != nil {
 		client.Close()
 		return nil, err
 	}
+	c.user, c.pass = user, pass
+
+	auth := config.auth(endpoint.Host, user, pass)
+	if encrypted && !upgraded {
+		auth = &encryptedAuth{Auth: auth}
+	}
+	c.setDeadline(timeouts.Auth)
+	start = time.Now()
+	err = client.Auth(auth)
+	config.metrics.observe(PhaseAuth, start)
+	if err != nil {
+		client.Close()
+		return nil, contextError(ctx, err)
+	}
+
+	c.extensions = parseExtensions(client)
+	c.extensions.StartTLS = offersStartTLS
+	c.extensions.TLS = encrypted
+
+	c.clearDeadline()
+	return c, nil
+}
+
+// ErrTLSRequired is returned when STARTTLS is mandatory or TLSConfig.RequireTLS
+// is set and the connection could not be encrypted. No credentials are sent over such a connection.
+var ErrTLSRequired = errors.New("TLS required")
+
+// directTLS reports whether the connection is encrypted from the start
+// instead of being upgraded with STARTTLS
+func (c *TLSConfig) directTLS() bool {
+	return c != nil && c.Policy == TLSPolicyDefault && !c.StartTLS
+}
+
+// policy returns the STARTTLS policy, resolving the default from StartTLS
+func (c *TLSConfig) policy() TLSPolicy {
+	switch {
+	case c == nil || c.directTLS():
+		return TLSDisabled
+	case c.Policy == TLSPolicyDefault:
+		return TLSMandatory
+	}
+	return c.Policy
+}
 
-	return client, nil
+// clientConfig returns the crypto/tls configuration for connecting to host,
+// verifying the certificate against host unless ServerName is set
+func (c *TLSConfig) clientConfig(host string) (*tls.Config, error) {
+	serverName := c.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	certificates := c.Certificates
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := c.certificate()
+		if err != nil {
+			return nil, err
+		}
+		certificates = append([]tls.Certificate{*cert}, certificates...)
+	}
+	return &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		ServerName:         serverName,
+		Certificates:       certificates,
+	}, nil
 }
 
 // Get a connection from the pool
-func (p *Pool) getConnection() (*smtp.Client, error) {
+func (p *Pool) getConnection() (*connection, error) {
+	return p.getConnectionContext(context.Background())
+}
+
+// getConnectionContext takes an idle connection from the pool or dials a new one under ctx
+func (p *Pool) getConnectionContext(ctx context.Context) (*connection, error) {
 	if p == nil || p.connections == nil {
 		return nil, fmt.Errorf("pool is not initialized")
 	}
@@ -114,24 +427,102 @@ func (p *Pool) getConnection() (*smtp.Client, error) {
 	select {
 	case client := <-p.connections:
 		if client == nil {
-			return p.createConnection()
+			return p.createConnectionContext(ctx)
 		}
-		return client, nil
+		return p.checkCredentials(ctx, client)
 	default:
-		return p.createConnection()
+		return p.createConnectionContext(ctx)
+	}
+}
+
+// checkCredentials returns c, or a new connection when the credentials provider
+// has rotated the credentials c authenticated with
+func (p *Pool) checkCredentials(ctx context.Context, c *connection) (*connection, error) {
+	if p.config.credentials == nil {
+		return c, nil
+	}
+	user, pass, err := p.config.getCredentials(ctx)
+	if err != nil {
+		p.releaseConnection(c)
+		return nil, err
+	}
+	if user == c.user && pass == c.pass {
+		return c, nil
 	}
+	c.quit(p.config.getCommandTimeouts().Quit)
+	return p.createConnectionContext(ctx)
 }
 
 // Release a connection back to the pool
-func (p *Pool) releaseConnection(client *smtp.Client) {
-	if client == nil {
+func (p *Pool) releaseConnection(c *connection) {
+	if c == nil {
 		return
 	}
 
-	select {
-	case p.connections <- client:
-	default:
-		client.Close()
+	c.clearDeadline()
+	p.mu.Lock()
+	pooled := false
+	if !p.retired {
+		select {
+		case p.connections <- c:
+			pooled = true
+		default:
+		}
+	}
+	p.mu.Unlock()
+
+	if !pooled {
+		c.quit(p.config.getCommandTimeouts().Quit)
+	}
+}
+
+// retire closes the idle connections of a pool that is no longer handed out;
+// connections in use are closed when they are released
+func (p *Pool) retire() {
+	p.mu.Lock()
+	p.retired = true
+	var idle []*connection
+	for len(p.connections) > 0 {
+		if c := <-p.connections; c != nil {
+			idle = append(idle, c)
+		}
+	}
+	p.mu.Unlock()
+
+	timeout := p.config.getCommandTimeouts().Quit
+	for _, c := range idle {
+		c.quit(timeout)
+	}
+}
+
+// discardConnection closes a connection whose state is unknown instead of returning it to the pool
+func (p *Pool) discardConnection(c *connection) {
+	if c == nil {
+		return
+	}
+	c.client.Close()
+}
+
+// retire removes the pools authenticated with user and pass, or with provider when it
+// is not nil, from the registry and retires them
+func (r *PoolRegistry) retire(user, pass string, provider CredentialsProvider) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	var retired []*Pool
+	for key, pool := range r.pools {
+		if provider != nil && key.credentials == provider ||
+			key.credentials == nil && key.user == user && key.pass == pass {
+			retired = append(retired, pool)
+			delete(r.pools, key)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, pool := range retired {
+		pool.retire()
 	}
 }
 
@@ -144,10 +535,80 @@ func (p *Pool) Close() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.retired = true
 	close(p.connections)
-	for client := range p.connections {
-		if client != nil {
-			client.Close()
+	timeout := p.config.getCommandTimeouts().Quit
+	for c := range p.connections {
+		if c != nil {
+			c.quit(timeout)
 		}
 	}
 }
+
+// staleConnectionError reports that a connection was found closed by the server
+// before the transaction started, so it is safe to retry on a new connection
+type staleConnectionError struct {
+	err error
+}
+
+// Error implements error
+func (e *staleConnectionError) Error() string {
+	return "connection closed by server: " + e.err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *staleConnectionError) Unwrap() error {
+	return e.err
+}
+
+// isConnectionLost reports whether err means the server closed the connection
+func isConnectionLost(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// contextError returns the context error when ctx is done, since err is then
+// only the symptom of the expired socket deadline
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := contextDone(ctx, err); ctxErr != nil {
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	return err
+}
+
+// contextDone returns the error of ctx when it is done. The socket deadline is
+// capped at the deadline of ctx, so err may be the timeout at that deadline
+// before ctx reports it is exceeded, which counts as done.
+func contextDone(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	var netErr net.Error
+	if deadline, ok := ctx.Deadline(); ok && errors.As(err, &netErr) && netErr.Timeout() && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// deadlineWriter extends the connection deadline before every write
+type deadlineWriter struct {
+	w       io.Writer
+	conn    *connection
+	timeout time.Duration
+	// err is the error of the last failed write to the connection
+	err error
+}
+
+// Write implements io.Writer
+func (dw *deadlineWriter) Write(p []byte) (int, error) {
+	if err := dw.conn.setDeadline(dw.timeout); err != nil {
+		dw.err = err
+		return 0, err
+	}
+	n, err := dw.w.Write(p)
+	if err != nil {
+		dw.err = err
+	}
+	return n, err
+}
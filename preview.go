@@ -0,0 +1,148 @@
+package gomail
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Preview is a machine-readable preview of an email, for rendering in an admin UI
+type Preview struct {
+	// Headers holds the headers the email is sent with, plus Bcc when it has Bcc recipients
+	Headers map[string]string `json:"headers"`
+	// Text is the plain text body, from AltContent or a plain text or Markdown Content
+	Text string `json:"text,omitempty"`
+	// HTML is the HTML body
+	HTML        string              `json:"html,omitempty"`
+	Attachments []PreviewAttachment `json:"attachments"`
+}
+
+// PreviewAttachment describes an attachment of a previewed email. Size is the size
+// of the content in bytes, the encoded size for a prepared attachment, and the
+// declared Size of a streaming attachment, which is zero when unknown.
+type PreviewAttachment struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// PreviewJSON returns a preview of the email as JSON, encoding a Preview. Unlike
+// PreviewEmail it only needs the message fields, not the server settings.
+func (m *Mail) PreviewJSON() ([]byte, error) {
+	if !m.validateMessage() {
+		return nil, errors.New("missing parameter")
+	}
+	preview, err := m.preview()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(preview)
+}
+
+// preview returns a preview of the email
+func (m *Mail) preview() (*Preview, error) {
+	preview := &Preview{Headers: make(map[string]string), Attachments: []PreviewAttachment{}}
+	// Folded lines are unfolded back into the single spaces they were folded at
+	for _, line := range strings.Split(strings.ReplaceAll(m.headers(), "\r\n ", " "), "\r\n") {
+		if name, value, ok := strings.Cut(line, ": "); ok {
+			preview.Headers[name] = value
+		}
+	}
+	if len(m.Bcc) > 0 && m.redirectTo == "" {
+		preview.Headers["Bcc"] = strings.Join(m.Bcc, ", ")
+	}
+
+	mediaType, err := m.bodyMediaType()
+	if err != nil {
+		return nil, err
+	}
+	if mediaType == "text/html" {
+		preview.HTML = m.Content
+		preview.Text = m.AltContent
+	} else {
+		preview.Text = m.Content
+	}
+
+	for _, name := range sortedKeys(m.Attachments) {
+		preview.Attachments = append(preview.Attachments, PreviewAttachment{Name: name, Size: int64(len(m.Attachments[name]))})
+	}
+	for _, attachment := range m.streamAttachments {
+		preview.Attachments = append(preview.Attachments, PreviewAttachment{Name: attachment.Name, Size: attachment.Size})
+	}
+	for _, attachment := range m.attachmentParts {
+		preview.Attachments = append(preview.Attachments, PreviewAttachment{Name: attachment.Name(), Size: attachment.Size()})
+	}
+	for _, attachment := range m.inlineAttachments {
+		preview.Attachments = append(preview.Attachments, PreviewAttachment{Name: attachment.Name, Size: int64(len(attachment.Data)), Inline: true})
+	}
+	return preview, nil
+}
+
+// OpenPreview writes the HTML body of the email to a temporary file and opens it
+// in the default browser, as a development aid while iterating on templates.
+// Images referenced as cid:<Name> are inlined from the inline attachments, and a
+// plain text body is shown preformatted. It returns the path of the file, which
+// is left for the browser to read and can be opened by hand if no browser starts.
+func (m *Mail) OpenPreview() (string, error) {
+	if !m.validateMessage() {
+		return "", errors.New("missing parameter")
+	}
+	page, err := m.previewHTML()
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp("", "gomail-preview-*.html")
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.WriteString(page); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	return file.Name(), openBrowser(file.Name())
+}
+
+// previewHTML returns the body of the email as a standalone HTML page
+func (m *Mail) previewHTML() (string, error) {
+	mediaType, err := m.bodyMediaType()
+	if err != nil {
+		return "", err
+	}
+	if mediaType != "text/html" {
+		return "<!DOCTYPE html>\n<pre>" + html.EscapeString(m.Content) + "</pre>\n", nil
+	}
+
+	pairs := make([]string, 0, 2*len(m.inlineAttachments))
+	for _, attachment := range m.inlineAttachments {
+		pairs = append(pairs, "cid:"+attachment.Name,
+			"data:"+attachment.contentType()+";base64,"+base64.StdEncoding.EncodeToString(attachment.Data))
+	}
+	return strings.NewReplacer(pairs...).Replace(m.Content), nil
+}
+
+// openBrowser opens a file in the default browser; a variable so tests can stub it
+var openBrowser = func(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,174 @@
+package gomail
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPreviewJSON(t *testing.T) {
+	prepared, err := PrepareAttachment("report.pdf", strings.NewReader("pdf"))
+	if err != nil {
+		t.Fatalf("PrepareAttachment() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		setup func(m *Mail)
+		want  Preview
+	}{
+		{
+			name:  "plain text",
+			setup: func(m *Mail) { m.SetContentType(TextPlain) },
+			want: Preview{
+				Headers: map[string]string{
+					"From":         "Test Sender <sender@example.com>",
+					"To":           "recipient@example.com",
+					"Cc":           "cc@example.com",
+					"Bcc":          "bcc@example.com",
+					"Subject":      "Test Subject",
+					"MIME-Version": "1.0",
+				},
+				Text:        "Test Content",
+				Attachments: []PreviewAttachment{},
+			},
+		},
+		{
+			name: "HTML with attachments",
+			setup: func(m *Mail) {
+				m.Content = "<p>Test Content</p>"
+				m.AltContent = "Test Content"
+				m.Attachments = map[string][]byte{"notes.txt": []byte("notes")}
+				m.SetPreparedAttachment(prepared)
+				m.SetInlineAttachment([]Attachment{{Name: "logo.png", Data: []byte("png")}})
+			},
+			want: Preview{
+				Headers: map[string]string{
+					"From":         "Test Sender <sender@example.com>",
+					"To":           "recipient@example.com",
+					"Cc":           "cc@example.com",
+					"Bcc":          "bcc@example.com",
+					"Subject":      "Test Subject",
+					"MIME-Version": "1.0",
+				},
+				Text: "Test Content",
+				HTML: "<p>Test Content</p>",
+				Attachments: []PreviewAttachment{
+					{Name: "notes.txt", Size: 5},
+					{Name: "report.pdf", Size: prepared.Size()},
+					{Name: "logo.png", Size: 3, Inline: true},
+				},
+			},
+		},
+		{
+			name:  "redirected",
+			setup: func(m *Mail) { m.RedirectAllTo("qa@example.com") },
+			want: Preview{
+				Headers: map[string]string{
+					"From":           "Test Sender <sender@example.com>",
+					"To":             "qa@example.com",
+					"Subject":        "Test Subject",
+					"X-Original-To":  "recipient@example.com",
+					"X-Original-Cc":  "cc@example.com",
+					"X-Original-Bcc": "bcc@example.com",
+					"MIME-Version":   "1.0",
+				},
+				HTML:        "Test Content",
+				Attachments: []PreviewAttachment{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+				Cc:      []string{"cc@example.com"},
+				Bcc:     []string{"bcc@example.com"},
+			}
+			tt.setup(m)
+
+			data, err := m.PreviewJSON()
+			if err != nil {
+				t.Fatalf("PreviewJSON() error = %v", err)
+			}
+			var got Preview
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("PreviewJSON() returned invalid JSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PreviewJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&Mail{}).PreviewJSON(); err == nil {
+		t.Error("PreviewJSON() of an incomplete email should fail")
+	}
+}
+
+func TestOpenPreview(t *testing.T) {
+	opened := ""
+	defer func(open func(string) error) { openBrowser = open }(openBrowser)
+	openBrowser = func(path string) error {
+		opened = path
+		return nil
+	}
+
+	tests := []struct {
+		name  string
+		setup func(m *Mail)
+		want  string
+	}{
+		{
+			name: "HTML with inline image",
+			setup: func(m *Mail) {
+				m.Content = `<img src="cid:logo.png"><p>Hello</p>`
+				m.SetInlineAttachment([]Attachment{{Name: "logo.png", Data: []byte("png")}})
+			},
+			want: `<img src="data:image/png;base64,cG5n"><p>Hello</p>`,
+		},
+		{
+			name: "plain text",
+			setup: func(m *Mail) {
+				m.Content = "a < b"
+				m.SetContentType(TextPlain)
+			},
+			want: "<!DOCTYPE html>\n<pre>a &lt; b</pre>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Subject: "Test Subject",
+				To:      []string{"recipient@example.com"},
+			}
+			tt.setup(m)
+
+			path, err := m.OpenPreview()
+			if err != nil {
+				t.Fatalf("OpenPreview() error = %v", err)
+			}
+			defer os.Remove(path)
+			if opened != path {
+				t.Errorf("browser opened %q, want %q", opened, path)
+			}
+			page, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading the preview: %v", err)
+			}
+			if string(page) != tt.want {
+				t.Errorf("preview page = %q, want %q", page, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Profiles manages named sender profiles, such as marketing, transactional and
+// alerts, behind one API. Each profile is a Mail holding its own server,
+// credentials, pools, rate limit and DKIM keys; messages sent through a profile
+// only contribute their message fields.
+type Profiles struct {
+	profiles map[string]*Mail
+	mu       sync.RWMutex
+}
+
+// NewProfiles creates an empty profile manager
+func NewProfiles() *Profiles {
+	return &Profiles{profiles: make(map[string]*Mail)}
+}
+
+// Register adds the profile name configured by config
+func (p *Profiles) Register(name string, config *Mail) error {
+	if name == "" || config == nil {
+		return errors.New("missing parameter")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.profiles[name]; ok {
+		return fmt.Errorf("profile %q is already registered", name)
+	}
+	p.profiles[name] = config
+	return nil
+}
+
+// Profile returns the configuration of the profile name
+func (p *Profiles) Profile(name string) (*Mail, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	config, ok := p.profiles[name]
+	return config, ok
+}
+
+// Send sends msg through the profile name
+func (p *Profiles) Send(name string, msg *Mail) error {
+	return p.SendContext(context.Background(), name, msg)
+}
+
+// SendContext sends msg through the profile name under ctx. The subject, content,
+// recipients and attachments come from msg; From, Name and the envelope sender
+// come from msg when set and from the profile otherwise.
+func (p *Profiles) SendContext(ctx context.Context, name string, msg *Mail) error {
+	config, ok := p.Profile(name)
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	return config.withMessage(msg).sendContext(ctx)
+}
+
+// Close closes the connections pooled by the profiles
+func (p *Profiles) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, config := range p.profiles {
+		config.poolMutex.Lock()
+		if config.senderPools != nil {
+			config.senderPools.Close()
+			config.senderPools = nil
+		}
+		config.poolMutex.Unlock()
+	}
+}
+
+// withMessage returns a copy of m for a single send of the message fields of msg
+func (m *Mail) withMessage(msg *Mail) *Mail {
+	c := m.clone()
+	c.applyIdentity()
+	c.identities = nil
+
+	if msg.From != "" {
+		c.From = msg.From
+	}
+	if msg.Name != "" {
+		c.Name = msg.Name
+	}
+	if msg.envelopeFrom != "" {
+		c.envelopeFrom = msg.envelopeFrom
+	}
+	c.Subject = msg.Subject
+	c.Content = msg.Content
+	c.AltContent = msg.AltContent
+	c.To = msg.To
+	c.Cc = msg.Cc
+	c.Bcc = msg.Bcc
+	c.Attachments = msg.Attachments
+	c.streamAttachments = msg.streamAttachments
+	c.inlineAttachments = msg.inlineAttachments
+	c.attachmentParts = msg.attachmentParts
+	c.customParts = msg.customParts
+	c.ContentType = msg.ContentType
+	c.calendar = msg.calendar
+	c.messageID = msg.messageID
+	if msg.tags != nil {
+		c.tags = msg.tags
+	}
+	if msg.quotaKey != "" {
+		c.quotaKey = msg.quotaKey
+	}
+	if msg.charset != "" {
+		c.charset = msg.charset
+	}
+	if msg.transferEncoding != "" {
+		c.transferEncoding = msg.transferEncoding
+	}
+	return c
+}
@@ -0,0 +1,83 @@
+package gomail
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	billing := newMockSMTPServer(t)
+	defer billing.close()
+	alerts := newMockSMTPServer(t)
+	defer alerts.close()
+
+	profile := func(server *mockSMTPServer, from string) *Mail {
+		host, port, _ := net.SplitHostPort(server.addr())
+		m := &Mail{From: from, Name: "Example", Host: host, Port: port, User: "user", Pass: "pass"}
+		return m.SetPoolSize(1)
+	}
+
+	profiles := NewProfiles()
+	defer profiles.Close()
+	if err := profiles.Register("billing", profile(billing, "billing@example.com")); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := profiles.Register("alerts", profile(alerts, "alerts@example.com")); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := profiles.Register("billing", profile(alerts, "other@example.com")); err == nil {
+		t.Error("Register() of a duplicate profile should fail")
+	}
+
+	tests := []struct {
+		name    string
+		profile string
+		msg     *Mail
+		server  *mockSMTPServer
+		from    string
+	}{
+		{
+			name:    "profile sender",
+			profile: "billing",
+			msg:     &Mail{Subject: "Invoice", Content: "Your invoice", To: []string{"customer@example.com"}},
+			server:  billing,
+			from:    "billing@example.com",
+		},
+		{
+			name:    "message sender",
+			profile: "alerts",
+			msg:     (&Mail{From: "oncall@example.com", Subject: "Alert", Content: "Disk full", To: []string{"ops@example.com"}}).SetMessageID("alert-7@example.com"),
+			server:  alerts,
+			from:    "oncall@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := profiles.Send(tt.profile, tt.msg); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			messages := tt.server.getMessages()
+			if len(messages) != 1 {
+				t.Fatalf("server received %d messages, want 1", len(messages))
+			}
+			if !strings.Contains(messages[0], "MAIL FROM:<"+tt.from+">") {
+				t.Errorf("message not sent from %s", tt.from)
+			}
+			if !strings.Contains(messages[0], "Subject: "+tt.msg.Subject) {
+				t.Errorf("message missing subject %q", tt.msg.Subject)
+			}
+			if id := tt.msg.messageID; id != "" && !strings.Contains(messages[0], "Message-ID: <"+id+">") {
+				t.Errorf("message missing the Message-ID %q", id)
+			}
+		})
+	}
+
+	if err := profiles.Send("marketing", &Mail{}); err == nil {
+		t.Error("Send() through an unknown profile should fail")
+	}
+	if config, _ := profiles.Profile("billing"); config.Subject != "" {
+		t.Error("Send() modified the profile")
+	}
+}
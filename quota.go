@@ -0,0 +1,110 @@
+package gomail
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuotaExceededError reports an email refused by an AsyncQueue because its
+// quota key used up its hourly or daily quota
+type QuotaExceededError struct {
+	Key string
+	// Window is time.Hour for the hourly quota and 24 hours for the daily quota
+	Window time.Duration
+	Limit  int
+	// Sent is the number of recipients already sent to in the window
+	Sent int
+	// Resume is when the next window starts
+	Resume time.Time
+}
+
+// Error implements error
+func (e *QuotaExceededError) Error() string {
+	period := "hourly"
+	if e.Window != time.Hour {
+		period = "daily"
+	}
+	return fmt.Sprintf("%s quota of %d recipients reached for %q (%d sent), resume at %s",
+		period, e.Limit, e.Key, e.Sent, e.Resume.Format(time.RFC3339))
+}
+
+// SetQuotaKey sets the key, e.g. a tenant id, whose quota the email counts
+// against when it is sent through an async queue with quotas
+func (m *Mail) SetQuotaKey(key string) *Mail {
+	m.quotaKey = key
+	return m
+}
+
+// SetQuotas limits the volume the queue sends per quota key to quotas. Emails
+// over the quota of their key fail with a *QuotaExceededError when queued.
+func (q *AsyncQueue) SetQuotas(quotas *Quotas) *AsyncQueue {
+	q.quotas = quotas
+	return q
+}
+
+// quotaUsage is the number of recipients sent to for a quota key in the
+// current hour and day
+type quotaUsage struct {
+	hour, day     time.Time
+	hourly, daily int
+}
+
+// reserve accounts for a send to recipients recipients with key at now, and
+// returns the function giving them back when the email is not sent
+func (q *Quotas) reserve(key string, recipients int, now time.Time) (release func(), err error) {
+	if q == nil || key == "" {
+		return func() {}, nil
+	}
+	quota, ok := q.Keys[key]
+	if !ok {
+		quota = q.Default
+	}
+	if quota.Hourly <= 0 && quota.Daily <= 0 {
+		return func() {}, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.usage == nil {
+		q.usage = make(map[string]*quotaUsage)
+	}
+	usage := q.usage[key]
+	if usage == nil {
+		usage = new(quotaUsage)
+		q.usage[key] = usage
+	}
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	year, month, date := local.Date()
+	hour := time.Date(year, month, date, local.Hour(), 0, 0, 0, loc)
+	day, nextDay := time.Date(year, month, date, 0, 0, 0, 0, loc), time.Date(year, month, date+1, 0, 0, 0, 0, loc)
+	if !usage.hour.Equal(hour) {
+		usage.hour, usage.hourly = hour, 0
+	}
+	if !usage.day.Equal(day) {
+		usage.day, usage.daily = day, 0
+	}
+
+	if quota.Daily > 0 && usage.daily+recipients > quota.Daily {
+		return nil, &QuotaExceededError{Key: key, Window: 24 * time.Hour, Limit: quota.Daily, Sent: usage.daily, Resume: nextDay}
+	}
+	if quota.Hourly > 0 && usage.hourly+recipients > quota.Hourly {
+		return nil, &QuotaExceededError{Key: key, Window: time.Hour, Limit: quota.Hourly, Sent: usage.hourly, Resume: hour.Add(time.Hour)}
+	}
+	usage.hourly += recipients
+	usage.daily += recipients
+
+	return func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if usage.hour.Equal(hour) {
+			usage.hourly -= recipients
+		}
+		if usage.day.Equal(day) {
+			usage.daily -= recipients
+		}
+	}, nil
+}
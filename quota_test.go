@@ -0,0 +1,151 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuotas(t *testing.T) {
+	clock := newFakeClock()
+	quotas := &Quotas{
+		Default: Quota{Hourly: 2},
+		Keys:    map[string]Quota{"enterprise": {Hourly: 100, Daily: 3}},
+	}
+	queue := NewAsyncQueue(10, QueueBlock).SetQuotas(quotas)
+	queue.workers = 1
+	newMail := func(key string) *Mail {
+		m := newSandboxMail().SetSandbox(false).SetClock(clock).SetAsyncQueue(queue).SetQuotaKey(key).
+			SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error { return nil }))
+		m.Bcc = nil
+		return m
+	}
+	send := func(key string) error {
+		return receive(t, newMail(key).SendAsync())
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := send("tenant-a"); err != nil {
+			t.Fatalf("send %d error = %v", i, err)
+		}
+	}
+	var quotaErr *QuotaExceededError
+	if err := send("tenant-a"); !errors.As(err, &quotaErr) {
+		t.Fatalf("third send error = %v, want a *QuotaExceededError", err)
+	}
+	want := QuotaExceededError{Key: "tenant-a", Window: time.Hour, Limit: 2, Sent: 2, Resume: clock.Now().Add(time.Hour)}
+	if *quotaErr != want {
+		t.Errorf("error = %+v, want %+v", *quotaErr, want)
+	}
+
+	// Other tenants and emails without a key are not affected
+	if err := send("tenant-b"); err != nil {
+		t.Errorf("other tenant: error = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := send(""); err != nil {
+			t.Errorf("email without a quota key: error = %v", err)
+		}
+	}
+
+	clock.Advance(time.Hour)
+	if err := send("tenant-a"); err != nil {
+		t.Errorf("send in the next hour error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := send("enterprise"); err != nil {
+			t.Fatalf("enterprise send %d error = %v", i, err)
+		}
+	}
+	if err := send("enterprise"); !errors.As(err, &quotaErr) || quotaErr.Window != 24*time.Hour {
+		t.Errorf("send over the daily quota error = %v, want the daily quota exceeded", err)
+	}
+	clock.Advance(15 * time.Hour)
+	if err := send("enterprise"); err != nil {
+		t.Errorf("send on the next day error = %v", err)
+	}
+}
+
+func TestQuotasLocation(t *testing.T) {
+	// 09:00 UTC is 14:30 in India, half an hour into its clock hour
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	quotas := &Quotas{Default: Quota{Hourly: 1, Daily: 2}, Location: time.FixedZone("IST", 5*60*60+30*60)}
+
+	steps := []struct {
+		name       string
+		at         time.Time
+		wantWindow time.Duration
+		wantResume time.Time
+	}{
+		{name: "first", at: now},
+		{name: "hour used up", at: now, wantWindow: time.Hour, wantResume: time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)},
+		{name: "next hour", at: now.Add(30 * time.Minute)},
+		{name: "day used up", at: now.Add(90 * time.Minute), wantWindow: 24 * time.Hour, wantResume: time.Date(2024, 5, 1, 18, 30, 0, 0, time.UTC)},
+		{name: "next day", at: time.Date(2024, 5, 1, 18, 30, 0, 0, time.UTC)},
+	}
+	for _, step := range steps {
+		_, err := quotas.reserve("tenant-a", 1, step.at)
+		var quotaErr *QuotaExceededError
+		if step.wantWindow == 0 {
+			if err != nil {
+				t.Errorf("%s: reserve() error = %v", step.name, err)
+			}
+			continue
+		}
+		if !errors.As(err, &quotaErr) || quotaErr.Window != step.wantWindow || !quotaErr.Resume.Equal(step.wantResume) {
+			t.Errorf("%s: reserve() error = %v, want the %v quota exceeded until %v", step.name, err, step.wantWindow, step.wantResume)
+		}
+	}
+}
+
+func TestQuotasRelease(t *testing.T) {
+	quotas := &Quotas{Default: Quota{Hourly: 1}}
+	queue := NewAsyncQueue(1, QueueError).SetQuotas(quotas)
+	// Without workers the queued emails are never sent
+	queue.workers = 0
+	newMail := func(key string) *Mail {
+		m := newSandboxMail().SetAsyncQueue(queue).SetQuotaKey(key)
+		m.Bcc = nil
+		return m
+	}
+
+	newMail("tenant-a").SendAsync()
+	if err := receive(t, newMail("tenant-b").SendAsync()); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("error = %v, want ErrQueueFull", err)
+	}
+	// The email refused by the full queue did not use the quota of tenant-b
+	<-queue.jobs
+	result := newMail("tenant-b").SendAsync()
+	select {
+	case err := <-result:
+		t.Errorf("queued email failed with %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestQuotasFailedSend(t *testing.T) {
+	quotas := &Quotas{Default: Quota{Hourly: 1}}
+	queue := NewAsyncQueue(10, QueueBlock).SetQuotas(quotas)
+	queue.workers = 1
+	rejected := errors.New("550 5.7.1 Message rejected")
+	newMail := func(err error) *Mail {
+		m := newSandboxMail().SetSandbox(false).SetAsyncQueue(queue).SetQuotaKey("tenant-a").
+			SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error { return err }))
+		m.Bcc = nil
+		return m
+	}
+
+	if err := receive(t, newMail(rejected).SendAsync()); !errors.Is(err, rejected) {
+		t.Fatalf("rejected send error = %v, want %v", err, rejected)
+	}
+	// The rejected email gave its recipient back to the quota
+	if err := receive(t, newMail(nil).SendAsync()); err != nil {
+		t.Fatalf("send after the rejected one error = %v", err)
+	}
+	var quotaErr *QuotaExceededError
+	if err := receive(t, newMail(nil).SendAsync()); !errors.As(err, &quotaErr) {
+		t.Errorf("send over the quota error = %v, want a *QuotaExceededError", err)
+	}
+}
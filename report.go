@@ -0,0 +1,76 @@
+package gomail
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SendReport describes the outcome of sending an email. MessageID is the
+// Message-ID the email was sent with, without angle brackets, and Attempts
+// counts the tries made under the retry policy. DKIM names the keys the email
+// was signed with by the DNS name of their public key, selector._domainkey.domain.
+type SendReport struct {
+	MessageID  string
+	Recipients []string
+	Attempts   int
+	Duration   time.Duration
+	Err        error
+	DKIM       []string
+}
+
+// SetMessageID sets the Message-ID of the email, without angle brackets. Without
+// one, a unique Message-ID is generated for every send.
+func (m *Mail) SetMessageID(id string) *Mail {
+	m.messageID = strings.Trim(id, "<>")
+	return m
+}
+
+// SendWithReport sends the email under ctx and reports the outcome
+func (m *Mail) SendWithReport(ctx context.Context) SendReport {
+	start := time.Now()
+	var report SendReport
+	report.Err = m.deliver(ctx, &report)
+	report.Duration = time.Since(start)
+	return report
+}
+
+// SendAsyncReport sends the email asynchronously like SendAsync and returns a
+// channel for the report of the send
+func (m *Mail) SendAsyncReport() chan SendReport {
+	report := make(chan SendReport, 1)
+	m.sendAsync(asyncJob{report: report})
+	return report
+}
+
+// assignMessageID generates a Message-ID for a send of the email when none is set
+func (m *Mail) assignMessageID() {
+	if m.messageID == "" {
+		m.messageID = newMessageID(m.From)
+	}
+}
+
+// newMessageID returns a unique Message-ID in the domain of the from address
+func newMessageID(from string) string {
+	domain := addressDomain(from)
+	if domain == "" {
+		domain = "localhost"
+	} else if ascii, err := domainToASCII(domain); err == nil {
+		domain = ascii
+	}
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%d.%x@%s", time.Now().UnixNano(), buf, domain)
+}
+
+// addressDomain returns the domain of an email address, or an empty string when it has none
+func addressDomain(address string) string {
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		return address[at+1:]
+	}
+	return ""
+}
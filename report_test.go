@@ -0,0 +1,170 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/mail"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendWithReport(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"to@example.com"},
+		Bcc:     []string{"bcc@example.com"},
+	}
+	m.SetPoolSize(1).SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	server.replyToRcpt("451 4.3.0 Try again later")
+
+	report := m.SendWithReport(context.Background())
+	if report.Err != nil {
+		t.Fatalf("SendWithReport() error = %v", report.Err)
+	}
+	if report.Attempts != 2 {
+		t.Errorf("Attempts = %d, want 2", report.Attempts)
+	}
+	if want := []string{"to@example.com", "bcc@example.com"}; !reflect.DeepEqual(report.Recipients, want) {
+		t.Errorf("Recipients = %v, want %v", report.Recipients, want)
+	}
+	if report.Duration <= 0 {
+		t.Error("Duration should be positive")
+	}
+	if !strings.HasSuffix(report.MessageID, "@example.com") {
+		t.Errorf("MessageID = %q, want one in the sender's domain", report.MessageID)
+	}
+	messages := server.getMessages()
+	if len(messages) != 1 || !strings.Contains(messages[0], "Message-ID: <"+report.MessageID+">\r\n") {
+		t.Error("email was not sent with the reported Message-ID")
+	}
+
+	// Every send gets its own Message-ID unless one is set
+	if again := m.SendWithReport(context.Background()); again.MessageID == report.MessageID {
+		t.Error("two sends got the same Message-ID")
+	}
+	m.SetMessageID("<fixed@example.com>")
+	if got := m.SendWithReport(context.Background()).MessageID; got != "fixed@example.com" {
+		t.Errorf("MessageID = %q, want fixed@example.com", got)
+	}
+}
+
+// archiverFunc adapts a function to Archiver
+type archiverFunc func(ctx context.Context, message []byte, metadata ArchiveMetadata) error
+
+func (f archiverFunc) Archive(ctx context.Context, message []byte, metadata ArchiveMetadata) error {
+	return f(ctx, message, metadata)
+}
+
+func TestConcurrentSendsOfOneEmail(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	// The Message-ID and the message captured for the archive are per send
+	var mu sync.Mutex
+	sent, archived := make(map[string]bool), make(map[string]bool)
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).SetPoolSize(2).
+		SetArchiver(archiverFunc(func(ctx context.Context, message []byte, metadata ArchiveMetadata) error {
+			msg, err := mail.ReadMessage(bytes.NewReader(message))
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			archived[strings.Trim(msg.Header.Get("Message-ID"), "<>")] = true
+			return nil
+		}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report := m.SendWithReport(context.Background())
+			if report.Err != nil {
+				t.Errorf("SendWithReport() error = %v", report.Err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			sent[report.MessageID] = true
+		}()
+	}
+	wg.Wait()
+	if len(sent) != 8 || !reflect.DeepEqual(sent, archived) {
+		t.Errorf("Message-IDs sent %v and archived %v, want 8 distinct ones archived as sent", sent, archived)
+	}
+	if m.messageID != "" || m.capture != nil {
+		t.Error("state of the sends left on the email")
+	}
+}
+
+func TestSendAsyncReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		queue   *AsyncQueue
+		wantErr bool
+	}{
+		{"goroutine", nil, false},
+		{"queue", NewAsyncQueue(1, QueueBlock), false},
+		{"closed queue", NewAsyncQueue(1, QueueBlock), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+
+			host, port, _ := net.SplitHostPort(server.addr())
+			m := &Mail{
+				From:    "sender@example.com",
+				Name:    "Test Sender",
+				Host:    host,
+				Port:    port,
+				User:    "user",
+				Pass:    "pass",
+				Subject: "Test Subject",
+				Content: "Test Content",
+				To:      []string{"recipient@example.com"},
+			}
+			m.SetPoolSize(1)
+			if tt.queue != nil {
+				m.SetAsyncQueue(tt.queue)
+				if tt.wantErr {
+					tt.queue.Close()
+				}
+			}
+
+			var report SendReport
+			select {
+			case report = <-m.SendAsyncReport():
+			case <-time.After(2 * time.Second):
+				t.Fatal("no report delivered")
+			}
+			if (report.Err != nil) != tt.wantErr {
+				t.Fatalf("report error = %v, wantErr %v", report.Err, tt.wantErr)
+			}
+			if len(report.Recipients) != 1 {
+				t.Errorf("Recipients = %v, want the recipient", report.Recipients)
+			}
+			if !tt.wantErr && (report.Attempts != 1 || report.MessageID == "") {
+				t.Errorf("report = %+v, want one attempt and a Message-ID", report)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package gomail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RecipientResolver expands symbolic recipients, such as "team:oncall" or
+// "role:billing-admins", to email addresses from a directory like LDAP or a
+// database, so call sites name groups instead of people. Every recipient
+// without an @ is symbolic.
+type RecipientResolver interface {
+	Resolve(ctx context.Context, recipient string) ([]string, error)
+}
+
+// SetRecipientResolver expands the symbolic To, Cc and Bcc recipients with
+// resolver on every send. The expanded addresses are only used for the send,
+// so changes in the directory are picked up by the next one.
+func (m *Mail) SetRecipientResolver(resolver RecipientResolver) *Mail {
+	m.resolver = resolver
+	return m
+}
+
+// resolveRecipients replaces the symbolic recipients of m with their addresses
+// under ctx, and returns the function that restores the recipients as set
+func (m *Mail) resolveRecipients(ctx context.Context, resolver RecipientResolver) (func(), error) {
+	if resolver == nil {
+		return func() {}, nil
+	}
+
+	to, cc, bcc := m.To, m.Cc, m.Bcc
+	var err error
+	if m.To, err = resolveAddresses(ctx, resolver, to); err == nil {
+		if m.Cc, err = resolveAddresses(ctx, resolver, cc); err == nil {
+			m.Bcc, err = resolveAddresses(ctx, resolver, bcc)
+		}
+	}
+	restore := func() { m.To, m.Cc, m.Bcc = to, cc, bcc }
+	if err != nil {
+		restore()
+		return nil, err
+	}
+	return restore, nil
+}
+
+// resolveAddresses expands the symbolic recipients among addresses, dropping duplicates
+func resolveAddresses(ctx context.Context, resolver RecipientResolver, addresses []string) ([]string, error) {
+	var resolved []string
+	seen := make(map[string]bool, len(addresses))
+	add := func(address string) {
+		if key := strings.ToLower(address); !seen[key] {
+			seen[key] = true
+			resolved = append(resolved, address)
+		}
+	}
+
+	for _, address := range addresses {
+		if strings.Contains(address, "@") {
+			add(address)
+			continue
+		}
+		members, err := resolver.Resolve(ctx, address)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving recipient %s: %w", address, err)
+		}
+		for _, member := range members {
+			add(member)
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,73 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// directory is a RecipientResolver over groups held in memory
+type directory map[string][]string
+
+func (d directory) Resolve(ctx context.Context, recipient string) ([]string, error) {
+	members, ok := d[recipient]
+	if !ok {
+		return nil, errors.New("unknown group")
+	}
+	return members, nil
+}
+
+func TestRecipientResolver(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	host, port, _ := net.SplitHostPort(server.addr())
+
+	resolver := directory{
+		"team:oncall":         {"ada@example.com", "grace@example.com"},
+		"role:billing-admins": {"Grace@example.com", "linus@example.com"},
+	}
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"team:oncall", "ops@example.com"},
+		Cc:      []string{"role:billing-admins"},
+	}
+	m.SetRecipientResolver(resolver)
+
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	messages := server.getMessages()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	for _, want := range []string{
+		"To: ada@example.com, grace@example.com, ops@example.com",
+		"Cc: Grace@example.com, linus@example.com",
+		"RCPT TO:<ada@example.com>", "RCPT TO:<grace@example.com>",
+		"RCPT TO:<ops@example.com>", "RCPT TO:<linus@example.com>",
+	} {
+		if !strings.Contains(messages[0], want) {
+			t.Errorf("message missing %q", want)
+		}
+	}
+
+	// The symbolic recipients are kept for the next send
+	if want := []string{"team:oncall", "ops@example.com"}; !reflect.DeepEqual(m.To, want) {
+		t.Errorf("To after the send = %v, want %v", m.To, want)
+	}
+
+	m.Bcc = []string{"team:unknown"}
+	if err := m.Send(); err == nil || !strings.Contains(err.Error(), "team:unknown") {
+		t.Errorf("Send() error = %v, want an error resolving team:unknown", err)
+	}
+}
@@ -0,0 +1,126 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// retryHintRegex matches retry hints such as "try again in 300 seconds" or "retry after 5 minutes"
+var retryHintRegex = regexp.MustCompile(`(?i)(?:try again|retry)(?: later)?(?: in| after)?\s+(\d+)\s*(seconds?|secs?|s|minutes?|mins?|m|hours?|h)\b`)
+
+// shouldRetry reports whether another attempt should follow the failed attempt
+func (p *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	return p != nil && attempt < p.MaxAttempts && IsTemporary(err)
+}
+
+// delay returns how long to wait before the attempt following the given one
+func (p *RetryPolicy) delay(attempt int, err error) time.Duration {
+	initial, max := p.InitialBackoff, p.MaxBackoff
+	if initial <= 0 {
+		initial = DefaultRetryBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxRetryBackoff
+	}
+
+	backoff := initial
+	for i := 1; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+	backoff = p.Jitter.apply(backoff)
+	if hint, ok := retryHint(err); ok && hint > backoff {
+		backoff = hint
+	}
+	if backoff > max {
+		backoff = max
+	}
+	return backoff
+}
+
+// apply randomizes backoff according to j
+func (j RetryJitter) apply(backoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return backoff
+	}
+	switch j {
+	case JitterFull:
+		return rand.N(backoff + 1)
+	case JitterEqual:
+		half := backoff / 2
+		return backoff - half + rand.N(half+1)
+	default:
+		return backoff
+	}
+}
+
+// IsTemporary reports whether err is a failure worth retrying: a 4xx SMTP reply,
+// a temporary *APIError or a network error such as a timeout or a refused, reset
+// or dropped connection. Cancelled or expired contexts are not temporary. The
+// retry policy retries exactly these errors.
+func IsTemporary(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code/100 == 4
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.temporary()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || isConnectionLost(err) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// IsPermanent reports whether err is a 5xx SMTP reply or an *APIError that is not
+// temporary, which retrying the same email would not change
+func IsPermanent(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return !apiErr.temporary()
+	}
+	var smtpErr *textproto.Error
+	return errors.As(err, &smtpErr) && smtpErr.Code/100 == 5
+}
+
+// retryHint extracts the retry delay suggested by a temporary failure reply, if any
+func retryHint(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter, apiErr.RetryAfter > 0
+	}
+	var smtpErr *textproto.Error
+	if !errors.As(err, &smtpErr) || (smtpErr.Code != 421 && smtpErr.Code != 450) {
+		return 0, false
+	}
+
+	match := retryHintRegex.FindStringSubmatch(smtpErr.Msg)
+	if match == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+
+	unit := time.Second
+	switch strings.ToLower(match[2])[0] {
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	}
+	return time.Duration(n) * unit, true
+}
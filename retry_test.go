@@ -0,0 +1,219 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryHint(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   time.Duration
+		wantOk bool
+	}{
+		{"seconds", &textproto.Error{Code: 421, Msg: "Service busy, try again in 300 seconds"}, 300 * time.Second, true},
+		{"minutes", &textproto.Error{Code: 450, Msg: "4.7.1 Greylisted, retry after 5 minutes"}, 5 * time.Minute, true},
+		{"short unit", &textproto.Error{Code: 450, Msg: "try again later in 30s"}, 30 * time.Second, true},
+		{"no hint", &textproto.Error{Code: 450, Msg: "Mailbox busy"}, 0, false},
+		{"other code", &textproto.Error{Code: 451, Msg: "try again in 300 seconds"}, 0, false},
+		{"not smtp", errors.New("try again in 300 seconds"), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryHint(tt.err)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("retryHint() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Minute}
+	busy := &textproto.Error{Code: 450, Msg: "Mailbox busy"}
+
+	tests := []struct {
+		name    string
+		attempt int
+		err     error
+		want    time.Duration
+	}{
+		{"first retry", 1, busy, time.Second},
+		{"exponential", 3, busy, 4 * time.Second},
+		{"capped", 10, busy, time.Minute},
+		{"hint extends backoff", 1, &textproto.Error{Code: 421, Msg: "try again in 30 seconds"}, 30 * time.Second},
+		{"hint capped", 1, &textproto.Error{Code: 421, Msg: "try again in 300 seconds"}, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.delay(tt.attempt, tt.err); got != tt.want {
+				t.Errorf("delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if policy.shouldRetry(1, &textproto.Error{Code: 550, Msg: "No such user"}) {
+		t.Error("shouldRetry() should not retry permanent failures")
+	}
+	if policy.shouldRetry(5, busy) {
+		t.Error("shouldRetry() should stop after MaxAttempts")
+	}
+	var nilPolicy *RetryPolicy
+	if nilPolicy.shouldRetry(1, busy) {
+		t.Error("shouldRetry() should not retry without a policy")
+	}
+}
+
+func TestRetryJitter(t *testing.T) {
+	busy := &textproto.Error{Code: 450, Msg: "Mailbox busy"}
+
+	tests := []struct {
+		name     string
+		jitter   RetryJitter
+		err      error
+		min, max time.Duration
+	}{
+		{"none", JitterNone, busy, 4 * time.Second, 4 * time.Second},
+		{"full", JitterFull, busy, 0, 4 * time.Second},
+		{"equal", JitterEqual, busy, 2 * time.Second, 4 * time.Second},
+		{"hint is a floor", JitterFull, &textproto.Error{Code: 421, Msg: "try again in 3 seconds"}, 3 * time.Second, 4 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Minute, Jitter: tt.jitter}
+			seen := make(map[time.Duration]bool)
+			for i := 0; i < 1000; i++ {
+				got := policy.delay(3, tt.err)
+				if got < tt.min || got > tt.max {
+					t.Fatalf("delay() = %v, want between %v and %v", got, tt.min, tt.max)
+				}
+				seen[got] = true
+			}
+			if randomized := len(seen) > 1; randomized != (tt.min != tt.max) {
+				t.Errorf("delay() returned %d distinct values", len(seen))
+			}
+		})
+	}
+}
+
+func TestSendRetriesTemporaryFailures(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	server.replyToRcpt("450 4.2.1 Mailbox busy, try again in 1 seconds")
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1).SetRetryPolicy(&RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+	})
+
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := len(server.getMessages()); got != 1 {
+		t.Errorf("server received %d messages, want 1", got)
+	}
+}
+
+func TestRetryBackoffUsesClock(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	server.replyToRcpt("450 4.2.1 Mailbox busy")
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	clock := newFakeClock()
+	m.SetClock(clock).SetPoolSize(1).SetRetryPolicy(&RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Hour,
+	})
+
+	sent := make(chan error)
+	go func() { sent <- m.Send() }()
+
+	// The retry waits an hour on the clock, not in real time
+	waitPending(t, clock, 1)
+	clock.Advance(time.Hour)
+	select {
+	case err := <-sent:
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send() did not retry after the backoff")
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantTemporary bool
+		wantPermanent bool
+	}{
+		{"nil", nil, false, false},
+		{"4xx reply", &textproto.Error{Code: 451, Msg: "Try again later"}, true, false},
+		{"wrapped 4xx reply", &SMTPError{Command: "RCPT", Err: &textproto.Error{Code: 450, Msg: "Mailbox busy"}}, true, false},
+		{"5xx reply", &textproto.Error{Code: 550, Msg: "No such user"}, false, true},
+		{"wrapped 5xx reply", fmt.Errorf("send: %w", &textproto.Error{Code: 554, Msg: "Rejected"}), false, true},
+		{"timeout", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, true, false},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true, false},
+		{"connection dropped", io.EOF, true, false},
+		{"cancelled", contextError(cancelledContext(), io.EOF), false, false},
+		{"other", errors.New("missing parameter"), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTemporary(tt.err); got != tt.wantTemporary {
+				t.Errorf("IsTemporary() = %v, want %v", got, tt.wantTemporary)
+			}
+			if got := IsPermanent(tt.err); got != tt.wantPermanent {
+				t.Errorf("IsPermanent() = %v, want %v", got, tt.wantPermanent)
+			}
+		})
+	}
+}
+
+// cancelledContext returns a context that is already cancelled
+func cancelledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
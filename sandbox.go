@@ -0,0 +1,42 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strings"
+)
+
+// SetSandbox enables or disables sandbox mode. In sandbox mode sending validates
+// and serializes the email as usual but hands it to the sandbox output instead of
+// the SMTP server, so staging environments can share the production configuration.
+func (m *Mail) SetSandbox(enabled bool) *Mail {
+	m.sandbox = enabled
+	return m
+}
+
+// SetSandboxOutput sets where sandbox mode writes the serialized emails to.
+// Without an output the emails are written to the standard logger.
+func (m *Mail) SetSandboxOutput(w io.Writer) *Mail {
+	m.sandboxOutput = w
+	return m
+}
+
+// sendSandbox serializes the email to the sandbox output instead of sending it
+func (m *Mail) sendSandbox() error {
+	var buf bytes.Buffer
+	if _, err := m.writeTo(&buf); err != nil {
+		return err
+	}
+
+	envelope := "sandbox: not sending email from " + m.getEnvelopeFrom() + " to " + strings.Join(m.recipients(), ", ")
+
+	if m.sandboxOutput == nil {
+		log.Printf("%s:\n%s", envelope, buf.String())
+		return nil
+	}
+
+	log.Printf("%s (%d bytes written to the sandbox output)", envelope, buf.Len())
+	_, err := buf.WriteTo(m.sandboxOutput)
+	return err
+}
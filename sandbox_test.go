@@ -0,0 +1,70 @@
+package gomail
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newSandboxMail() *Mail {
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    "smtp.invalid",
+		Port:    "25",
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+		Bcc:     []string{"hidden@example.com"},
+	}
+	return m.SetSandbox(true)
+}
+
+func TestSandbox(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	t.Run("output", func(t *testing.T) {
+		logs.Reset()
+		var out bytes.Buffer
+		m := newSandboxMail().SetSandboxOutput(&out)
+
+		if err := m.Send(); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if !strings.Contains(out.String(), "Subject: Test Subject") {
+			t.Errorf("sandbox output missing the message: %q", out.String())
+		}
+		if !strings.Contains(logs.String(), "hidden@example.com") {
+			t.Errorf("sandbox log missing the Bcc recipient: %q", logs.String())
+		}
+	})
+
+	t.Run("logger", func(t *testing.T) {
+		logs.Reset()
+		if err := newSandboxMail().Send(); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if !strings.Contains(logs.String(), "Subject: Test Subject") {
+			t.Errorf("sandbox log missing the message: %q", logs.String())
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		var out bytes.Buffer
+		m := newSandboxMail().SetSandboxOutput(&out)
+		m.To = []string{"invalid"}
+
+		if err := m.Send(); err == nil {
+			t.Error("Send() expected validation error in sandbox mode")
+		}
+		if out.Len() != 0 {
+			t.Error("invalid email should not be written to the sandbox output")
+		}
+	})
+}
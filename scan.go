@@ -0,0 +1,88 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// ContentScanner inspects the attachments of an email before it is sent, e.g.
+// with ClamAV or an ICAP server, so files that violate a policy never leave.
+// Scan is called with the decoded content of each attachment and returns an
+// error to block the email.
+type ContentScanner interface {
+	Scan(ctx context.Context, name string, content io.Reader) error
+}
+
+// ContentBlockedError reports an email that was not sent because the content
+// scanner blocked one of its attachments
+type ContentBlockedError struct {
+	Name string
+	Err  error
+}
+
+// Error implements error
+func (e *ContentBlockedError) Error() string {
+	return fmt.Sprintf("email not sent: attachment %q blocked: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the error of the content scanner
+func (e *ContentBlockedError) Unwrap() error {
+	return e.Err
+}
+
+// SetContentScanner scans every attachment with scanner before the email is
+// sent. Streaming attachments are buffered in memory while they are scanned,
+// so that the content sent is the content scanned.
+func (m *Mail) SetContentScanner(scanner ContentScanner) *Mail {
+	m.contentScanner = scanner
+	return m
+}
+
+// scanAttachments scans the attachments of the email with scanner under ctx
+func (m *Mail) scanAttachments(ctx context.Context, scanner ContentScanner) error {
+	if scanner == nil {
+		return nil
+	}
+	scan := func(name string, content io.Reader) error {
+		if err := scanner.Scan(ctx, name, content); err != nil {
+			return &ContentBlockedError{Name: name, Err: err}
+		}
+		return nil
+	}
+
+	for _, name := range sortedKeys(m.Attachments) {
+		if err := scan(name, bytes.NewReader(m.Attachments[name])); err != nil {
+			return err
+		}
+	}
+	for _, attachment := range m.inlineAttachments {
+		if err := scan(attachment.Name, bytes.NewReader(attachment.Data)); err != nil {
+			return err
+		}
+	}
+	for _, attachment := range m.attachmentParts {
+		content := base64.NewDecoder(base64.StdEncoding, bytes.NewReader(attachment.encoded))
+		if err := scan(attachment.Name(), content); err != nil {
+			return err
+		}
+	}
+
+	if len(m.streamAttachments) == 0 {
+		return nil
+	}
+	// The stream is sent from what the scanner read, followed by anything it left
+	streams := make([]AttachmentReader, len(m.streamAttachments))
+	for i, attachment := range m.streamAttachments {
+		var scanned bytes.Buffer
+		if err := scan(attachment.Name, io.TeeReader(attachment.Reader, &scanned)); err != nil {
+			return err
+		}
+		attachment.Reader = io.MultiReader(&scanned, attachment.Reader)
+		streams[i] = attachment
+	}
+	m.streamAttachments = streams
+	return nil
+}
@@ -0,0 +1,102 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var errInfected = errors.New("infected")
+
+// recordingScanner records the attachments it scans and blocks the ones named block
+type recordingScanner struct {
+	block   string
+	scanned map[string]string
+}
+
+func (s *recordingScanner) Scan(ctx context.Context, name string, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	s.scanned[name] = string(data)
+	if name == s.block {
+		return errInfected
+	}
+	return nil
+}
+
+func TestContentScanner(t *testing.T) {
+	prepared, err := PrepareAttachment("report.pdf", strings.NewReader("pdf content"))
+	if err != nil {
+		t.Fatalf("PrepareAttachment() error = %v", err)
+	}
+	all := map[string]string{
+		"notes.txt":  "notes",
+		"logo.png":   "png",
+		"report.pdf": "pdf content",
+		"data.csv":   "a,b",
+	}
+
+	tests := []struct {
+		name    string
+		block   string
+		scanned map[string]string
+	}{
+		{name: "clean", scanned: all},
+		{name: "blocked", block: "logo.png", scanned: map[string]string{"notes.txt": "notes", "logo.png": "png"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			scanner := &recordingScanner{block: tt.block, scanned: make(map[string]string)}
+			m := &Mail{
+				From:        "sender@example.com",
+				Name:        "Test Sender",
+				Host:        "smtp.example.com",
+				Port:        "587",
+				User:        "user",
+				Pass:        "pass",
+				Subject:     "Test Subject",
+				Content:     "Test Content",
+				To:          []string{"recipient@example.com"},
+				Attachments: map[string][]byte{"notes.txt": []byte("notes")},
+			}
+			m.SetInlineAttachment([]Attachment{{Name: "logo.png", Data: []byte("png")}}).
+				SetPreparedAttachment(prepared).
+				SetStreamAttachment([]AttachmentReader{{Name: "data.csv", Reader: strings.NewReader("a,b")}}).
+				SetContentScanner(scanner).
+				SetSandbox(true).
+				SetSandboxOutput(&output)
+
+			err := m.Send()
+			if !reflect.DeepEqual(scanner.scanned, tt.scanned) {
+				t.Errorf("scanned %v, want %v", scanner.scanned, tt.scanned)
+			}
+			if tt.block == "" {
+				if err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+				// The scanned stream is still sent in full
+				if !strings.Contains(output.String(), base64.StdEncoding.EncodeToString([]byte("a,b"))) {
+					t.Error("streaming attachment missing from the sent email")
+				}
+				return
+			}
+
+			var blocked *ContentBlockedError
+			if !errors.As(err, &blocked) || blocked.Name != tt.block || !errors.Is(err, errInfected) {
+				t.Fatalf("Send() error = %v, want a ContentBlockedError for %s", err, tt.block)
+			}
+			if output.Len() > 0 {
+				t.Error("blocked email was sent")
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package gomail
+
+import "context"
+
+// SendAs sends the email on behalf of sender under ctx without modifying m, so
+// one configured Mail can send for many tenants. The identity pool, if any, is
+// applied first and sender overrides it. Connections are pooled per server and
+// credentials, in the pool registry of m or else in a registry private to m.
+func (m *Mail) SendAs(ctx context.Context, sender Sender) error {
+	return m.withSender(sender).sendContext(ctx)
+}
+
+// SetEnvelopeFrom sets the SMTP envelope sender (Return-Path) used instead of From
+func (m *Mail) SetEnvelopeFrom(address string) *Mail {
+	m.envelopeFrom = address
+	return m
+}
+
+// withSender returns a copy of m for a single send with the overrides of sender applied
+func (m *Mail) withSender(sender Sender) *Mail {
+	c := m.clone()
+	c.applyIdentity()
+	c.identities = nil
+
+	if sender.From != "" {
+		c.From = sender.From
+	}
+	if sender.Name != "" {
+		c.Name = sender.Name
+	}
+	if sender.EnvelopeFrom != "" {
+		c.envelopeFrom = sender.EnvelopeFrom
+	}
+	if sender.DKIM != nil {
+		c.dkim = sender.DKIM
+	}
+	if sender.Host != "" || sender.Port != "" {
+		// The fallbacks are backups of the configured server, not of the tenant's
+		c.failover = nil
+	}
+	if sender.Host != "" {
+		c.Host = sender.Host
+	}
+	if sender.Port != "" {
+		c.Port = sender.Port
+	}
+	if sender.User != "" || sender.Pass != "" {
+		// Explicit credentials take the place of the credentials provider
+		c.credentials, c.oauth2 = nil, nil
+	}
+	if sender.User != "" {
+		c.User = sender.User
+	}
+	if sender.Pass != "" {
+		c.Pass = sender.Pass
+	}
+	return c
+}
+
+// clone returns a copy of m for a single send. The copy shares the rate limiter,
+// failover state and identity pool of m, and pools its connections in the
+// registry returned by senderRegistry.
+func (m *Mail) clone() *Mail {
+	c := m.copyFields()
+	c.poolRegistry = m.senderRegistry()
+	return c
+}
+
+// sendCopy returns the copy of m a send of it is made with. The state of the
+// send, such as the generated Message-ID or the message captured for the
+// archive, is kept on the copy, so the email can be sent concurrently. Unlike a
+// clone, the copy uses the connection pools of m and records the server
+// responses of the send on m.
+func (m *Mail) sendCopy() *Mail {
+	c := m.copyFields()
+	c.poolRegistry = m.poolRegistry
+	c.transcript = m.transcript
+	c.session = m.session
+	c.origin = m
+	if m.origin != nil {
+		c.origin = m.origin
+	}
+	return c
+}
+
+// copyFields returns a copy of the settings and message fields of m, without
+// its connection pools
+func (m *Mail) copyFields() *Mail {
+	// The settings UpdateConfig replaces are copied together
+	m.configMutex.Lock()
+	host, port, user, pass := m.Host, m.Port, m.User, m.Pass
+	tlsConfig, rateLimiter := m.tlsConfig, m.rateLimiter
+	configChanged := m.getConfigChanged()
+	m.configMutex.Unlock()
+
+	return &Mail{
+		From:              m.From,
+		Name:              m.Name,
+		Host:              host,
+		Port:              port,
+		User:              user,
+		Pass:              pass,
+		Subject:           m.Subject,
+		Content:           m.Content,
+		AltContent:        m.AltContent,
+		To:                m.To,
+		Cc:                m.Cc,
+		Bcc:               m.Bcc,
+		Attachments:       m.Attachments,
+		Timeout:           m.Timeout,
+		KeepAlive:         m.KeepAlive,
+		poolSize:          m.poolSize,
+		streamAttachments: m.streamAttachments,
+		inlineAttachments: m.inlineAttachments,
+		tlsConfig:         tlsConfig,
+		rateLimiter:       rateLimiter,
+		ContentType:       m.ContentType,
+		TemplateEngine:    m.TemplateEngine,
+		dkim:              m.dkim,
+		dkimKeys:          m.dkimKeys,
+		charset:           m.charset,
+		transferEncoding:  m.transferEncoding,
+		boundaryGenerator: m.boundaryGenerator,
+		commandTimeouts:   m.commandTimeouts,
+		connectTimeout:    m.connectTimeout,
+		commandTimeout:    m.commandTimeout,
+		dataTimeout:       m.dataTimeout,
+		dataBuffer:        m.dataBuffer,
+		retryPolicy:       m.retryPolicy,
+		failover:          m.failover,
+		identities:        m.identities,
+		envelopeFrom:      m.envelopeFrom,
+		returnPath:        m.returnPath,
+		clock:             m.clock,
+		sandbox:           m.sandbox,
+		sandboxOutput:     m.sandboxOutput,
+		redirectTo:        m.redirectTo,
+		recipientPolicy:   m.recipientPolicy,
+		resolver:          m.resolver,
+		attachmentPolicy:  m.attachmentPolicy,
+		htmlCheck:         m.htmlCheck,
+		archiver:          m.archiver,
+		transport:         m.transport,
+		tags:              m.tags,
+		messageID:         m.messageID,
+		envelopeTo:        m.envelopeTo,
+		quotaKey:          m.quotaKey,
+		warmUp:            m.warmUp,
+		attachmentDedup:   m.attachmentDedup,
+		duplicateGuard:    m.duplicateGuard,
+		credentials:       m.credentials,
+		oauth2:            m.oauth2,
+		authMethod:        m.authMethod,
+		localName:         m.localName,
+		journal:           m.journal,
+		spamCheck:         m.spamCheck,
+		contentScanner:    m.contentScanner,
+		calendar:          m.calendar,
+		attachmentParts:   m.attachmentParts,
+		customParts:       m.customParts,
+		metrics:           m.metrics,
+		configChanged:     configChanged,
+	}
+}
+
+// senderRegistry returns the pool registry for sends made through copies of m
+func (m *Mail) senderRegistry() *PoolRegistry {
+	if m.poolRegistry != nil {
+		return m.poolRegistry
+	}
+
+	m.poolMutex.Lock()
+	defer m.poolMutex.Unlock()
+	if m.senderPools == nil {
+		m.senderPools = NewPoolRegistry()
+	}
+	return m.senderPools
+}
@@ -0,0 +1,85 @@
+package gomail
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSendAs(t *testing.T) {
+	client := newMockSMTPServer(t)
+	defer client.close()
+	tenant := newMockSMTPServer(t)
+	defer tenant.close()
+
+	host, port, _ := net.SplitHostPort(client.addr())
+	tenantHost, tenantPort, _ := net.SplitHostPort(tenant.addr())
+
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1).SetMessageID("order-42@example.com")
+
+	err := m.SendAs(context.Background(), Sender{
+		From:         "news@tenant.example",
+		Name:         "Tenant",
+		EnvelopeFrom: "bounces@tenant.example",
+		Host:         tenantHost,
+		Port:         tenantPort,
+		User:         "tenant",
+		Pass:         "secret",
+	})
+	if err != nil {
+		t.Fatalf("SendAs() error = %v", err)
+	}
+
+	messages := tenant.getMessages()
+	if len(messages) != 1 {
+		t.Fatalf("tenant server received %d messages, want 1", len(messages))
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte("\x00tenant\x00secret"))
+	for _, want := range []string{"AUTH PLAIN " + auth, "MAIL FROM:<bounces@tenant.example>", "news@tenant.example", "Message-ID: <order-42@example.com>"} {
+		if !strings.Contains(messages[0], want) {
+			t.Errorf("tenant message missing %q", want)
+		}
+	}
+	if got := len(client.getMessages()); got != 0 {
+		t.Errorf("client server received %d messages, want 0", got)
+	}
+	if m.From != "sender@example.com" || m.Host != host || m.User != "user" || m.envelopeFrom != "" {
+		t.Error("SendAs() modified the mail")
+	}
+
+	// Empty fields keep the configured sender and server
+	if err := m.SendAs(context.Background(), Sender{Name: "Support"}); err != nil {
+		t.Fatalf("SendAs() error = %v", err)
+	}
+	messages = client.getMessages()
+	if len(messages) != 1 {
+		t.Fatalf("client server received %d messages, want 1", len(messages))
+	}
+	if !strings.Contains(messages[0], "MAIL FROM:<sender@example.com>") || !strings.Contains(messages[0], "Support") {
+		t.Error("SendAs() without overrides should send as the configured sender")
+	}
+}
+
+func TestSetEnvelopeFrom(t *testing.T) {
+	m := &Mail{From: "sender@example.com"}
+	if got := m.getEnvelopeFrom(); got != "sender@example.com" {
+		t.Errorf("envelope sender = %s, want the From address", got)
+	}
+	m.SetEnvelopeFrom("bounces@example.com")
+	if got := m.getEnvelopeFrom(); got != "bounces@example.com" {
+		t.Errorf("envelope sender = %s, want bounces@example.com", got)
+	}
+}
@@ -0,0 +1,83 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+)
+
+// Session is a single authenticated SMTP connection used to send any number of
+// messages in sequence, for batch jobs that want deterministic connection usage
+// instead of the pool. A Session must be closed after use.
+type Session struct {
+	config *Mail
+	conn   *connection
+	closed bool
+	mu     sync.Mutex
+}
+
+// Dial opens a session on the server configured in m
+func (m *Mail) Dial(ctx context.Context) (*Session, error) {
+	if endpoint := m.endpoint(); endpoint.Host == "" || endpoint.Port == "" || !m.hasCredentials() {
+		return nil, errors.New("missing parameter")
+	}
+
+	conn, err := dialConnection(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{config: m, conn: conn}, nil
+}
+
+// Send sends msg over the session. Only the message fields of msg are used;
+// the settings come from the Mail the session was dialed from.
+func (s *Session) Send(msg *Mail) error {
+	return s.SendContext(context.Background(), msg)
+}
+
+// SendContext sends msg over the session under ctx. The send goes through the
+// same steps as Mail.SendContext, such as sandbox mode, redirection, journal
+// copies and the spam check, with the connection of the session in place of
+// the pool. A panic during the send is returned as a *PanicError.
+func (s *Session) SendContext(ctx context.Context, msg *Mail) error {
+	c := s.config.withMessage(msg)
+	c.session = s
+	return c.sendContext(ctx)
+}
+
+// send makes a single attempt at sending m over the session under ctx
+func (s *Session) send(ctx context.Context, m *Mail, capture *bytes.Buffer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("session is closed")
+	}
+
+	unbind := s.conn.bind(ctx)
+	defer unbind()
+
+	if err := m.transmit(s.conn, m.getCommandTimeouts(), capture); err != nil {
+		return contextError(ctx, err)
+	}
+	return nil
+}
+
+// Extensions returns the service extensions of the server the session is connected to
+func (s *Session) Extensions() Extensions {
+	return s.conn.extensions
+}
+
+// Close ends the session with QUIT and closes the connection
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.conn.quit(s.config.getCommandTimeouts().Quit)
+	return nil
+}
@@ -0,0 +1,105 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	config := &Mail{Host: host, Port: port, User: "user", Pass: "pass"}
+
+	session, err := config.Dial(context.Background())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+
+	for _, subject := range []string{"First", "Second"} {
+		msg := &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Subject: subject,
+			Content: "Test Content",
+			To:      []string{"recipient@example.com"},
+		}
+		if err := session.Send(msg); err != nil {
+			t.Fatalf("Session.Send(%s) error = %v", subject, err)
+		}
+	}
+
+	if err := session.Close(); err != nil {
+		t.Errorf("Session.Close() error = %v", err)
+	}
+	if err := session.Send(&Mail{From: "a@example.com", Name: "a", Subject: "s", Content: "c", To: []string{"b@example.com"}}); err == nil {
+		t.Error("Session.Send() after Close() should fail")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	messages := server.getMessages()
+	if len(messages) != 2 {
+		t.Fatalf("server received %d messages, want 2", len(messages))
+	}
+	if strings.Contains(messages[0], "RSET") {
+		t.Error("first message should not be preceded by RSET")
+	}
+	if !strings.Contains(messages[1], "RSET") {
+		t.Error("second message should be preceded by RSET")
+	}
+	if server.quitCount() != 1 {
+		t.Errorf("server received %d QUIT commands, want 1", server.quitCount())
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	if _, err := (&Mail{}).Dial(context.Background()); err == nil {
+		t.Error("Dial() without connection settings should fail")
+	}
+
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	session, err := (&Mail{Host: host, Port: port, User: "user", Pass: "pass"}).Dial(context.Background())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer session.Close()
+
+	if err := session.Send(&Mail{Subject: "missing fields"}); err == nil {
+		t.Error("Session.Send() with an invalid message should fail")
+	}
+}
+
+func TestSessionSandbox(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	var output bytes.Buffer
+	config := (&Mail{Host: host, Port: port, User: "user", Pass: "pass"}).SetSandbox(true).SetSandboxOutput(&output)
+
+	session, err := config.Dial(context.Background())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer session.Close()
+
+	msg := &Mail{From: "sender@example.com", Name: "Sender", Subject: "Sandboxed", Content: "c", To: []string{"recipient@example.com"}}
+	if err := session.Send(msg); err != nil {
+		t.Fatalf("Session.Send() error = %v", err)
+	}
+
+	if !strings.Contains(output.String(), "Subject: Sandboxed") {
+		t.Errorf("sandbox output does not contain the email:\n%s", output.String())
+	}
+	if messages := server.getMessages(); len(messages) != 0 {
+		t.Errorf("server received %d messages, want 0", len(messages))
+	}
+}
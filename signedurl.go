@@ -0,0 +1,135 @@
+package gomail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Errors returned when verifying a signed URL
+var (
+	// ErrInvalidSignature is returned for a URL that is unsigned or was altered
+	ErrInvalidSignature = errors.New("invalid URL signature")
+	// ErrURLExpired is returned for a correctly signed URL past its expiry
+	ErrURLExpired = errors.New("signed URL expired")
+)
+
+// Query parameters of a signed URL
+const (
+	signedURLRecipient = "rcpt"
+	signedURLMessageID = "mid"
+	signedURLExpires   = "exp"
+	signedURLSignature = "sig"
+)
+
+// URLToken is what a signed URL vouches for: the recipient an email was sent to,
+// the Message-ID of the email and when the URL stops being valid. A zero Expires
+// never expires.
+type URLToken struct {
+	Recipient string
+	MessageID string
+	Expires   time.Time
+}
+
+// URLSigner signs the unsubscribe and tracking URLs put in emails with
+// HMAC-SHA256 and verifies them in the HTTP handlers they lead to, so that a
+// recipient cannot unsubscribe or be tracked as somebody else. The signature
+// covers the path and every query parameter, e.g. the target of a click
+// tracking redirect. URLs are signed with Key and verified with Key or any of
+// PreviousKeys, so the key can be rotated without breaking emails already sent.
+type URLSigner struct {
+	Key          []byte
+	PreviousKeys [][]byte
+	// Clock is the source of time of the expiry check, the system clock when nil
+	Clock Clock
+}
+
+// Sign returns rawURL with the recipient, Message-ID and expiry of token and
+// the signature added to its query
+func (s *URLSigner) Sign(rawURL string, token URLToken) (string, error) {
+	if len(s.Key) == 0 {
+		return "", errors.New("missing signing key")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Del(signedURLSignature)
+	query.Set(signedURLRecipient, token.Recipient)
+	if token.MessageID != "" {
+		query.Set(signedURLMessageID, token.MessageID)
+	}
+	if !token.Expires.IsZero() {
+		query.Set(signedURLExpires, strconv.FormatInt(token.Expires.Unix(), 10))
+	}
+	query.Set(signedURLSignature, base64.RawURLEncoding.EncodeToString(signURL(s.Key, u.Path, query)))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Verify checks the signature and expiry of u and returns the token it carries
+func (s *URLSigner) Verify(u *url.URL) (URLToken, error) {
+	query := u.Query()
+	signature, err := base64.RawURLEncoding.DecodeString(query.Get(signedURLSignature))
+	if err != nil || len(signature) == 0 {
+		return URLToken{}, ErrInvalidSignature
+	}
+	valid := false
+	for _, key := range append([][]byte{s.Key}, s.PreviousKeys...) {
+		if len(key) > 0 && hmac.Equal(signature, signURL(key, u.Path, query)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return URLToken{}, ErrInvalidSignature
+	}
+
+	token := URLToken{Recipient: query.Get(signedURLRecipient), MessageID: query.Get(signedURLMessageID)}
+	if expires := query.Get(signedURLExpires); expires != "" {
+		seconds, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return URLToken{}, ErrInvalidSignature
+		}
+		token.Expires = time.Unix(seconds, 0)
+		if !s.now().Before(token.Expires) {
+			return token, ErrURLExpired
+		}
+	}
+	return token, nil
+}
+
+// VerifyRequest checks the URL of the request r, as received by the HTTP handler
+// the signed URL leads to, and returns the token it carries
+func (s *URLSigner) VerifyRequest(r *http.Request) (URLToken, error) {
+	return s.Verify(r.URL)
+}
+
+// now returns the current time of the signer's clock
+func (s *URLSigner) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// signURL returns the HMAC-SHA256 under key of path and the query parameters
+// other than the signature, in their canonical encoding
+func signURL(key []byte, path string, query url.Values) []byte {
+	unsigned := make(url.Values, len(query))
+	for name, values := range query {
+		if name != signedURLSignature {
+			unsigned[name] = values
+		}
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + "?" + unsigned.Encode()))
+	return mac.Sum(nil)
+}
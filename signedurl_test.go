@@ -0,0 +1,87 @@
+package gomail
+
+import (
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	clock := newFakeClock()
+	signer := &URLSigner{Key: []byte("current"), PreviousKeys: [][]byte{[]byte("previous")}, Clock: clock}
+	token := URLToken{Recipient: "user@example.com", MessageID: "123.abc@example.com", Expires: clock.Now().Add(24 * time.Hour)}
+
+	signed, err := signer.Sign("https://example.com/unsubscribe?list=news", token)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	oldSigned, _ := (&URLSigner{Key: []byte("previous")}).Sign("https://example.com/unsubscribe", token)
+	click, _ := signer.Sign("https://example.com/click?u=https%3A%2F%2Fshop.example.com%2F", URLToken{Recipient: "user@example.com"})
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{name: "valid", url: signed},
+		{name: "previous key", url: oldSigned},
+		{name: "no expiry", url: click},
+		{name: "other recipient", url: strings.Replace(signed, "rcpt=user", "rcpt=admin", 1), wantErr: ErrInvalidSignature},
+		{name: "other path", url: strings.Replace(signed, "/unsubscribe", "/delete", 1), wantErr: ErrInvalidSignature},
+		{name: "added parameter", url: signed + "&admin=1", wantErr: ErrInvalidSignature},
+		{name: "other click target", url: strings.Replace(click, "shop.example.com", "evil.example.com", 1), wantErr: ErrInvalidSignature},
+		{name: "unsigned", url: "https://example.com/unsubscribe?rcpt=user%40example.com", wantErr: ErrInvalidSignature},
+		{name: "unknown key", url: mustSign(t, &URLSigner{Key: []byte("other")}, token), wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := signer.Verify(u)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Recipient != "user@example.com" {
+				t.Errorf("Verify() recipient = %q", got.Recipient)
+			}
+		})
+	}
+
+	t.Run("request", func(t *testing.T) {
+		got, err := signer.VerifyRequest(httptest.NewRequest("GET", signed, nil))
+		if err != nil {
+			t.Fatalf("VerifyRequest() error = %v", err)
+		}
+		if got.Recipient != token.Recipient || got.MessageID != token.MessageID || !got.Expires.Equal(token.Expires) {
+			t.Errorf("VerifyRequest() = %+v, want %+v", got, token)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		clock.Advance(24 * time.Hour)
+		u, _ := url.Parse(signed)
+		if _, err := signer.Verify(u); !errors.Is(err, ErrURLExpired) {
+			t.Errorf("Verify() error = %v, want ErrURLExpired", err)
+		}
+	})
+
+	if _, err := (&URLSigner{}).Sign("https://example.com/", token); err == nil {
+		t.Error("Sign() without a key should fail")
+	}
+}
+
+// mustSign signs an unsubscribe URL for token with signer
+func mustSign(t *testing.T, signer *URLSigner, token URLToken) string {
+	t.Helper()
+	signed, err := signer.Sign("https://example.com/unsubscribe", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
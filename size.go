@@ -0,0 +1,102 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrMessageTooLarge matches a MessageTooLargeError
+var ErrMessageTooLarge = errors.New("message too large")
+
+// MessageTooLargeError reports an email larger than the server accepts, as
+// advertised by the SIZE extension of RFC 1870. It matches ErrMessageTooLarge.
+type MessageTooLargeError struct {
+	Size    int64
+	MaxSize int64
+}
+
+// Error implements error
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("message of at least %d bytes exceeds the server limit of %d bytes", e.Size, e.MaxSize)
+}
+
+// Is reports whether target is ErrMessageTooLarge
+func (e *MessageTooLargeError) Is(target error) bool {
+	return target == ErrMessageTooLarge
+}
+
+// ServerMaxSize returns the maximum message size advertised by the server with
+// the SIZE extension, connecting under ctx if needed. Zero means no limit was advertised.
+func (m *Mail) ServerMaxSize(ctx context.Context) (int64, error) {
+	extensions, err := m.Extensions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return extensions.Size, nil
+}
+
+// parseMaxSize returns the limit of a SIZE extension parameter, zero when there is none
+func parseMaxSize(param string) int64 {
+	size, err := strconv.ParseInt(param, 10, 64)
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+// minimumSize returns a lower bound of the serialized size of the email,
+// computed without serializing it so it can be checked before DATA
+func (m *Mail) minimumSize() int64 {
+	if m.prepared != nil {
+		return int64(len(m.prepared))
+	}
+
+	size := int64(len(m.headers()) + len(m.Content) + len(m.AltContent))
+	for _, data := range m.Attachments {
+		size += base64Size(int64(len(data)))
+	}
+	for _, attachment := range m.inlineAttachments {
+		size += base64Size(int64(len(attachment.Data)))
+	}
+	for _, attachment := range m.streamAttachments {
+		size += base64Size(attachment.Size)
+	}
+	for _, attachment := range m.attachmentParts {
+		size += attachment.Size()
+	}
+	for _, part := range m.customParts {
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			size += base64Size(int64(len(part.Body)))
+		} else {
+			size += int64(len(part.Body))
+		}
+	}
+	return size
+}
+
+// base64Size returns the size of n bytes once base64 encoded in lines of 76 characters
+func base64Size(n int64) int64 {
+	encoded := (n + 2) / 3 * 4
+	return encoded + (encoded+maxLineLength-1)/maxLineLength*2
+}
+
+// sizeLimitWriter fails writes once more than max bytes were written, so an
+// email larger than the server accepts is abandoned as early as possible
+type sizeLimitWriter struct {
+	w       io.Writer
+	max     int64
+	written int64
+}
+
+// Write implements io.Writer
+func (lw *sizeLimitWriter) Write(p []byte) (int, error) {
+	lw.written += int64(len(p))
+	if lw.written > lw.max {
+		return 0, &MessageTooLargeError{Size: lw.written, MaxSize: lw.max}
+	}
+	return lw.w.Write(p)
+}
@@ -0,0 +1,101 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestBase64Size(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 56, 57, 58, 1000, 4096} {
+		var buf bytes.Buffer
+		if err := writeBase64(&buf, bytes.NewReader(make([]byte, n))); err != nil {
+			t.Fatal(err)
+		}
+		if got := base64Size(int64(n)); got != int64(buf.Len()) {
+			t.Errorf("base64Size(%d) = %d, want %d", n, got, buf.Len())
+		}
+	}
+}
+
+func TestMinimumSize(t *testing.T) {
+	m := &Mail{
+		From:        "sender@example.com",
+		Name:        "Test Sender",
+		Subject:     "Test Subject",
+		Content:     "<p>Test Content</p>",
+		To:          []string{"recipient@example.com"},
+		Attachments: map[string][]byte{"data.bin": bytes.Repeat([]byte{1}, 5000)},
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if size := m.minimumSize(); size > int64(buf.Len()) || size < 6000 {
+		t.Errorf("minimumSize() = %d, want a close lower bound of %d", size, buf.Len())
+	}
+}
+
+func TestServerMaxSize(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+	server.advertise("SIZE 2000")
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	newMail := func() *Mail {
+		m := &Mail{
+			From:    "sender@example.com",
+			Name:    "Test Sender",
+			Host:    host,
+			Port:    port,
+			User:    "user",
+			Pass:    "pass",
+			Subject: "Test Subject",
+			Content: "Test Content",
+			To:      []string{"recipient@example.com"},
+		}
+		return m.SetPoolSize(1)
+	}
+
+	m := newMail()
+	if size, err := m.ServerMaxSize(context.Background()); err != nil || size != 2000 {
+		t.Fatalf("ServerMaxSize() = %d, %v, want 2000", size, err)
+	}
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() of a small email error = %v", err)
+	}
+
+	// Known sizes are refused before the transaction starts
+	m.SetAttachment(map[string][]byte{"big.bin": make([]byte, 10000)})
+	var tooLarge *MessageTooLargeError
+	if err := m.Send(); !errors.As(err, &tooLarge) || tooLarge.MaxSize != 2000 || !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Send() error = %v, want a MessageTooLargeError", err)
+	}
+
+	// Custom parts count with their encoding
+	m = newMail().SetCustomPart(CustomPart{
+		Header: textproto.MIMEHeader{"Content-Type": {"application/xml"}, "Content-Transfer-Encoding": {"base64"}},
+		Body:   make([]byte, 1600),
+	})
+	if err := m.Send(); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Send() error = %v, want ErrMessageTooLarge", err)
+	}
+
+	// Streams of unknown size are abandoned once they exceed the limit,
+	// without terminating the data so nothing truncated is delivered
+	m = newMail().SetStreamAttachment([]AttachmentReader{
+		{Name: "big.bin", Reader: strings.NewReader(strings.Repeat("x", 10000))},
+	})
+	if err := m.Send(); !errors.As(err, &tooLarge) {
+		t.Errorf("Send() error = %v, want a MessageTooLargeError", err)
+	}
+
+	if got := len(server.getMessages()); got != 1 {
+		t.Errorf("server received %d messages, want only the small one", got)
+	}
+}
@@ -0,0 +1,57 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrSMTPUTF8Required is returned for an address whose local part is not ASCII
+// when the server does not offer SMTPUTF8. Only the domain of an address can
+// be converted to ASCII.
+var ErrSMTPUTF8Required = errors.New("server does not support SMTPUTF8")
+
+// envelope returns the envelope sender and recipients of the email for a server
+// that does or does not offer SMTPUTF8. Without it the internationalized
+// domains are converted to punycode.
+func (m *Mail) envelope(smtpUTF8 bool) (from string, to []string, err error) {
+	if from, err = envelopeAddress(m.getEnvelopeFrom(), smtpUTF8); err != nil {
+		return "", nil, err
+	}
+	recipients := m.recipients()
+	to = make([]string, len(recipients))
+	for i, recipient := range recipients {
+		if to[i], err = envelopeAddress(recipient, smtpUTF8); err != nil {
+			return "", nil, err
+		}
+	}
+	return from, to, nil
+}
+
+// envelopeAddress returns address as it can be given to a server that does or
+// does not offer SMTPUTF8
+func envelopeAddress(address string, smtpUTF8 bool) (string, error) {
+	if smtpUTF8 || isASCII(address) {
+		return address, nil
+	}
+	at := strings.LastIndex(address, "@")
+	if at < 0 || !isASCII(address[:at]) {
+		return "", fmt.Errorf("%w: %s", ErrSMTPUTF8Required, address)
+	}
+	domain, err := domainToASCII(address[at+1:])
+	if err != nil {
+		return "", fmt.Errorf("invalid domain in %s: %w", address, err)
+	}
+	return address[:at+1] + domain, nil
+}
+
+// domainProfile is the IDNA lookup profile, which maps and normalizes a domain
+// and rejects invalid labels, that also enforces the DNS length limits
+var domainProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.VerifyDNSLength(true))
+
+// domainToASCII converts an internationalized domain name to its ASCII form
+func domainToASCII(domain string) (string, error) {
+	return domainProfile.ToASCII(domain)
+}
@@ -0,0 +1,139 @@
+package gomail
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestDomainToASCII(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"example.com", "example.com"},
+		{"例子.中国", "xn--fsqu00a.xn--fiqs8s"},
+		{"Bücher.example", "xn--bcher-kva.example"},
+		{"münchen.de", "xn--mnchen-3ya.de"},
+		{"例子。中国", "xn--fsqu00a.xn--fiqs8s"},
+		{"пример.рф", "xn--e1afmkfd.xn--p1ai"},
+		{"Ｂücher.EXAMPLE", "xn--bcher-kva.example"},
+		{"Bu\u0308cher.example", "xn--bcher-kva.example"},
+	}
+	for _, tt := range tests {
+		got, err := domainToASCII(tt.domain)
+		if err != nil || got != tt.want {
+			t.Errorf("domainToASCII(%q) = %q, %v, want %q", tt.domain, got, err, tt.want)
+		}
+	}
+
+	invalid := []string{
+		"例子..中国",
+		strings.Repeat("ü", 60) + ".de",
+		"bücher-.example",
+		"bü_cher.example",
+		"\u0301bücher.example",
+		"bü\u200dcher.example",
+	}
+	for _, domain := range invalid {
+		if got, err := domainToASCII(domain); err == nil {
+			t.Errorf("domainToASCII(%q) = %q, want an error", domain, got)
+		}
+	}
+}
+
+func TestEnvelopeAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		smtpUTF8 bool
+		want     string
+		wantErr  error
+	}{
+		{"ascii", "user@example.com", false, "user@example.com", nil},
+		{"SMTPUTF8", "用户@例子.中国", true, "用户@例子.中国", nil},
+		{"punycode domain", "user@例子.中国", false, "user@xn--fsqu00a.xn--fiqs8s", nil},
+		{"local part needs SMTPUTF8", "用户@例子.中国", false, "", ErrSMTPUTF8Required},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := envelopeAddress(tt.address, tt.smtpUTF8)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("envelopeAddress() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInternationalizedAddresses(t *testing.T) {
+	m := &Mail{}
+	for _, email := range []string{"用户@例子.中国", "user@bücher.example", "почта@пример.рф", "用户@example.xn--p1ai"} {
+		if !m.isEmailValid(email) {
+			t.Errorf("isEmailValid(%q) = false, want true", email)
+		}
+	}
+	for _, email := range []string{"用户 名@例子.中国", "用户@例子", "user@example.xn--p1ai\u3000"} {
+		if m.isEmailValid(email) {
+			t.Errorf("isEmailValid(%q) = true, want false", email)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		smtpUTF8 bool
+		to       string
+		want     []string
+		wantErr  error
+	}{
+		{
+			name:     "SMTPUTF8",
+			smtpUTF8: true,
+			to:       "用户@例子.中国",
+			want:     []string{"MAIL FROM:<sender@example.com> SMTPUTF8\r\n", "RCPT TO:<用户@例子.中国>\r\n"},
+		},
+		{
+			name: "punycode fallback",
+			to:   "user@例子.中国",
+			want: []string{"MAIL FROM:<sender@example.com>\r\n", "RCPT TO:<user@xn--fsqu00a.xn--fiqs8s>\r\n"},
+		},
+		{
+			name:    "local part without SMTPUTF8",
+			to:      "用户@例子.中国",
+			wantErr: ErrSMTPUTF8Required,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockSMTPServer(t)
+			defer server.close()
+			if tt.smtpUTF8 {
+				server.advertise("SMTPUTF8")
+			}
+			host, port, _ := net.SplitHostPort(server.addr())
+
+			m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).SetTo(tt.to)
+			m.Bcc = nil
+			err := m.Send()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Send() error = %v, want %v", err, tt.wantErr)
+			}
+			messages := server.getMessages()
+			if tt.wantErr != nil {
+				if len(messages) != 0 {
+					t.Error("message sent without a deliverable envelope")
+				}
+				return
+			}
+			if len(messages) != 1 {
+				t.Fatalf("server received %d messages, want 1", len(messages))
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(messages[0], want) {
+					t.Errorf("transaction does not contain %q:\n%s", want, messages[0])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package gomail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpamChecker scores a serialized message, e.g. with SpamAssassin or rspamd
+type SpamChecker interface {
+	Check(ctx context.Context, message []byte) (SpamScore, error)
+}
+
+// SpamRejectedError reports an email that was not sent because it scored above the spam check's MaxScore
+type SpamRejectedError struct {
+	Score    SpamScore
+	MaxScore float64
+}
+
+// Error implements error
+func (e *SpamRejectedError) Error() string {
+	return fmt.Sprintf("email not sent: spam score %.1f exceeds %.1f", e.Score.Score, e.MaxScore)
+}
+
+// SetSpamCheck scores every email with a spam checker before it is sent
+func (m *Mail) SetSpamCheck(config *SpamCheckConfig) *Mail {
+	m.spamCheck = config
+	return m
+}
+
+// SpamScore serializes the email and scores it with the configured spam checker
+func (m *Mail) SpamScore(ctx context.Context) (SpamScore, error) {
+	if m.spamCheck == nil || m.spamCheck.Checker == nil {
+		return SpamScore{}, fmt.Errorf("spam check not configured")
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return SpamScore{}, err
+	}
+	return m.spamCheck.Checker.Check(ctx, buf.Bytes())
+}
+
+// prepare serializes the email once, so that it is checked and sent identically
+func (m *Mail) prepare() error {
+	var buf bytes.Buffer
+	if _, err := m.writeTo(&buf); err != nil {
+		return err
+	}
+	m.prepared = buf.Bytes()
+	return nil
+}
+
+// checkSpam scores the prepared email, reporting the score and refusing emails above the maximum
+func (m *Mail) checkSpam(ctx context.Context) error {
+	score, err := m.spamCheck.Checker.Check(ctx, m.prepared)
+	if err != nil {
+		return fmt.Errorf("spam check failed: %w", err)
+	}
+	if m.spamCheck.OnScore != nil {
+		m.spamCheck.OnScore(m, score)
+	}
+	if m.spamCheck.MaxScore > 0 && score.Score > m.spamCheck.MaxScore {
+		return &SpamRejectedError{Score: score, MaxScore: m.spamCheck.MaxScore}
+	}
+	return nil
+}
+
+// SpamAssassin checks messages with a SpamAssassin spamd server, speaking the
+// protocol spamc uses
+type SpamAssassin struct {
+	// Addr is the address of spamd, e.g. "localhost:783"
+	Addr string
+	// Timeout limits the whole check, DefaultTimeout when zero
+	Timeout time.Duration
+}
+
+// Check implements SpamChecker
+func (s *SpamAssassin) Check(ctx context.Context, message []byte) (SpamScore, error) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return SpamScore{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := fmt.Fprintf(conn, "SYMBOLS SPAMC/1.5\r\nContent-length: %d\r\n\r\n", len(message)); err != nil {
+		return SpamScore{}, err
+	}
+	if _, err := conn.Write(message); err != nil {
+		return SpamScore{}, err
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+	}
+
+	return parseSpamdResponse(bufio.NewReader(conn))
+}
+
+// parseSpamdResponse parses the response of spamd to a SYMBOLS request:
+//
+//	SPAMD/1.1 0 EX_OK
+//	Spam: True ; 15.2 / 5.0
+//
+//	SYMBOL_A,SYMBOL_B
+func parseSpamdResponse(r *bufio.Reader) (SpamScore, error) {
+	status, err := r.ReadString('\n')
+	if err != nil {
+		return SpamScore{}, fmt.Errorf("spamd: %w", err)
+	}
+	if fields := strings.Fields(status); len(fields) < 3 || fields[1] != "0" {
+		return SpamScore{}, fmt.Errorf("spamd: %s", strings.TrimSpace(status))
+	}
+
+	var score SpamScore
+	found := false
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Spam:"); ok {
+			// e.g. "True ; 15.2 / 5.0"
+			verdict, scores, _ := strings.Cut(value, ";")
+			got, threshold, _ := strings.Cut(scores, "/")
+			score.IsSpam = strings.EqualFold(strings.TrimSpace(verdict), "true")
+			score.Score, _ = strconv.ParseFloat(strings.TrimSpace(got), 64)
+			score.Threshold, _ = strconv.ParseFloat(strings.TrimSpace(threshold), 64)
+			found = true
+		}
+		if err != nil {
+			break
+		}
+	}
+	if !found {
+		return SpamScore{}, fmt.Errorf("spamd: response has no Spam header")
+	}
+
+	symbols, _ := io.ReadAll(r)
+	for _, symbol := range strings.Split(strings.TrimSpace(string(symbols)), ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			score.Symbols = append(score.Symbols, symbol)
+		}
+	}
+	return score, nil
+}
+
+// Rspamd checks messages with the HTTP API of an rspamd worker
+type Rspamd struct {
+	// URL is the check endpoint, e.g. "http://localhost:11333/checkv2"
+	URL string
+	// Password is sent in the Password header when set
+	Password string
+	// Client is the HTTP client used for the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Check implements SpamChecker
+func (s *Rspamd) Check(ctx context.Context, message []byte) (SpamScore, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(message))
+	if err != nil {
+		return SpamScore{}, err
+	}
+	if s.Password != "" {
+		req.Header.Set("Password", s.Password)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return SpamScore{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return SpamScore{}, fmt.Errorf("rspamd: %s", resp.Status)
+	}
+
+	var result struct {
+		Score         float64             `json:"score"`
+		RequiredScore float64             `json:"required_score"`
+		Action        string              `json:"action"`
+		Symbols       map[string]struct{} `json:"symbols"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return SpamScore{}, fmt.Errorf("rspamd: invalid response: %w", err)
+	}
+
+	score := SpamScore{
+		Score:     result.Score,
+		Threshold: result.RequiredScore,
+		IsSpam:    result.Action != "" && result.Action != "no action" && result.Action != "greylist",
+	}
+	for symbol := range result.Symbols {
+		score.Symbols = append(score.Symbols, symbol)
+	}
+	sort.Strings(score.Symbols)
+	return score, nil
+}
@@ -0,0 +1,183 @@
+package gomail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseSpamdResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     SpamScore
+		wantErr  bool
+	}{
+		{
+			"spam",
+			"SPAMD/1.1 0 EX_OK\r\nContent-length: 27\r\nSpam: True ; 15.2 / 5.0\r\n\r\nBAYES_99,HTML_MESSAGE,URIBL",
+			SpamScore{Score: 15.2, Threshold: 5, IsSpam: true, Symbols: []string{"BAYES_99", "HTML_MESSAGE", "URIBL"}},
+			false,
+		},
+		{
+			"ham",
+			"SPAMD/1.1 0 EX_OK\r\nSpam: False ; -0.1 / 5.0\r\n\r\n",
+			SpamScore{Score: -0.1, Threshold: 5},
+			false,
+		},
+		{"error status", "SPAMD/1.0 76 Bad header line\r\n\r\n", SpamScore{}, true},
+		{"no verdict", "SPAMD/1.1 0 EX_OK\r\n\r\n", SpamScore{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSpamdResponse(bufio.NewReader(strings.NewReader(tt.response)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSpamdResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Score != tt.want.Score || got.Threshold != tt.want.Threshold || got.IsSpam != tt.want.IsSpam ||
+				strings.Join(got.Symbols, ",") != strings.Join(tt.want.Symbols, ",") {
+				t.Errorf("parseSpamdResponse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpamAssassin(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		request, _ := r.ReadString('\n')
+		length := 0
+		for {
+			line, _ := r.ReadString('\n')
+			if line == "\r\n" || line == "" {
+				break
+			}
+			if value, ok := strings.CutPrefix(line, "Content-length: "); ok {
+				length, _ = strconv.Atoi(strings.TrimSpace(value))
+			}
+		}
+		body := make([]byte, length)
+		io.ReadFull(r, body)
+		received <- request + string(body)
+		conn.Write([]byte("SPAMD/1.1 0 EX_OK\r\nSpam: False ; 1.5 / 5.0\r\n\r\nHTML_MESSAGE"))
+	}()
+
+	checker := &SpamAssassin{Addr: listener.Addr().String()}
+	score, err := checker.Check(context.Background(), []byte("Subject: hi\r\n\r\nbody"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if score.Score != 1.5 || score.IsSpam {
+		t.Errorf("Check() = %+v, want a 1.5 ham score", score)
+	}
+	if got := <-received; got != "SYMBOLS SPAMC/1.5\r\nSubject: hi\r\n\r\nbody" {
+		t.Errorf("spamd received %q", got)
+	}
+}
+
+func TestRspamd(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Password") != "secret" {
+			http.Error(w, "unauthorized", http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, `{"score": 9.5, "required_score": 15, "action": "add header",
+			"symbols": {"R_SPF_FAIL": {"score": 1}, "BAYES_SPAM": {"score": 5}}}`)
+	}))
+	defer server.Close()
+
+	checker := &Rspamd{URL: server.URL + "/checkv2", Password: "secret"}
+	score, err := checker.Check(context.Background(), []byte("Subject: hi\r\n\r\nbody"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if score.Score != 9.5 || score.Threshold != 15 || !score.IsSpam || strings.Join(score.Symbols, ",") != "BAYES_SPAM,R_SPF_FAIL" {
+		t.Errorf("Check() = %+v", score)
+	}
+
+	checker.Password = "wrong"
+	if _, err := checker.Check(context.Background(), nil); err == nil {
+		t.Error("Check() expected error for a rejected request")
+	}
+}
+
+// fixedSpamChecker scores every message with score, recording the last message checked
+type fixedSpamChecker struct {
+	score   float64
+	message string
+}
+
+func (c *fixedSpamChecker) Check(_ context.Context, message []byte) (SpamScore, error) {
+	c.message = string(message)
+	return SpamScore{Score: c.score}, nil
+}
+
+func TestSendWithSpamCheck(t *testing.T) {
+	server := newMockSMTPServer(t)
+	defer server.close()
+
+	host, port, _ := net.SplitHostPort(server.addr())
+	m := &Mail{
+		From:    "sender@example.com",
+		Name:    "Test Sender",
+		Host:    host,
+		Port:    port,
+		User:    "user",
+		Pass:    "pass",
+		Subject: "Test Subject",
+		Content: "Test Content",
+		To:      []string{"recipient@example.com"},
+	}
+	m.SetPoolSize(1).SetStreamAttachment([]AttachmentReader{
+		{Name: "report.txt", Reader: strings.NewReader("streamed attachment")},
+	})
+
+	checker := &fixedSpamChecker{score: 2}
+	var reported []float64
+	m.SetSpamCheck(&SpamCheckConfig{
+		Checker:  checker,
+		MaxScore: 5,
+		OnScore:  func(_ *Mail, score SpamScore) { reported = append(reported, score.Score) },
+	})
+
+	// The streamed attachment is read once, so the message sent is the one checked
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !strings.Contains(server.getMessages()[0], checker.message) {
+		t.Error("message sent differs from the message checked")
+	}
+	if len(reported) != 1 || reported[0] != 2 {
+		t.Errorf("OnScore() reported %v, want [2]", reported)
+	}
+
+	checker.score = 12
+	var rejected *SpamRejectedError
+	if err := m.Send(); !errors.As(err, &rejected) || rejected.Score.Score != 12 {
+		t.Errorf("Send() error = %v, want a SpamRejectedError", err)
+	}
+	if got := len(server.getMessages()); got != 1 {
+		t.Errorf("server received %d messages, want only the first", got)
+	}
+}
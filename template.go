@@ -0,0 +1,153 @@
+package gomail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TemplateLoader loads templates from a store such as a database or CMS. The
+// version of a template, e.g. an ETag or revision number, identifies its source:
+// LoadTemplate is called with the version of the cached template, or an empty
+// version when none is cached, and returns ErrTemplateNotModified, or the same
+// version, while the cached template is current.
+type TemplateLoader interface {
+	LoadTemplate(name, version string) (source, newVersion string, err error)
+}
+
+// ErrTemplateNotModified is returned by a TemplateLoader when the cached version of a template is current
+var ErrTemplateNotModified = errors.New("template not modified")
+
+// sampleDataExt is the extension of the sample data file shipped next to a
+// template, e.g. welcome.sample.json for welcome.html
+const sampleDataExt = ".sample.json"
+
+// templatePath returns the path of the template file called name
+func (e *TemplateEngine) templatePath(name string) string {
+	return e.path(name + e.DefaultExt)
+}
+
+// path returns the path of the file called name in BaseDir
+func (e *TemplateEngine) path(name string) string {
+	if e.FS != nil {
+		return path.Join(e.BaseDir, name)
+	}
+	return filepath.Join(e.BaseDir, name)
+}
+
+// parse parses the template called name with the FuncMap and the given options.
+// The template is named after its file, as ParseFiles and ParseFS name it.
+func (e *TemplateEngine) parse(name string, options ...string) (*template.Template, error) {
+	if e.Loader != nil {
+		tmpl, _, err := e.load(name, "", options...)
+		return tmpl, err
+	}
+
+	file := e.templatePath(name)
+	tmpl := template.New(filepath.Base(file)).Funcs(e.FuncMap).Option(options...)
+	if e.FS != nil {
+		return tmpl.ParseFS(e.FS, file)
+	}
+	return tmpl.ParseFiles(file)
+}
+
+// load loads and parses the template called name from the loader, unless version is
+// the current version of the template, in which case it returns a nil template
+func (e *TemplateEngine) load(name, version string, options ...string) (*template.Template, string, error) {
+	source, newVersion, err := e.Loader.LoadTemplate(name, version)
+	if errors.Is(err, ErrTemplateNotModified) || err == nil && version != "" && newVersion == version {
+		return nil, version, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	tmpl, err := template.New(name).Funcs(e.FuncMap).Option(options...).Parse(source)
+	if err != nil {
+		return nil, "", err
+	}
+	return tmpl, newVersion, nil
+}
+
+// SampleData loads the sample data shipped next to the template called name.
+// The error wraps os.ErrNotExist when the template has no sample data.
+func (e *TemplateEngine) SampleData(name string) (map[string]any, error) {
+	var content []byte
+	var err error
+	if e.FS != nil {
+		content, err = fs.ReadFile(e.FS, e.path(name+sampleDataExt))
+	} else {
+		content, err = os.ReadFile(e.path(name + sampleDataExt))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("invalid sample data for template %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// LintTemplate renders the template called name with its sample data, failing
+// on any field the template uses that the sample data does not provide
+func (e *TemplateEngine) LintTemplate(name string) error {
+	data, err := e.SampleData(name)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := e.parse(name, "missingkey=error")
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	if err := tmpl.Execute(io.Discard, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return nil
+}
+
+// LintTemplates lints every template in BaseDir that ships sample data
+func (e *TemplateEngine) LintTemplates() error {
+	var samples []string
+	var err error
+	if e.FS != nil {
+		samples, err = fs.Glob(e.FS, e.path("*"+sampleDataExt))
+	} else {
+		samples, err = filepath.Glob(e.path("*" + sampleDataExt))
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sample := range samples {
+		name := strings.TrimSuffix(filepath.Base(sample), sampleDataExt)
+		if err := e.LintTemplate(name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RenderTemplateSample renders the template called name with its sample data
+// as the email content, e.g. for previews
+func (m *Mail) RenderTemplateSample(name string) error {
+	if m.TemplateEngine == nil {
+		return errors.New("template engine not configured")
+	}
+
+	data, err := m.TemplateEngine.SampleData(name)
+	if err != nil {
+		return err
+	}
+	return m.RenderTemplate(name, data)
+}
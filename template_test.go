@@ -0,0 +1,138 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTemplateFiles writes files, keyed by name, into a new template directory
+func writeTemplateFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRenderTemplateSample(t *testing.T) {
+	dir := writeTemplateFiles(t, map[string]string{
+		"welcome.html":        `<p>Hello {{.Name}}, you have {{.Count}} messages from {{.Team.Name}}</p>`,
+		"welcome.sample.json": `{"Name": "Ayşe", "Count": 3, "Team": {"Name": "Support"}}`,
+		"bare.html":           `<p>{{.Name}}</p>`,
+	})
+
+	m := &Mail{}
+	m.SetTemplateEngine(&TemplateEngine{BaseDir: dir, DefaultExt: ".html"})
+
+	if err := m.RenderTemplateSample("welcome"); err != nil {
+		t.Fatalf("RenderTemplateSample() error = %v", err)
+	}
+	if want := "<p>Hello Ayşe, you have 3 messages from Support</p>"; m.Content != want {
+		t.Errorf("Content = %q, want %q", m.Content, want)
+	}
+
+	if err := m.RenderTemplateSample("bare"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("RenderTemplateSample() error = %v, want os.ErrNotExist for a template without sample data", err)
+	}
+}
+
+func TestLintTemplates(t *testing.T) {
+	dir := writeTemplateFiles(t, map[string]string{
+		"welcome.html":        `<p>Hello {{.Name}}</p>`,
+		"welcome.sample.json": `{"Name": "Ayşe"}`,
+		"invoice.html":        `<p>{{.Customer.Name}} owes {{.Total}}</p>`,
+		"invoice.sample.json": `{"Customer": {"Name": "Ayşe"}}`,
+		"broken.html":         `<p>{{.Name}}</p>`,
+		"broken.sample.json":  `{"Name": `,
+	})
+	engine := &TemplateEngine{BaseDir: dir, DefaultExt: ".html"}
+
+	if err := engine.LintTemplate("welcome"); err != nil {
+		t.Errorf("LintTemplate() error = %v", err)
+	}
+
+	err := engine.LintTemplates()
+	if err == nil {
+		t.Fatal("LintTemplates() expected errors")
+	}
+	for _, want := range []string{"invoice:", "Total", "broken: invalid sample data"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LintTemplates() error = %v, want it to mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "welcome") {
+		t.Errorf("LintTemplates() error = %v, should not report the valid template", err)
+	}
+}
+
+// memoryLoader is a TemplateLoader over templates held in memory, versioned by revision
+type memoryLoader struct {
+	sources       map[string]string
+	revisions     map[string]int
+	notModified   bool
+	loads, parses int
+}
+
+func (l *memoryLoader) LoadTemplate(name, version string) (string, string, error) {
+	l.loads++
+	source, ok := l.sources[name]
+	if !ok {
+		return "", "", os.ErrNotExist
+	}
+	current := fmt.Sprintf("rev-%d", l.revisions[name])
+	if l.notModified && version == current {
+		return "", "", ErrTemplateNotModified
+	}
+	l.parses++
+	return source, current, nil
+}
+
+func TestTemplateLoader(t *testing.T) {
+	for _, notModified := range []bool{false, true} {
+		t.Run(fmt.Sprintf("notModified=%v", notModified), func(t *testing.T) {
+			loader := &memoryLoader{
+				sources:     map[string]string{"welcome": `<p>Hello {{.}}</p>`},
+				revisions:   map[string]int{"welcome": 1},
+				notModified: notModified,
+			}
+			m := &Mail{}
+			m.SetTemplateEngine(&TemplateEngine{Loader: loader})
+
+			render := func(want string) {
+				t.Helper()
+				if err := m.RenderTemplate("welcome", "Ayşe"); err != nil {
+					t.Fatalf("RenderTemplate() error = %v", err)
+				}
+				if m.Content != want {
+					t.Errorf("Content = %q, want %q", m.Content, want)
+				}
+			}
+
+			render("<p>Hello Ayşe</p>")
+			render("<p>Hello Ayşe</p>")
+
+			// A new version replaces the cached template
+			loader.sources["welcome"] = `<p>Welcome {{.}}</p>`
+			loader.revisions["welcome"]++
+			render("<p>Welcome Ayşe</p>")
+
+			if loader.loads != 3 {
+				t.Errorf("loader consulted %d times, want 3", loader.loads)
+			}
+			if notModified && loader.parses != 2 {
+				t.Errorf("loader returned the source %d times, want 2", loader.parses)
+			}
+
+			if err := m.RenderTemplate("missing", nil); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("RenderTemplate() error = %v, want os.ErrNotExist", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package gomail
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"text/template"
+)
+
+// TemplateCache stores the parsed templates of a TemplateEngine, with the
+// version of each as returned by the TemplateLoader, if any. Implementations
+// must be safe for concurrent use.
+type TemplateCache interface {
+	Get(name string) (tmpl *template.Template, version string, ok bool)
+	Add(name, version string, tmpl *template.Template)
+}
+
+// TemplateCacheStats are the counters of a template cache
+type TemplateCacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	// Len is the number of templates cached
+	Len int
+}
+
+// TemplateLRU is a TemplateCache holding up to a fixed number of templates,
+// evicting the least recently used one to make room
+type TemplateLRU struct {
+	size    int
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	stats   TemplateCacheStats
+}
+
+// templateEntry is a template cached by a TemplateLRU
+type templateEntry struct {
+	name    string
+	version string
+	tmpl    *template.Template
+}
+
+// NewTemplateLRU creates a template cache holding up to size templates, or any
+// number of templates when size is not positive
+func NewTemplateLRU(size int) *TemplateLRU {
+	return &TemplateLRU{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Get implements TemplateCache
+func (c *TemplateLRU) Get(name string) (*template.Template, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[name]
+	if !ok {
+		c.stats.Misses++
+		return nil, "", false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	entry := element.Value.(*templateEntry)
+	return entry.tmpl, entry.version, true
+}
+
+// Add implements TemplateCache
+func (c *TemplateLRU) Add(name, version string, tmpl *template.Template) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[name]; ok {
+		element.Value = &templateEntry{name: name, version: version, tmpl: tmpl}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[name] = c.order.PushFront(&templateEntry{name: name, version: version, tmpl: tmpl})
+	for c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*templateEntry).name)
+		c.stats.Evictions++
+	}
+}
+
+// Stats returns the counters of the cache
+func (c *TemplateLRU) Stats() TemplateCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Len = c.order.Len()
+	return stats
+}
+
+// cache returns the template cache of the engine, creating the default one on first use
+func (e *TemplateEngine) cache() TemplateCache {
+	if e.Cache != nil {
+		return e.Cache
+	}
+	e.cacheOnce.Do(func() { e.defaultCache = NewTemplateLRU(DefaultTemplateCacheSize) })
+	return e.defaultCache
+}
+
+// CacheStats returns the counters of the engine's template cache, zero when
+// the cache is a TemplateCache that does not keep them
+func (e *TemplateEngine) CacheStats() TemplateCacheStats {
+	if cache, ok := e.cache().(interface{ Stats() TemplateCacheStats }); ok {
+		return cache.Stats()
+	}
+	return TemplateCacheStats{}
+}
+
+// cachedTemplate returns the template called name, parsing and caching it on first
+// use. A template of a loader is revalidated with its version on every use.
+func (e *TemplateEngine) cachedTemplate(name string) (*template.Template, error) {
+	cache := e.cache()
+	tmpl, version, exists := cache.Get(name)
+	if exists && e.Loader == nil {
+		return tmpl, nil
+	}
+
+	if e.Loader != nil {
+		if !exists {
+			version = ""
+		}
+		loaded, newVersion, err := e.load(name, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load template: %w", err)
+		}
+		if loaded == nil {
+			return tmpl, nil
+		}
+		tmpl, version = loaded, newVersion
+	} else {
+		var err error
+		if tmpl, err = e.parse(name); err != nil {
+			return nil, fmt.Errorf("failed to parse template: %w", err)
+		}
+	}
+
+	cache.Add(name, version, tmpl)
+	return tmpl, nil
+}
@@ -0,0 +1,89 @@
+package gomail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+)
+
+func TestTemplateLRU(t *testing.T) {
+	cache := NewTemplateLRU(2)
+	for _, name := range []string{"a", "b"} {
+		cache.Add(name, "v1", template.Must(template.New(name).Parse(name)))
+	}
+	if _, _, ok := cache.Get("a"); !ok {
+		t.Fatal("Get(a) missed")
+	}
+
+	// b is the least recently used and makes room for c
+	cache.Add("c", "v1", template.Must(template.New("c").Parse("c")))
+	if _, _, ok := cache.Get("b"); ok {
+		t.Error("Get(b) hit, want it evicted")
+	}
+	cache.Add("a", "v2", template.Must(template.New("a").Parse("a2")))
+	if _, version, ok := cache.Get("a"); !ok || version != "v2" {
+		t.Errorf("Get(a) = %q, %v, want the replaced v2", version, ok)
+	}
+
+	want := TemplateCacheStats{Hits: 2, Misses: 1, Evictions: 1, Len: 2}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	unlimited := NewTemplateLRU(0)
+	for _, name := range []string{"a", "b", "c"} {
+		unlimited.Add(name, "", template.Must(template.New(name).Parse(name)))
+	}
+	if got := unlimited.Stats(); got.Len != 3 || got.Evictions != 0 {
+		t.Errorf("unlimited Stats() = %+v, want 3 templates kept", got)
+	}
+}
+
+func TestTemplateEngineCache(t *testing.T) {
+	dir := writeTemplateFiles(t, map[string]string{
+		"welcome.html": "Hello {{.Name}}",
+		"goodbye.html": "Bye {{.Name}}",
+	})
+
+	t.Run("shared by every mail", func(t *testing.T) {
+		engine := &TemplateEngine{BaseDir: dir, DefaultExt: ".html"}
+		first := newSandboxMail().SetTemplateEngine(engine)
+		if err := first.RenderTemplate("welcome", map[string]string{"Name": "Ada"}); err != nil {
+			t.Fatalf("RenderTemplate() error = %v", err)
+		}
+
+		// The second mail uses the template parsed for the first
+		if err := os.WriteFile(filepath.Join(dir, "welcome.html"), []byte("Changed"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		second := newSandboxMail().SetTemplateEngine(engine)
+		if err := second.RenderTemplate("welcome", map[string]string{"Name": "Grace"}); err != nil {
+			t.Fatalf("RenderTemplate() error = %v", err)
+		}
+		if second.Content != "Hello Grace" {
+			t.Errorf("Content = %q, want the cached template rendered", second.Content)
+		}
+		want := TemplateCacheStats{Hits: 1, Misses: 1, Len: 1}
+		if got := engine.CacheStats(); got != want {
+			t.Errorf("CacheStats() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("size limit", func(t *testing.T) {
+		cache := NewTemplateLRU(1)
+		engine := &TemplateEngine{BaseDir: dir, DefaultExt: ".html", Cache: cache}
+		m := newSandboxMail().SetTemplateEngine(engine)
+		for _, name := range []string{"welcome", "goodbye", "welcome"} {
+			if err := m.RenderTemplate(name, map[string]string{"Name": "Ada"}); err != nil {
+				t.Fatalf("RenderTemplate(%s) error = %v", name, err)
+			}
+		}
+		if got := cache.Stats(); got.Evictions != 2 || got.Misses != 3 || got.Len != 1 {
+			t.Errorf("Stats() = %+v, want every template evicted by the next", got)
+		}
+		if engine.CacheStats() != cache.Stats() {
+			t.Error("CacheStats() differs from the stats of the engine's cache")
+		}
+	})
+}
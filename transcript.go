@@ -0,0 +1,197 @@
+package gomail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// redacted replaces the credentials of an AUTH exchange in a transcript
+const redacted = "<redacted>"
+
+// SendWithTranscript sends the email under ctx like SendContext and returns the
+// SMTP commands and server responses of the send, one per line prefixed with
+// "C: " or "S: ", to attach to a bug report. Credentials are redacted and the
+// message data is replaced by its size. The email is sent over a connection of
+// its own, so the transcript covers the whole session of every attempt; it is
+// empty when the email is not sent over SMTP, as in sandbox mode.
+func (m *Mail) SendWithTranscript(ctx context.Context) (string, error) {
+	t := new(transcript)
+	c := m.sendCopy()
+	c.transcript = t
+	err := c.sendContext(ctx)
+	return t.String(), err
+}
+
+// sendTranscribed sends the email to the server at endpoint under ctx over a
+// new connection, which is recorded in the transcript of the email and closed
+// after the send instead of being pooled
+func (m *Mail) sendTranscribed(ctx context.Context, endpoint Endpoint, capture *bytes.Buffer) error {
+	conn, err := dialEndpoint(ctx, m, endpoint)
+	if err != nil {
+		return err
+	}
+
+	unbind := conn.bind(ctx)
+	err = m.transmit(conn, m.getCommandTimeouts(), capture)
+	unbind()
+	m.setServerResponses(conn.serverResponses())
+
+	if contextDone(ctx, err) != nil {
+		conn.client.Close()
+		if err != nil {
+			return contextError(ctx, err)
+		}
+		return nil
+	}
+	if err != nil {
+		conn.client.Close()
+		return err
+	}
+	conn.quit(m.getCommandTimeouts().Quit)
+	return nil
+}
+
+// transcript records the commands sent to and the responses read from the
+// servers of a send, redacting the credentials of AUTH exchanges and leaving
+// out the message data
+type transcript struct {
+	mu     sync.Mutex
+	lines  []string
+	client []byte
+	server []byte
+	// command is the command awaiting its final response
+	command string
+	// auth is set during an AUTH exchange, data while the message data is sent
+	auth, data bool
+	dataSize   int
+	// encrypted is set once STARTTLS is accepted, from when the data of the
+	// network connection is no longer readable
+	encrypted bool
+}
+
+// connect starts the record of a new connection to addr
+func (t *transcript) connect(addr string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.client, t.server = nil, nil
+	t.command, t.auth, t.data, t.encrypted = "", false, false, false
+	t.lines = append(t.lines, "* connecting to "+addr)
+}
+
+// record scans p, written by the client or read from the server, for lines.
+// Data of the network connection, rather than of the TLS session above it, is
+// ignored once the connection is upgraded with STARTTLS.
+func (t *transcript) record(p []byte, client, network bool) {
+	if t == nil || len(p) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if network && t.encrypted {
+		return
+	}
+
+	partial := &t.server
+	if client {
+		partial = &t.client
+	}
+	*partial = append(*partial, p...)
+	for {
+		end := bytes.IndexByte(*partial, '\n')
+		if end < 0 {
+			return
+		}
+		line := strings.TrimRight(string((*partial)[:end]), "\r")
+		*partial = (*partial)[end+1:]
+		if client {
+			t.clientLine(line, end+1)
+		} else {
+			t.serverLine(line)
+		}
+	}
+}
+
+// clientLine records a line of size bytes written by the client
+func (t *transcript) clientLine(line string, size int) {
+	switch {
+	case t.data:
+		if line != "." {
+			t.dataSize += size
+			return
+		}
+		t.data = false
+		t.lines = append(t.lines, fmt.Sprintf("C: <message data, %d bytes>", t.dataSize), "C: .")
+		t.command = "."
+	case t.auth:
+		t.lines = append(t.lines, "C: "+redacted)
+	default:
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			t.lines = append(t.lines, "C: "+line)
+			return
+		}
+		t.command = strings.ToUpper(fields[0])
+		if t.command == "AUTH" && len(fields) > 1 {
+			t.auth = true
+			line = "AUTH " + fields[1]
+			if len(fields) > 2 {
+				line += " " + redacted
+			}
+		}
+		t.lines = append(t.lines, "C: "+line)
+	}
+}
+
+// serverLine records a line read from the server. A line with a space after
+// the code ends the response to the pending command.
+func (t *transcript) serverLine(line string) {
+	t.lines = append(t.lines, "S: "+line)
+	if len(line) >= 4 && line[3] == '-' {
+		return
+	}
+
+	code := line[:min(3, len(line))]
+	switch t.command {
+	case "DATA":
+		t.data = code == "354"
+		t.dataSize = 0
+	case "STARTTLS":
+		t.encrypted = strings.HasPrefix(code, "2")
+	}
+	// A challenge continues the AUTH exchange
+	if code != "334" {
+		t.auth = false
+		t.command = ""
+	}
+}
+
+// String returns the lines recorded
+func (t *transcript) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Join(t.lines, "\n")
+}
+
+// recordingWriter is a writer whose writes are recorded in a transcript. Every
+// write is flushed, keeping the order of the lines written and read.
+type recordingWriter struct {
+	w          *bufio.Writer
+	transcript *transcript
+}
+
+// Write implements io.Writer
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	n, err := rw.w.Write(p)
+	if err == nil {
+		err = rw.w.Flush()
+	}
+	rw.transcript.record(p[:n], true, false)
+	return n, err
+}
@@ -0,0 +1,179 @@
+package gomail
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendWithTranscript(t *testing.T) {
+	plain := newMockSMTPServer(t)
+	defer plain.close()
+	startTLS := newMockSMTPServer(t)
+	startTLS.tlsConfig = serverTLSConfig(t)
+	defer startTLS.close()
+	direct := newMockTLSServer(t)
+	defer direct.close()
+
+	tests := []struct {
+		name      string
+		server    *mockSMTPServer
+		tlsConfig *TLSConfig
+		want      []string
+	}{
+		{
+			name:   "plain",
+			server: plain,
+			want: []string{
+				"S: 220 mock.server ESMTP ready",
+				"C: EHLO localhost",
+				"S: 250-mock.server",
+				"S: 250 AUTH PLAIN",
+				"C: AUTH PLAIN <redacted>",
+				"S: 235 Authentication successful",
+				"C: MAIL FROM:<sender@example.com>",
+				"S: 250 Sender OK",
+				"C: RCPT TO:<recipient@example.com>",
+				"S: 250 Recipient OK",
+				"C: DATA",
+				"S: 354 Start mail input",
+			},
+		},
+		{
+			name:      "STARTTLS",
+			server:    startTLS,
+			tlsConfig: &TLSConfig{StartTLS: true, InsecureSkipVerify: true},
+			want: []string{
+				"S: 250-STARTTLS",
+				"C: STARTTLS",
+				"S: 220 Ready to start TLS",
+				"C: EHLO localhost",
+				"C: AUTH PLAIN <redacted>",
+				"S: 250 Message accepted",
+			},
+		},
+		{
+			name:      "direct TLS",
+			server:    direct,
+			tlsConfig: &TLSConfig{InsecureSkipVerify: true},
+			want: []string{
+				"S: 220 mock.server ESMTP ready",
+				"C: AUTH PLAIN <redacted>",
+				"S: 250 Message accepted",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port, _ := net.SplitHostPort(tt.server.addr())
+			m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port).
+				SetTimeout(5 * time.Second).SetTLSConfig(tt.tlsConfig)
+			m.Bcc = nil
+
+			transcript, err := m.SendWithTranscript(context.Background())
+			if err != nil {
+				t.Fatalf("SendWithTranscript() error = %v", err)
+			}
+			if !strings.HasPrefix(transcript, "* connecting to "+tt.server.addr()+"\n") {
+				t.Errorf("transcript does not start with the connection:\n%s", transcript)
+			}
+			if !strings.HasSuffix(transcript, "C: .\nS: 250 Message accepted\nC: QUIT\nS: 221 Bye") {
+				t.Errorf("transcript does not end with the message accepted and QUIT:\n%s", transcript)
+			}
+			if !strings.Contains(transcript, "\nC: <message data, ") {
+				t.Errorf("transcript does not contain the size of the message data:\n%s", transcript)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(transcript, want+"\n") {
+					t.Errorf("transcript does not contain %q:\n%s", want, transcript)
+				}
+			}
+			for _, secret := range []string{"Test Content", "Test Subject", "dXNlcg", "AHVzZXIAcGFzcw"} {
+				if strings.Contains(transcript, secret) {
+					t.Errorf("transcript contains %q", secret)
+				}
+			}
+		})
+	}
+
+	t.Run("failed send", func(t *testing.T) {
+		server := newMockSMTPServer(t)
+		defer server.close()
+		server.replyToRcpt("550 5.1.1 User unknown")
+		host, port, _ := net.SplitHostPort(server.addr())
+
+		m := newSandboxMail().SetSandbox(false).SetHost(host).SetPort(port)
+		m.Bcc = nil
+		transcript, err := m.SendWithTranscript(context.Background())
+		if err == nil {
+			t.Fatal("SendWithTranscript() succeeded, want the rejected recipient")
+		}
+		if !strings.HasSuffix(transcript, "C: RCPT TO:<recipient@example.com>\nS: 550 5.1.1 User unknown") {
+			t.Errorf("transcript does not end with the rejection:\n%s", transcript)
+		}
+		if server.connectionCount() != 1 || m.transcript != nil {
+			t.Error("transcript not limited to the send")
+		}
+	})
+
+	t.Run("sandbox", func(t *testing.T) {
+		transcript, err := newSandboxMail().SendWithTranscript(context.Background())
+		if err != nil || transcript != "" {
+			t.Errorf("SendWithTranscript() = %q, %v, want an empty transcript", transcript, err)
+		}
+	})
+}
+
+func TestTranscriptRedaction(t *testing.T) {
+	session := []struct {
+		client bool
+		data   string
+	}{
+		{false, "220 mx.example.com ESMTP\r\n"},
+		{true, "AUTH LOGIN\r\n"},
+		{false, "334 VXNlcm5hbWU6\r\n"},
+		{true, "dXNlcg==\r\n"},
+		{false, "334 UGFzc3dvcmQ6\r\n"},
+		{true, "cGFzcw==\r\n"},
+		{false, "235 2.7.0 Accepted\r\n"},
+		{true, "DATA\r\n"},
+		{false, "354 Go ahead\r\n"},
+		{true, "Subject: Secret\r\n\r\n"},
+		{true, "Body\r\n.\r\n"},
+		{false, "250 2.0.0 Queued\r\n"},
+		{true, "auth plain AHVzZXIAcGFzcw==\r\n"},
+		{false, "503 5.5.1 Already authenticated\r\n"},
+		{true, "NOOP\r\n"},
+	}
+
+	tr := new(transcript)
+	tr.connect("mx.example.com:587")
+	for _, part := range session {
+		tr.record([]byte(part.data), part.client, true)
+	}
+
+	want := strings.Join([]string{
+		"* connecting to mx.example.com:587",
+		"S: 220 mx.example.com ESMTP",
+		"C: AUTH LOGIN",
+		"S: 334 VXNlcm5hbWU6",
+		"C: <redacted>",
+		"S: 334 UGFzc3dvcmQ6",
+		"C: <redacted>",
+		"S: 235 2.7.0 Accepted",
+		"C: DATA",
+		"S: 354 Go ahead",
+		"C: <message data, 25 bytes>",
+		"C: .",
+		"S: 250 2.0.0 Queued",
+		"C: AUTH plain <redacted>",
+		"S: 503 5.5.1 Already authenticated",
+		"C: NOOP",
+	}, "\n")
+	if got := tr.String(); got != want {
+		t.Errorf("transcript =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Transport delivers an email that has passed validation and the send policies.
+// SMTPTransport is the default; SetTransport swaps in another delivery backend,
+// or middleware that wraps SMTPTransport, e.g. for logging or metrics. Send is
+// called once per attempt under the retry policy. A transport that serializes
+// the message with WriteTo also provides the copy handed to the archiver.
+type Transport interface {
+	Send(ctx context.Context, m *Mail) error
+}
+
+// TransportFunc adapts a function to the Transport interface
+type TransportFunc func(ctx context.Context, m *Mail) error
+
+// Send implements Transport
+func (f TransportFunc) Send(ctx context.Context, m *Mail) error {
+	return f(ctx, m)
+}
+
+// SMTPTransport delivers emails over SMTP with the server, pool, TLS, rate limit
+// and failover settings of the email
+var SMTPTransport Transport = TransportFunc(func(ctx context.Context, m *Mail) error {
+	return m.sendOnce(ctx, m.capture)
+})
+
+// SetTransport sets the transport emails are delivered with instead of SMTP.
+// Sessions always deliver over their SMTP connection.
+func (m *Mail) SetTransport(transport Transport) *Mail {
+	m.transport = transport
+	return m
+}
+
+// SetTags sets the tags of the email, which delivery API transports pass on to
+// the service for grouping its analytics. SMTP ignores them.
+func (m *Mail) SetTags(tags ...string) *Mail {
+	m.tags = tags
+	return m
+}
+
+// getTransport returns the transport, which defaults to SMTPTransport
+func (m *Mail) getTransport() Transport {
+	if m.transport == nil {
+		return SMTPTransport
+	}
+	return m.transport
+}
+
+// apiRecipients splits the envelope recipients of the email into the To, Cc and
+// Bcc fields of a delivery API. To and Cc recipients keep their field, and every
+// other recipient (Bcc, SendToAudience batches, journal addresses) is Bcc. When
+// RedirectAllTo is set the redirect address is the only To recipient.
+func (m *Mail) apiRecipients() (to, cc, bcc []string) {
+	envelope := m.recipients()
+	seen := make(map[string]bool)
+	header := func(addresses []string) []string {
+		if m.redirectTo != "" {
+			addresses = []string{m.redirectTo}
+		}
+		var fields []string
+		for _, address := range addresses {
+			if !seen[address] && slices.Contains(envelope, address) {
+				seen[address] = true
+				fields = append(fields, address)
+			}
+		}
+		return fields
+	}
+	to = header(m.To)
+	if m.redirectTo == "" {
+		cc = header(m.Cc)
+	}
+	for _, address := range envelope {
+		if !seen[address] {
+			seen[address] = true
+			bcc = append(bcc, address)
+		}
+	}
+	return to, cc, bcc
+}
+
+// apiBodies returns the plain text and HTML bodies of the email for a delivery
+// API. Markdown content is sent as plain text.
+func (m *Mail) apiBodies() (text, html string, err error) {
+	mediaType, err := m.bodyMediaType()
+	if err != nil {
+		return "", "", err
+	}
+	if mediaType == string(TextHTML) {
+		return m.AltContent, m.Content, nil
+	}
+	return m.Content, "", nil
+}
+
+// apiAttachments returns the attachments of the email for a delivery API, read
+// into memory. Streamed attachments are replaced by what was read, so the message
+// can still be written afterwards.
+func (m *Mail) apiAttachments() ([]Attachment, error) {
+	if len(m.customParts) > 0 {
+		return nil, errCustomPartsUnsupported
+	}
+	var attachments []Attachment
+	for _, name := range sortedKeys(m.Attachments) {
+		attachments = append(attachments, Attachment{Name: name, ContentType: "application/octet-stream", Data: m.Attachments[name]})
+	}
+	for _, attachment := range m.inlineAttachments {
+		attachment.Inline = true
+		attachment.ContentType = attachment.contentType()
+		attachments = append(attachments, attachment)
+	}
+
+	streams := make([]AttachmentReader, len(m.streamAttachments))
+	for i, attachment := range m.streamAttachments {
+		data, err := io.ReadAll(newAttachmentStream(attachment))
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, Attachment{Name: attachment.Name, ContentType: "application/octet-stream", Data: data})
+		streams[i] = AttachmentReader{Name: attachment.Name, Reader: bytes.NewReader(data), Size: int64(len(data))}
+	}
+	m.streamAttachments = streams
+
+	for _, attachment := range m.attachmentParts {
+		data, err := base64.StdEncoding.DecodeString(string(attachment.encoded))
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, Attachment{Name: attachment.Name(), ContentType: attachment.header.Get("Content-Type"), Data: data})
+	}
+	return attachments, nil
+}
+
+// apiHeaders returns the headers a delivery API does not set from its other
+// fields: the Message-ID and, when RedirectAllTo is set, the original recipients
+func (m *Mail) apiHeaders() map[string]string {
+	headers := make(map[string]string)
+	if m.messageID != "" {
+		headers["Message-ID"] = "<" + sanitizeHeaderValue(m.messageID) + ">"
+	}
+	if m.redirectTo != "" {
+		// Keep the original recipients visible to whoever reads the redirected email
+		for name, addresses := range map[string][]string{"X-Original-To": m.To, "X-Original-Cc": m.Cc, "X-Original-Bcc": m.Bcc} {
+			if len(addresses) > 0 {
+				headers[name] = sanitizeHeaderValue(strings.Join(addresses, ", "))
+			}
+		}
+	}
+	return headers
+}
+
+// captureMessage writes the message to the archive capture of the send, if any,
+// for transports that do not serialize it themselves
+func (m *Mail) captureMessage() error {
+	if m.capture == nil {
+		return nil
+	}
+	_, err := m.WriteTo(io.Discard)
+	return err
+}
@@ -0,0 +1,292 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default endpoints of the Microsoft Graph transport
+const (
+	DefaultGraphEndpoint = "https://graph.microsoft.com"
+	DefaultGraphLogin    = "https://login.microsoftonline.com"
+)
+
+// GraphTransport delivers emails through the Microsoft Graph sendMail API of an
+// Office 365 mailbox, for tenants where SMTP basic authentication is disabled. It
+// authenticates as an application registered in TenantID with the OAuth2 client
+// credentials flow, which needs the Mail.Send application permission, and caches
+// the access token until shortly before it expires.
+//
+// The message is mapped to the API fields: To, Cc and Bcc, the HTML or plain
+// text body, the attachments, the Message-ID and, when redirecting, the original
+// recipients. Graph builds the plain text alternative itself, so AltContent is
+// not sent, and a calendar event is attached as invite.ics. Graph accepts
+// requests of up to 4 MB, attachments included.
+type GraphTransport struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// User is the user principal name or ID of the mailbox sending, the From
+	// address of the email when empty
+	User string
+	// SaveToSentItems keeps a copy of every email in the Sent Items folder of the mailbox
+	SaveToSentItems bool
+	// Endpoint is the base URL of the Graph API, DefaultGraphEndpoint when empty
+	Endpoint string
+	// LoginEndpoint is the base URL of the identity platform, DefaultGraphLogin when empty
+	LoginEndpoint string
+	// Client is the HTTP client used for the requests, http.DefaultClient when nil
+	Client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// graphSendMail is the request body of the sendMail API
+type graphSendMail struct {
+	Message         graphMessage `json:"message"`
+	SaveToSentItems bool         `json:"saveToSentItems"`
+}
+
+// graphMessage is the message of a sendMail request
+type graphMessage struct {
+	Subject                string               `json:"subject"`
+	Body                   graphBody            `json:"body"`
+	From                   graphRecipient       `json:"from"`
+	ToRecipients           []graphRecipient     `json:"toRecipients,omitempty"`
+	CcRecipients           []graphRecipient     `json:"ccRecipients,omitempty"`
+	BccRecipients          []graphRecipient     `json:"bccRecipients,omitempty"`
+	Attachments            []graphAttachment    `json:"attachments,omitempty"`
+	InternetMessageID      string               `json:"internetMessageId,omitempty"`
+	InternetMessageHeaders []graphMessageHeader `json:"internetMessageHeaders,omitempty"`
+}
+
+// graphBody is the body of a message, of the "HTML" or "Text" content type
+type graphBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+// graphRecipient is a recipient or the sender of a message
+type graphRecipient struct {
+	EmailAddress struct {
+		Address string `json:"address"`
+		Name    string `json:"name,omitempty"`
+	} `json:"emailAddress"`
+}
+
+// graphAttachment is a file attachment with base64 encoded content
+type graphAttachment struct {
+	ODataType    string `json:"@odata.type"`
+	Name         string `json:"name"`
+	ContentType  string `json:"contentType"`
+	ContentBytes string `json:"contentBytes"`
+	IsInline     bool   `json:"isInline,omitempty"`
+	ContentID    string `json:"contentId,omitempty"`
+}
+
+// graphMessageHeader is a custom header of a message, which Graph only accepts with an X- prefix
+type graphMessageHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Send implements Transport
+func (t *GraphTransport) Send(ctx context.Context, m *Mail) error {
+	if err := m.waitRateLimit(ctx); err != nil {
+		return err
+	}
+	message, err := newGraphMessage(m)
+	if err != nil {
+		return err
+	}
+	if err := m.captureMessage(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(graphSendMail{Message: *message, SaveToSentItems: t.SaveToSentItems})
+	if err != nil {
+		return err
+	}
+
+	user := t.User
+	if user == "" {
+		user = m.From
+	}
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultGraphEndpoint
+	}
+	sendURL := strings.TrimSuffix(endpoint, "/") + "/v1.0/users/" + url.PathEscape(user) + "/sendMail"
+
+	// A token revoked before it expires is replaced once
+	for retried := false; ; retried = true {
+		token, err := t.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := t.client().Do(req)
+		if err != nil {
+			return contextError(ctx, err)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && !retried {
+			resp.Body.Close()
+			t.resetToken(token)
+			continue
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			return newAPIError("graph", resp)
+		}
+		return nil
+	}
+}
+
+// accessToken returns the cached access token, requesting a new one when it is
+// missing or about to expire
+func (t *GraphTransport) accessToken(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expires) {
+		return t.token, nil
+	}
+
+	login := t.LoginEndpoint
+	if login == "" {
+		login = DefaultGraphLogin
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {t.ClientID},
+		"client_secret": {t.ClientSecret},
+		"scope":         {"https://graph.microsoft.com/.default"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(login, "/")+"/"+url.PathEscape(t.TenantID)+"/oauth2/v2.0/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.client().Do(req)
+	if err != nil {
+		return "", contextError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError("graph", resp)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	// Renew a minute early so a token does not expire in flight
+	t.token = token.AccessToken
+	t.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return t.token, nil
+}
+
+// resetToken drops token from the cache unless it was already replaced
+func (t *GraphTransport) resetToken(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == token {
+		t.token = ""
+	}
+}
+
+// client returns the HTTP client used for the requests
+func (t *GraphTransport) client() *http.Client {
+	if t.Client == nil {
+		return http.DefaultClient
+	}
+	return t.Client
+}
+
+// newGraphMessage maps m to the message of a sendMail request
+func newGraphMessage(m *Mail) (*graphMessage, error) {
+	message := &graphMessage{
+		Subject: m.Subject,
+		From:    graphRecipients(m.From)[0],
+	}
+	message.From.EmailAddress.Name = m.Name
+
+	to, cc, bcc := m.apiRecipients()
+	message.ToRecipients = graphRecipients(to...)
+	message.CcRecipients = graphRecipients(cc...)
+	message.BccRecipients = graphRecipients(bcc...)
+
+	text, html, err := m.apiBodies()
+	if err != nil {
+		return nil, err
+	}
+	message.Body = graphBody{ContentType: "Text", Content: text}
+	if html != "" {
+		message.Body = graphBody{ContentType: "HTML", Content: html}
+	}
+
+	attachments, err := m.apiAttachments()
+	if err != nil {
+		return nil, err
+	}
+	if m.calendar != nil {
+		attachments = append(attachments, Attachment{
+			Name:        "invite.ics",
+			ContentType: "text/calendar; charset=UTF-8; method=" + m.calendar.method,
+			Data:        []byte(m.calendarContent(time.Now())),
+		})
+	}
+	for _, attachment := range attachments {
+		part := graphAttachment{
+			ODataType:    "#microsoft.graph.fileAttachment",
+			Name:         attachment.Name,
+			ContentType:  attachment.ContentType,
+			ContentBytes: base64.StdEncoding.EncodeToString(attachment.Data),
+		}
+		if attachment.Inline {
+			part.IsInline, part.ContentID = true, attachment.Name
+		}
+		message.Attachments = append(message.Attachments, part)
+	}
+
+	headers := m.apiHeaders()
+	message.InternetMessageID = headers["Message-ID"]
+	delete(headers, "Message-ID")
+	for _, name := range []string{"X-Original-To", "X-Original-Cc", "X-Original-Bcc"} {
+		if value, ok := headers[name]; ok {
+			message.InternetMessageHeaders = append(message.InternetMessageHeaders, graphMessageHeader{Name: name, Value: value})
+		}
+	}
+	return message, nil
+}
+
+// graphRecipients returns the API recipients of addresses
+func graphRecipients(addresses ...string) []graphRecipient {
+	if len(addresses) == 0 {
+		return nil
+	}
+	recipients := make([]graphRecipient, len(addresses))
+	for i, address := range addresses {
+		recipients[i].EmailAddress.Address = address
+	}
+	return recipients
+}
@@ -0,0 +1,194 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// mailgunEndpoints are the base URLs of the Mailgun API by region
+var mailgunEndpoints = map[MailgunRegion]string{
+	MailgunUS: "https://api.mailgun.net",
+	MailgunEU: "https://api.eu.mailgun.net",
+}
+
+// MailgunTransport delivers emails through the Mailgun messages API of Domain
+// instead of SMTP. The message is mapped to the API fields: To, Cc and Bcc, the
+// plain text and HTML bodies, the attachments, the Message-ID and the tags. A
+// calendar event is attached as invite.ics. When no To recipient is left, e.g.
+// in the later batches of SendToAudience, the recipients are sent as a batch in
+// which each of them receives a copy addressed to them. Mailgun signs the email
+// itself; the DKIM keys and envelope sender of the email are not used.
+type MailgunTransport struct {
+	// Domain is the sending domain registered with Mailgun, e.g. "mg.example.com"
+	Domain string
+	APIKey string
+	// Region is the region the domain is hosted in, MailgunUS when empty
+	Region MailgunRegion
+	// Endpoint, when set, is the base URL of the API instead of that of Region
+	Endpoint string
+	// Tags are added to the tags of every email. Mailgun accepts up to 3 tags per email.
+	Tags []string
+	// Client is the HTTP client used for the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Send implements Transport
+func (t *MailgunTransport) Send(ctx context.Context, m *Mail) error {
+	endpoint, err := t.endpoint()
+	if err != nil {
+		return err
+	}
+	if err := m.waitRateLimit(ctx); err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := t.writeForm(writer, m); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if err := m.captureMessage(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v3/"+url.PathEscape(t.Domain)+"/messages", &body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("api", t.APIKey)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return newAPIError("mailgun", resp)
+	}
+	return nil
+}
+
+// endpoint returns the base URL of the API
+func (t *MailgunTransport) endpoint() (string, error) {
+	if t.Endpoint != "" {
+		return strings.TrimSuffix(t.Endpoint, "/"), nil
+	}
+	region := t.Region
+	if region == "" {
+		region = MailgunUS
+	}
+	endpoint, ok := mailgunEndpoints[region]
+	if !ok {
+		return "", fmt.Errorf("mailgun: unknown region %q", region)
+	}
+	return endpoint, nil
+}
+
+// writeForm writes m as the form fields of a messages API request
+func (t *MailgunTransport) writeForm(writer *multipart.Writer, m *Mail) error {
+	var fields [][2]string
+	add := func(name string, values ...string) {
+		for _, value := range values {
+			fields = append(fields, [2]string{name, value})
+		}
+	}
+
+	add("from", (&mail.Address{Name: m.Name, Address: m.From}).String())
+	to, cc, bcc := m.apiRecipients()
+	if len(to) == 0 {
+		// Recipient variables make a batch send, in which every To recipient
+		// receives a copy addressed to them
+		recipients := append(cc, bcc...)
+		variables := make(map[string]struct{}, len(recipients))
+		for _, address := range recipients {
+			variables[address] = struct{}{}
+		}
+		encoded, err := json.Marshal(variables)
+		if err != nil {
+			return err
+		}
+		add("to", recipients...)
+		add("recipient-variables", string(encoded))
+	} else {
+		add("to", to...)
+		add("cc", cc...)
+		add("bcc", bcc...)
+	}
+	add("subject", m.Subject)
+
+	text, html, err := m.apiBodies()
+	if err != nil {
+		return err
+	}
+	if text != "" {
+		add("text", text)
+	}
+	if html != "" {
+		add("html", html)
+	}
+	add("o:tag", append(append([]string{}, t.Tags...), m.tags...)...)
+	headers := m.apiHeaders()
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add("h:"+name, headers[name])
+	}
+
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+
+	attachments, err := m.apiAttachments()
+	if err != nil {
+		return err
+	}
+	if m.calendar != nil {
+		attachments = append(attachments, Attachment{
+			Name:        "invite.ics",
+			ContentType: "text/calendar; charset=UTF-8; method=" + m.calendar.method,
+			Data:        []byte(m.calendarContent(time.Now())),
+		})
+	}
+	for _, attachment := range attachments {
+		// Mailgun refers to inline attachments by their file name, as cid:<Name>
+		field := "attachment"
+		if attachment.Inline {
+			field = "inline"
+		}
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Disposition": []string{mime.FormatMediaType("form-data", map[string]string{"name": field, "filename": attachment.Name})},
+			"Content-Type":        []string{attachment.ContentType},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(attachment.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
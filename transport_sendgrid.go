@@ -0,0 +1,187 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultSendGridEndpoint is the base URL of the SendGrid v3 API
+const DefaultSendGridEndpoint = "https://api.sendgrid.com"
+
+// SendGridTransport delivers emails through the SendGrid v3 Mail Send API
+// instead of SMTP, for hosts that block the outbound SMTP ports. The message is
+// mapped to the API fields: To, Cc and Bcc, the plain text, HTML and calendar
+// bodies, the attachments, the Message-ID and the tags, as categories. SendGrid requires a To recipient,
+// so when no To recipient is left, e.g. in the later batches of SendToAudience,
+// each recipient receives a copy addressed to them. SendGrid signs the email
+// itself; the DKIM keys and envelope sender of the email are not used.
+type SendGridTransport struct {
+	APIKey string
+	// Endpoint is the base URL of the API, DefaultSendGridEndpoint when empty
+	Endpoint string
+	// Client is the HTTP client used for the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// sendGridMessage is the request body of the Mail Send API
+type sendGridMessage struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+	Categories       []string                  `json:"categories,omitempty"`
+}
+
+// sendGridPersonalization is a set of recipients receiving the same copy
+type sendGridPersonalization struct {
+	To  []sendGridAddress `json:"to"`
+	Cc  []sendGridAddress `json:"cc,omitempty"`
+	Bcc []sendGridAddress `json:"bcc,omitempty"`
+}
+
+// sendGridAddress is an email address with an optional display name
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// sendGridContent is a body of the email
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// sendGridAttachment is an attachment with base64 encoded content
+type sendGridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type,omitempty"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+// Send implements Transport
+func (t *SendGridTransport) Send(ctx context.Context, m *Mail) error {
+	if err := m.waitRateLimit(ctx); err != nil {
+		return err
+	}
+	message, err := newSendGridMessage(m)
+	if err != nil {
+		return err
+	}
+	if err := m.captureMessage(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultSendGridEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return newAPIError("sendgrid", resp)
+	}
+	return nil
+}
+
+// newSendGridMessage maps m to a Mail Send API request
+func newSendGridMessage(m *Mail) (*sendGridMessage, error) {
+	message := &sendGridMessage{
+		From:       sendGridAddress{Email: m.From, Name: m.Name},
+		Subject:    m.Subject,
+		Categories: m.tags,
+	}
+
+	to, cc, bcc := m.apiRecipients()
+	if len(to) == 0 {
+		for _, address := range append(cc, bcc...) {
+			message.Personalizations = append(message.Personalizations, sendGridPersonalization{To: sendGridAddresses(address)})
+		}
+	} else {
+		message.Personalizations = []sendGridPersonalization{{
+			To:  sendGridAddresses(to...),
+			Cc:  sendGridAddresses(cc...),
+			Bcc: sendGridAddresses(bcc...),
+		}}
+	}
+
+	// SendGrid requires the plain text body to come first and the HTML body next
+	text, html, err := m.apiBodies()
+	if err != nil {
+		return nil, err
+	}
+	if text != "" {
+		message.Content = append(message.Content, sendGridContent{Type: "text/plain", Value: text})
+	}
+	if html != "" {
+		message.Content = append(message.Content, sendGridContent{Type: "text/html", Value: html})
+	}
+	if m.calendar != nil {
+		message.Content = append(message.Content, sendGridContent{
+			Type:  "text/calendar; method=" + m.calendar.method,
+			Value: m.calendarContent(time.Now()),
+		})
+	}
+
+	attachments, err := m.apiAttachments()
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		part := sendGridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(attachment.Data),
+			Type:        attachment.ContentType,
+			Filename:    attachment.Name,
+			Disposition: "attachment",
+		}
+		if attachment.Inline {
+			part.Disposition = "inline"
+			part.ContentID = attachment.Name
+		}
+		message.Attachments = append(message.Attachments, part)
+	}
+
+	if headers := m.apiHeaders(); len(headers) > 0 {
+		message.Headers = headers
+	}
+	return message, nil
+}
+
+// sendGridAddresses returns the API addresses of addresses
+func sendGridAddresses(addresses ...string) []sendGridAddress {
+	if len(addresses) == 0 {
+		return nil
+	}
+	result := make([]sendGridAddress, len(addresses))
+	for i, address := range addresses {
+		result[i] = sendGridAddress{Email: address}
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"github.com/aws/smithy-go"
+)
+
+// sesMaxRecipients is the maximum number of recipients of an SES email
+const sesMaxRecipients = 50
+
+// SESTransport delivers emails through the Amazon SES v2 API as raw messages,
+// so users on AWS need no SMTP credentials. The message is composed, DKIM signed
+// and archived as for SMTP and submitted with its envelope recipients through
+// the AWS SDK. The envelope sender, when it differs from From, receives the
+// bounce and complaint notifications.
+//
+// The SDK client is built on the first send, from the settings at that time.
+// Retries are left to the retry policy of the email, so the SDK does not retry.
+type SESTransport struct {
+	// Region is the AWS region, e.g. "eu-west-1". The region of the SDK's
+	// default configuration, such as AWS_REGION, is used when empty.
+	Region string
+	// Credentials supplies the AWS credentials, which are cached until they
+	// expire. AccessKeyID, SecretAccessKey and SessionToken are used when it is
+	// nil, and the SDK's default credential chain, such as shared config
+	// profiles, SSO and IAM roles, when AccessKeyID is empty as well.
+	Credentials aws.CredentialsProvider
+	// AccessKeyID, SecretAccessKey and SessionToken are static AWS credentials
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// ConfigurationSet is the SES configuration set emails are sent with, if any
+	ConfigurationSet string
+	// Endpoint is the base URL of the API, "https://email.<Region>.amazonaws.com" when empty
+	Endpoint string
+	// Client is the HTTP client used for the requests, the SDK's default when nil
+	Client *http.Client
+
+	mu     sync.Mutex
+	client *sesv2.Client
+}
+
+// Send implements Transport
+func (t *SESTransport) Send(ctx context.Context, m *Mail) error {
+	client, err := t.sesClient(ctx)
+	if err != nil {
+		return err
+	}
+	recipients := m.recipients()
+	if len(recipients) > sesMaxRecipients {
+		return fmt.Errorf("ses: %d recipients exceed the limit of %d per email", len(recipients), sesMaxRecipients)
+	}
+	if err := m.waitRateLimit(ctx); err != nil {
+		return err
+	}
+
+	var message bytes.Buffer
+	if _, err := m.WriteTo(&message); err != nil {
+		return err
+	}
+	input := &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(m.From),
+		Destination:      &types.Destination{ToAddresses: recipients},
+		Content:          &types.EmailContent{Raw: &types.RawMessage{Data: message.Bytes()}},
+	}
+	if t.ConfigurationSet != "" {
+		input.ConfigurationSetName = aws.String(t.ConfigurationSet)
+	}
+	if envelopeFrom := m.getEnvelopeFrom(); envelopeFrom != m.From {
+		input.FeedbackForwardingEmailAddress = aws.String(envelopeFrom)
+	}
+
+	if _, err := client.SendEmail(ctx, input); err != nil {
+		return sesError(ctx, err)
+	}
+	return nil
+}
+
+// sesClient returns the SES client of the transport, loading the SDK's default
+// configuration on first use
+func (t *SESTransport) sesClient(ctx context.Context) (*sesv2.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	var options []func(*config.LoadOptions) error
+	if t.Region != "" {
+		options = append(options, config.WithRegion(t.Region))
+	}
+	switch {
+	case t.Credentials != nil:
+		options = append(options, config.WithCredentialsProvider(t.Credentials))
+	case t.AccessKeyID != "":
+		options = append(options, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(t.AccessKeyID, t.SecretAccessKey, t.SessionToken)))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("ses: loading the AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("ses: missing region")
+	}
+
+	t.client = sesv2.NewFromConfig(cfg, func(o *sesv2.Options) {
+		o.Retryer = aws.NopRetryer{}
+		if t.Client != nil {
+			o.HTTPClient = t.Client
+		}
+		if t.Endpoint != "" {
+			o.BaseEndpoint = aws.String(t.Endpoint)
+		}
+	})
+	return t.client, nil
+}
+
+// sesError returns the error of a failed SendEmail call, as an *APIError when
+// the service responded
+func sesError(ctx context.Context, err error) error {
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) {
+		return contextError(ctx, err)
+	}
+	apiErr := &APIError{Service: "ses", StatusCode: respErr.HTTPStatusCode(), Message: respErr.Err.Error()}
+	var smithyErr smithy.APIError
+	if errors.As(err, &smithyErr) {
+		apiErr.Message = smithyErr.ErrorCode() + ": " + smithyErr.ErrorMessage()
+	}
+	if seconds, convErr := strconv.Atoi(respErr.Response.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
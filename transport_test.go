@@ -0,0 +1,591 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// recordingArchiver keeps the last message archived
+type recordingArchiver struct {
+	message []byte
+}
+
+func (a *recordingArchiver) Archive(ctx context.Context, message []byte, metadata ArchiveMetadata) error {
+	a.message = message
+	return nil
+}
+
+func TestTransport(t *testing.T) {
+	t.Run("custom backend", func(t *testing.T) {
+		var delivered bytes.Buffer
+		archiver := &recordingArchiver{}
+		m := newSandboxMail().SetSandbox(false).SetArchiver(archiver).
+			SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error {
+				_, err := m.WriteTo(&delivered)
+				return err
+			}))
+
+		if err := m.Send(); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if !bytes.Contains(delivered.Bytes(), []byte("Subject: Test Subject")) {
+			t.Error("transport did not receive the message")
+		}
+		if !bytes.Equal(archiver.message, delivered.Bytes()) {
+			t.Error("archived message differs from the message delivered by the transport")
+		}
+	})
+
+	t.Run("middleware around SMTP", func(t *testing.T) {
+		server := newMockSMTPServer(t)
+		defer server.close()
+		host, port, _ := net.SplitHostPort(server.addr())
+
+		var subjects []string
+		m := newSandboxMail().SetSandbox(false)
+		m.Host, m.Port = host, port
+		m.SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error {
+			subjects = append(subjects, m.Subject)
+			return SMTPTransport.Send(ctx, m)
+		}))
+
+		if err := m.Send(); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if len(subjects) != 1 || len(server.getMessages()) != 1 {
+			t.Errorf("middleware saw %v, server received %d messages", subjects, len(server.getMessages()))
+		}
+	})
+}
+
+func TestSendGridTransport(t *testing.T) {
+	var requests []sendGridMessage
+	statuses := []int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3/mail/send" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer SG.key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var message sendGridMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, message)
+
+		status := http.StatusAccepted
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		w.WriteHeader(status)
+		if status != http.StatusAccepted {
+			w.Write([]byte(`{"errors":[{"message":"rejected"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	newMail := func() *Mail {
+		m := newSandboxMail().SetSandbox(false).
+			SetTransport(&SendGridTransport{APIKey: "SG.key", Endpoint: server.URL + "/", Client: server.Client()})
+		m.Cc = []string{"copy@example.com"}
+		m.AltContent = "Plain Content"
+		return m
+	}
+
+	t.Run("message", func(t *testing.T) {
+		requests = nil
+		archiver := &recordingArchiver{}
+		m := newMail().SetArchiver(archiver).
+			SetAttachment(map[string][]byte{"report.txt": []byte("report")}).
+			SetInlineAttachment([]Attachment{{Name: "logo.png", ContentType: "image/png", Data: []byte("png")}})
+
+		report := m.SendWithReport(context.Background())
+		if report.Err != nil {
+			t.Fatalf("SendWithReport() error = %v", report.Err)
+		}
+		if len(requests) != 1 {
+			t.Fatalf("server received %d requests, want 1", len(requests))
+		}
+		got := requests[0]
+		want := []sendGridPersonalization{{
+			To:  []sendGridAddress{{Email: "recipient@example.com"}},
+			Cc:  []sendGridAddress{{Email: "copy@example.com"}},
+			Bcc: []sendGridAddress{{Email: "hidden@example.com"}},
+		}}
+		if !jsonEqual(got.Personalizations, want) {
+			t.Errorf("personalizations = %+v", got.Personalizations)
+		}
+		if got.From != (sendGridAddress{Email: "sender@example.com", Name: "Test Sender"}) || got.Subject != "Test Subject" {
+			t.Errorf("from = %+v, subject = %q", got.From, got.Subject)
+		}
+		wantContent := []sendGridContent{{Type: "text/plain", Value: "Plain Content"}, {Type: "text/html", Value: "Test Content"}}
+		if !jsonEqual(got.Content, wantContent) {
+			t.Errorf("content = %+v", got.Content)
+		}
+		wantAttachments := []sendGridAttachment{
+			{Content: base64.StdEncoding.EncodeToString([]byte("report")), Type: "application/octet-stream", Filename: "report.txt", Disposition: "attachment"},
+			{Content: base64.StdEncoding.EncodeToString([]byte("png")), Type: "image/png", Filename: "logo.png", Disposition: "inline", ContentID: "logo.png"},
+		}
+		if !jsonEqual(got.Attachments, wantAttachments) {
+			t.Errorf("attachments = %+v", got.Attachments)
+		}
+		if got.Headers["Message-ID"] != "<"+report.MessageID+">" {
+			t.Errorf("headers = %v, want the Message-ID %s", got.Headers, report.MessageID)
+		}
+		if !bytes.Contains(archiver.message, []byte("Subject: Test Subject")) {
+			t.Error("the message sent through SendGrid was not archived")
+		}
+	})
+
+	t.Run("audience batch", func(t *testing.T) {
+		requests = nil
+		m := newMail()
+		m.Cc, m.Bcc = nil, nil
+		if _, err := SendToAudience(context.Background(), m, []string{"a@example.com", "b@example.com", "c@example.com"}, 2); err != nil {
+			t.Fatalf("SendToAudience() error = %v", err)
+		}
+		want := [][]sendGridPersonalization{
+			{{To: []sendGridAddress{{Email: "recipient@example.com"}}, Bcc: []sendGridAddress{{Email: "a@example.com"}, {Email: "b@example.com"}}}},
+			// Without a To recipient every recipient gets a copy addressed to them
+			{{To: []sendGridAddress{{Email: "c@example.com"}}}},
+		}
+		if len(requests) != 2 || !jsonEqual(requests[0].Personalizations, want[0]) || !jsonEqual(requests[1].Personalizations, want[1]) {
+			t.Errorf("requests = %+v", requests)
+		}
+	})
+
+	t.Run("redirected", func(t *testing.T) {
+		requests = nil
+		if err := newMail().RedirectAllTo("qa@example.com").Send(); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		want := []sendGridPersonalization{{To: []sendGridAddress{{Email: "qa@example.com"}}}}
+		if len(requests) != 1 || !jsonEqual(requests[0].Personalizations, want) {
+			t.Fatalf("requests = %+v", requests)
+		}
+		if got := requests[0].Headers["X-Original-To"]; got != "recipient@example.com" {
+			t.Errorf("X-Original-To = %q", got)
+		}
+	})
+
+	t.Run("retried", func(t *testing.T) {
+		requests = nil
+		statuses = []int{http.StatusTooManyRequests}
+		clock := newFakeClock()
+		m := newMail().SetClock(clock).SetRetryPolicy(&RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+
+		done := make(chan SendReport, 1)
+		go func() { done <- m.SendWithReport(context.Background()) }()
+		// The Retry-After header of the response sets the delay
+		waitPending(t, clock, 1)
+		clock.Advance(999 * time.Millisecond)
+		if len(requests) != 1 {
+			t.Fatalf("retried before the Retry-After delay")
+		}
+		clock.Advance(time.Millisecond)
+
+		report := <-done
+		if report.Err != nil || report.Attempts != 2 || len(requests) != 2 {
+			t.Errorf("report = %+v, %d requests", report, len(requests))
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		statuses = []int{http.StatusBadRequest}
+		err := newMail().SetRetryPolicy(&RetryPolicy{MaxAttempts: 3}).Send()
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Service != "sendgrid" || apiErr.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Send() error = %v, want a SendGrid *APIError", err)
+		}
+		if !IsPermanent(err) || IsTemporary(err) {
+			t.Error("a 400 response should be a permanent failure")
+		}
+		if !strings.Contains(apiErr.Message, "rejected") {
+			t.Errorf("Message = %q", apiErr.Message)
+		}
+	})
+}
+
+// jsonEqual reports whether a and b have the same JSON encoding
+func jsonEqual(a, b any) bool {
+	encodedA, _ := json.Marshal(a)
+	encodedB, _ := json.Marshal(b)
+	return bytes.Equal(encodedA, encodedB)
+}
+
+func TestMailgunTransport(t *testing.T) {
+	var forms []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3/mg.example.com/messages" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if user, pass, _ := r.BasicAuth(); user != "api" || pass != "key-123" {
+			t.Errorf("basic auth = %q, %q", user, pass)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parsing form: %v", err)
+		}
+		forms = append(forms, r)
+		w.Write([]byte(`{"id":"<id@mg.example.com>","message":"Queued. Thank you."}`))
+	}))
+	defer server.Close()
+
+	newMail := func() *Mail {
+		m := newSandboxMail().SetSandbox(false).SetTransport(&MailgunTransport{
+			Domain:   "mg.example.com",
+			APIKey:   "key-123",
+			Endpoint: server.URL,
+			Tags:     []string{"newsletter"},
+			Client:   server.Client(),
+		})
+		m.Cc = []string{"copy@example.com"}
+		return m
+	}
+
+	t.Run("message", func(t *testing.T) {
+		forms = nil
+		m := newMail().SetTags("may").
+			SetAttachment(map[string][]byte{"report.txt": []byte("report")}).
+			SetInlineAttachment([]Attachment{{Name: "logo.png", ContentType: "image/png", Data: []byte("png")}})
+		m.AltContent = "Plain Content"
+
+		report := m.SendWithReport(context.Background())
+		if report.Err != nil {
+			t.Fatalf("SendWithReport() error = %v", report.Err)
+		}
+		if len(forms) != 1 {
+			t.Fatalf("server received %d requests, want 1", len(forms))
+		}
+		form := forms[0].MultipartForm
+		want := map[string][]string{
+			"from":         {`"Test Sender" <sender@example.com>`},
+			"to":           {"recipient@example.com"},
+			"cc":           {"copy@example.com"},
+			"bcc":          {"hidden@example.com"},
+			"subject":      {"Test Subject"},
+			"text":         {"Plain Content"},
+			"html":         {"Test Content"},
+			"o:tag":        {"newsletter", "may"},
+			"h:Message-ID": {"<" + report.MessageID + ">"},
+		}
+		if !jsonEqual(form.Value, want) {
+			t.Errorf("form values = %v, want %v", form.Value, want)
+		}
+		for field, name := range map[string]string{"attachment": "report.txt", "inline": "logo.png"} {
+			if files := form.File[field]; len(files) != 1 || files[0].Filename != name {
+				t.Errorf("%s files = %v, want %s", field, files, name)
+			}
+		}
+	})
+
+	t.Run("audience batch", func(t *testing.T) {
+		forms = nil
+		m := newMail()
+		m.Cc, m.Bcc = nil, nil
+		if _, err := SendToAudience(context.Background(), m, []string{"a@example.com", "b@example.com", "c@example.com"}, 1); err != nil {
+			t.Fatalf("SendToAudience() error = %v", err)
+		}
+		if len(forms) != 3 {
+			t.Fatalf("server received %d requests, want 3", len(forms))
+		}
+		first := forms[0].MultipartForm.Value
+		if !jsonEqual(first["to"], []string{"recipient@example.com"}) || !jsonEqual(first["bcc"], []string{"a@example.com"}) {
+			t.Errorf("first batch = %v", first)
+		}
+		// Without a To recipient the batch is a Mailgun batch send
+		second := forms[1].MultipartForm.Value
+		if !jsonEqual(second["to"], []string{"b@example.com"}) || !jsonEqual(second["recipient-variables"], []string{`{"b@example.com":{}}`}) || second["bcc"] != nil {
+			t.Errorf("second batch = %v", second)
+		}
+	})
+
+	t.Run("region", func(t *testing.T) {
+		tests := []struct {
+			transport MailgunTransport
+			want      string
+			wantErr   bool
+		}{
+			{transport: MailgunTransport{}, want: "https://api.mailgun.net"},
+			{transport: MailgunTransport{Region: MailgunEU}, want: "https://api.eu.mailgun.net"},
+			{transport: MailgunTransport{Region: MailgunEU, Endpoint: "http://localhost:8080/"}, want: "http://localhost:8080"},
+			{transport: MailgunTransport{Region: "ap"}, wantErr: true},
+		}
+		for _, tt := range tests {
+			got, err := tt.transport.endpoint()
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("endpoint() of region %q = %q, %v", tt.transport.Region, got, err)
+			}
+		}
+	})
+}
+
+// sesSendEmail is the request body of the SES SendEmail API
+type sesSendEmail struct {
+	FromEmailAddress               string
+	Destination                    struct{ ToAddresses []string }
+	Content                        struct{ Raw struct{ Data string } }
+	FeedbackForwardingEmailAddress string
+	ConfigurationSetName           string
+}
+
+func TestSESTransport(t *testing.T) {
+	var requests []sesSendEmail
+	var headers []http.Header
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v2/email/outbound-emails" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		var request sesSendEmail
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests, headers = append(requests, request), append(headers, r.Header)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"MessageId":"0100018c"}`))
+	}))
+	defer server.Close()
+
+	newMail := func(transport *SESTransport) *Mail {
+		transport.Endpoint, transport.Client = server.URL, server.Client()
+		return newSandboxMail().SetSandbox(false).SetTransport(transport)
+	}
+
+	t.Run("raw message", func(t *testing.T) {
+		requests, headers = nil, nil
+		archiver := &recordingArchiver{}
+		m := newMail(&SESTransport{
+			Region:           "eu-west-1",
+			AccessKeyID:      "AKID",
+			SecretAccessKey:  "secret",
+			SessionToken:     "token",
+			ConfigurationSet: "tracking",
+		}).SetEnvelopeFrom("bounces@example.com").SetArchiver(archiver)
+
+		if err := m.Send(); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if len(requests) != 1 {
+			t.Fatalf("server received %d requests, want 1", len(requests))
+		}
+		got := requests[0]
+		if got.FromEmailAddress != "sender@example.com" || got.FeedbackForwardingEmailAddress != "bounces@example.com" || got.ConfigurationSetName != "tracking" {
+			t.Errorf("request = %+v", got)
+		}
+		if !jsonEqual(got.Destination.ToAddresses, []string{"recipient@example.com", "hidden@example.com"}) {
+			t.Errorf("ToAddresses = %v", got.Destination.ToAddresses)
+		}
+		raw, err := base64.StdEncoding.DecodeString(got.Content.Raw.Data)
+		if err != nil {
+			t.Fatalf("decoding the raw message: %v", err)
+		}
+		if !bytes.Contains(raw, []byte("Subject: Test Subject\r\n")) || bytes.Contains(raw, []byte("Bcc:")) {
+			t.Errorf("raw message = %s", raw)
+		}
+		if !bytes.Equal(archiver.message, raw) {
+			t.Error("archived message differs from the raw message sent")
+		}
+		if !strings.HasPrefix(headers[0].Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(headers[0].Get("Authorization"), "/eu-west-1/ses/aws4_request") ||
+			headers[0].Get("X-Amz-Security-Token") != "token" {
+			t.Errorf("request headers = %v", headers[0])
+		}
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		requests, headers = nil, nil
+		// Only the environment configures the SDK
+		t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/config")
+		t.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.TempDir()+"/credentials")
+		t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+		t.Setenv("AWS_REGION", "us-west-2")
+		t.Setenv("AWS_ACCESS_KEY_ID", "ENVKEY")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+		if err := newMail(&SESTransport{}).Send(); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if got := headers[0].Get("Authorization"); !strings.Contains(got, "Credential=ENVKEY/") || !strings.Contains(got, "/us-west-2/ses/") {
+			t.Errorf("Authorization = %s", got)
+		}
+
+		t.Setenv("AWS_ACCESS_KEY_ID", "")
+		if err := newMail(&SESTransport{}).Send(); err == nil {
+			t.Error("Send() without credentials should fail")
+		}
+	})
+
+	t.Run("credentials provider", func(t *testing.T) {
+		requests, headers = nil, nil
+		t.Setenv("AWS_ACCESS_KEY_ID", "ENVKEY")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+		var retrieved int
+		provider := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			retrieved++
+			return aws.Credentials{AccessKeyID: fmt.Sprintf("ROLE%d", retrieved), SecretAccessKey: "secret", SessionToken: "role-token"}, nil
+		})
+		m := newMail(&SESTransport{Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Credentials: provider})
+		for i := 0; i < 2; i++ {
+			if err := m.Send(); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+		}
+		// The provider takes precedence, and its credentials are cached
+		for i, header := range headers {
+			if got := header.Get("Authorization"); !strings.Contains(got, "Credential=ROLE1/") || header.Get("X-Amz-Security-Token") != "role-token" {
+				t.Errorf("request %d headers = %v", i, header)
+			}
+		}
+		if retrieved != 1 {
+			t.Errorf("credentials retrieved %d times, want 1", retrieved)
+		}
+
+		failing := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{}, errors.New("no EC2 IMDS role found")
+		})
+		err := newMail(&SESTransport{Region: "eu-west-1", Credentials: failing}).Send()
+		if err == nil || !strings.Contains(err.Error(), "no EC2 IMDS role found") {
+			t.Errorf("Send() error = %v, want the provider error", err)
+		}
+		if len(requests) != 2 {
+			t.Errorf("server received %d requests, want 2", len(requests))
+		}
+	})
+
+	t.Run("too many recipients", func(t *testing.T) {
+		m := newMail(&SESTransport{Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret"})
+		for i := range 50 {
+			m.Bcc = append(m.Bcc, fmt.Sprintf("r%d@example.com", i))
+		}
+		if err := m.Send(); err == nil || !strings.Contains(err.Error(), "exceed the limit") {
+			t.Errorf("Send() error = %v, want the recipient limit error", err)
+		}
+	})
+
+	t.Run("throttled", func(t *testing.T) {
+		status = http.StatusTooManyRequests
+		defer func() { status = http.StatusOK }()
+		err := newMail(&SESTransport{Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret"}).Send()
+		if !IsTemporary(err) {
+			t.Errorf("Send() error = %v, want a temporary error", err)
+		}
+	})
+}
+
+func TestGraphTransport(t *testing.T) {
+	var tokenRequests int
+	var messages []graphSendMail
+	revoked := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tenant-id/oauth2/v2.0/token":
+			r.ParseForm()
+			if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_id") != "client-id" ||
+				r.Form.Get("client_secret") != "secret" || r.Form.Get("scope") != "https://graph.microsoft.com/.default" {
+				t.Errorf("token request form = %v", r.Form)
+			}
+			tokenRequests++
+			json.NewEncoder(w).Encode(map[string]any{
+				"token_type":   "Bearer",
+				"expires_in":   3599,
+				"access_token": fmt.Sprintf("token-%d", tokenRequests),
+			})
+		case "/v1.0/users/sender@example.com/sendMail":
+			if r.Header.Get("Authorization") == "Bearer "+revoked {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var message graphSendMail
+			if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+				t.Errorf("decoding request: %v", err)
+			}
+			messages = append(messages, message)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	transport := &GraphTransport{
+		TenantID:        "tenant-id",
+		ClientID:        "client-id",
+		ClientSecret:    "secret",
+		SaveToSentItems: true,
+		Endpoint:        server.URL,
+		LoginEndpoint:   server.URL,
+		Client:          server.Client(),
+	}
+	newMail := func() *Mail {
+		return newSandboxMail().SetSandbox(false).SetTransport(transport)
+	}
+
+	m := newMail().SetInlineAttachment([]Attachment{{Name: "logo.png", ContentType: "image/png", Data: []byte("png")}})
+	report := m.SendWithReport(context.Background())
+	if report.Err != nil {
+		t.Fatalf("SendWithReport() error = %v", report.Err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	got := messages[0]
+	if !got.SaveToSentItems || got.Message.Subject != "Test Subject" || got.Message.InternetMessageID != "<"+report.MessageID+">" {
+		t.Errorf("message = %+v", got)
+	}
+	if got.Message.Body != (graphBody{ContentType: "HTML", Content: "Test Content"}) {
+		t.Errorf("body = %+v", got.Message.Body)
+	}
+	if got.Message.From.EmailAddress.Address != "sender@example.com" || got.Message.From.EmailAddress.Name != "Test Sender" {
+		t.Errorf("from = %+v", got.Message.From)
+	}
+	if !jsonEqual(got.Message.ToRecipients, graphRecipients("recipient@example.com")) ||
+		!jsonEqual(got.Message.BccRecipients, graphRecipients("hidden@example.com")) || got.Message.CcRecipients != nil {
+		t.Errorf("recipients = %+v, %+v, %+v", got.Message.ToRecipients, got.Message.CcRecipients, got.Message.BccRecipients)
+	}
+	wantAttachments := []graphAttachment{{
+		ODataType:    "#microsoft.graph.fileAttachment",
+		Name:         "logo.png",
+		ContentType:  "image/png",
+		ContentBytes: base64.StdEncoding.EncodeToString([]byte("png")),
+		IsInline:     true,
+		ContentID:    "logo.png",
+	}}
+	if !jsonEqual(got.Message.Attachments, wantAttachments) {
+		t.Errorf("attachments = %+v", got.Message.Attachments)
+	}
+
+	// The token is cached, and replaced when it is revoked
+	if err := newMail().RedirectAllTo("qa@example.com").Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("requested %d tokens, want 1", tokenRequests)
+	}
+	wantHeaders := []graphMessageHeader{{Name: "X-Original-To", Value: "recipient@example.com"}, {Name: "X-Original-Bcc", Value: "hidden@example.com"}}
+	if !jsonEqual(messages[1].Message.InternetMessageHeaders, wantHeaders) {
+		t.Errorf("headers = %+v", messages[1].Message.InternetMessageHeaders)
+	}
+	revoked = "token-1"
+	if err := newMail().Send(); err != nil {
+		t.Fatalf("Send() with a revoked token error = %v", err)
+	}
+	if tokenRequests != 2 || len(messages) != 3 {
+		t.Errorf("requested %d tokens and sent %d messages, want 2 and 3", tokenRequests, len(messages))
+	}
+}
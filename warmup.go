@@ -0,0 +1,92 @@
+package gomail
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WarmUpLimitError reports an email refused because the warm-up volume of the day is used up
+type WarmUpLimitError struct {
+	// Day is the day of the warm-up, starting at 1
+	Day   int
+	Limit int
+	// Sent is the number of recipients already sent to on Day
+	Sent int
+	// Resume is when the next day of the warm-up starts
+	Resume time.Time
+}
+
+// Error implements error
+func (e *WarmUpLimitError) Error() string {
+	return fmt.Sprintf("warm-up day %d limit of %d recipients reached (%d sent), resume at %s",
+		e.Day, e.Limit, e.Sent, e.Resume.Format(time.RFC3339))
+}
+
+// SetWarmUp limits and spreads the volume sent with the email, e.g. on a sender
+// profile, while its domain or IP address is warmed up
+func (m *Mail) SetWarmUp(warmUp *WarmUp) *Mail {
+	m.warmUp = warmUp
+	return m
+}
+
+// reserve accounts for a send to recipients recipients at the current time of
+// clock, and returns the function giving them back when the email is not sent.
+// It returns a *WarmUpLimitError when the volume of the day would be exceeded
+// and, when spreading, waits for the send's turn under ctx.
+func (w *WarmUp) reserve(ctx context.Context, clock Clock, recipients int) (release func(), err error) {
+	if w == nil {
+		return func() {}, nil
+	}
+
+	w.mu.Lock()
+	now := clock.Now()
+	day := max(int(now.Sub(w.Start)/(24*time.Hour)), 0)
+	if day >= len(w.DailyLimits) {
+		w.mu.Unlock()
+		return func() {}, nil
+	}
+	if day != w.day {
+		w.day, w.sent = day, 0
+	}
+	limit := w.DailyLimits[day]
+	if w.sent+recipients > limit {
+		err := &WarmUpLimitError{Day: day + 1, Limit: limit, Sent: w.sent, Resume: w.Start.Add(time.Duration(day+1) * 24 * time.Hour)}
+		w.mu.Unlock()
+		return nil, err
+	}
+	w.sent += recipients
+
+	// Each recipient takes its share of the day
+	var wait time.Duration
+	if w.Spread {
+		turn := w.next
+		if turn.Before(now) {
+			turn = now
+		}
+		w.next = turn.Add(time.Duration(recipients) * 24 * time.Hour / time.Duration(limit))
+		wait = turn.Sub(now)
+	}
+	w.mu.Unlock()
+
+	release = func() { w.release(day, recipients) }
+	if wait <= 0 {
+		return release, nil
+	}
+	select {
+	case <-clock.After(wait):
+		return release, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// release gives back the volume reserved for a send that did not happen on day
+func (w *WarmUp) release(day, recipients int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.day == day {
+		w.sent -= recipients
+	}
+}
@@ -0,0 +1,142 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWarmUp(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	warmUp := &WarmUp{Start: start, DailyLimits: []int{2, 5}}
+
+	steps := []struct {
+		name       string
+		advance    time.Duration
+		recipients int
+		wantErr    bool
+	}{
+		{name: "day 1", recipients: 1},
+		{name: "day 1 limit", recipients: 1},
+		{name: "day 1 exceeded", recipients: 1, wantErr: true},
+		{name: "day 2", advance: 24 * time.Hour, recipients: 4},
+		{name: "day 2 exceeded", recipients: 2, wantErr: true},
+		{name: "day 2 remaining", recipients: 1},
+		{name: "after the warm-up", advance: 24 * time.Hour, recipients: 1000},
+	}
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		_, err := warmUp.reserve(context.Background(), clock, step.recipients)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: reserve() error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+	}
+
+	clock = newFakeClock()
+	_, err := (&WarmUp{Start: clock.Now().Add(-time.Hour), DailyLimits: []int{0}}).reserve(context.Background(), clock, 1)
+	want := &WarmUpLimitError{Day: 1, Limit: 0, Sent: 0, Resume: clock.Now().Add(23 * time.Hour)}
+	var limitErr *WarmUpLimitError
+	if !errors.As(err, &limitErr) || *limitErr != *want {
+		t.Errorf("reserve() error = %v, want %v", err, want)
+	}
+}
+
+func TestWarmUpSpread(t *testing.T) {
+	clock := newFakeClock()
+	// 4 recipients a day: one every 6 hours
+	warmUp := &WarmUp{Start: clock.Now(), DailyLimits: []int{4}, Spread: true}
+
+	if _, err := warmUp.reserve(context.Background(), clock, 1); err != nil {
+		t.Fatalf("reserve() error = %v", err)
+	}
+	done := make(chan error, 1)
+	reserve := func(ctx context.Context, recipients int) {
+		_, err := warmUp.reserve(ctx, clock, recipients)
+		done <- err
+	}
+	go reserve(context.Background(), 1)
+	waitPending(t, clock, 1)
+	clock.Advance(6*time.Hour - time.Minute)
+	select {
+	case <-done:
+		t.Fatal("the second send did not wait for its turn")
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatalf("reserve() error = %v", err)
+	}
+
+	// A cancelled wait gives its volume back
+	ctx, cancel := context.WithCancel(context.Background())
+	go reserve(ctx, 2)
+	waitPending(t, clock, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("reserve() error = %v, want context.Canceled", err)
+	}
+	if warmUp.sent != 2 {
+		t.Errorf("sent = %d after a cancelled send, want 2", warmUp.sent)
+	}
+}
+
+func TestWarmUpFailedSend(t *testing.T) {
+	failed := errors.New("550 5.7.1 rejected")
+	var sendErr error
+	m := (&Mail{}).SetFrom("news@example.com").SetName("Newsletter").
+		SetHost("smtp.invalid").SetPort("587").SetUser("news").SetPass("secret").
+		SetSubject("News").SetContent("Content").SetTo("a@example.com", "b@example.com").
+		SetWarmUp(&WarmUp{Start: time.Now(), DailyLimits: []int{2}}).
+		SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error {
+			return sendErr
+		}))
+
+	// A failed send gives its volume back, so the email can be sent again
+	sendErr = failed
+	if err := m.Send(); !errors.Is(err, failed) {
+		t.Fatalf("Send() error = %v, want %v", err, failed)
+	}
+	sendErr = nil
+	if err := m.Send(); err != nil {
+		t.Fatalf("Send() after a failed send error = %v", err)
+	}
+	var limitErr *WarmUpLimitError
+	if err := m.Send(); !errors.As(err, &limitErr) {
+		t.Fatalf("Send() error = %v, want a *WarmUpLimitError", err)
+	}
+}
+
+func TestWarmUpProfile(t *testing.T) {
+	var delivered int
+	config := (&Mail{}).SetFrom("news@example.com").SetName("Newsletter").
+		SetHost("smtp.invalid").SetPort("587").SetUser("news").SetPass("secret").
+		SetWarmUp(&WarmUp{Start: time.Now(), DailyLimits: []int{3}}).
+		SetTransport(TransportFunc(func(ctx context.Context, m *Mail) error {
+			delivered += len(m.recipients())
+			return nil
+		}))
+	profiles := NewProfiles()
+	if err := profiles.Register("marketing", config); err != nil {
+		t.Fatal(err)
+	}
+
+	newMsg := func(to ...string) *Mail {
+		return (&Mail{}).SetSubject("News").SetContent("Content").SetTo(to...)
+	}
+	if err := profiles.Send("marketing", newMsg("a@example.com", "b@example.com")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	err := profiles.Send("marketing", newMsg("c@example.com", "d@example.com"))
+	var limitErr *WarmUpLimitError
+	if !errors.As(err, &limitErr) || limitErr.Sent != 2 {
+		t.Fatalf("Send() error = %v, want a *WarmUpLimitError", err)
+	}
+	if err := profiles.Send("marketing", newMsg("c@example.com")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if delivered != 3 {
+		t.Errorf("delivered to %d recipients, want 3", delivered)
+	}
+}